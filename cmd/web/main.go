@@ -3,22 +3,32 @@ package main
 import (
 	"context"
 	"encoding/gob"
+	"fmt"
 	"html/template"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 
 	"github.com/gin-gonic/gin"
 	_"github.com/joho/godotenv"
 
+	"github.com/yusuf/track-space/pkg/blob"
+	"github.com/yusuf/track-space/pkg/collab"
 	"github.com/yusuf/track-space/pkg/config"
 	"github.com/yusuf/track-space/pkg/controller"
+	"github.com/yusuf/track-space/pkg/data/tsRepoStore"
 	"github.com/yusuf/track-space/pkg/driver"
 	"github.com/yusuf/track-space/pkg/model"
 	"github.com/yusuf/track-space/pkg/ws"
 	"github.com/yusuf/track-space/pkg/wsconfig"
 	"github.com/yusuf/track-space/pkg/wsmodel"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 var (
@@ -70,9 +80,6 @@ func main() {
 	// Listening to the localhost mail server
 	go ListenToMailChannel(mailPass)
 
-	// Listening to PayLoad from the websocket
-	go ws.GetDataFromChannel()
-
 	// connecting to the database
 	Client := db.DatabaseConnection(mongodbURI)
 
@@ -83,8 +90,35 @@ func main() {
 		}
 	}()
 
+	// attachments of projects are kept on disk unless BLOB_STORE asks for GridFS
+	blobStore, err := attachmentStore(Client)
+	if err != nil {
+		log.Fatalln("cannot open the attachment store : ", err)
+	}
+	app.BlobStore = blobStore
+	app.AttachmentQuota = attachmentQuota()
+
+	// projects edited together are written back to the database every 10 seconds
+	app.Collab = collab.NewHub(10 * time.Second)
+
 	repo := controller.NewTrackSpace(&app, Client)
 
+	// Serving the clients of the chat rooms, their messages are kept in the database
+	app.Chat = ws.NewHub(tsRepoStore.NewTsMongoDBRepo(&app, Client), repo)
+	// the instances behind a load balancer share their chat through CHAT_BACKPLANE
+	backplane, err := chatBackplane(Client)
+	if err != nil {
+		log.Fatalln("cannot open the chat backplane : ", err)
+	}
+	app.Chat.UseBackplane(backplane)
+	go app.Chat.Run()
+	// browsers may only open the chat from CHAT_ALLOWED_ORIGINS, or from the same host when unset
+	ws.UpgradeSocketConn.CheckOrigin = ws.CheckOrigin(ws.ParseOrigins(os.Getenv("CHAT_ALLOWED_ORIGINS")))
+
+	log.Println("Application starting todo reminder scheduler")
+	// Scanning upcoming todos for reminder and agenda mails
+	go ListenForTodoReminder(tsRepoStore.NewTsMongoDBRepo(&app, Client), app.MailChan, time.Minute)
+
 	gin.SetMode(gin.ReleaseMode)
 	appRouter := gin.New()
 	proxyErr := appRouter.SetTrustedProxies([]string{"127.0.0.1"})
@@ -101,8 +135,71 @@ func main() {
 
 	Routes(appRouter, *repo)
 
-	err := appRouter.Run(portNumber)
-	if err != nil {
-		log.Fatal(err)
+	srv := &http.Server{Addr: portNumber, Handler: appRouter}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	// Shutting down on interrupt: no new request is accepted, the chat clients get a close frame
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	<-quit
+	log.Println("Application shutting down")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Println("cannot shut the server down gracefully : ", err)
+	}
+	// the websocket connections are hijacked, the server does not wait for them
+	app.Chat.Stop()
+}
+
+/*
+attachmentStore : this returns the blob store picked by BLOB_STORE, "local" (the
+default) writes under BLOB_DIR and "gridfs" uses the attachments bucket
+*/
+func attachmentStore(client *mongo.Client) (blob.Store, error) {
+	switch os.Getenv("BLOB_STORE") {
+	case "", "local":
+		dir := os.Getenv("BLOB_DIR")
+		if dir == "" {
+			dir = "./uploads"
+		}
+		return blob.NewLocalStore(dir)
+	case "gridfs":
+		return blob.NewGridFSStore(client.Database("track_space"), "attachments")
+	default:
+		return nil, fmt.Errorf("unknown BLOB_STORE %q", os.Getenv("BLOB_STORE"))
+	}
+}
+
+// chatEventsSize : the bytes of the capped collection of the chat events, the oldest are dropped past it
+const chatEventsSize = 16 << 20
+
+/*
+chatBackplane : this returns the chat backplane picked by CHAT_BACKPLANE, "local" (the
+default) for a single instance and "mongo" to tail the chat_events capped collection
+shared by every instance
+*/
+func chatBackplane(client *mongo.Client) (ws.Backplane, error) {
+	switch os.Getenv("CHAT_BACKPLANE") {
+	case "", "local":
+		return ws.NewLocalBackplane(), nil
+	case "mongo":
+		return ws.NewMongoBackplane(client.Database("track_space"), "chat_events", chatEventsSize)
+	default:
+		return nil, fmt.Errorf("unknown CHAT_BACKPLANE %q", os.Getenv("CHAT_BACKPLANE"))
+	}
+}
+
+// attachmentQuota : this returns the bytes of attachments a user may store, ATTACHMENT_QUOTA_MB or 100 MB
+func attachmentQuota() int64 {
+	quota, err := strconv.ParseInt(os.Getenv("ATTACHMENT_QUOTA_MB"), 10, 64)
+	if err != nil || quota <= 0 {
+		quota = 100
 	}
+	return quota << 20
 }
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/yusuf/track-space/pkg/data"
+	"github.com/yusuf/track-space/pkg/model"
+	"github.com/yusuf/track-space/pkg/recur"
+)
+
+// agendaHour : hour of the day (server local time) from which the daily agenda is sent
+const agendaHour = 7
+
+// reminderUser : the part of a user document the reminder scheduler needs
+type reminderUser struct {
+	ID           string       `bson:"_id"`
+	Email        string       `bson:"email"`
+	FirstName    string       `bson:"first_name"`
+	ReminderLead int          `bson:"reminder_lead"`
+	DailyAgenda  bool         `bson:"daily_agenda"`
+	AgendaSentOn string       `bson:"agenda_sent_on"`
+	Todo         []model.Todo `bson:"todo"`
+}
+
+/*
+ListenForTodoReminder : goroutine that scans the upcoming todos of the users with
+reminders turned on at each tick and enqueues reminder mails at the lead time set by
+the user, as well as the daily agenda mail, onto the mail channel
+*/
+func ListenForTodoReminder(repo data.TrackSpaceDBRepo, mailChan chan<- model.Email, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		scanTodoReminder(repo, mailChan, time.Now())
+		<-ticker.C
+	}
+}
+
+func scanTodoReminder(repo data.TrackSpaceDBRepo, mailChan chan<- model.Email, now time.Time) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Println("Recovery from a failed reminder scan :", r)
+		}
+	}()
+
+	documents, err := repo.GetReminderUsers()
+	if err != nil {
+		log.Printf("cannot get user data for reminders : %v", err)
+		return
+	}
+	for _, document := range documents {
+		user, err := decodeReminderUser(document)
+		if err != nil || user.Email == "" {
+			continue
+		}
+
+		if user.ReminderLead > 0 {
+			lead := time.Duration(user.ReminderLead) * time.Minute
+			for _, due := range dueReminders(user.Todo, lead, now) {
+				mailChan <- reminderMail(user, due)
+				// the occurrences before today have started already, their dates are not needed anymore
+				if err := repo.MarkTodoReminded(due.TodoID, due.Date, now.Format("2006-01-02")); err != nil {
+					log.Printf("cannot record reminder for todo %s : %v", due.TodoID, err)
+				}
+			}
+		}
+
+		today := now.Format("2006-01-02")
+		if user.DailyAgenda && user.AgendaSentOn != today && now.Hour() >= agendaHour {
+			agenda := dailyAgenda(user.Todo, now)
+			if len(agenda) > 0 {
+				mailChan <- agendaMail(user, today, agenda)
+			}
+			if err := repo.MarkAgendaSent(user.ID, today); err != nil {
+				log.Printf("cannot record agenda for user %s : %v", user.ID, err)
+			}
+		}
+	}
+}
+
+func decodeReminderUser(document primitive.M) (reminderUser, error) {
+	var user reminderUser
+	raw, err := bson.Marshal(document)
+	if err != nil {
+		return user, err
+	}
+	err = bson.Unmarshal(raw, &user)
+	return user, err
+}
+
+/*
+dueReminders : this returns the pending occurrences starting between now and now+lead
+that no reminder has been sent for yet
+*/
+func dueReminders(todos []model.Todo, lead time.Duration, now time.Time) []recur.Instance {
+	var due []recur.Instance
+	for _, todo := range todos {
+		if todo.StartTime == "" || (todo.Recurrence == "" && todo.Status == recur.StatusDone) {
+			continue
+		}
+		instances, err := recur.Occurrences(todo, now, now.Add(lead))
+		if err != nil {
+			continue
+		}
+		for _, instance := range instances {
+			if instance.Status == recur.StatusDone || instance.Status == recur.StatusSkipped {
+				continue
+			}
+			if contains(todo.Reminded, instance.Date) {
+				continue
+			}
+			due = append(due, instance)
+		}
+	}
+	return due
+}
+
+// dailyAgenda : this returns the pending occurrences of the day of now
+func dailyAgenda(todos []model.Todo, now time.Time) []recur.Instance {
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	to := from.Add(24*time.Hour - time.Second)
+
+	var agenda []recur.Instance
+	for _, todo := range todos {
+		instances, err := recur.Occurrences(todo, from, to)
+		if err != nil {
+			continue
+		}
+		for _, instance := range instances {
+			if instance.Status == recur.StatusDone || instance.Status == recur.StatusSkipped {
+				continue
+			}
+			agenda = append(agenda, instance)
+		}
+	}
+	sort.SliceStable(agenda, func(i, j int) bool {
+		return agenda[i].StartTime < agenda[j].StartTime
+	})
+	return agenda
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func reminderMail(user reminderUser, due recur.Instance) model.Email {
+	message := fmt.Sprintf(`
+			<strong>Upcoming Task Reminder</strong><br>
+			Hi, %s:<br>
+			<p>This is a reminder that <strong>%s</strong> is scheduled
+			for %s from %s to %s.
+			</p>
+			`, html.EscapeString(user.FirstName), html.EscapeString(due.Task), due.Date, due.StartTime, due.EndTime)
+	return model.Email{
+		Subject:  fmt.Sprintf("Reminder : %s at %s", due.Task, due.StartTime),
+		Content:  message,
+		Sender:   "official.trackspace@gmail.com",
+		Receiver: user.Email,
+		Template: "email.html",
+	}
+}
+
+func agendaMail(user reminderUser, today string, agenda []recur.Instance) model.Email {
+	var items strings.Builder
+	for _, instance := range agenda {
+		if instance.StartTime == "" {
+			items.WriteString(fmt.Sprintf("<li>%s</li>", html.EscapeString(instance.Task)))
+			continue
+		}
+		items.WriteString(fmt.Sprintf("<li>%s - %s : %s</li>", instance.StartTime, instance.EndTime, html.EscapeString(instance.Task)))
+	}
+	message := fmt.Sprintf(`
+			<strong>Your Agenda for %s</strong><br>
+			Hi, %s:<br>
+			<p>Here are your scheduled plans for today</p>
+			<ul>%s</ul>
+			`, today, html.EscapeString(user.FirstName), items.String())
+	return model.Email{
+		Subject:  fmt.Sprintf("Your agenda for %s", today),
+		Content:  message,
+		Sender:   "official.trackspace@gmail.com",
+		Receiver: user.Email,
+		Template: "email.html",
+	}
+}
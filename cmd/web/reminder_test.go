@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yusuf/track-space/pkg/model"
+)
+
+func TestDueReminders(t *testing.T) {
+	now := time.Date(2026, 10, 19, 8, 50, 0, 0, time.Local)
+	todos := []model.Todo{
+		{ID: "due", ToDoTask: "stand-up", DateSchedule: "2026-10-19", StartTime: "09:00", EndTime: "09:15", Status: "Not done"},
+		{ID: "later", ToDoTask: "lunch", DateSchedule: "2026-10-19", StartTime: "12:00", EndTime: "13:00", Status: "Not done"},
+		{ID: "done", ToDoTask: "review", DateSchedule: "2026-10-19", StartTime: "09:00", EndTime: "10:00", Status: "Done"},
+		{ID: "sent", ToDoTask: "sync", DateSchedule: "2026-10-12", StartTime: "09:00", EndTime: "09:30", Status: "Not done",
+			Recurrence: "FREQ=WEEKLY", Reminded: []string{"2026-10-19"}},
+		{ID: "series", ToDoTask: "check-in", DateSchedule: "2026-10-01", StartTime: "08:55", EndTime: "09:00", Status: "Not done",
+			Recurrence: "FREQ=DAILY"},
+	}
+
+	got := dueReminders(todos, 15*time.Minute, now)
+	want := map[string]bool{"due": true, "series": true}
+	if len(got) != len(want) {
+		t.Fatalf("dueReminders() = %+v, want todos %v", got, want)
+	}
+	for _, instance := range got {
+		if !want[instance.TodoID] || instance.Date != "2026-10-19" {
+			t.Errorf("dueReminders() returned unexpected %+v", instance)
+		}
+	}
+}
+
+func TestDailyAgenda(t *testing.T) {
+	now := time.Date(2026, 10, 19, 7, 0, 0, 0, time.Local)
+	todos := []model.Todo{
+		{ID: "b", ToDoTask: "lunch", DateSchedule: "2026-10-19", StartTime: "12:00", EndTime: "13:00", Status: "Not done"},
+		{ID: "a", ToDoTask: "stand-up", DateSchedule: "2026-10-19", StartTime: "09:00", EndTime: "09:15", Status: "Not done"},
+		{ID: "c", ToDoTask: "tomorrow", DateSchedule: "2026-10-20", StartTime: "09:00", EndTime: "09:15", Status: "Not done"},
+	}
+
+	got := dailyAgenda(todos, now)
+	if len(got) != 2 || got[0].TodoID != "a" || got[1].TodoID != "b" {
+		t.Errorf("dailyAgenda() = %+v", got)
+	}
+}
@@ -31,12 +31,20 @@ func Routes(routes *gin.Engine, h controller.TrackSpace) {
 
 	//router.GET("/user/log-out", h.ExecuteLogOut())
 
+	// Secret calendar feed for calendar apps, authorized by the token in the url
+	router.GET("/calendar/:token", h.CalendarFeed())
+
+	// Read-only public link of a project, authorized by the token in the url
+	router.GET("/p/:token", h.PublicProject())
+
 	authRouter := routes.Group("/auth")
 
 	authRouter.Use(IsAuthorized())
 	{
 		// authRouter.Handle(http.MethodConnect, "/workspace", h.ProcessWorkSpace())
 		authRouter.GET("/user/dashboard", h.GetDashBoard())
+		authRouter.GET("/user/dashboard/stats", h.DashboardStats())
+		authRouter.GET("/user/live", h.LiveUpdates())
 
 		authRouter.GET("/user/workspace", h.ProjectWorkspace())
 		authRouter.POST("/user/workspace", h.PostWorkSpaceProject())
@@ -44,8 +52,25 @@ func Routes(routes *gin.Engine, h controller.TrackSpace) {
 		authRouter.GET("/user/project-table", h.ShowProjectTable())
 		authRouter.GET("/user/:src/:id/show-project", h.ShowUserProject())
 		authRouter.POST("/user/project-table/:src/:id/change", h.ModifyUserProject())
+		authRouter.POST("/user/project-preview", h.PreviewProject())
+		authRouter.POST("/user/project-table/:src/:id/pin", h.PinProject())
+		authRouter.POST("/user/project-table/:src/:id/attachments", h.UploadAttachment())
+		authRouter.GET("/user/project-table/:src/:id/attachments/:file", h.DownloadAttachment())
+		authRouter.POST("/user/project-table/:src/:id/attachments/:file/delete", h.DeleteAttachment())
+		authRouter.GET("/user/project-table/:src/:id/collab", h.CollabProject())
+		authRouter.POST("/user/project-table/:src/:id/shares", h.ShareProject())
+		authRouter.POST("/user/project-table/:src/:id/shares/:user/delete", h.UnshareProject())
+		authRouter.POST("/user/project-table/:src/:id/public-link", h.CreatePublicLink())
+		authRouter.POST("/user/project-table/:src/:id/public-link/delete", h.RevokePublicLink())
+		authRouter.POST("/user/project-table/:src/:id/comments", h.AddProjectComment())
+		authRouter.POST("/user/project-table/:src/:id/comments/:comment/change", h.ModifyProjectComment())
+		authRouter.POST("/user/project-table/:src/:id/comments/:comment/delete", h.DeleteProjectComment())
+		authRouter.POST("/user/project-retag", h.RetagProjects())
 		authRouter.GET("/user/:src/:id/delete", h.DeleteProject())
 
+		authRouter.GET("/user/export", h.ExportWorkspace())
+		authRouter.GET("/user/import", h.ImportPage())
+		authRouter.POST("/user/import", h.ImportProjects())
 		authRouter.GET("/user/todo", h.GetTodo())
 		authRouter.POST("/user/todo", h.PostTodoData())
 
@@ -53,15 +78,58 @@ func Routes(routes *gin.Engine, h controller.TrackSpace) {
 		authRouter.GET("/user/:src/:id/show-todo", h.ShowTodoSchedule())
 		authRouter.POST("/user/todo-table/:src/:id/change", h.ModifyUserTodo())
 		authRouter.GET("/user/show-todo/:src/:id/delete", h.DeleteTodo())
+		authRouter.GET("/user/todo-occurrences", h.ShowTodoOccurrences())
+		authRouter.POST("/user/todo-table/:src/:id/occurrence", h.ModifyTodoOccurrence())
+		authRouter.POST("/user/todo-table/:src/:id/project", h.SetTodoProject())
+		authRouter.POST("/user/todo-table/:src/:id/checklist", h.AddChecklistItem())
+		authRouter.POST("/user/todo-table/:src/:id/checklist-order", h.ReorderChecklist())
+		authRouter.POST("/user/todo-table/:src/:id/checklist/:item/toggle", h.ToggleChecklistItem())
+		authRouter.POST("/user/todo-table/:src/:id/checklist/:item/delete", h.RemoveChecklistItem())
+		authRouter.POST("/user/todo-table/:src/:id/comments", h.AddTodoComment())
+		authRouter.POST("/user/todo-table/:src/:id/comments/:comment/change", h.ModifyTodoComment())
+		authRouter.POST("/user/todo-table/:src/:id/comments/:comment/delete", h.DeleteTodoComment())
+		authRouter.POST("/user/calendar", h.CreateCalendarFeed())
+		authRouter.POST("/user/calendar/import", h.ImportCalendar())
+		authRouter.POST("/user/reminder-settings", h.UpdateReminderSettings())
+
+		authRouter.GET("/user/todo-board", h.ShowTodoBoard())
+		authRouter.POST("/user/todo-board", h.UpdateTodoBoard())
+		authRouter.POST("/user/todo-board/:id/move", h.MoveTodoOnBoard())
+
+		authRouter.GET("/user/notifications", h.ShowNotifications())
+		authRouter.POST("/user/notifications/read", h.ReadNotifications())
+
+		authRouter.GET("/user/teams", h.ShowTeams())
+		authRouter.POST("/user/teams", h.CreateTeam())
+		authRouter.POST("/user/team-switch", h.SwitchTeam())
+		authRouter.GET("/user/teams/:team", h.ShowTeam())
+		authRouter.POST("/user/teams/:team/invites", h.InviteTeamMember())
+		authRouter.POST("/user/teams/:team/invites/:token/delete", h.RevokeTeamInvite())
+		authRouter.POST("/user/teams/:team/members/:member/role", h.SetTeamMemberRole())
+		authRouter.POST("/user/teams/:team/members/:member/delete", h.RemoveTeamMember())
+		authRouter.POST("/user/teams/:team/projects", h.CreateTeamProject())
+		authRouter.GET("/user/teams/:team/projects/:id", h.ShowTeamProject())
+		authRouter.POST("/user/teams/:team/projects/:id/change", h.ModifyTeamProject())
+		authRouter.POST("/user/teams/:team/projects/:id/delete", h.DeleteTeamProject())
+		authRouter.POST("/user/teams/:team/todos", h.CreateTeamTodo())
+		authRouter.POST("/user/teams/:team/todos/:id/status", h.SetTeamTodoStatus())
+		authRouter.POST("/user/teams/:team/todos/:id/delete", h.DeleteTeamTodo())
+		authRouter.GET("/user/team-invites/:token/:answer", h.TeamInvite())
+		authRouter.POST("/user/team-invites/:token/:answer", h.AnswerTeamInvite())
 
 		// Routes for websocket handlers
 		authRouter.GET("/user/chat", h.ChatRoom())
+		authRouter.GET("/user/chat/messages", h.ChatHistory())
+		authRouter.POST("/user/chat/rooms", h.CreateChatRoom())
+		authRouter.POST("/user/chat/rooms/:room/members", h.InviteChatRoomMember())
+		authRouter.POST("/user/chat/rooms/:room/members/:member/delete", h.RemoveChatRoomMember())
 		authRouter.GET("/ts", h.ChatRoomEndpoint())
 		authRouter.GET("/user/logout", h.ExecuteLogOut())
 
 		//Admin routes
 		authRouter.GET("/admin", h.AdminPage())
 		authRouter.GET("/:src/dashboard/:id/delete", h.AdminDeleteUser())
+		authRouter.POST("/admin/chat", h.ModerateChat())
 
 	}
 }
@@ -0,0 +1,59 @@
+package access
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/yusuf/track-space/pkg/model"
+)
+
+// roles a user can have on a project
+const (
+	RoleOwner  = "owner"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+var (
+	ErrNoAccess    = errors.New("project not found")
+	ErrInvalidRole = errors.New("a project is shared as viewer or editor")
+	ErrShareOwner  = errors.New("a project cannot be shared with its owner")
+)
+
+/*
+RoleOf : this returns the role of a user on a project owned by ownerID, the empty
+role when the project is not shared with the user
+*/
+func RoleOf(project model.Project, ownerID, userID string) string {
+	if userID == "" {
+		return ""
+	}
+	if userID == ownerID {
+		return RoleOwner
+	}
+	for _, share := range project.Shares {
+		if share.UserID == userID {
+			return share.Role
+		}
+	}
+	return ""
+}
+
+// CanView : this reports whether a role lets a user read a project
+func CanView(role string) bool {
+	return role == RoleOwner || role == RoleEditor || role == RoleViewer
+}
+
+// CanEdit : this reports whether a role lets a user change the content of a project
+func CanEdit(role string) bool {
+	return role == RoleOwner || role == RoleEditor
+}
+
+// ParseRole : this checks the role a project is shared with, as typed in a form
+func ParseRole(raw string) (string, error) {
+	switch role := strings.ToLower(strings.TrimSpace(raw)); role {
+	case RoleViewer, RoleEditor:
+		return role, nil
+	}
+	return "", ErrInvalidRole
+}
@@ -0,0 +1,57 @@
+package access
+
+import (
+	"testing"
+
+	"github.com/yusuf/track-space/pkg/model"
+)
+
+func TestRoleOf(t *testing.T) {
+	project := model.Project{Shares: []model.Share{
+		{UserID: "u2", Role: RoleEditor},
+		{UserID: "u3", Role: RoleViewer},
+	}}
+	tests := []struct {
+		name    string
+		userID  string
+		want    string
+		canView bool
+		canEdit bool
+	}{
+		{"owner", "u1", RoleOwner, true, true},
+		{"editor", "u2", RoleEditor, true, true},
+		{"viewer", "u3", RoleViewer, true, false},
+		{"stranger", "u4", "", false, false},
+		{"anonymous", "", "", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			role := RoleOf(project, "u1", tt.userID)
+			if role != tt.want {
+				t.Errorf("RoleOf() = %q, want %q", role, tt.want)
+			}
+			if CanView(role) != tt.canView || CanEdit(role) != tt.canEdit {
+				t.Errorf("CanView() = %v, CanEdit() = %v", CanView(role), CanEdit(role))
+			}
+		})
+	}
+}
+
+func TestParseRole(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    string
+		wantErr bool
+	}{
+		{"viewer", RoleViewer, false},
+		{" Editor ", RoleEditor, false},
+		{"owner", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRole(tt.raw)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseRole(%q) = %q, %v", tt.raw, got, err)
+		}
+	}
+}
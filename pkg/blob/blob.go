@@ -0,0 +1,70 @@
+package blob
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrNotFound      = errors.New("blob not found")
+	ErrInvalidKey    = errors.New("invalid blob key")
+	ErrQuotaExceeded = errors.New("storage quota exceeded")
+)
+
+var keyPattern = regexp.MustCompile(`^[a-f0-9]{32}$`)
+
+/*
+Store : the storage of the content of attachments, blobs are addressed by keys
+made with NewKey and never change once written
+*/
+type Store interface {
+	// Put : writes the content read from r under key and returns its size
+	Put(key string, r io.Reader) (int64, error)
+	// Open : returns the content stored under key, ErrNotFound when there is none
+	Open(key string) (io.ReadCloser, error)
+	// Delete : removes the content stored under key, deleting a missing blob is not an error
+	Delete(key string) error
+}
+
+// NewKey : this returns a random key for a new blob
+func NewKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// ValidKey : this reports whether key was made by NewKey, so it is safe to use as a file name
+func ValidKey(key string) bool {
+	return keyPattern.MatchString(key)
+}
+
+/*
+Sniff : this detects the content type of a file from its first 512 bytes, the type
+announced by the browser on upload is never trusted
+*/
+func Sniff(head []byte) string {
+	contentType := http.DetectContentType(head)
+	if i := strings.Index(contentType, ";"); i >= 0 && !strings.HasPrefix(contentType, "text/") {
+		contentType = contentType[:i]
+	}
+	return contentType
+}
+
+/*
+Inline : this reports whether a content type is safe to show in the browser. Any
+other attachment is downloaded, so uploaded html or scripts never run on the site
+*/
+func Inline(contentType string) bool {
+	switch contentType {
+	case "image/png", "image/jpeg", "image/gif", "image/webp", "image/bmp", "application/pdf":
+		return true
+	}
+	return strings.HasPrefix(contentType, "text/plain")
+}
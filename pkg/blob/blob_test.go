@@ -0,0 +1,71 @@
+package blob
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLocalStore(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := store.Put(key, strings.NewReader("hello"))
+	if err != nil || n != 5 {
+		t.Fatalf("Put() = %d, %v", n, err)
+	}
+	rc, err := store.Open(key)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	_ = rc.Close()
+	if string(data) != "hello" {
+		t.Errorf("Open() content = %q", data)
+	}
+
+	if err := store.Delete(key); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := store.Open(key); err != ErrNotFound {
+		t.Errorf("Open() after Delete() error = %v, want %v", err, ErrNotFound)
+	}
+	if err := store.Delete(key); err != nil {
+		t.Errorf("Delete() of a missing blob error = %v", err)
+	}
+	if _, err := store.Put("../../etc/passwd", strings.NewReader("x")); err != ErrInvalidKey {
+		t.Errorf("Put() with an unsafe key error = %v, want %v", err, ErrInvalidKey)
+	}
+}
+
+func TestSniff(t *testing.T) {
+	tests := []struct {
+		name   string
+		head   string
+		want   string
+		inline bool
+	}{
+		{"png", "\x89PNG\r\n\x1a\n", "image/png", true},
+		{"pdf", "%PDF-1.7", "application/pdf", true},
+		{"html", "<!DOCTYPE html><script>alert(1)</script>", "text/html; charset=utf-8", false},
+		{"zip", "PK\x03\x04", "application/zip", false},
+		{"text", "just some notes", "text/plain; charset=utf-8", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Sniff([]byte(tt.head))
+			if got != tt.want {
+				t.Errorf("Sniff() = %q, want %q", got, tt.want)
+			}
+			if Inline(got) != tt.inline {
+				t.Errorf("Inline(%q) = %v, want %v", got, !tt.inline, tt.inline)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package blob
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// gridFSTimeout : deadline of every GridFS operation
+const gridFSTimeout = 100 * time.Second
+
+// GridFSStore : stores blobs in a GridFS bucket of the database, the key is the id of the file
+type GridFSStore struct {
+	bucket *gridfs.Bucket
+}
+
+// NewGridFSStore : this returns a store writing to the named bucket of the database
+func NewGridFSStore(db *mongo.Database, bucketName string) (*GridFSStore, error) {
+	bucket, err := gridfs.NewBucket(db, options.GridFSBucket().SetName(bucketName))
+	if err != nil {
+		return nil, err
+	}
+	return &GridFSStore{bucket: bucket}, nil
+}
+
+// Put : this uploads the blob in chunks, GridFS only shows the file once it is complete
+func (s *GridFSStore) Put(key string, r io.Reader) (int64, error) {
+	if !ValidKey(key) {
+		return 0, ErrInvalidKey
+	}
+	if err := s.bucket.SetWriteDeadline(time.Now().Add(gridFSTimeout)); err != nil {
+		return 0, err
+	}
+	counter := &countingReader{r: r}
+	if err := s.bucket.UploadFromStreamWithID(key, key, counter); err != nil {
+		return 0, err
+	}
+	return counter.n, nil
+}
+
+// Open : this opens a download stream of the blob
+func (s *GridFSStore) Open(key string) (io.ReadCloser, error) {
+	if !ValidKey(key) {
+		return nil, ErrInvalidKey
+	}
+	if err := s.bucket.SetReadDeadline(time.Now().Add(gridFSTimeout)); err != nil {
+		return nil, err
+	}
+	stream, err := s.bucket.OpenDownloadStream(key)
+	if errors.Is(err, gridfs.ErrFileNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
+// Delete : this removes the file and its chunks from the bucket
+func (s *GridFSStore) Delete(key string) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+	if err := s.bucket.SetWriteDeadline(time.Now().Add(gridFSTimeout)); err != nil {
+		return err
+	}
+	err := s.bucket.Delete(key)
+	if errors.Is(err, gridfs.ErrFileNotFound) {
+		return nil
+	}
+	return err
+}
+
+// countingReader : counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
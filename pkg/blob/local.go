@@ -0,0 +1,79 @@
+package blob
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// LocalStore : stores blobs as files of a directory, two levels deep to keep directories small
+type LocalStore struct {
+	root string
+}
+
+// NewLocalStore : this returns a store writing under the root directory, creating it when missing
+func NewLocalStore(root string) (*LocalStore, error) {
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, err
+	}
+	return &LocalStore{root: root}, nil
+}
+
+func (s *LocalStore) path(key string) string {
+	return filepath.Join(s.root, key[:2], key)
+}
+
+/*
+Put : this writes the blob to a temporary file first and renames it once complete,
+so a failed upload never leaves a partial blob behind
+*/
+func (s *LocalStore) Put(key string, r io.Reader) (int64, error) {
+	if !ValidKey(key) {
+		return 0, ErrInvalidKey
+	}
+	dir := filepath.Dir(s.path(key))
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return 0, err
+	}
+	tmp, err := os.CreateTemp(dir, key+".*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := io.Copy(tmp, r)
+	if err != nil {
+		_ = tmp.Close()
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+	return n, os.Rename(tmp.Name(), s.path(key))
+}
+
+// Open : this opens the file of the blob
+func (s *LocalStore) Open(key string) (io.ReadCloser, error) {
+	if !ValidKey(key) {
+		return nil, ErrInvalidKey
+	}
+	f, err := os.Open(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+// Delete : this removes the file of the blob
+func (s *LocalStore) Delete(key string) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+	err := os.Remove(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
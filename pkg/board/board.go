@@ -0,0 +1,178 @@
+package board
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/yusuf/track-space/pkg/model"
+)
+
+// Key of the column that marks todos as done, every board must have it
+const DoneColumn = "done"
+
+var (
+	ErrTodoNotFound  = errors.New("todo not found on the board")
+	ErrUnknownColumn = errors.New("column does not exist on the board")
+	ErrWIPLimit      = errors.New("column has reached its work in progress limit")
+)
+
+var columnKey = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// DefaultColumns : the columns of a board the user has not configured yet
+func DefaultColumns() []model.BoardColumn {
+	return []model.BoardColumn{
+		{Key: "backlog", Title: "Backlog"},
+		{Key: "in-progress", Title: "In progress"},
+		{Key: DoneColumn, Title: "Done"},
+	}
+}
+
+// Columns : this returns the configured columns of a board or the default ones
+func Columns(configured []model.BoardColumn) []model.BoardColumn {
+	if len(configured) == 0 {
+		return DefaultColumns()
+	}
+	return configured
+}
+
+/*
+ValidateColumns : this checks a board configuration, column keys must be unique
+lowercase slugs, WIP limits cannot be negative (0 means no limit) and the done
+column must be present
+*/
+func ValidateColumns(columns []model.BoardColumn) error {
+	if len(columns) == 0 {
+		return errors.New("a board needs at least one column")
+	}
+	seen := make(map[string]bool)
+	for _, col := range columns {
+		if !columnKey.MatchString(col.Key) {
+			return fmt.Errorf("invalid column key %q", col.Key)
+		}
+		if seen[col.Key] {
+			return fmt.Errorf("duplicate column key %q", col.Key)
+		}
+		if strings.TrimSpace(col.Title) == "" {
+			return fmt.Errorf("column %q needs a title", col.Key)
+		}
+		if col.WIPLimit < 0 {
+			return fmt.Errorf("invalid work in progress limit for column %q", col.Key)
+		}
+		seen[col.Key] = true
+	}
+	if !seen[DoneColumn] {
+		return fmt.Errorf("the %q column cannot be removed", DoneColumn)
+	}
+	return nil
+}
+
+/*
+ColumnOf : this returns the column a todo is on. The status stays the source of
+truth for done todos, so a todo marked done elsewhere shows on the done column.
+Todos that were never placed on the board, or whose column was removed, go to
+the first column
+*/
+func ColumnOf(todo model.Todo, columns []model.BoardColumn) string {
+	if todo.Status == "Done" {
+		return DoneColumn
+	}
+	for _, col := range columns {
+		if col.Key == todo.Column && col.Key != DoneColumn {
+			return col.Key
+		}
+	}
+	return columns[0].Key
+}
+
+// Arrange : this groups the todos by column, each column ordered by position
+func Arrange(todos []model.Todo, columns []model.BoardColumn) map[string][]model.Todo {
+	arranged := make(map[string][]model.Todo)
+	for _, todo := range todos {
+		key := ColumnOf(todo, columns)
+		todo.Column = key
+		arranged[key] = append(arranged[key], todo)
+	}
+	for key := range arranged {
+		list := arranged[key]
+		sort.SliceStable(list, func(i, j int) bool {
+			return list[i].Position < list[j].Position
+		})
+	}
+	return arranged
+}
+
+/*
+Move : this moves a todo to the given position (0 based) of a column and returns
+every todo whose column, position or status changed, with its new values. Moving
+into the done column marks the todo as done, moving out of it as not done
+*/
+func Move(todos []model.Todo, columns []model.BoardColumn, todoID, column string, position int) ([]model.Todo, error) {
+	var target *model.BoardColumn
+	for i := range columns {
+		if columns[i].Key == column {
+			target = &columns[i]
+		}
+	}
+	if target == nil {
+		return nil, ErrUnknownColumn
+	}
+
+	arranged := Arrange(todos, columns)
+	var moving model.Todo
+	found := false
+	for key, list := range arranged {
+		for i, todo := range list {
+			if todo.ID == todoID {
+				moving = todo
+				found = true
+				arranged[key] = append(list[:i:i], list[i+1:]...)
+				break
+			}
+		}
+	}
+	if !found {
+		return nil, ErrTodoNotFound
+	}
+	source := moving.Column
+
+	dest := arranged[column]
+	if source != column && target.WIPLimit > 0 && len(dest) >= target.WIPLimit {
+		return nil, ErrWIPLimit
+	}
+	if position < 0 || position > len(dest) {
+		position = len(dest)
+	}
+	dest = append(dest[:position:position], append([]model.Todo{moving}, dest[position:]...)...)
+	arranged[column] = dest
+
+	previous := make(map[string]model.Todo)
+	for _, todo := range todos {
+		previous[todo.ID] = todo
+	}
+
+	var changed []model.Todo
+	for _, key := range []string{source, column} {
+		for i, todo := range arranged[key] {
+			todo.Position = i
+			todo.Column = key
+			if todo.ID == todoID {
+				if key == DoneColumn {
+					todo.Status = "Done"
+				} else if source == DoneColumn {
+					todo.Status = "Not done"
+				}
+			}
+			old := previous[todo.ID]
+			if old.Column != todo.Column || old.Position != todo.Position || old.Status != todo.Status {
+				changed = append(changed, todo)
+			}
+		}
+		if source == column {
+			break
+		}
+	}
+	return changed, nil
+}
@@ -0,0 +1,100 @@
+package board
+
+import (
+	"testing"
+
+	"github.com/yusuf/track-space/pkg/model"
+)
+
+func boardTodos() []model.Todo {
+	return []model.Todo{
+		{ID: "a", Status: "Not done", Column: "backlog", Position: 0},
+		{ID: "b", Status: "Not done", Column: "backlog", Position: 1},
+		{ID: "c", Status: "Not done", Column: "in-progress", Position: 0},
+		{ID: "d", Status: "Done"},
+	}
+}
+
+func TestArrange(t *testing.T) {
+	arranged := Arrange(boardTodos(), DefaultColumns())
+	if len(arranged["backlog"]) != 2 || arranged["backlog"][0].ID != "a" {
+		t.Errorf("Arrange() backlog = %+v", arranged["backlog"])
+	}
+	if len(arranged[DoneColumn]) != 1 || arranged[DoneColumn][0].ID != "d" {
+		t.Errorf("Arrange() done = %+v", arranged[DoneColumn])
+	}
+}
+
+func TestMove(t *testing.T) {
+	tests := []struct {
+		name     string
+		columns  []model.BoardColumn
+		todoID   string
+		column   string
+		position int
+		want     map[string]model.Todo
+		wantErr  error
+	}{
+		{
+			name: "reorder", columns: DefaultColumns(), todoID: "b", column: "backlog", position: 0,
+			want: map[string]model.Todo{
+				"b": {Column: "backlog", Position: 0, Status: "Not done"},
+				"a": {Column: "backlog", Position: 1, Status: "Not done"},
+			},
+		},
+		{
+			name: "to-done", columns: DefaultColumns(), todoID: "c", column: DoneColumn, position: 0,
+			want: map[string]model.Todo{
+				"c": {Column: DoneColumn, Position: 0, Status: "Done"},
+				"d": {Column: DoneColumn, Position: 1, Status: "Done"},
+			},
+		},
+		{
+			name: "wip-limit", todoID: "a", column: "in-progress", position: 0, wantErr: ErrWIPLimit,
+			columns: []model.BoardColumn{
+				{Key: "backlog", Title: "Backlog"},
+				{Key: "in-progress", Title: "In progress", WIPLimit: 1},
+				{Key: DoneColumn, Title: "Done"},
+			},
+		},
+		{name: "unknown-column", columns: DefaultColumns(), todoID: "a", column: "nope", wantErr: ErrUnknownColumn},
+		{name: "unknown-todo", columns: DefaultColumns(), todoID: "z", column: "backlog", wantErr: ErrTodoNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed, err := Move(boardTodos(), tt.columns, tt.todoID, tt.column, tt.position)
+			if err != tt.wantErr {
+				t.Fatalf("Move() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(changed) != len(tt.want) {
+				t.Fatalf("Move() changed = %+v, want %+v", changed, tt.want)
+			}
+			for _, todo := range changed {
+				want, ok := tt.want[todo.ID]
+				if !ok || todo.Column != want.Column || todo.Position != want.Position || todo.Status != want.Status {
+					t.Errorf("Move() changed %+v, want %+v", todo, want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateColumns(t *testing.T) {
+	tests := []struct {
+		name    string
+		columns []model.BoardColumn
+		wantErr bool
+	}{
+		{"default", DefaultColumns(), false},
+		{"no-done", []model.BoardColumn{{Key: "backlog", Title: "Backlog"}}, true},
+		{"bad-key", []model.BoardColumn{{Key: "Bad Key", Title: "x"}, {Key: DoneColumn, Title: "Done"}}, true},
+		{"duplicate", []model.BoardColumn{{Key: DoneColumn, Title: "Done"}, {Key: DoneColumn, Title: "Done"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateColumns(tt.columns); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateColumns() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
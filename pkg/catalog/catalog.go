@@ -0,0 +1,154 @@
+package catalog
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/yusuf/track-space/pkg/model"
+)
+
+const (
+	// MaxTags : number of tags a project can carry
+	MaxTags = 20
+	// MaxTagLength : number of characters of a tag
+	MaxTagLength = 32
+	// MaxFolderDepth : number of nested levels of a folder path
+	MaxFolderDepth = 5
+	// cloudLevels : number of font sizes of the tag cloud
+	cloudLevels = 5
+)
+
+var (
+	tagPattern     = regexp.MustCompile(fmt.Sprintf(`^[\p{L}\p{N}][\p{L}\p{N}_.+#-]{0,%d}$`, MaxTagLength-1))
+	segmentPattern = regexp.MustCompile(`^[\p{L}\p{N}][\p{L}\p{N} _.-]{0,39}$`)
+
+	ErrTooManyTags       = fmt.Errorf("a project can have at most %d tags", MaxTags)
+	ErrFolderDepth       = fmt.Errorf("folders can be nested at most %d levels deep", MaxFolderDepth)
+	ErrNoProjectSelected = errors.New("select at least one project")
+)
+
+// TagCount : a tag of the tag cloud with the number of projects carrying it
+type TagCount struct {
+	Tag   string
+	Count int
+	// Level : relative weight from 1 to 5 used to size the tag in the cloud
+	Level int
+}
+
+/*
+ParseTags : this turns a comma separated list of tags into lower case tags without
+duplicates, keeping the order they were typed in
+*/
+func ParseTags(raw string) ([]string, error) {
+	return NormalizeTags(strings.Split(raw, ","))
+}
+
+// NormalizeTags : this trims, lower cases, validates and de-duplicates tags
+func NormalizeTags(raw []string) ([]string, error) {
+	tags := []string{}
+	seen := make(map[string]bool)
+	for _, tag := range raw {
+		tag = strings.ToLower(strings.Join(strings.Fields(tag), "-"))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if !tagPattern.MatchString(tag) {
+			return nil, fmt.Errorf("invalid tag %q", tag)
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	if len(tags) > MaxTags {
+		return nil, ErrTooManyTags
+	}
+	return tags, nil
+}
+
+/*
+NormalizeFolder : this cleans a folder path such as "work / client a/" into
+"work/client a". The empty path is the root folder
+*/
+func NormalizeFolder(path string) (string, error) {
+	var segments []string
+	for _, segment := range strings.Split(path, "/") {
+		segment = strings.Join(strings.Fields(segment), " ")
+		if segment == "" {
+			continue
+		}
+		if segment == "." || segment == ".." || !segmentPattern.MatchString(segment) {
+			return "", fmt.Errorf("invalid folder name %q", segment)
+		}
+		segments = append(segments, segment)
+	}
+	if len(segments) > MaxFolderDepth {
+		return "", ErrFolderDepth
+	}
+	return strings.Join(segments, "/"), nil
+}
+
+/*
+Folders : this returns every folder holding projects together with all their parent
+folders, sorted so that a folder comes right before its sub folders
+*/
+func Folders(projects []model.Project) []string {
+	seen := make(map[string]bool)
+	var folders []string
+	for _, project := range projects {
+		segments := strings.Split(project.Folder, "/")
+		for i := range segments {
+			folder := strings.Join(segments[:i+1], "/")
+			if folder == "" || seen[folder] {
+				continue
+			}
+			seen[folder] = true
+			folders = append(folders, folder)
+		}
+	}
+	sort.Strings(folders)
+	return folders
+}
+
+/*
+Cloud : this counts the projects carrying each tag, most used tags first, and
+spreads the counts over the levels of the tag cloud
+*/
+func Cloud(projects []model.Project) []TagCount {
+	counts := make(map[string]int)
+	for _, project := range projects {
+		for _, tag := range project.Tags {
+			counts[tag]++
+		}
+	}
+	cloud := make([]TagCount, 0, len(counts))
+	maxCount := 0
+	for tag, count := range counts {
+		cloud = append(cloud, TagCount{Tag: tag, Count: count})
+		if count > maxCount {
+			maxCount = count
+		}
+	}
+	sort.Slice(cloud, func(i, j int) bool {
+		if cloud[i].Count != cloud[j].Count {
+			return cloud[i].Count > cloud[j].Count
+		}
+		return cloud[i].Tag < cloud[j].Tag
+	})
+	spread := maxCount - 1
+	if spread < 1 {
+		spread = 1
+	}
+	for i := range cloud {
+		cloud[i].Level = 1 + (cloud[i].Count-1)*(cloudLevels-1)/spread
+	}
+	return cloud
+}
+
+// PinnedFirst : this orders the pinned projects before the others, keeping the order within each group
+func PinnedFirst(projects []model.Project) {
+	sort.SliceStable(projects, func(i, j int) bool {
+		return projects[i].Pinned && !projects[j].Pinned
+	})
+}
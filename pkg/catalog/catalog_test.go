@@ -0,0 +1,90 @@
+package catalog
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/yusuf/track-space/pkg/model"
+)
+
+func TestParseTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    []string
+		wantErr bool
+	}{
+		{"empty", "", []string{}, false},
+		{"normalize", " Go, api ,GO, web dev", []string{"go", "api", "web-dev"}, false},
+		{"symbols", "c++, c#, node.js", []string{"c++", "c#", "node.js"}, false},
+		{"invalid", "<script>", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTags(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTags() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseTags() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeFolder(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{"root", " / ", "", false},
+		{"nested", "work / client  a/", "work/client a", false},
+		{"parent", "work/../secret", "", true},
+		{"too-deep", "a/b/c/d/e/f", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeFolder(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NormalizeFolder() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeFolder() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFolders(t *testing.T) {
+	projects := []model.Project{{Folder: "work/client a"}, {Folder: ""}, {Folder: "home"}, {Folder: "work"}}
+	want := []string{"home", "work", "work/client a"}
+	if got := Folders(projects); !reflect.DeepEqual(got, want) {
+		t.Errorf("Folders() = %q, want %q", got, want)
+	}
+}
+
+func TestCloud(t *testing.T) {
+	projects := []model.Project{
+		{Tags: []string{"go", "api"}},
+		{Tags: []string{"go"}},
+		{Tags: []string{"go", "web"}},
+	}
+	want := []TagCount{{"go", 3, 5}, {"api", 1, 1}, {"web", 1, 1}}
+	if got := Cloud(projects); !reflect.DeepEqual(got, want) {
+		t.Errorf("Cloud() = %+v, want %+v", got, want)
+	}
+}
+
+func TestPinnedFirst(t *testing.T) {
+	projects := []model.Project{{ID: "a"}, {ID: "b", Pinned: true}, {ID: "c"}, {ID: "d", Pinned: true}}
+	PinnedFirst(projects)
+	var got []string
+	for _, project := range projects {
+		got = append(got, project.ID)
+	}
+	if want := []string{"b", "d", "a", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("PinnedFirst() = %q, want %q", got, want)
+	}
+}
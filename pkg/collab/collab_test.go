@@ -0,0 +1,333 @@
+package collab
+
+import (
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+func units(s string) []uint16 { return utf16.Encode([]rune(s)) }
+
+func text(u []uint16) string { return string(utf16.Decode(u)) }
+
+func TestOperation_Apply(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		op   *Operation
+		want string
+	}{
+		{"insert at start", "world", new(Operation).Insert("hello ").Retain(5), "hello world"},
+		{"delete in middle", "hello big world", new(Operation).Retain(6).Delete(4).Retain(5), "hello world"},
+		{"replace", "cat", new(Operation).Delete(1).Insert("b").Retain(2), "bat"},
+		{"emoji counts two units", "a😀b", new(Operation).Retain(1).Delete(2).Insert("-").Retain(1), "a-b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.op.Apply(units(tt.doc))
+			if err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			if text(got) != tt.want {
+				t.Errorf("Apply() = %q, want %q", text(got), tt.want)
+			}
+		})
+	}
+
+	if _, err := new(Operation).Retain(3).Apply(units("four")); !errors.Is(err, ErrBaseLength) {
+		t.Errorf("Apply() on wrong length error = %v, want ErrBaseLength", err)
+	}
+}
+
+func TestOperation_JSON(t *testing.T) {
+	op := new(Operation).Retain(2).Delete(3).Insert("x😀").Retain(1)
+	data, err := json.Marshal(op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `[2,"x😀",-3,1]` {
+		t.Errorf("Marshal() = %s", data)
+	}
+	var back Operation
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.BaseLen != 6 || back.TargetLen != 6 {
+		t.Errorf("Unmarshal() lengths = %d, %d, want 6, 6", back.BaseLen, back.TargetLen)
+	}
+
+	for _, bad := range []string{`[0]`, `[""]`, `[true]`, `{}`} {
+		if err := json.Unmarshal([]byte(bad), &back); err == nil {
+			t.Errorf("Unmarshal(%s) accepted an invalid operation", bad)
+		}
+	}
+}
+
+func TestOperation_TransformIndex(t *testing.T) {
+	op := new(Operation).Retain(3).Insert("abc").Retain(2).Delete(2).Retain(3)
+	tests := []struct{ index, want int }{
+		{0, 0}, {3, 6}, {5, 8}, {6, 8}, {7, 8}, {8, 9}, {10, 11},
+	}
+	for _, tt := range tests {
+		if got := op.TransformIndex(tt.index); got != tt.want {
+			t.Errorf("TransformIndex(%d) = %d, want %d", tt.index, got, tt.want)
+		}
+	}
+}
+
+// randomOperation : an operation editing a random part of doc
+func randomOperation(r *rand.Rand, doc []uint16) *Operation {
+	op := new(Operation)
+	for left := len(doc); left > 0; {
+		n := 1 + r.Intn(left)
+		switch r.Intn(3) {
+		case 0:
+			op.Retain(n)
+		case 1:
+			op.Delete(n)
+		default:
+			op.Insert(string(rune('a' + r.Intn(26)))).Retain(n)
+		}
+		left -= n
+	}
+	if r.Intn(2) == 0 {
+		op.Insert("z")
+	}
+	return op
+}
+
+func TestTransform_Converges(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		doc := units("the quick brown fox jumps over the lazy dog"[:r.Intn(44)])
+		a, b := randomOperation(r, doc), randomOperation(r, doc)
+		aPrime, bPrime, err := Transform(a, b)
+		if err != nil {
+			t.Fatalf("Transform() error = %v", err)
+		}
+		afterA, _ := a.Apply(doc)
+		left, err := bPrime.Apply(afterA)
+		if err != nil {
+			t.Fatalf("b' does not apply after a: %v", err)
+		}
+		afterB, _ := b.Apply(doc)
+		right, err := aPrime.Apply(afterB)
+		if err != nil {
+			t.Fatalf("a' does not apply after b: %v", err)
+		}
+		if text(left) != text(right) {
+			t.Fatalf("documents diverge: %q and %q", text(left), text(right))
+		}
+	}
+}
+
+func TestDocument_Receive(t *testing.T) {
+	d := NewDocument("hello")
+	// two editors both on revision 0
+	if _, err := d.Receive(0, new(Operation).Retain(5).Insert(" world")); err != nil {
+		t.Fatal(err)
+	}
+	op, err := d.Receive(0, new(Operation).Insert("oh, ").Retain(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Text() != "oh, hello world" || d.Revision() != 2 {
+		t.Errorf("document = %q at %d", d.Text(), d.Revision())
+	}
+	if op.BaseLen != 11 {
+		t.Errorf("transformed operation base length = %d, want 11", op.BaseLen)
+	}
+	if _, err := d.Receive(5, new(Operation).Retain(15)); !errors.Is(err, ErrStaleRevision) {
+		t.Errorf("Receive() on unknown revision error = %v", err)
+	}
+	if index, _ := d.TransformIndex(0, 2); index != 6 {
+		t.Errorf("TransformIndex() = %d, want 6", index)
+	}
+}
+
+func TestDocument_MaxLength(t *testing.T) {
+	d := NewDocument(strings.Repeat("a", MaxLength-2))
+	if _, err := d.Receive(0, new(Operation).Retain(MaxLength-2).Insert("bb")); err != nil {
+		t.Fatalf("Receive() up to MaxLength error = %v", err)
+	}
+	if _, err := d.Receive(1, new(Operation).Retain(MaxLength).Insert("c")); !errors.Is(err, ErrTooLong) {
+		t.Errorf("Receive() beyond MaxLength error = %v, want %v", err, ErrTooLong)
+	}
+	if d.Revision() != 1 {
+		t.Errorf("revision = %d, want the refused operation not applied", d.Revision())
+	}
+
+	// a document loaded too long may still shrink, but never grow
+	d = NewDocument(strings.Repeat("a", MaxLength+10))
+	if _, err := d.Receive(0, new(Operation).Retain(MaxLength+5).Delete(5)); err != nil {
+		t.Errorf("Receive() shortening a long document error = %v", err)
+	}
+	if _, err := d.Receive(1, new(Operation).Retain(MaxLength+5).Insert("d")); !errors.Is(err, ErrTooLong) {
+		t.Errorf("Receive() growing a long document error = %v, want %v", err, ErrTooLong)
+	}
+}
+
+// fakeConn : a connection fed through channels
+type fakeConn struct {
+	in     chan Message
+	out    chan Message
+	closed chan struct{}
+	once   sync.Once
+}
+
+func newFakeConn() *fakeConn {
+	return &fakeConn{in: make(chan Message), out: make(chan Message, 64), closed: make(chan struct{})}
+}
+
+func (f *fakeConn) ReadJSON(v interface{}) error {
+	select {
+	case msg := <-f.in:
+		data, _ := json.Marshal(msg)
+		return json.Unmarshal(data, v)
+	case <-f.closed:
+		return errors.New("closed")
+	}
+}
+
+func (f *fakeConn) WriteJSON(v interface{}) error {
+	data, _ := json.Marshal(v)
+	var msg Message
+	_ = json.Unmarshal(data, &msg)
+	f.out <- msg
+	return nil
+}
+
+func (f *fakeConn) Ping() error {
+	return nil
+}
+
+func (f *fakeConn) Close() error {
+	f.once.Do(func() { close(f.closed) })
+	return nil
+}
+
+func (f *fakeConn) expect(t *testing.T, kind string) Message {
+	t.Helper()
+	for {
+		select {
+		case msg := <-f.out:
+			if msg.Type == kind {
+				return msg
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no %q message received", kind)
+		}
+	}
+}
+
+func TestHub_Serve(t *testing.T) {
+	saved := make(chan string, 4)
+	hub := NewHub(time.Hour)
+	load := func() (string, error) { return "draft", nil }
+	save := func(content string) error {
+		saved <- content
+		return nil
+	}
+
+	alice, bob := newFakeConn(), newFakeConn()
+	done := make(chan struct{}, 2)
+	go func() { _ = hub.Serve(alice, "p1", "alice", load, save); done <- struct{}{} }()
+	first := alice.expect(t, MsgInit)
+	if first.Content != "draft" || first.Revision != 0 {
+		t.Fatalf("init = %+v", first)
+	}
+	go func() { _ = hub.Serve(bob, "p1", "bob", load, save); done <- struct{}{} }()
+	if first := bob.expect(t, MsgInit); len(first.Peers) != 1 || first.Peers[0].Name != "alice" {
+		t.Fatalf("bob init peers = %+v", first.Peers)
+	}
+	alice.expect(t, MsgJoin)
+
+	alice.in <- Message{Type: MsgOp, Revision: 0, Op: new(Operation).Retain(5).Insert("!")}
+	if ack := alice.expect(t, MsgAck); ack.Revision != 1 {
+		t.Errorf("ack revision = %d, want 1", ack.Revision)
+	}
+	if op := bob.expect(t, MsgOp); op.Revision != 1 {
+		t.Errorf("relayed revision = %d, want 1", op.Revision)
+	}
+
+	bob.in <- Message{Type: MsgCursor, Revision: 0, Cursor: &Cursor{Anchor: 5, Head: 5}}
+	if cursor := alice.expect(t, MsgCursor); cursor.Cursor.Head != 6 {
+		t.Errorf("cursor head = %d, want 6", cursor.Cursor.Head)
+	}
+
+	_ = bob.Close()
+	alice.expect(t, MsgLeave)
+	_ = alice.Close()
+	<-done
+	<-done
+	select {
+	case content := <-saved:
+		if content != "draft!" {
+			t.Errorf("saved %q, want %q", content, "draft!")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("document not saved once the last editor left")
+	}
+	if n := hub.Editors("p1"); n != 0 {
+		t.Errorf("Editors() = %d after everybody left", n)
+	}
+}
+
+func TestReplacement(t *testing.T) {
+	tests := []struct{ from, to string }{
+		{"hello world", "hello brave world"},
+		{"hello world", "hello"},
+		{"", "new"},
+		{"old", ""},
+		{"same", "same"},
+		{"aaa", "aaaa"},
+		{"draft 😀", "draft 😃"},
+	}
+	for _, tt := range tests {
+		op := replacement(units(tt.from), units(tt.to))
+		got, err := op.Apply(units(tt.from))
+		if err != nil || text(got) != tt.to {
+			t.Errorf("replacement(%q, %q) applied = %q, %v", tt.from, tt.to, text(got), err)
+		}
+	}
+}
+
+func TestHub_Replace(t *testing.T) {
+	saved := make(chan string, 4)
+	hub := NewHub(time.Hour)
+	load := func() (string, error) { return "draft", nil }
+	save := func(content string) error {
+		saved <- content
+		return nil
+	}
+	if err := hub.Replace("p1", "nobody edits it"); err != nil {
+		t.Fatalf("Replace() without editors error = %v", err)
+	}
+
+	alice := newFakeConn()
+	done := make(chan struct{})
+	go func() { _ = hub.Serve(alice, "p1", "alice", load, save); close(done) }()
+	alice.expect(t, MsgInit)
+
+	// the form saved "final draft" while alice edits, she gets it as an operation
+	if err := hub.Replace("p1", "final draft"); err != nil {
+		t.Fatalf("Replace() error = %v", err)
+	}
+	op := alice.expect(t, MsgOp)
+	if got, _ := op.Op.Apply(units("draft")); text(got) != "final draft" || op.Revision != 1 {
+		t.Errorf("relayed operation gives %q at %d, want %q at 1", text(got), op.Revision, "final draft")
+	}
+	alice.in <- Message{Type: MsgOp, Revision: 1, Op: new(Operation).Retain(11).Insert("!")}
+	alice.expect(t, MsgAck)
+
+	_ = alice.Close()
+	<-done
+	if content := <-saved; content != "final draft!" {
+		t.Errorf("saved %q, want the edit of the form and of alice", content)
+	}
+}
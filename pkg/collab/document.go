@@ -0,0 +1,99 @@
+package collab
+
+import (
+	"errors"
+	"unicode/utf16"
+)
+
+const (
+	// maxHistory : number of applied operations kept to transform late operations against
+	maxHistory = 1000
+	// MaxLength : the longest document in UTF-16 code units, operations growing it further are refused
+	MaxLength = 1 << 20
+)
+
+var (
+	ErrStaleRevision = errors.New("revision is too old or unknown, reload the document")
+	ErrTooLong       = errors.New("the document is too long")
+)
+
+/*
+Document : the server copy of a document being edited together. Every operation
+received names the revision it was made on and is transformed against the
+operations applied since then before it is applied in turn
+*/
+type Document struct {
+	text []uint16
+	// history : the last applied operations, history[i] took the document from revision start+i to start+i+1
+	history []*Operation
+	start   int
+}
+
+// NewDocument : this returns a document holding text at revision 0
+func NewDocument(text string) *Document {
+	return &Document{text: utf16.Encode([]rune(text))}
+}
+
+// Revision : this returns the number of operations applied to the document
+func (d *Document) Revision() int {
+	return d.start + len(d.history)
+}
+
+// Text : this returns the current content of the document
+func (d *Document) Text() string {
+	return string(utf16.Decode(d.text))
+}
+
+/*
+Receive : this applies an operation made on the given revision and returns it as
+it was applied to the current revision, the form every other editor has to apply.
+An operation leaving the document longer than MaxLength is refused unless it
+shortens it
+*/
+func (d *Document) Receive(revision int, op *Operation) (*Operation, error) {
+	if revision < d.start || revision > d.Revision() {
+		return nil, ErrStaleRevision
+	}
+	for _, applied := range d.history[revision-d.start:] {
+		var err error
+		op, _, err = Transform(op, applied)
+		if err != nil {
+			return nil, err
+		}
+	}
+	text, err := op.Apply(d.text)
+	if err != nil {
+		return nil, err
+	}
+	if len(text) > MaxLength && len(text) > len(d.text) {
+		return nil, ErrTooLong
+	}
+	d.text = text
+	d.history = append(d.history, op)
+	if len(d.history) > maxHistory {
+		drop := len(d.history) - maxHistory
+		d.history = append([]*Operation(nil), d.history[drop:]...)
+		d.start += drop
+	}
+	return op, nil
+}
+
+/*
+TransformIndex : this moves a position made on the given revision to the same place
+in the current revision
+*/
+func (d *Document) TransformIndex(revision, index int) (int, error) {
+	if revision < d.start || revision > d.Revision() {
+		return 0, ErrStaleRevision
+	}
+	for _, applied := range d.history[revision-d.start:] {
+		index = applied.TransformIndex(index)
+	}
+	if index > len(d.text) {
+		index = len(d.text)
+	}
+	if index < 0 {
+		index = 0
+	}
+	return index, nil
+}
@@ -0,0 +1,436 @@
+package collab
+
+import (
+	"log"
+	"sync"
+	"time"
+	"unicode/utf16"
+
+	"github.com/yusuf/track-space/pkg/wsconfig"
+)
+
+// types of the messages exchanged with the editors
+const (
+	MsgInit   = "init"
+	MsgOp     = "op"
+	MsgAck    = "ack"
+	MsgCursor = "cursor"
+	MsgJoin   = "join"
+	MsgLeave  = "leave"
+	MsgError  = "error"
+)
+
+const (
+	// sendQueue : messages waiting for a slow editor before it is disconnected
+	sendQueue = 256
+	// MaxMessageSize : the largest message in bytes read from an editor, pasting a long document fits
+	MaxMessageSize = 4 * MaxLength
+)
+
+// colors : the colors handed out in turn to the editors of a room
+var colors = []string{"#e6194b", "#3cb44b", "#4363d8", "#f58231", "#911eb4", "#42d4f4", "#f032e6", "#9a6324"}
+
+/*
+Conn : the connection of an editor, a wsconfig.SocketConnection in the application.
+Reads fail once the editor is silent for too long, which the pings prevent
+*/
+type Conn interface {
+	ReadJSON(v interface{}) error
+	WriteJSON(v interface{}) error
+	Ping() error
+	Close() error
+}
+
+// SaveFunc : persists the content of a document
+type SaveFunc func(content string) error
+
+// Cursor : the selection of an editor, head is where the caret is
+type Cursor struct {
+	Anchor int `json:"anchor"`
+	Head   int `json:"head"`
+}
+
+// Participant : an editor of a document as the other editors see it
+type Participant struct {
+	ID     int     `json:"id"`
+	Name   string  `json:"name"`
+	Color  string  `json:"color"`
+	Cursor *Cursor `json:"cursor,omitempty"`
+}
+
+/*
+Message : what the editors and the server send each other. Editors send "op" and
+"cursor" messages made on a revision, the server answers with "init" once
+connected, "ack" once an operation of the editor is applied and relays the
+operations, cursors, arrivals and departures of the other editors
+*/
+type Message struct {
+	Type     string        `json:"type"`
+	Revision int           `json:"rev"`
+	Op       *Operation    `json:"op,omitempty"`
+	Cursor   *Cursor       `json:"cursor,omitempty"`
+	Client   int           `json:"client,omitempty"`
+	Content  string        `json:"content,omitempty"`
+	Peer     *Participant  `json:"peer,omitempty"`
+	Peers    []Participant `json:"peers,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
+
+/*
+Hub : the rooms of the documents being edited. A room is opened by its first
+editor, saves its document every save interval while it changes and a last time
+once its last editor leaves
+*/
+type Hub struct {
+	mu           sync.Mutex
+	rooms        map[string]*room
+	saveInterval time.Duration
+	pingPeriod   time.Duration
+}
+
+type room struct {
+	key     string
+	mu      sync.Mutex
+	doc     *Document
+	clients map[int]*client
+	nextID  int
+	dirty   bool
+	save    SaveFunc
+	// saving : held while the document is written so saves never overtake each other
+	saving sync.Mutex
+	closed bool
+	// done : closed once the room is saved and removed from the hub
+	done chan struct{}
+	stop chan struct{}
+}
+
+type client struct {
+	Participant
+	conn    Conn
+	send    chan Message
+	dropped bool
+}
+
+// NewHub : this returns a hub saving the documents every saveInterval
+func NewHub(saveInterval time.Duration) *Hub {
+	return &Hub{rooms: make(map[string]*room), saveInterval: saveInterval, pingPeriod: wsconfig.PingPeriod}
+}
+
+/*
+Serve : this makes conn an editor of the document of key named name until the
+connection ends. The first editor of a document opens it with load, its content
+is written back with save
+*/
+func (h *Hub) Serve(conn Conn, key, name string, load func() (string, error), save SaveFunc) error {
+	r, c, err := h.join(key, name, conn, load, save)
+	if err != nil {
+		return err
+	}
+	go c.write(h.pingPeriod)
+	defer h.leave(r, c)
+
+	for {
+		var msg Message
+		if err := conn.ReadJSON(&msg); err != nil {
+			return nil
+		}
+		switch msg.Type {
+		case MsgOp:
+			r.receiveOp(c, msg)
+		case MsgCursor:
+			r.moveCursor(c, msg)
+		}
+	}
+}
+
+// Editors : this returns the number of editors of the document of key
+func (h *Hub) Editors(key string) int {
+	h.mu.Lock()
+	r := h.rooms[key]
+	h.mu.Unlock()
+	if r == nil {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.clients)
+}
+
+/*
+Replace : this makes content the text of the document of key after it was saved
+elsewhere, like the edit form of a project, so the editors never write their older
+copy over it. The editors of an open document get the change as an operation, a
+document being closed is saved again with content once its last save is done
+*/
+func (h *Hub) Replace(key, content string) error {
+	h.mu.Lock()
+	r := h.rooms[key]
+	h.mu.Unlock()
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		<-r.done
+		return r.save(content)
+	}
+	defer r.mu.Unlock()
+	op := replacement(r.doc.text, utf16.Encode([]rune(content)))
+	if op.IsNoop() {
+		return nil
+	}
+	op, err := r.doc.Receive(r.doc.Revision(), op)
+	if err != nil {
+		return err
+	}
+	r.applied(op)
+	// no editor made it, client 0 is nobody
+	r.broadcast(0, Message{Type: MsgOp, Revision: r.doc.Revision(), Op: op})
+	return nil
+}
+
+// replacement : this returns the operation turning from into to, replacing what lies between their common start and end
+func replacement(from, to []uint16) *Operation {
+	prefix := 0
+	for prefix < len(from) && prefix < len(to) && from[prefix] == to[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(from)-prefix && suffix < len(to)-prefix && from[len(from)-1-suffix] == to[len(to)-1-suffix] {
+		suffix++
+	}
+	return new(Operation).
+		Retain(prefix).
+		Delete(len(from) - prefix - suffix).
+		insertUnits(to[prefix : len(to)-suffix]).
+		Retain(suffix)
+}
+
+func (h *Hub) join(key, name string, conn Conn, load func() (string, error), save SaveFunc) (*room, *client, error) {
+	for {
+		r, err := h.open(key, load, save)
+		if err != nil {
+			return nil, nil, err
+		}
+		r.mu.Lock()
+		if r.closed {
+			// the last editor just left, wait for the document to be saved before opening it again
+			r.mu.Unlock()
+			<-r.done
+			continue
+		}
+		r.nextID++
+		c := &client{
+			Participant: Participant{ID: r.nextID, Name: name, Color: colors[(r.nextID-1)%len(colors)]},
+			conn:        conn,
+			send:        make(chan Message, sendQueue),
+		}
+		peers := make([]Participant, 0, len(r.clients))
+		for _, other := range r.clients {
+			peers = append(peers, other.Participant)
+		}
+		r.clients[c.ID] = c
+		c.send <- Message{Type: MsgInit, Revision: r.doc.Revision(), Content: r.doc.Text(), Client: c.ID, Peers: peers}
+		peer := c.Participant
+		r.broadcast(c.ID, Message{Type: MsgJoin, Revision: r.doc.Revision(), Peer: &peer})
+		r.mu.Unlock()
+		return r, c, nil
+	}
+}
+
+// open : this returns the room of key, loading its document when nobody edits it yet
+func (h *Hub) open(key string, load func() (string, error), save SaveFunc) (*room, error) {
+	h.mu.Lock()
+	r := h.rooms[key]
+	h.mu.Unlock()
+	if r != nil {
+		return r, nil
+	}
+
+	content, err := load()
+	if err != nil {
+		return nil, err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if r := h.rooms[key]; r != nil {
+		return r, nil
+	}
+	r = &room{
+		key:     key,
+		doc:     NewDocument(content),
+		clients: make(map[int]*client),
+		save:    save,
+		done:    make(chan struct{}),
+		stop:    make(chan struct{}),
+	}
+	h.rooms[key] = r
+	go r.autosave(h.saveInterval)
+	return r, nil
+}
+
+func (h *Hub) leave(r *room, c *client) {
+	r.mu.Lock()
+	delete(r.clients, c.ID)
+	close(c.send)
+	r.broadcast(c.ID, Message{Type: MsgLeave, Revision: r.doc.Revision(), Client: c.ID})
+	last := len(r.clients) == 0
+	if last {
+		r.closed = true
+		close(r.stop)
+	}
+	r.mu.Unlock()
+	if !last {
+		return
+	}
+
+	if err := r.flush(); err != nil {
+		log.Printf("cannot save document %s : %v", r.key, err)
+	}
+	h.mu.Lock()
+	if h.rooms[r.key] == r {
+		delete(h.rooms, r.key)
+	}
+	h.mu.Unlock()
+	close(r.done)
+}
+
+func (r *room) receiveOp(c *client, msg Message) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if msg.Op == nil {
+		r.sendTo(c, Message{Type: MsgError, Revision: r.doc.Revision(), Error: ErrInvalidOp.Error()})
+		return
+	}
+	op, err := r.doc.Receive(msg.Revision, msg.Op)
+	if err != nil {
+		r.sendTo(c, Message{Type: MsgError, Revision: r.doc.Revision(), Error: err.Error()})
+		return
+	}
+	r.applied(op)
+	rev := r.doc.Revision()
+	r.sendTo(c, Message{Type: MsgAck, Revision: rev})
+	r.broadcast(c.ID, Message{Type: MsgOp, Revision: rev, Op: op, Client: c.ID})
+}
+
+// applied : this moves the cursors of the editors over an operation applied to the document, the room lock is held
+func (r *room) applied(op *Operation) {
+	r.dirty = true
+	for _, other := range r.clients {
+		if other.Cursor != nil {
+			other.Cursor = &Cursor{Anchor: op.TransformIndex(other.Cursor.Anchor), Head: op.TransformIndex(other.Cursor.Head)}
+		}
+	}
+}
+
+func (r *room) moveCursor(c *client, msg Message) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if msg.Cursor == nil {
+		return
+	}
+	anchor, err := r.doc.TransformIndex(msg.Revision, msg.Cursor.Anchor)
+	if err != nil {
+		return
+	}
+	head, err := r.doc.TransformIndex(msg.Revision, msg.Cursor.Head)
+	if err != nil {
+		return
+	}
+	c.Cursor = &Cursor{Anchor: anchor, Head: head}
+	r.broadcast(c.ID, Message{Type: MsgCursor, Revision: r.doc.Revision(), Client: c.ID, Cursor: c.Cursor})
+}
+
+// broadcast : this queues a message for every editor but the one of id, the room lock is held
+func (r *room) broadcast(from int, msg Message) {
+	for id, c := range r.clients {
+		if id != from {
+			r.sendTo(c, msg)
+		}
+	}
+}
+
+// sendTo : this queues a message for an editor, disconnecting the editor when it falls too far behind
+func (r *room) sendTo(c *client, msg Message) {
+	if c.dropped {
+		return
+	}
+	select {
+	case c.send <- msg:
+	default:
+		c.dropped = true
+		log.Printf("editor %d of document %s is too slow, disconnecting", c.ID, r.key)
+		_ = c.conn.Close()
+	}
+}
+
+func (r *room) autosave(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.flush(); err != nil {
+				log.Printf("cannot save document %s : %v", r.key, err)
+			}
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// flush : this saves the document when it changed since the last save
+func (r *room) flush() error {
+	r.saving.Lock()
+	defer r.saving.Unlock()
+	r.mu.Lock()
+	if !r.dirty {
+		r.mu.Unlock()
+		return nil
+	}
+	content := r.doc.Text()
+	r.dirty = false
+	r.mu.Unlock()
+
+	if err := r.save(content); err != nil {
+		r.mu.Lock()
+		r.dirty = true
+		r.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+/*
+write : this writes the queued messages of an editor and pings it every pingPeriod,
+the connection is closed once a write or a ping fails
+*/
+func (c *client) write(pingPeriod time.Duration) {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case msg, ok := <-c.send:
+			if !ok {
+				return
+			}
+			if err := c.conn.WriteJSON(msg); err != nil {
+				c.fail()
+				return
+			}
+		case <-ticker.C:
+			if err := c.conn.Ping(); err != nil {
+				c.fail()
+				return
+			}
+		}
+	}
+}
+
+// fail : this closes the connection of an editor and keeps draining its queue so the room never blocks on it
+func (c *client) fail() {
+	_ = c.conn.Close()
+	for range c.send {
+	}
+}
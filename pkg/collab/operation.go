@@ -0,0 +1,282 @@
+package collab
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"unicode/utf16"
+)
+
+var (
+	ErrBaseLength = errors.New("operation does not apply to a document of this length")
+	ErrInvalidOp  = errors.New("invalid operation")
+)
+
+/*
+Operation : an edit of a whole text document, a sequence of retained, inserted and
+deleted characters walking the document from its start. Lengths are counted in
+UTF-16 code units, the unit browsers use for the positions inside a textarea.
+
+On the wire an operation is the json array used by ot.js: a positive number
+retains characters, a negative number deletes characters and a string inserts it
+*/
+type Operation struct {
+	ops []component
+	// BaseLen : length of the document the operation applies to
+	BaseLen int
+	// TargetLen : length of the document once the operation is applied
+	TargetLen int
+}
+
+// component : one step of an operation, exactly one of the fields is set
+type component struct {
+	retain int
+	delete int
+	insert []uint16
+}
+
+// Retain : this skips over n characters of the document
+func (o *Operation) Retain(n int) *Operation {
+	if n <= 0 {
+		return o
+	}
+	o.BaseLen += n
+	o.TargetLen += n
+	if last := o.last(); last != nil && last.retain > 0 {
+		last.retain += n
+		return o
+	}
+	o.ops = append(o.ops, component{retain: n})
+	return o
+}
+
+// Insert : this inserts text at the current position
+func (o *Operation) Insert(text string) *Operation {
+	return o.insertUnits(utf16.Encode([]rune(text)))
+}
+
+func (o *Operation) insertUnits(units []uint16) *Operation {
+	if len(units) == 0 {
+		return o
+	}
+	o.TargetLen += len(units)
+	last := o.last()
+	switch {
+	case last != nil && last.insert != nil:
+		last.insert = append(last.insert, units...)
+	case last != nil && last.delete > 0:
+		// an insert is always kept before the delete it sits next to, so that
+		// equal operations have the same components
+		n := len(o.ops)
+		if n > 1 && o.ops[n-2].insert != nil {
+			o.ops[n-2].insert = append(o.ops[n-2].insert, units...)
+		} else {
+			deleted := *last
+			o.ops[n-1] = component{insert: append([]uint16(nil), units...)}
+			o.ops = append(o.ops, deleted)
+		}
+	default:
+		o.ops = append(o.ops, component{insert: append([]uint16(nil), units...)})
+	}
+	return o
+}
+
+// Delete : this removes the next n characters of the document
+func (o *Operation) Delete(n int) *Operation {
+	if n <= 0 {
+		return o
+	}
+	o.BaseLen += n
+	if last := o.last(); last != nil && last.delete > 0 {
+		last.delete += n
+		return o
+	}
+	o.ops = append(o.ops, component{delete: n})
+	return o
+}
+
+func (o *Operation) last() *component {
+	if len(o.ops) == 0 {
+		return nil
+	}
+	return &o.ops[len(o.ops)-1]
+}
+
+// IsNoop : this reports whether the operation leaves the document unchanged
+func (o *Operation) IsNoop() bool {
+	return len(o.ops) == 0 || (len(o.ops) == 1 && o.ops[0].retain > 0)
+}
+
+// Apply : this returns the document with the operation applied to it
+func (o *Operation) Apply(doc []uint16) ([]uint16, error) {
+	if len(doc) != o.BaseLen {
+		return nil, ErrBaseLength
+	}
+	out := make([]uint16, 0, o.TargetLen)
+	pos := 0
+	for _, c := range o.ops {
+		switch {
+		case c.retain > 0:
+			out = append(out, doc[pos:pos+c.retain]...)
+			pos += c.retain
+		case c.delete > 0:
+			pos += c.delete
+		default:
+			out = append(out, c.insert...)
+		}
+	}
+	return out, nil
+}
+
+/*
+TransformIndex : this moves a position of the document the operation applies to,
+such as a cursor, to the same place in the document the operation produces
+*/
+func (o *Operation) TransformIndex(index int) int {
+	newIndex, oldIndex := index, 0
+	for _, c := range o.ops {
+		switch {
+		case c.retain > 0:
+			oldIndex += c.retain
+		case c.delete > 0:
+			newIndex -= minInt(index-oldIndex, c.delete)
+			oldIndex += c.delete
+		default:
+			newIndex += len(c.insert)
+		}
+		if oldIndex > index {
+			break
+		}
+	}
+	return newIndex
+}
+
+/*
+Transform : this takes two operations a and b made concurrently on the same
+document and returns a' and b' such that applying a then b' gives the same
+document as applying b then a'. When both insert at the same position the text
+of a comes first
+*/
+func Transform(a, b *Operation) (*Operation, *Operation, error) {
+	if a.BaseLen != b.BaseLen {
+		return nil, nil, ErrBaseLength
+	}
+	aPrime, bPrime := &Operation{}, &Operation{}
+	ops1, ops2 := a.ops, b.ops
+	op1, op2 := next(&ops1), next(&ops2)
+
+	for op1 != nil || op2 != nil {
+		if op1 != nil && op1.insert != nil {
+			aPrime.insertUnits(op1.insert)
+			bPrime.Retain(len(op1.insert))
+			op1 = next(&ops1)
+			continue
+		}
+		if op2 != nil && op2.insert != nil {
+			aPrime.Retain(len(op2.insert))
+			bPrime.insertUnits(op2.insert)
+			op2 = next(&ops2)
+			continue
+		}
+		if op1 == nil || op2 == nil {
+			return nil, nil, ErrInvalidOp
+		}
+
+		n1, n2 := op1.retain+op1.delete, op2.retain+op2.delete
+		n := minInt(n1, n2)
+		switch {
+		case op1.retain > 0 && op2.retain > 0:
+			aPrime.Retain(n)
+			bPrime.Retain(n)
+		case op1.delete > 0 && op2.retain > 0:
+			aPrime.Delete(n)
+		case op1.retain > 0 && op2.delete > 0:
+			bPrime.Delete(n)
+		}
+		// both deleting the same characters leaves nothing to do for either side
+		op1 = consume(op1, n, &ops1)
+		op2 = consume(op2, n, &ops2)
+	}
+	return aPrime, bPrime, nil
+}
+
+// next : this takes a copy of the first component off ops, nil once they are all taken
+func next(ops *[]component) *component {
+	if len(*ops) == 0 {
+		return nil
+	}
+	c := (*ops)[0]
+	*ops = (*ops)[1:]
+	return &c
+}
+
+// consume : this uses up n characters of a retain or delete, moving on to the next component once it is empty
+func consume(c *component, n int, ops *[]component) *component {
+	if c.retain > 0 {
+		c.retain -= n
+		if c.retain == 0 {
+			return next(ops)
+		}
+		return c
+	}
+	c.delete -= n
+	if c.delete == 0 {
+		return next(ops)
+	}
+	return c
+}
+
+// MarshalJSON : this writes the operation as an ot.js json array
+func (o *Operation) MarshalJSON() ([]byte, error) {
+	out := make([]interface{}, 0, len(o.ops))
+	for _, c := range o.ops {
+		switch {
+		case c.retain > 0:
+			out = append(out, c.retain)
+		case c.delete > 0:
+			out = append(out, -c.delete)
+		default:
+			out = append(out, string(utf16.Decode(c.insert)))
+		}
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON : this reads an operation from an ot.js json array
+func (o *Operation) UnmarshalJSON(data []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*o = Operation{}
+	for _, item := range raw {
+		var text string
+		if err := json.Unmarshal(item, &text); err == nil {
+			if text == "" {
+				return ErrInvalidOp
+			}
+			o.Insert(text)
+			continue
+		}
+		var n int
+		if err := json.Unmarshal(item, &n); err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidOp, item)
+		}
+		switch {
+		case n > 0:
+			o.Retain(n)
+		case n < 0:
+			o.Delete(-n)
+		default:
+			return ErrInvalidOp
+		}
+	}
+	return nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,149 @@
+package comment
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yusuf/track-space/pkg/model"
+)
+
+// kinds of documents comments are attached to
+const (
+	TargetProject = "project"
+	TargetTodo    = "todo"
+)
+
+const (
+	// MaxLength : longest comment, in characters
+	MaxLength = 5000
+	// MaxMentions : most users a single comment notifies
+	MaxMentions = 10
+	// maxDepth : deepest indentation of a reply, deeper replies are shown at this depth
+	maxDepth = 4
+)
+
+var (
+	ErrEmpty     = errors.New("a comment cannot be empty")
+	ErrTooLong   = errors.New("a comment has at most 5000 characters")
+	ErrNotFound  = errors.New("comment not found")
+	ErrNotAuthor = errors.New("only the author of a comment can change it")
+)
+
+// mentionPattern : an email address right after an @, like @jane@example.com
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@.])@([\w.%+\-]+@[\w\-]+(?:\.[\w\-]+)*\.[A-Za-z]{2,})`)
+
+// ParseContent : this trims a comment typed in a form and checks its length
+func ParseContent(raw string) (string, error) {
+	content := strings.TrimSpace(strings.ReplaceAll(raw, "\r\n", "\n"))
+	if content == "" {
+		return "", ErrEmpty
+	}
+	if utf8.RuneCountInString(content) > MaxLength {
+		return "", ErrTooLong
+	}
+	return content, nil
+}
+
+/*
+Mentions : this returns the emails mentioned in a comment, in order of appearance and
+without duplicates, up to MaxMentions
+*/
+func Mentions(content string) []string {
+	var emails []string
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		email := strings.TrimRight(match[1], ".")
+		if seen[strings.ToLower(email)] {
+			continue
+		}
+		seen[strings.ToLower(email)] = true
+		emails = append(emails, email)
+		if len(emails) == MaxMentions {
+			break
+		}
+	}
+	return emails
+}
+
+// NewMentions : this returns the mentions of after that were not already in before
+func NewMentions(before, after []string) []string {
+	known := make(map[string]bool, len(before))
+	for _, email := range before {
+		known[strings.ToLower(email)] = true
+	}
+	var added []string
+	for _, email := range after {
+		if !known[strings.ToLower(email)] {
+			added = append(added, email)
+		}
+	}
+	return added
+}
+
+// Entry : a comment of a thread with the depth it is shown at
+type Entry struct {
+	model.Comment
+	Depth int
+}
+
+/*
+Thread : this orders comments as a thread, every reply right after its parent and
+the siblings oldest first. Replies to a missing parent are shown at the top level
+*/
+func Thread(comments []model.Comment) []Entry {
+	ids := make(map[string]bool, len(comments))
+	for _, c := range comments {
+		ids[c.ID] = true
+	}
+	children := make(map[string][]model.Comment)
+	for _, c := range comments {
+		parent := c.ParentID
+		if !ids[parent] || parent == c.ID {
+			parent = ""
+		}
+		children[parent] = append(children[parent], c)
+	}
+	for _, list := range children {
+		// object ids start with their creation time, so they sort oldest first
+		sort.SliceStable(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	}
+
+	thread := make([]Entry, 0, len(comments))
+	visited := make(map[string]bool, len(comments))
+	var add func(c model.Comment, depth int)
+	add = func(c model.Comment, depth int) {
+		if visited[c.ID] {
+			return
+		}
+		visited[c.ID] = true
+		if depth > maxDepth {
+			thread = append(thread, Entry{Comment: c, Depth: maxDepth})
+		} else {
+			thread = append(thread, Entry{Comment: c, Depth: depth})
+		}
+		for _, reply := range children[c.ID] {
+			add(reply, depth+1)
+		}
+	}
+	for _, c := range children[""] {
+		add(c, 0)
+	}
+	// comments replying to each other in a loop are never reached from the top level
+	for _, c := range comments {
+		add(c, 0)
+	}
+	return thread
+}
+
+// Excerpt : this shortens a comment to at most n characters for notifications
+func Excerpt(content string, n int) string {
+	content = strings.Join(strings.Fields(content), " ")
+	if utf8.RuneCountInString(content) <= n {
+		return content
+	}
+	runes := []rune(content)
+	return strings.TrimSpace(string(runes[:n])) + "…"
+}
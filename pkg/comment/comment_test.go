@@ -0,0 +1,112 @@
+package comment
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yusuf/track-space/pkg/model"
+)
+
+func TestParseContent(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    string
+		wantErr error
+	}{
+		{"  looks good\r\n", "looks good", nil},
+		{"line one\r\nline two", "line one\nline two", nil},
+		{"   ", "", ErrEmpty},
+		{strings.Repeat("é", MaxLength), strings.Repeat("é", MaxLength), nil},
+		{strings.Repeat("a", MaxLength+1), "", ErrTooLong},
+	}
+	for _, tt := range tests {
+		got, err := ParseContent(tt.raw)
+		if err != tt.wantErr || got != tt.want {
+			t.Errorf("ParseContent(%.20q) = %.20q, %v", tt.raw, got, err)
+		}
+	}
+}
+
+func TestMentions(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"none", "no mention here, mail jane@example.com", nil},
+		{"one", "@jane@example.com can you check?", []string{"jane@example.com"}},
+		{"sentence end", "thanks @jane.doe@mail.example.org.", []string{"jane.doe@mail.example.org"}},
+		{"duplicates", "@a@x.io and @A@X.io and @b@x.io", []string{"a@x.io", "b@x.io"}},
+		{"inside a word", "foo@bar@x.io", nil},
+		{"new line", "first\n@a@x.io", []string{"a@x.io"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Mentions(tt.content); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Mentions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMentionsLimit(t *testing.T) {
+	var content []string
+	for i := 0; i < MaxMentions+5; i++ {
+		content = append(content, "@user"+strings.Repeat("x", i)+"@example.com")
+	}
+	if got := Mentions(strings.Join(content, " ")); len(got) != MaxMentions {
+		t.Errorf("Mentions() returned %d emails, want %d", len(got), MaxMentions)
+	}
+}
+
+func TestNewMentions(t *testing.T) {
+	got := NewMentions([]string{"a@x.io", "b@x.io"}, []string{"B@x.io", "c@x.io"})
+	if want := []string{"c@x.io"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("NewMentions() = %v, want %v", got, want)
+	}
+}
+
+func TestThread(t *testing.T) {
+	comments := []model.Comment{
+		{ID: "01", Content: "first"},
+		{ID: "02", Content: "second"},
+		{ID: "03", ParentID: "01", Content: "reply to first"},
+		{ID: "04", ParentID: "03", Content: "reply to reply"},
+		{ID: "05", ParentID: "gone", Content: "orphan"},
+		{ID: "06", ParentID: "07", Content: "loop a"},
+		{ID: "07", ParentID: "06", Content: "loop b"},
+	}
+	var got []string
+	var depths []int
+	for _, entry := range Thread(comments) {
+		got = append(got, entry.ID)
+		depths = append(depths, entry.Depth)
+	}
+	if want := []string{"01", "03", "04", "02", "05", "06", "07"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Thread() order = %v, want %v", got, want)
+	}
+	if want := []int{0, 1, 2, 0, 0, 0, 1}; !reflect.DeepEqual(depths, want) {
+		t.Errorf("Thread() depths = %v, want %v", depths, want)
+	}
+}
+
+func TestThreadDepth(t *testing.T) {
+	comments := []model.Comment{{ID: "00"}}
+	for i := 1; i < 10; i++ {
+		comments = append(comments, model.Comment{ID: string(rune('0'+i)) + "0", ParentID: comments[i-1].ID})
+	}
+	thread := Thread(comments)
+	if len(thread) != len(comments) || thread[len(thread)-1].Depth != maxDepth {
+		t.Errorf("Thread() = %+v", thread)
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	if got := Excerpt("short\n text", 20); got != "short text" {
+		t.Errorf("Excerpt() = %q", got)
+	}
+	if got := Excerpt("a long comment to shorten", 6); got != "a long…" {
+		t.Errorf("Excerpt() = %q", got)
+	}
+}
@@ -4,7 +4,10 @@ import (
 	"github.com/go-playground/validator/v10"
 	"log"
 
+	"github.com/yusuf/track-space/pkg/blob"
+	"github.com/yusuf/track-space/pkg/collab"
 	"github.com/yusuf/track-space/pkg/model"
+	"github.com/yusuf/track-space/pkg/ws"
 )
 
 type AppConfig struct {
@@ -12,4 +15,12 @@ type AppConfig struct {
 	ErrorLogger *log.Logger
 	MailChan    chan model.Email
 	Validator   *validator.Validate
+	// BlobStore : where the content of project attachments is kept
+	BlobStore blob.Store
+	// AttachmentQuota : bytes of attachments every user can store
+	AttachmentQuota int64
+	// Collab : the rooms of the projects being edited together
+	Collab *collab.Hub
+	// Chat : the clients of the chat room
+	Chat *ws.Hub
 }
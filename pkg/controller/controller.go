@@ -1,27 +1,48 @@
 package controller
 
 import (
+	"bytes"
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"html"
+	"io"
 	"io/ioutil"
 	"log"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-contrib/sessions"
+	"github.com/yusuf/track-space/pkg/access"
+	"github.com/yusuf/track-space/pkg/blob"
+	"github.com/yusuf/track-space/pkg/board"
+	"github.com/yusuf/track-space/pkg/catalog"
+	"github.com/yusuf/track-space/pkg/collab"
+	"github.com/yusuf/track-space/pkg/comment"
 	"github.com/yusuf/track-space/pkg/data"
 	"github.com/yusuf/track-space/pkg/data/tsRepoStore"
+	"github.com/yusuf/track-space/pkg/ical"
 	"github.com/yusuf/track-space/pkg/key"
+	"github.com/yusuf/track-space/pkg/recur"
+	"github.com/yusuf/track-space/pkg/render"
+	"github.com/yusuf/track-space/pkg/team"
 	"github.com/yusuf/track-space/pkg/temp"
+	"github.com/yusuf/track-space/pkg/workfile"
 	"github.com/yusuf/track-space/pkg/ws"
 	"github.com/yusuf/track-space/pkg/wsconfig"
 	"github.com/yusuf/track-space/pkg/wsmodel"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 
@@ -518,71 +539,12 @@ func (ts *TrackSpace) GetDashBoard() gin.HandlerFunc {
 				_ = c.AbortWithError(http.StatusNotFound, gin.Error{Err: err})
 				return
 			}
-			// Count different projects type
-			currentDate := time.Now().Format("2006-01-02")
-			var storedDate string
-			count := make(map[string]int)
-			for k, value := range user {
-				if k == "project_details" {
-					switch v := value.(type) {
-					case primitive.A:
-						countCode, countText, countArticle := 0, 0, 0
-						// _ is the index and y is the array of structs
-						for _, y := range v {
-							switch tools := y.(type) {
-							case primitive.M:
-								for i, j := range tools {
-									// fmt.Println(i, j)
-									if i == "created_at" {
-										storedDate = fmt.Sprint(j)
-									}
-									if i == "tools_use_as" && j == "code" {
-										countCode += 1
-									} else if i == "tools_use_as" && j == "text" {
-										countText += 1
-									} else if i == "tools_use_as" && j == "article" {
-										countArticle += 1
-									}
-
-								}
-								ts.Code(count, countCode)
-								ts.Text(count, countText)
-								ts.Article(count, countArticle)
-							}
-						}
-					}
-				}
-				if k == "todo" {
-					switch todoList := value.(type) {
-					case primitive.A:
-						todoNo := len(todoList)
-						ts.Todo(count, todoNo)
-					}
-				}
-			}
-
-			tsStat := model.Data{
-				Date:    currentDate,
-				Code:    count["code"],
-				Article: count["article"],
-				Text:    count["text"],
-				Todo:    count["todoNo"],
-				Total:   count["article"] + count["text"] + count["code"],
-			}
-
-			if currentDate == storedDate {
-				err = ts.tsDB.UpdateUserStat(tsStat, userData.UserID)
-				if err != nil {
-					_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
-					return
-				}
-			}
-			r, err := ts.tsDB.GetUserStatByID(userData.UserID)
+			data, err := ts.dashboardStat(userData.UserID, user)
 			if err != nil {
 				_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
 				return
 			}
-			statFile, err := json.MarshalIndent(r["data"], "", " ")
+			statFile, err := json.MarshalIndent(data, "", " ")
 			if err != nil {
 				_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
 				return
@@ -595,15 +557,170 @@ func (ts *TrackSpace) GetDashBoard() gin.HandlerFunc {
 				_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
 				return
 			}
+			// the workspace switcher lists the teams of the user, the active one is kept in the session
+			teams, err := ts.tsDB.GetUserTeams(userData.UserID)
+			if err != nil {
+				_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+				return
+			}
+			var activeTeam *model.Team
+			if teamID, _ := tsData.Get("team_id").(string); teamID != "" {
+				tm, err := ts.tsDB.GetTeam(teamID)
+				if err != nil && err != mongo.ErrNoDocuments {
+					_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+					return
+				}
+				if _, ok := team.Member(tm, userData.UserID); ok {
+					activeTeam = &tm
+				}
+			}
+			unread, err := ts.tsDB.CountUnreadNotifications(userData.UserID)
+			if err != nil {
+				_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+				return
+			}
 			c.HTML(http.StatusOK, "dash.html", gin.H{
-				"FirstName": user["first_name"],
-				"LastName":  user["last_name"],
-				"token":     t,
+				"FirstName":  user["first_name"],
+				"LastName":   user["last_name"],
+				"token":      t,
+				"LiveToken":  chatToken(c),
+				"Teams":      teams,
+				"ActiveTeam": activeTeam,
+				"Unread":     unread,
 			})
 		}
 	}
 }
 
+/*
+dashboardStat : this counts the projects of every kind and the todos of the user, keeps
+them as the stat of today when a project was created today and returns the stats of
+every day shown by the dashboard
+*/
+func (ts *TrackSpace) dashboardStat(userID string, user primitive.M) (interface{}, error) {
+	// Count different projects type
+	currentDate := time.Now().Format("2006-01-02")
+	var storedDate string
+	count := make(map[string]int)
+	for k, value := range user {
+		if k == "project_details" {
+			switch v := value.(type) {
+			case primitive.A:
+				countCode, countText, countArticle := 0, 0, 0
+				// _ is the index and y is the array of structs
+				for _, y := range v {
+					switch tools := y.(type) {
+					case primitive.M:
+						for i, j := range tools {
+							// fmt.Println(i, j)
+							if i == "created_at" {
+								storedDate = fmt.Sprint(j)
+							}
+							if i == "tools_use_as" && j == "code" {
+								countCode += 1
+							} else if i == "tools_use_as" && j == "text" {
+								countText += 1
+							} else if i == "tools_use_as" && j == "article" {
+								countArticle += 1
+							}
+
+						}
+						ts.Code(count, countCode)
+						ts.Text(count, countText)
+						ts.Article(count, countArticle)
+					}
+				}
+			}
+		}
+		if k == "todo" {
+			switch todoList := value.(type) {
+			case primitive.A:
+				todoNo := len(todoList)
+				ts.Todo(count, todoNo)
+			}
+		}
+	}
+
+	tsStat := model.Data{
+		Date:    currentDate,
+		Code:    count["code"],
+		Article: count["article"],
+		Text:    count["text"],
+		Todo:    count["todoNo"],
+		Total:   count["article"] + count["text"] + count["code"],
+	}
+
+	if currentDate == storedDate {
+		if err := ts.tsDB.UpdateUserStat(tsStat, userID); err != nil {
+			return nil, err
+		}
+	}
+	r, err := ts.tsDB.GetUserStatByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	return r["data"], nil
+}
+
+/*
+DashboardStats : this returns the stats of the dashboard of the user as JSON, so the
+dashboard refreshes its charts once a project or todo changed
+*/
+func (ts *TrackSpace) DashboardStats() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userData := sessions.Default(c).Get("session_data").(model.SessionData)
+		user, err := ts.tsDB.SendUserDetails(userData.UserID)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusNotFound, gin.Error{Err: err})
+			return
+		}
+		data, err := ts.dashboardStat(userData.UserID, user)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		c.JSON(http.StatusOK, data)
+	}
+}
+
+/*
+LiveUpdates : this connects a dashboard, project table or todo table page of the user to
+the websocket, which pushes the changes of their projects and todos made from any tab
+or device so the page updates in place
+*/
+func (ts *TrackSpace) LiveUpdates() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userData := sessions.Default(c).Get("session_data").(model.SessionData)
+		// the pages get the chat token of the session, another site cannot upgrade with the cookie
+		if !validChatToken(c) {
+			log.Printf("live websocket rejected for user %s from %s : invalid chat token", userData.UserID, c.ClientIP())
+			_ = c.AbortWithError(http.StatusForbidden, gin.Error{Err: errors.New("invalid chat token")})
+			return
+		}
+		wsConn, err := ws.UpgradeSocketConn.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			// the upgrader already answered the request with the error
+			log.Printf("Unable to connect to socket : %v", err)
+			return
+		}
+		ts.AppConfig.Chat.Watch(wsconfig.NewSocketConnection(wsConn), userData.UserID)
+	}
+}
+
+/*
+notifyChange : this tells the live pages of the user that one of their projects or todos
+was created, updated or deleted, id is empty when several of them changed
+*/
+func (ts *TrackSpace) notifyChange(userID, kind, action, id string) {
+	if ts.AppConfig.Chat == nil {
+		return
+	}
+	ts.AppConfig.Chat.Notify(userID, wsmodel.SocketResponse{
+		Condition: "changed",
+		Change:    &wsmodel.Change{Kind: kind, Action: action, ID: id},
+	})
+}
+
 /*
 ProjectWorkspace :  this show the user workspace worksheet to execute
 
@@ -638,10 +755,21 @@ func (ts *TrackSpace) PostWorkSpaceProject() gin.HandlerFunc {
 		project.ProjectName = strings.ToLower(c.PostForm("project-name"))
 		project.ToolsUseAs = strings.ToLower(c.PostForm("project-tool-use"))
 		project.ProjectContent = c.Request.Form.Get("myText")
+		project.ContentFormat = render.FormatMarkdown
 		project.Status = "unmodified"
 		project.CreatedAt = time.Now().Format("2006-01-02")
 		project.UpdatedAt = time.Now().Format("2006-01-02")
 
+		tags, folder, err := projectOrganization(c)
+		if err != nil {
+			c.HTML(http.StatusBadRequest, "work.html", gin.H{
+				"save": err.Error(),
+			})
+			return
+		}
+		project.Tags = tags
+		project.Folder = folder
+
 		// Server side validation of the user input from a form
 		if err := ts.AppConfig.Validator.Struct(&project); err != nil {
 			if _, ok := err.(*validator.InvalidValidationError); !ok {
@@ -651,11 +779,12 @@ func (ts *TrackSpace) PostWorkSpaceProject() gin.HandlerFunc {
 			}
 		}
 
-		err := ts.tsDB.StoreProjectData(userData.UserID, project)
+		err = ts.tsDB.StoreProjectData(userData.UserID, project)
 		if err != nil {
 			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
 			return
 		}
+		ts.notifyChange(userData.UserID, wsmodel.ChangeProject, wsmodel.ChangeCreated, project.ID)
 
 		// c.Redirect(http.StatusSeeOther, "/auth/user/workspace")
 		c.HTML(http.StatusOK, "work.html", gin.H{
@@ -665,396 +794,3032 @@ func (ts *TrackSpace) PostWorkSpaceProject() gin.HandlerFunc {
 }
 
 /*
-ShowProjectTable - this give the full projects details of a particular
-user and help the user to modify each projects
+ImportPage : this shows the page to import projects from a zip archive or a directory
 */
-func (ts *TrackSpace) ShowProjectTable() gin.HandlerFunc {
+func (ts *TrackSpace) ImportPage() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		project := make(map[string]interface{})
-		var allProjects []map[string]interface{}
-
-		tsData := sessions.Default(c)
-		userData := tsData.Get("session_data").(model.SessionData)
-
-		user, err := ts.tsDB.SendUserDetails(userData.UserID)
-		if err != nil {
-			log.Println("cannot get user project data from the database")
-			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
-			return
-		}
-		switch p := user["project_details"].(type) {
-		case primitive.A:
-			for _, x := range p {
-				switch k := x.(type) {
-				case primitive.M:
-					for i, j := range k {
-						project[i] = j
-					}
-					allProjects = append(allProjects, k)
-				}
-			}
-		}
-		c.HTML(http.StatusOK, "project-table.html", gin.H{
-			"Project":   allProjects,
-			"FirstName": user["first_name"],
-			"LastName":  user["last_name"],
+		c.HTML(http.StatusOK, "import.html", gin.H{
+			"MaxImportSize": workfile.MaxImportSize >> 20,
+			"MaxFileSize":   workfile.MaxFileSize >> 10,
 		})
 	}
 }
 
 /*
-ShowUserProject : this  handler direct the user to a page to make changes and modify their
-existing projects store in the database
+ImportProjects : this turns every Markdown, text and source file of an uploaded zip
+archive ("archive") or directory ("files", with their relative "paths") into a
+project and reports what happened to each file
 */
-func (ts *TrackSpace) ShowUserProject() gin.HandlerFunc {
+func (ts *TrackSpace) ImportProjects() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var project model.Project
-		projectMap := make(map[string]string)
-		sourceLink := c.Param("src")
-		project.ID = c.Param("id")
-		ok := primitive.IsValidObjectID(c.Param("id"))
+		tsData := sessions.Default(c)
+		userData := tsData.Get("session_data").(model.SessionData)
 
-		if sourceLink != "project-table" && !ok {
-			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: errors.New("invalid url parameters")})
+		page := gin.H{
+			"MaxImportSize": workfile.MaxImportSize >> 20,
+			"MaxFileSize":   workfile.MaxFileSize >> 10,
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, workfile.MaxImportSize)
+		if err := c.Request.ParseMultipartForm(8 << 20); err != nil {
+			page["error"] = fmt.Sprintf("the upload must be a zip archive or files of at most %d MB", workfile.MaxImportSize>>20)
+			c.HTML(http.StatusRequestEntityTooLarge, "import.html", page)
 			return
 		}
+		defer func() {
+			_ = c.Request.MultipartForm.RemoveAll()
+		}()
 
-		projectData, err := ts.tsDB.GetProjectData(project.ID)
+		files, closeFiles, err := uploadedImportFiles(c.Request.MultipartForm)
 		if err != nil {
-			log.Println("cannot get user project data from the database")
-			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			page["error"] = err.Error()
+			c.HTML(http.StatusBadRequest, "import.html", page)
 			return
 		}
+		defer closeFiles()
 
-		switch p := projectData["project_details"].(type) {
-		case primitive.A:
-			for _, x := range p {
-				log.Println(len(p))
-				switch k := x.(type) {
-				case primitive.M:
-					for i, j := range k {
-						projectMap[i] = fmt.Sprint(j)
-					}
-				}
+		var results []workfile.Result
+		imported := 0
+		for _, f := range files {
+			if workfile.Ignored(f.Path) {
+				continue
 			}
-		}
-
-		for x, y := range projectMap {
-			fmt.Println(x, y)
-			if x == "project_name" {
-				project.ProjectName = y
+			project, err := workfile.Prepare(f)
+			if errors.Is(err, workfile.ErrUnsupported) {
+				results = append(results, workfile.Result{Path: f.Path, Status: workfile.Skipped, Reason: err.Error()})
+				continue
 			}
-			if x == "tools_use_as" {
-				project.ToolsUseAs = y
+			if err != nil {
+				results = append(results, workfile.Result{Path: f.Path, Status: workfile.Failed, Reason: err.Error()})
+				continue
 			}
-			if x == "project_content" {
-				project.ProjectContent = y
+			project.ID = primitive.NewObjectID().Hex()
+			project.Status = "unmodified"
+			project.CreatedAt = time.Now().Format("2006-01-02")
+			project.UpdatedAt = time.Now().Format("2006-01-02")
+
+			if err := ts.tsDB.StoreProjectData(userData.UserID, project); err != nil {
+				results = append(results, workfile.Result{Path: f.Path, Status: workfile.Failed, Reason: "cannot save the project"})
+				continue
 			}
-
+			imported++
+			results = append(results, workfile.Result{Path: f.Path, Project: project.ProjectName, Status: workfile.Imported})
 		}
 
-		c.HTML(http.StatusOK, "show-project.html", gin.H{
-			"projectID":      project.ID,
-			"projectName":    project.ProjectName,
-			"projectContent": project.ProjectContent,
-			"toolsUseAs":     project.ToolsUseAs,
-		})
+		if imported > 0 {
+			ts.notifyChange(userData.UserID, wsmodel.ChangeProject, wsmodel.ChangeCreated, "")
+		}
+		page["Results"] = results
+		page["success"] = fmt.Sprintf("%d of %d files imported as projects", imported, len(results))
+		c.HTML(http.StatusOK, "import.html", page)
 	}
 }
 
 /*
-ModifyUserProject - this method helps to post modified and changes in user projects
-and also update the project status as well
+uploadedImportFiles : this lists the files of the uploaded zip archive or directory,
+the returned function closes the archive once the files are read
 */
-func (ts *TrackSpace) ModifyUserProject() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		var project model.Project
-		sourceLink := c.Param("src")
-
-		projectID := c.Param("id")
-		ok := primitive.IsValidObjectID(projectID)
-		if sourceLink != "show-project" && !ok {
-			_ = c.AbortWithError(http.StatusNotFound, gin.Error{Err: errors.New("invalid ID cannot convert the Object ID")})
+func uploadedImportFiles(form *multipart.Form) ([]workfile.File, func(), error) {
+	if archives := form.File["archive"]; len(archives) > 0 {
+		archive, err := archives[0].Open()
+		if err != nil {
+			return nil, nil, err
 		}
-		project.ID = projectID
-		project.ProjectName = strings.ToLower(c.PostForm("project-name"))
-		project.ToolsUseAs = strings.ToLower(c.PostForm("project-tool-use"))
-		project.ProjectContent = c.Request.Form.Get("myText")
-		project.Status = "modified"
-		project.UpdatedAt = time.Now().Format("2006-01-02")
-		project.CreatedAt = time.Now().Format("2006-01-02")
-
-		tsData := sessions.Default(c)
-		userData := tsData.Get("session_data").(model.SessionData)
-
-		log.Println(projectID)
-
-		err := ts.tsDB.ModifyProjectData(userData.UserID, projectID, project)
+		files, err := workfile.ZipFiles(archive, archives[0].Size)
 		if err != nil {
-			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			_ = archive.Close()
+			if errors.Is(err, workfile.ErrTooManyFiles) {
+				return nil, nil, err
+			}
+			return nil, nil, errors.New("the archive is not a valid zip file")
 		}
+		return files, func() { _ = archive.Close() }, nil
+	}
 
-		c.HTML(http.StatusSeeOther, "dash.html", gin.H{
-			"updateProject": fmt.Sprintf("%s project updated successfully", project.ProjectName),
+	uploads := form.File["files"]
+	if len(uploads) == 0 {
+		return nil, nil, errors.New("choose a zip archive or a directory to import")
+	}
+	if len(uploads) > workfile.MaxImportFiles {
+		return nil, nil, workfile.ErrTooManyFiles
+	}
+	// browsers drop the directories from the file names of a multipart upload, so
+	// the page sends the relative path of every file alongside it
+	paths := form.Value["paths"]
+	files := make([]workfile.File, 0, len(uploads))
+	for i, upload := range uploads {
+		upload := upload
+		name := upload.Filename
+		if i < len(paths) && paths[i] != "" {
+			name = paths[i]
+		}
+		files = append(files, workfile.File{
+			Path: name,
+			Size: upload.Size,
+			Open: func() (io.ReadCloser, error) { return upload.Open() },
 		})
 	}
+	return files, func() {}, nil
+}
+
+// sharedProject : a project shared with the logged-in user and the role it is shared with
+type sharedProject struct {
+	model.SharedProject
+	Role string
 }
 
 /*
-DeleteProject : this is to delete select project existing in the database
+ShowProjectTable - this give the full projects details of a particular
+user and help the user to modify each projects
 */
-func (ts *TrackSpace) DeleteProject() gin.HandlerFunc {
+func (ts *TrackSpace) ShowProjectTable() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var project model.Project
-		sourceLink := c.Param("src")
-		ok := primitive.IsValidObjectID(c.Param("id"))
-		if sourceLink != "project-table" && !ok {
-			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: errors.New("invalid url parameters")})
+		tsData := sessions.Default(c)
+		userData := tsData.Get("session_data").(model.SessionData)
+
+		user, err := ts.tsDB.SendUserDetails(userData.UserID)
+		if err != nil {
+			log.Println("cannot get user project data from the database")
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
 			return
 		}
+		allProjects := userProjects(user)
 
-		project.ID = c.Param("id")
-		ok = primitive.IsValidObjectID(project.ID)
-		if !ok {
-			log.Println("invalid ID cannot convert the Object ID")
-			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: errors.New("invalid ID cannot convert the Object ID")})
+		// the tag and folder of the query narrow the table down, the tag cloud and
+		// the folder list always cover every project
+		projects := allProjects
+		tag := strings.ToLower(c.Query("tag"))
+		folder, _ := catalog.NormalizeFolder(c.Query("folder"))
+		switch {
+		case tag != "":
+			projects, err = ts.tsDB.GetProjectsByTag(userData.UserID, tag)
+		case folder != "":
+			projects, err = ts.tsDB.GetProjectsByFolder(userData.UserID, folder)
 		}
-		err := ts.tsDB.DeleteUserProject(project.ID)
 		if err != nil {
 			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
 		}
+		catalog.PinnedFirst(projects)
 
-		c.HTML(http.StatusSeeOther, "dash.html", gin.H{
-			"deleteProject": fmt.Sprintf("%s project delete successfully. Go back to dashboard", project.ProjectName),
+		sharedProjects, err := ts.tsDB.GetSharedProjects(userData.UserID)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		shared := make([]sharedProject, 0, len(sharedProjects))
+		for _, p := range sharedProjects {
+			shared = append(shared, sharedProject{
+				SharedProject: p,
+				Role:          access.RoleOf(p.Project, p.OwnerID, userData.UserID),
+			})
+		}
+
+		c.HTML(http.StatusOK, "project-table.html", gin.H{
+			"Shared":    shared,
+			"Project":   projects,
+			"TagCloud":  catalog.Cloud(allProjects),
+			"Folders":   catalog.Folders(allProjects),
+			"Tag":       tag,
+			"Folder":    folder,
+			"FirstName": user["first_name"],
+			"LastName":  user["last_name"],
+			"LiveToken": chatToken(c),
 		})
 	}
 }
 
 /*
-GetTodo - this will help the user to get the todo-page
-to set up a schedule
+PinProject : this pins a project to the top of the project table, or unpins it when
+the "pinned" form value is false
 */
-func (ts *TrackSpace) GetTodo() gin.HandlerFunc {
+func (ts *TrackSpace) PinProject() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.HTML(http.StatusOK, "todo.html", gin.H{})
+		tsData := sessions.Default(c)
+		userData := tsData.Get("session_data").(model.SessionData)
+
+		projectID := c.Param("id")
+		if !primitive.IsValidObjectID(projectID) {
+			_ = c.AbortWithError(http.StatusNotFound, gin.Error{Err: errors.New("invalid ID cannot convert the Object ID")})
+			return
+		}
+		pinned := c.PostForm("pinned") == "true"
+		err := ts.tsDB.SetProjectPinned(userData.UserID, projectID, pinned)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		ts.notifyChange(userData.UserID, wsmodel.ChangeProject, wsmodel.ChangeUpdated, projectID)
+		c.Redirect(http.StatusSeeOther, "/auth/user/project-table")
 	}
 }
 
 /*
-PostTodoData : this get the user schedule details from the form and store in the
-database
+RetagProjects : this adds the "add-tags" and removes the "remove-tags" comma separated
+tags on every selected "project" of the project table
 */
-func (ts *TrackSpace) PostTodoData() gin.HandlerFunc {
+func (ts *TrackSpace) RetagProjects() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var todo model.Todo
 		tsData := sessions.Default(c)
 		userData := tsData.Get("session_data").(model.SessionData)
 
 		if err := c.Request.ParseForm(); err != nil {
-			log.Println("cannot parse the daily task form")
 			_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: err})
 			return
 		}
-
-		userID := userData.UserID
-		todo.ID = primitive.NewObjectID().Hex()
-		todo.ToDoTask = c.Request.Form.Get("task")
-		todo.DateSchedule = c.Request.Form.Get("schedule-date")
-		todo.StartTime = c.Request.Form.Get("start-time")
-		todo.EndTime = c.Request.Form.Get("end-time")
-		todo.Status = "Not done"
-		// Server side validation of the user input from a form
-		if err := ts.AppConfig.Validator.Struct(&todo); err != nil {
-			if _, ok := err.(*validator.InvalidValidationError); !ok {
-				_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: err})
-				log.Println(err)
+		projectIDs := c.Request.PostForm["project"]
+		if len(projectIDs) == 0 {
+			_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: catalog.ErrNoProjectSelected})
+			return
+		}
+		for _, id := range projectIDs {
+			if !primitive.IsValidObjectID(id) {
+				_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: errors.New("invalid ID cannot convert the Object ID")})
 				return
 			}
 		}
-
-		err := ts.tsDB.StoreTodoData(todo, userID)
+		addTags, err := catalog.ParseTags(c.PostForm("add-tags"))
 		if err != nil {
-			log.Println("error while inserting todo data in database")
 			_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: err})
 			return
 		}
-
-		if err := tsData.Save(); err != nil {
-			log.Println("error from the session storage")
+		removeTags, err := catalog.ParseTags(c.PostForm("remove-tags"))
+		if err != nil {
+			_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: err})
+			return
 		}
 
-		c.HTML(http.StatusOK, "todo.html", gin.H{
-			"addTodo": fmt.Sprintf("%s added to schedule plans", todo.ToDoTask),
-		})
+		err = ts.tsDB.RetagProjects(userData.UserID, projectIDs, addTags, removeTags)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		ts.notifyChange(userData.UserID, wsmodel.ChangeProject, wsmodel.ChangeUpdated, "")
+		c.Redirect(http.StatusSeeOther, "/auth/user/project-table")
 	}
 }
 
-// ShowTodoTable : this  handler direct the user to a page to make changes and modify their
-// existing todo store in the database
-func (ts *TrackSpace) ShowTodoTable() gin.HandlerFunc {
+// projectOrganization : this reads the tags and the folder of the project form
+func projectOrganization(c *gin.Context) ([]string, string, error) {
+	tags, err := catalog.ParseTags(c.PostForm("project-tags"))
+	if err != nil {
+		return nil, "", err
+	}
+	folder, err := catalog.NormalizeFolder(c.PostForm("project-folder"))
+	if err != nil {
+		return nil, "", err
+	}
+	return tags, folder, nil
+}
+
+/*
+ShowUserProject : this  handler direct the user to a page to make changes and modify their
+existing projects store in the database. Projects shared with the user open read-only
+for viewers and editable for editors
+*/
+func (ts *TrackSpace) ShowUserProject() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		todo := make(map[string]interface{})
-		var allTodo []map[string]interface{}
+		project, owner, role, ok := ts.projectAccess(c)
+		if !ok {
+			return
+		}
 
-		tsData := sessions.Default(c)
-		userData := tsData.Get("session_data").(model.SessionData)
-		userID := userData.UserID
+		// the linked tasks, the attachment quota and the sharing settings belong to the owner
+		var todos []model.Todo
+		var progress int
+		var used int64
+		var publicURL string
+		if role == access.RoleOwner {
+			user, err := ts.tsDB.SendUserDetails(owner["_id"].(string))
+			if err != nil {
+				_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+				return
+			}
+			todos, progress = projectTodos(userTodos(user), project.ID)
+			used = storageUsed(user)
+			if project.PublicToken != "" {
+				publicURL = publicProjectURL(c, project.PublicToken)
+			}
+		}
 
-		user, err := ts.tsDB.SendUserDetails(userID)
+		rendered, err := render.Content(project.ProjectContent, project.ContentFormat, project.ToolsUseAs == "code")
 		if err != nil {
-			log.Println("cannot get user project data from the database")
 			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
 			return
 		}
-		switch p := user["todo"].(type) {
-		case primitive.A:
-			for _, x := range p {
-				switch k := x.(type) {
-				case primitive.M:
-					for i, j := range k {
-						todo[i] = j
-					}
-					allTodo = append(allTodo, k)
-				}
+		comments, err := ts.tsDB.GetComments(comment.TargetProject, project.ID)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+
+		c.HTML(http.StatusOK, "show-project.html", gin.H{
+			"projectID":       project.ID,
+			"projectName":     project.ProjectName,
+			"projectContent":  project.ProjectContent,
+			"renderedContent": rendered,
+			"toolsUseAs":      project.ToolsUseAs,
+			"projectTags":     strings.Join(project.Tags, ", "),
+			"projectFolder":   project.Folder,
+			"Todos":           todos,
+			"TodoProgress":    progress,
+			"Attachments":     project.Attachments,
+			"StorageUsed":     used >> 10,
+			"StorageQuota":    ts.AppConfig.AttachmentQuota >> 10,
+			"Role":            role,
+			"IsOwner":         role == access.RoleOwner,
+			"CanEdit":         access.CanEdit(role),
+			"OwnerName":       displayName(owner),
+			"Shares":          project.Shares,
+			"PublicURL":       publicURL,
+			"Comments":        comment.Thread(comments),
+			"CommentURL":      fmt.Sprintf("/auth/user/project-table/show-project/%s/comments", project.ID),
+			"UserID":          sessions.Default(c).Get("session_data").(model.SessionData).UserID,
+			"CollabToken":     chatToken(c),
+		})
+	}
+}
+
+/*
+PreviewProject : this renders the Markdown of the workspace editor to sanitized html
+the same way the project page shows it
+*/
+func (ts *TrackSpace) PreviewProject() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		toolsUseAs := strings.ToLower(c.PostForm("project-tool-use"))
+		rendered, err := render.Markdown(c.PostForm("myText"), toolsUseAs == "code")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"html": rendered})
+	}
+}
+
+/*
+ModifyUserProject - this method helps to post modified and changes in user projects
+and also update the project status as well. Editors of a shared project change its
+name and content, its tags and folder stay the ones of the owner
+*/
+func (ts *TrackSpace) ModifyUserProject() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		stored, owner, role, ok := ts.projectAccess(c)
+		if !ok {
+			return
+		}
+		if !access.CanEdit(role) {
+			_ = c.AbortWithError(http.StatusForbidden, gin.Error{Err: errors.New("this project is shared with you read-only")})
+			return
+		}
+
+		var project model.Project
+		project.ID = stored.ID
+		project.ProjectName = strings.ToLower(c.PostForm("project-name"))
+		project.ToolsUseAs = strings.ToLower(c.PostForm("project-tool-use"))
+		project.ProjectContent = c.Request.Form.Get("myText")
+		project.ContentFormat = render.FormatMarkdown
+		project.Status = "modified"
+		project.UpdatedAt = time.Now().Format("2006-01-02")
+		project.CreatedAt = time.Now().Format("2006-01-02")
+
+		project.Tags, project.Folder = stored.Tags, stored.Folder
+		if role == access.RoleOwner {
+			tags, folder, err := projectOrganization(c)
+			if err != nil {
+				_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: err})
+				return
 			}
+			project.Tags = tags
+			project.Folder = folder
 		}
-		c.HTML(http.StatusOK, "todo-table.html", gin.H{
-			"Todos":     allTodo,
-			"FirstName": user["first_name"],
-			"LastName":  user["last_name"],
+
+		err := ts.tsDB.ModifyProjectData(owner["_id"].(string), project.ID, project)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		// the editors of the project take the content of the form, their next save would undo it otherwise
+		if err := ts.AppConfig.Collab.Replace(project.ID, project.ProjectContent); err != nil {
+			log.Printf("cannot send the saved content of project %s to its editors : %v", project.ID, err)
+		}
+		ts.notifyChange(owner["_id"].(string), wsmodel.ChangeProject, wsmodel.ChangeUpdated, project.ID)
+
+		c.HTML(http.StatusSeeOther, "dash.html", gin.H{
+			"updateProject": fmt.Sprintf("%s project updated successfully", project.ProjectName),
 		})
 	}
 }
 
+// maxAttachmentSize : largest file that can be attached to a project
+const maxAttachmentSize = 25 << 20
+
 /*
-ShowTodoSchedule : this will show the selected schedule plans to show all it fulls
-details  and as well make changes to it
+UploadAttachment : this attaches the uploaded "attachment" file to a project. The
+content type is sniffed from the content and the upload is refused once it would
+take the user over the storage quota
 */
-func (ts *TrackSpace) ShowTodoSchedule() gin.HandlerFunc {
+func (ts *TrackSpace) UploadAttachment() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var todo model.Todo
-		TodoMap := make(map[string]string)
-		sourceLink := c.Param("src")
-		todo.ID = c.Param("id")
-		ok := primitive.IsValidObjectID(c.Param("id"))
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxAttachmentSize+1<<20)
+		project, user, ok := ts.userProject(c)
+		if !ok {
+			return
+		}
+		file, header, err := c.Request.FormFile("attachment")
+		if err != nil {
+			_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: errors.New("choose a file of at most 25 MB to attach")})
+			return
+		}
+		defer file.Close()
+		if header.Size > maxAttachmentSize {
+			_ = c.AbortWithError(http.StatusRequestEntityTooLarge, gin.Error{Err: errors.New("attachments are limited to 25 MB")})
+			return
+		}
+		if storageUsed(user)+header.Size > ts.AppConfig.AttachmentQuota {
+			_ = c.AbortWithError(http.StatusRequestEntityTooLarge, gin.Error{Err: blob.ErrQuotaExceeded})
+			return
+		}
 
-		if sourceLink != "todo-table" && !ok {
-			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: errors.New("invalid url parameters")})
+		head := make([]byte, 512)
+		n, err := io.ReadFull(file, head)
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: err})
 			return
 		}
+		head = head[:n]
 
-		TodoData, err := ts.tsDB.GetTodoData(todo.ID)
+		blobKey, err := blob.NewKey()
 		if err != nil {
-			log.Println("cannot get user project data from the database")
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		size, err := ts.AppConfig.BlobStore.Put(blobKey, io.MultiReader(bytes.NewReader(head), file))
+		if err != nil {
+			log.Printf("cannot store attachment of project %s : %v", project.ID, err)
 			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
 			return
 		}
 
-		switch p := TodoData["todo"].(type) {
-		case primitive.A:
-			for _, x := range p {
-				log.Println(len(p))
-				switch k := x.(type) {
-				case primitive.M:
-					for i, j := range k {
-						TodoMap[i] = fmt.Sprint(j)
-					}
-				}
+		attachment := model.Attachment{
+			ID:          primitive.NewObjectID().Hex(),
+			Name:        filepath.Base(header.Filename),
+			ContentType: blob.Sniff(head),
+			Size:        size,
+			Key:         blobKey,
+			UploadedAt:  time.Now().Format("2006-01-02"),
+		}
+		err = ts.tsDB.AddProjectAttachment(user["_id"].(string), project.ID, attachment, ts.AppConfig.AttachmentQuota)
+		if err != nil {
+			_ = ts.AppConfig.BlobStore.Delete(blobKey)
+			status := http.StatusInternalServerError
+			if errors.Is(err, blob.ErrQuotaExceeded) {
+				status = http.StatusRequestEntityTooLarge
 			}
+			_ = c.AbortWithError(status, gin.Error{Err: err})
+			return
 		}
+		c.Redirect(http.StatusSeeOther, fmt.Sprintf("/auth/user/project-table/%s/show-project", project.ID))
+	}
+}
 
-		for x, y := range TodoMap {
-			// fmt.Println(x, y)
-			if x == "to_do_task" {
-				todo.ToDoTask = y
-			}
-			if x == "schedule_date" {
-				todo.DateSchedule = y
-			}
-			if x == "start_time" {
-				todo.StartTime = y
+/*
+DownloadAttachment : this sends an attachment of a project the logged-in user can see.
+Only images, pdf and plain text are shown in the browser, everything else is
+downloaded
+*/
+func (ts *TrackSpace) DownloadAttachment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		project, _, _, ok := ts.projectAccess(c)
+		if !ok {
+			return
+		}
+		attachment, ok := findAttachment(project.Attachments, c.Param("file"))
+		if !ok {
+			_ = c.AbortWithError(http.StatusNotFound, gin.Error{Err: errors.New("attachment not found")})
+			return
+		}
+		content, err := ts.AppConfig.BlobStore.Open(attachment.Key)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, blob.ErrNotFound) {
+				status = http.StatusNotFound
 			}
-			if x == "end_time" {
-				todo.EndTime = y
+			_ = c.AbortWithError(status, gin.Error{Err: err})
+			return
+		}
+		defer content.Close()
+
+		disposition := "attachment"
+		if blob.Inline(attachment.ContentType) {
+			disposition = "inline"
+		}
+		c.Header("X-Content-Type-Options", "nosniff")
+		c.Header("Content-Security-Policy", "default-src 'none'; img-src 'self'; style-src 'unsafe-inline'; sandbox")
+		c.Header("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Name}))
+		c.Header("Cache-Control", "private, max-age=3600")
+		c.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, content, nil)
+	}
+}
+
+// DeleteAttachment : this removes an attachment from a project and from the blob store
+func (ts *TrackSpace) DeleteAttachment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		project, user, ok := ts.userProject(c)
+		if !ok {
+			return
+		}
+		attachment, ok := findAttachment(project.Attachments, c.Param("file"))
+		if !ok {
+			_ = c.AbortWithError(http.StatusNotFound, gin.Error{Err: errors.New("attachment not found")})
+			return
+		}
+		err := ts.tsDB.RemoveProjectAttachment(user["_id"].(string), project.ID, attachment)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		if err := ts.AppConfig.BlobStore.Delete(attachment.Key); err != nil {
+			log.Printf("cannot delete blob %s : %v", attachment.Key, err)
+		}
+		c.Redirect(http.StatusSeeOther, fmt.Sprintf("/auth/user/project-table/%s/show-project", project.ID))
+	}
+}
+
+/*
+CollabProject : this upgrades the connection to the websocket the content of a
+project is edited on together by its owner and editors. Edits of every editor are merged on the server
+and the content is saved while it changes and once the last editor leaves
+*/
+func (ts *TrackSpace) CollabProject() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		project, owner, role, ok := ts.projectAccess(c)
+		if !ok {
+			return
+		}
+		if !access.CanEdit(role) {
+			_ = c.AbortWithError(http.StatusForbidden, gin.Error{Err: errors.New("this project is shared with you read-only")})
+			return
+		}
+		ownerID := owner["_id"].(string)
+		userID := sessions.Default(c).Get("session_data").(model.SessionData).UserID
+		// the project page gets the chat token of the session, another site cannot upgrade with the cookie
+		if !validChatToken(c) {
+			log.Printf("collaborative editing websocket rejected for user %s from %s : invalid chat token", userID, c.ClientIP())
+			_ = c.AbortWithError(http.StatusForbidden, gin.Error{Err: errors.New("invalid chat token")})
+			return
+		}
+		user, err := ts.tsDB.SendUserDetails(userID)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+
+		wsConn, err := ws.UpgradeSocketConn.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			log.Printf("cannot upgrade the collaborative editing connection : %v", err)
+			return
+		}
+		conn := wsconfig.NewSocketConnection(wsConn)
+		// an operation may carry a whole pasted document, larger than a chat message
+		wsConn.SetReadLimit(collab.MaxMessageSize)
+		defer conn.Close()
+
+		load := func() (string, error) {
+			return project.ProjectContent, nil
+		}
+		save := func(content string) error {
+			return ts.saveProjectContent(ownerID, project.ID, content)
+		}
+		if err := ts.AppConfig.Collab.Serve(conn, project.ID, displayName(user), load, save); err != nil {
+			log.Printf("cannot open project %s for editing : %v", project.ID, err)
+		}
+	}
+}
+
+/*
+saveProjectContent : this writes the content of a project edited together, reading
+the project again first so that only its content and update date change
+*/
+func (ts *TrackSpace) saveProjectContent(userID, projectID, content string) error {
+	user, err := ts.tsDB.SendUserDetails(userID)
+	if err != nil {
+		return err
+	}
+	project, ok := findProject(userProjects(user), projectID)
+	if !ok {
+		return errors.New("project not found")
+	}
+	project.ProjectContent = content
+	project.UpdatedAt = time.Now().Format("2006-01-02")
+	if err := ts.tsDB.ModifyProjectData(userID, projectID, project); err != nil {
+		return err
+	}
+	ts.notifyChange(userID, wsmodel.ChangeProject, wsmodel.ChangeUpdated, projectID)
+	return nil
+}
+
+// displayName : this returns the name of a user shown to the other users
+func displayName(user primitive.M) string {
+	var profile struct {
+		FirstName string `bson:"first_name"`
+		LastName  string `bson:"last_name"`
+		Email     string `bson:"email"`
+	}
+	if err := decodeDocument(user, &profile); err != nil {
+		log.Printf("cannot decode user profile : %v", err)
+	}
+	if name := strings.TrimSpace(profile.FirstName + " " + profile.LastName); name != "" {
+		return name
+	}
+	return profile.Email
+}
+
+/*
+ShareProject : this shares a project of the logged-in user with the track-space user
+registered with the "share-email" of the form, as "share-role" viewer or editor.
+Sharing it again with the same user changes the role
+*/
+func (ts *TrackSpace) ShareProject() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		project, user, ok := ts.userProject(c)
+		if !ok {
+			return
+		}
+		role, err := access.ParseRole(c.PostForm("share-role"))
+		if err != nil {
+			_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: err})
+			return
+		}
+		email := strings.TrimSpace(c.PostForm("share-email"))
+		sharedUser, err := ts.tsDB.GetUserByEmail(email)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if err == mongo.ErrNoDocuments {
+				status = http.StatusNotFound
+				err = fmt.Errorf("no track-space user is registered with %s", email)
 			}
+			_ = c.AbortWithError(status, gin.Error{Err: err})
+			return
+		}
+		sharedUserID, _ := sharedUser["_id"].(string)
+		if sharedUserID == user["_id"] {
+			_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: access.ErrShareOwner})
+			return
 		}
 
-		c.HTML(http.StatusOK, "show-todo.html", gin.H{
-			"TodoID":       todo.ID,
-			"Task":         todo.ToDoTask,
-			"DateSchedule": todo.DateSchedule,
-			"StartTime":    todo.StartTime,
-			"EndTime":      todo.EndTime,
-			"Status":       "Done",
-		})
+		share := model.Share{
+			UserID:   sharedUserID,
+			Email:    email,
+			Role:     role,
+			SharedAt: time.Now().Format("2006-01-02"),
+		}
+		err = ts.tsDB.ShareProject(user["_id"].(string), project.ID, share)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		c.Redirect(http.StatusSeeOther, fmt.Sprintf("/auth/user/project-table/%s/show-project", project.ID))
+	}
+}
+
+// UnshareProject : this stops sharing a project of the logged-in user with the user of the ":user" url parameter
+func (ts *TrackSpace) UnshareProject() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		project, user, ok := ts.userProject(c)
+		if !ok {
+			return
+		}
+		err := ts.tsDB.UnshareProject(user["_id"].(string), project.ID, c.Param("user"))
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		c.Redirect(http.StatusSeeOther, fmt.Sprintf("/auth/user/project-table/%s/show-project", project.ID))
 	}
 }
 
 /*
-ModifyUserTodo - this method helps to post modified and changes in user projects
-and also update the todo status as well
+CreatePublicLink : this creates a secret read-only link to a project of the logged-in
+user that opens without login. Creating it again revokes the previous link
 */
-func (ts *TrackSpace) ModifyUserTodo() gin.HandlerFunc {
+func (ts *TrackSpace) CreatePublicLink() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var todo model.Todo
+		ts.setPublicToken(c, key.GenerateToken())
+	}
+}
+
+// RevokePublicLink : this removes the public link of a project of the logged-in user
+func (ts *TrackSpace) RevokePublicLink() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ts.setPublicToken(c, "")
+	}
+}
+
+func (ts *TrackSpace) setPublicToken(c *gin.Context, token string) {
+	project, user, ok := ts.userProject(c)
+	if !ok {
+		return
+	}
+	err := ts.tsDB.SetProjectPublicToken(user["_id"].(string), project.ID, token)
+	if err != nil {
+		_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+		return
+	}
+	c.Redirect(http.StatusSeeOther, fmt.Sprintf("/auth/user/project-table/%s/show-project", project.ID))
+}
+
+/*
+PublicProject : this shows the project of a public link read-only to anybody holding
+the link, without login
+*/
+func (ts *TrackSpace) PublicProject() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Param("token")
+		if len(token) != 64 {
+			_ = c.AbortWithError(http.StatusNotFound, gin.Error{Err: errors.New("project not found")})
+			return
+		}
+		owner, err := ts.tsDB.GetProjectByPublicToken(token)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if err == mongo.ErrNoDocuments {
+				status = http.StatusNotFound
+			}
+			_ = c.AbortWithError(status, gin.Error{Err: errors.New("project not found")})
+			return
+		}
+		projects := userProjects(owner)
+		if len(projects) == 0 {
+			_ = c.AbortWithError(http.StatusNotFound, gin.Error{Err: errors.New("project not found")})
+			return
+		}
+		project := projects[0]
+
+		rendered, err := render.Content(project.ProjectContent, project.ContentFormat, project.ToolsUseAs == "code")
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		// the link is a secret, it must not leak through search engines nor referrers
+		c.Header("X-Robots-Tag", "noindex, nofollow")
+		c.Header("Referrer-Policy", "no-referrer")
+		c.Header("Cache-Control", "private, no-store")
+		c.HTML(http.StatusOK, "public-project.html", gin.H{
+			"projectName":     project.ProjectName,
+			"toolsUseAs":      project.ToolsUseAs,
+			"updatedAt":       project.UpdatedAt,
+			"renderedContent": rendered,
+			"OwnerName":       strings.TrimSpace(fmt.Sprintf("%v %v", owner["first_name"], owner["last_name"])),
+		})
+	}
+}
+
+/*
+projectAccess : this looks up the project of the ":id" url parameter, its owner and
+the role of the logged-in user on it. The request is aborted as not found when the
+project is neither owned by nor shared with the user
+*/
+func (ts *TrackSpace) projectAccess(c *gin.Context) (model.Project, primitive.M, string, bool) {
+	userData := sessions.Default(c).Get("session_data").(model.SessionData)
+
+	projectID := c.Param("id")
+	if !primitive.IsValidObjectID(projectID) {
+		_ = c.AbortWithError(http.StatusNotFound, gin.Error{Err: errors.New("invalid ID cannot convert the Object ID")})
+		return model.Project{}, nil, "", false
+	}
+	owner, err := ts.tsDB.GetProjectOwner(projectID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if err == mongo.ErrNoDocuments {
+			status = http.StatusNotFound
+		}
+		_ = c.AbortWithError(status, gin.Error{Err: access.ErrNoAccess})
+		return model.Project{}, nil, "", false
+	}
+	project, ok := findProject(userProjects(owner), projectID)
+	ownerID, _ := owner["_id"].(string)
+	role := access.RoleOf(project, ownerID, userData.UserID)
+	if !ok || !access.CanView(role) {
+		_ = c.AbortWithError(http.StatusNotFound, gin.Error{Err: access.ErrNoAccess})
+		return model.Project{}, nil, "", false
+	}
+	return project, owner, role, true
+}
+
+/*
+userProject : this looks up the project of the ":id" url parameter among the projects
+of the logged-in user, aborting the request when it is not one of them
+*/
+func (ts *TrackSpace) userProject(c *gin.Context) (model.Project, primitive.M, bool) {
+	tsData := sessions.Default(c)
+	userData := tsData.Get("session_data").(model.SessionData)
+
+	projectID := c.Param("id")
+	if !primitive.IsValidObjectID(projectID) {
+		_ = c.AbortWithError(http.StatusNotFound, gin.Error{Err: errors.New("invalid ID cannot convert the Object ID")})
+		return model.Project{}, nil, false
+	}
+	user, err := ts.tsDB.SendUserDetails(userData.UserID)
+	if err != nil {
+		_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+		return model.Project{}, nil, false
+	}
+	project, ok := findProject(userProjects(user), projectID)
+	if !ok {
+		_ = c.AbortWithError(http.StatusNotFound, gin.Error{Err: errors.New("project not found")})
+		return model.Project{}, nil, false
+	}
+	return project, user, true
+}
+
+func findAttachment(attachments []model.Attachment, id string) (model.Attachment, bool) {
+	for _, attachment := range attachments {
+		if attachment.ID == id {
+			return attachment, true
+		}
+	}
+	return model.Attachment{}, false
+}
+
+// storageUsed : this returns the bytes of attachments stored by a user
+func storageUsed(user primitive.M) int64 {
+	var usage struct {
+		StorageUsed int64 `bson:"storage_used"`
+	}
+	if err := decodeDocument(user, &usage); err != nil {
+		log.Printf("cannot decode storage usage : %v", err)
+	}
+	return usage.StorageUsed
+}
+
+/*
+DeleteProject : this is to delete select project existing in the database
+*/
+func (ts *TrackSpace) DeleteProject() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var project model.Project
 		sourceLink := c.Param("src")
 		ok := primitive.IsValidObjectID(c.Param("id"))
-		if sourceLink != "show-todo" && !ok {
+		if sourceLink != "project-table" && !ok {
 			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: errors.New("invalid url parameters")})
 			return
 		}
 
-		todo.ID = c.Param("id")
-		ok = primitive.IsValidObjectID(todo.ID)
+		project.ID = c.Param("id")
+		ok = primitive.IsValidObjectID(project.ID)
+		if !ok {
+			log.Println("invalid ID cannot convert the Object ID")
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: errors.New("invalid ID cannot convert the Object ID")})
+		}
+		userData := sessions.Default(c).Get("session_data").(model.SessionData)
+		user, err := ts.tsDB.SendUserDetails(userData.UserID)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		// projects can be shared, only their owner may delete them
+		stored, ok := findProject(userProjects(user), project.ID)
+		if !ok {
+			_ = c.AbortWithError(http.StatusNotFound, gin.Error{Err: access.ErrNoAccess})
+			return
+		}
+		// the attachments of the project are removed from the blob store first
+		for _, attachment := range stored.Attachments {
+			if err := ts.tsDB.RemoveProjectAttachment(userData.UserID, project.ID, attachment); err != nil {
+				_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+				return
+			}
+			if err := ts.AppConfig.BlobStore.Delete(attachment.Key); err != nil {
+				log.Printf("cannot delete blob %s : %v", attachment.Key, err)
+			}
+		}
+
+		err = ts.tsDB.DeleteUserProject(project.ID)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		ts.notifyChange(userData.UserID, wsmodel.ChangeProject, wsmodel.ChangeDeleted, project.ID)
+		if err := ts.tsDB.DeleteTargetComments(comment.TargetProject, project.ID); err != nil {
+			log.Printf("cannot delete the comments of project %s : %v", project.ID, err)
+		}
+
+		c.HTML(http.StatusSeeOther, "dash.html", gin.H{
+			"deleteProject": fmt.Sprintf("%s project delete successfully. Go back to dashboard", project.ProjectName),
+		})
+	}
+}
+
+/*
+ExportWorkspace : this streams the whole workspace of the user, profile, projects,
+todos and statistics, as a zip archive to download
+*/
+func (ts *TrackSpace) ExportWorkspace() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tsData := sessions.Default(c)
+		userData := tsData.Get("session_data").(model.SessionData)
+
+		fileName := fmt.Sprintf("track-space-%s.zip", time.Now().Format("2006-01-02"))
+		c.Header("Content-Type", "application/zip")
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, fileName))
+		c.Status(http.StatusOK)
+
+		// the archive is written while it is read from the database, once the first
+		// bytes are sent the status cannot change anymore, so a later failure only
+		// ends the download early
+		err := workfile.WriteArchive(c.Writer, ts.tsDB, userData.UserID)
+		if err != nil {
+			log.Printf("cannot export the workspace of user %s : %v", userData.UserID, err)
+			if !c.Writer.Written() {
+				c.Header("Content-Disposition", "")
+				_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+				return
+			}
+			_ = c.Error(err)
+		}
+	}
+}
+
+/*
+GetTodo - this will help the user to get the todo-page
+to set up a schedule
+*/
+func (ts *TrackSpace) GetTodo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var projects []model.Project
+		tsData := sessions.Default(c)
+		if userData, ok := tsData.Get("session_data").(model.SessionData); ok {
+			user, err := ts.tsDB.SendUserDetails(userData.UserID)
+			if err != nil {
+				_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+				return
+			}
+			projects = userProjects(user)
+		}
+		c.HTML(http.StatusOK, "todo.html", gin.H{
+			"Projects":  projects,
+			"ProjectID": c.Query("project"),
+		})
+	}
+}
+
+/*
+PostTodoData : this get the user schedule details from the form and store in the
+database
+*/
+func (ts *TrackSpace) PostTodoData() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var todo model.Todo
+		tsData := sessions.Default(c)
+		userData := tsData.Get("session_data").(model.SessionData)
+
+		if err := c.Request.ParseForm(); err != nil {
+			log.Println("cannot parse the daily task form")
+			_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: err})
+			return
+		}
+
+		userID := userData.UserID
+		todo.ID = primitive.NewObjectID().Hex()
+		todo.ToDoTask = c.Request.Form.Get("task")
+		todo.DateSchedule = c.Request.Form.Get("schedule-date")
+		todo.StartTime = c.Request.Form.Get("start-time")
+		todo.EndTime = c.Request.Form.Get("end-time")
+		todo.Status = "Not done"
+		todo.ProjectID = c.Request.Form.Get("project-id")
+
+		user, err := ts.tsDB.SendUserDetails(userID)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		projects := userProjects(user)
+		if _, ok := findProject(projects, todo.ProjectID); todo.ProjectID != "" && !ok {
+			c.HTML(http.StatusBadRequest, "todo.html", gin.H{
+				"addTodo":  "the selected project does not exist",
+				"Projects": projects,
+			})
+			return
+		}
+
+		recurrence, err := recur.FromPreset(
+			c.Request.Form.Get("repeat"),
+			c.Request.Form["repeat-days"],
+			c.Request.Form.Get("repeat-interval"),
+			c.Request.Form.Get("repeat-until"),
+			c.Request.Form.Get("repeat-count"),
+		)
+		if err != nil {
+			c.HTML(http.StatusBadRequest, "todo.html", gin.H{
+				"addTodo":  err.Error(),
+				"Projects": projects,
+			})
+			return
+		}
+		todo.Recurrence = recurrence
+		// Server side validation of the user input from a form
+		if err := ts.AppConfig.Validator.Struct(&todo); err != nil {
+			if _, ok := err.(*validator.InvalidValidationError); !ok {
+				_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: err})
+				log.Println(err)
+				return
+			}
+		}
+
+		err = ts.tsDB.StoreTodoData(todo, userID)
+		if err != nil {
+			log.Println("error while inserting todo data in database")
+			_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: err})
+			return
+		}
+		ts.notifyChange(userID, wsmodel.ChangeTodo, wsmodel.ChangeCreated, todo.ID)
+
+		if err := tsData.Save(); err != nil {
+			log.Println("error from the session storage")
+		}
+
+		c.HTML(http.StatusOK, "todo.html", gin.H{
+			"addTodo":   fmt.Sprintf("%s added to schedule plans", todo.ToDoTask),
+			"Projects":  projects,
+			"ProjectID": todo.ProjectID,
+		})
+	}
+}
+
+// ShowTodoTable : this  handler direct the user to a page to make changes and modify their
+// existing todo store in the database
+func (ts *TrackSpace) ShowTodoTable() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		todo := make(map[string]interface{})
+		var allTodo []map[string]interface{}
+
+		tsData := sessions.Default(c)
+		userData := tsData.Get("session_data").(model.SessionData)
+		userID := userData.UserID
+
+		user, err := ts.tsDB.SendUserDetails(userID)
+		if err != nil {
+			log.Println("cannot get user project data from the database")
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		projects := userProjects(user)
+		projectFilter := c.Query("project")
+		switch p := user["todo"].(type) {
+		case primitive.A:
+			for _, x := range p {
+				switch k := x.(type) {
+				case primitive.M:
+					t, err := decodeTodo(k)
+					if err != nil {
+						continue
+					}
+					if projectFilter != "" && t.ProjectID != projectFilter {
+						continue
+					}
+					for i, j := range k {
+						todo[i] = j
+					}
+					k["progress"] = checklistProgress(t.Checklist)
+					k["project"] = ""
+					if project, ok := findProject(projects, t.ProjectID); ok {
+						k["project"] = project.ProjectName
+					}
+					allTodo = append(allTodo, k)
+				}
+			}
+		}
+		var calendarURL string
+		if token, ok := user["calendar_token"].(string); ok && token != "" {
+			calendarURL = calendarFeedURL(c, token)
+		}
+		c.HTML(http.StatusOK, "todo-table.html", gin.H{
+			"Todos":         allTodo,
+			"FirstName":     user["first_name"],
+			"LastName":      user["last_name"],
+			"CalendarURL":   calendarURL,
+			"ReminderLead":  user["reminder_lead"],
+			"DailyAgenda":   user["daily_agenda"],
+			"Projects":      projects,
+			"ProjectFilter": projectFilter,
+			"LiveToken":     chatToken(c),
+		})
+	}
+}
+
+/*
+CreateCalendarFeed : this generates a new secret iCalendar feed url for the user todo
+schedule. Generating it again revokes the previous url
+*/
+func (ts *TrackSpace) CreateCalendarFeed() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tsData := sessions.Default(c)
+		userData := tsData.Get("session_data").(model.SessionData)
+
+		token := key.GenerateToken()
+		err := ts.tsDB.SetCalendarToken(userData.UserID, token)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		c.Redirect(http.StatusSeeOther, "/auth/user/todo-table")
+	}
+}
+
+/*
+UpdateReminderSettings : this stores how long before a todo starts the user gets a
+reminder mail and whether a daily agenda mail is sent
+*/
+func (ts *TrackSpace) UpdateReminderSettings() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tsData := sessions.Default(c)
+		userData := tsData.Get("session_data").(model.SessionData)
+
+		if err := c.Request.ParseForm(); err != nil {
+			_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: err})
+			return
+		}
+		lead := 0
+		if value := c.Request.Form.Get("reminder-lead"); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 || n > 7*24*60 {
+				_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: errors.New("invalid reminder lead time")})
+				return
+			}
+			lead = n
+		}
+		dailyAgenda := c.Request.Form.Get("daily-agenda") == "on"
+
+		err := ts.tsDB.UpdateReminderSettings(userData.UserID, lead, dailyAgenda)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		c.Redirect(http.StatusSeeOther, "/auth/user/todo-table")
+	}
+}
+
+/*
+CalendarFeed : this serves the todo schedule of the user owning the secret token as
+an iCalendar (.ics) feed so calendar apps can subscribe to it without logging in
+*/
+func (ts *TrackSpace) CalendarFeed() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := strings.TrimSuffix(c.Param("token"), ".ics")
+		if token == "" {
+			_ = c.AbortWithError(http.StatusNotFound, gin.Error{Err: errors.New("calendar not found")})
+			return
+		}
+		user, err := ts.tsDB.GetUserByCalendarToken(token)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusNotFound, gin.Error{Err: errors.New("calendar not found")})
+			return
+		}
+
+		name := strings.TrimSpace(fmt.Sprintf("%v %v track-space", user["first_name"], user["last_name"]))
+		c.Header("Content-Type", "text/calendar; charset=utf-8")
+		c.Header("Content-Disposition", `inline; filename="track-space.ics"`)
+		c.Status(http.StatusOK)
+		if err := ical.Encode(c.Writer, name, userTodos(user)); err != nil {
+			log.Printf("cannot write calendar feed : %v", err)
+		}
+	}
+}
+
+/*
+ImportCalendar : this imports the VEVENT and VTODO entries of an uploaded .ics file
+into the user todo schedule. Entries whose UID is already on the schedule are skipped
+*/
+func (ts *TrackSpace) ImportCalendar() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tsData := sessions.Default(c)
+		userData := tsData.Get("session_data").(model.SessionData)
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, 2<<20)
+		file, _, err := c.Request.FormFile("calendar")
+		if err != nil {
+			_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: err})
+			return
+		}
+		defer file.Close()
+
+		entries, err := ical.Decode(file)
+		if err != nil {
+			c.HTML(http.StatusBadRequest, "todo.html", gin.H{
+				"addTodo": fmt.Sprintf("cannot import calendar : %v", err),
+			})
+			return
+		}
+
+		user, err := ts.tsDB.SendUserDetails(userData.UserID)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		seen := make(map[string]bool)
+		for _, todo := range userTodos(user) {
+			seen[ical.UID(todo)] = true
+		}
+
+		imported, skipped := 0, 0
+		for _, todo := range entries {
+			if seen[todo.UID] {
+				skipped++
+				continue
+			}
+			seen[todo.UID] = true
+			todo.ID = primitive.NewObjectID().Hex()
+			err := ts.tsDB.StoreTodoData(todo, userData.UserID)
+			if err != nil {
+				log.Println("error while inserting todo data in database")
+				_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+				return
+			}
+			imported++
+		}
+		if imported > 0 {
+			ts.notifyChange(userData.UserID, wsmodel.ChangeTodo, wsmodel.ChangeCreated, "")
+		}
+
+		c.HTML(http.StatusOK, "todo.html", gin.H{
+			"addTodo": fmt.Sprintf("%d imported to schedule plans, %d already on schedule", imported, skipped),
+		})
+	}
+}
+
+// calendarFeedURL : this builds the absolute url of a calendar feed token
+func calendarFeedURL(c *gin.Context, token string) string {
+	return fmt.Sprintf("%s/calendar/%s.ics", baseURL(c), token)
+}
+
+// publicProjectURL : this returns the full public link of a project
+func publicProjectURL(c *gin.Context, token string) string {
+	return fmt.Sprintf("%s/p/%s", baseURL(c), token)
+}
+
+// baseURL : this returns the scheme and host the request was made to
+func baseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s", scheme, c.Request.Host)
+}
+
+/*
+ShowTodoBoard : this shows the user todos as a board with a column for each stage
+of work, ordered the way the user arranged them
+*/
+func (ts *TrackSpace) ShowTodoBoard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tsData := sessions.Default(c)
+		userData := tsData.Get("session_data").(model.SessionData)
+
+		user, err := ts.tsDB.SendUserDetails(userData.UserID)
+		if err != nil {
+			log.Println("cannot get user todo data from the database")
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		columns := userBoardColumns(user)
+		arranged := board.Arrange(userTodos(user), columns)
+
+		type boardColumn struct {
+			model.BoardColumn
+			Todos []model.Todo
+			Full  bool
+		}
+		var view []boardColumn
+		for _, col := range columns {
+			todos := arranged[col.Key]
+			view = append(view, boardColumn{
+				BoardColumn: col,
+				Todos:       todos,
+				Full:        col.WIPLimit > 0 && len(todos) >= col.WIPLimit,
+			})
+		}
+		c.HTML(http.StatusOK, "todo-board.html", gin.H{
+			"Columns":   view,
+			"FirstName": user["first_name"],
+			"LastName":  user["last_name"],
+		})
+	}
+}
+
+/*
+UpdateTodoBoard : this changes the columns of the user todo board, their titles
+and work in progress limits. Todos on a removed column move back to the first column
+*/
+func (ts *TrackSpace) UpdateTodoBoard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tsData := sessions.Default(c)
+		userData := tsData.Get("session_data").(model.SessionData)
+
+		if err := c.Request.ParseForm(); err != nil {
+			_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: err})
+			return
+		}
+		keys := c.Request.Form["column-key"]
+		titles := c.Request.Form["column-title"]
+		limits := c.Request.Form["column-wip"]
+		if len(titles) != len(keys) || len(limits) != len(keys) {
+			_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: errors.New("incomplete board columns")})
+			return
+		}
+
+		var columns []model.BoardColumn
+		for i, k := range keys {
+			col := model.BoardColumn{
+				Key:   strings.ToLower(strings.TrimSpace(k)),
+				Title: strings.TrimSpace(titles[i]),
+			}
+			if k == "" && col.Title == "" {
+				// blank row of the form
+				continue
+			}
+			if limits[i] != "" {
+				n, err := strconv.Atoi(limits[i])
+				if err != nil {
+					_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: errors.New("invalid work in progress limit")})
+					return
+				}
+				col.WIPLimit = n
+			}
+			columns = append(columns, col)
+		}
+		if err := board.ValidateColumns(columns); err != nil {
+			_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: err})
+			return
+		}
+
+		err := ts.tsDB.UpdateBoardColumns(userData.UserID, columns)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		c.Redirect(http.StatusSeeOther, "/auth/user/todo-board")
+	}
+}
+
+/*
+MoveTodoOnBoard : this moves a todo to a position of a board column, keeping the
+order of the other todos and the work in progress limit of the column
+*/
+func (ts *TrackSpace) MoveTodoOnBoard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tsData := sessions.Default(c)
+		userData := tsData.Get("session_data").(model.SessionData)
+
+		todoID := c.Param("id")
+		if !primitive.IsValidObjectID(todoID) {
+			_ = c.AbortWithError(http.StatusNotFound, gin.Error{Err: errors.New("invalid ID cannot convert the Object ID")})
+			return
+		}
+		if err := c.Request.ParseForm(); err != nil {
+			_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: err})
+			return
+		}
+		column := c.Request.Form.Get("column")
+		position, err := strconv.Atoi(c.Request.Form.Get("position"))
+		if err != nil {
+			_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: errors.New("invalid board position")})
+			return
+		}
+
+		moved, err := ts.moveOnBoard(userData.UserID, todoID, column, position)
+		switch err {
+		case nil:
+		case board.ErrTodoNotFound:
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		case board.ErrWIPLimit, data.ErrBoardChanged:
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		case board.ErrUnknownColumn:
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		default:
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		ts.notifyChange(userData.UserID, wsmodel.ChangeTodo, wsmodel.ChangeUpdated, todoID)
+		c.JSON(http.StatusOK, gin.H{
+			"todo_id":  todoID,
+			"column":   moved.Column,
+			"position": moved.Position,
+		})
+	}
+}
+
+// boardMoveAttempts : how many times a move is made again on a board changed by another move meanwhile
+const boardMoveAttempts = 3
+
+/*
+moveOnBoard : this moves a todo of the user on the board read from the database and
+returns it where it landed. The move only applies to the version of the board it was
+made on, it is made again on the new board when another move got there first
+*/
+func (ts *TrackSpace) moveOnBoard(userID, todoID, column string, position int) (model.Todo, error) {
+	for attempt := 1; ; attempt++ {
+		user, err := ts.tsDB.SendUserDetails(userID)
+		if err != nil {
+			return model.Todo{}, err
+		}
+		todos := userTodos(user)
+		changed, err := board.Move(todos, userBoardColumns(user), todoID, column, position)
+		if err != nil {
+			return model.Todo{}, err
+		}
+		err = ts.tsDB.MoveTodoOnBoard(userID, userBoardVersion(user), changed)
+		if err == data.ErrBoardChanged && attempt < boardMoveAttempts {
+			continue
+		}
+		if err != nil {
+			return model.Todo{}, err
+		}
+		return movedTodo(todos, changed, todoID), nil
+	}
+}
+
+/*
+movedTodo : this returns the todo moved as the move left it, the todos changed hold it
+unless it was dropped back where it was
+*/
+func movedTodo(todos, changed []model.Todo, todoID string) model.Todo {
+	if todo, ok := findTodo(changed, todoID); ok {
+		return todo
+	}
+	todo, _ := findTodo(todos, todoID)
+	return todo
+}
+
+/*
+ShowTodoSchedule : this will show the selected schedule plans to show all it fulls
+details  and as well make changes to it
+*/
+func (ts *TrackSpace) ShowTodoSchedule() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var todo model.Todo
+		TodoMap := make(map[string]string)
+		sourceLink := c.Param("src")
+		todo.ID = c.Param("id")
+		ok := primitive.IsValidObjectID(c.Param("id"))
+
+		if sourceLink != "todo-table" && !ok {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: errors.New("invalid url parameters")})
+			return
+		}
+
+		TodoData, err := ts.tsDB.GetTodoData(todo.ID)
+		if err != nil {
+			log.Println("cannot get user project data from the database")
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+
+		switch p := TodoData["todo"].(type) {
+		case primitive.A:
+			for _, x := range p {
+				log.Println(len(p))
+				switch k := x.(type) {
+				case primitive.M:
+					for i, j := range k {
+						TodoMap[i] = fmt.Sprint(j)
+					}
+				}
+			}
+		}
+
+		for x, y := range TodoMap {
+			// fmt.Println(x, y)
+			if x == "to_do_task" {
+				todo.ToDoTask = y
+			}
+			if x == "schedule_date" {
+				todo.DateSchedule = y
+			}
+			if x == "start_time" {
+				todo.StartTime = y
+			}
+			if x == "end_time" {
+				todo.EndTime = y
+			}
+			if x == "recurrence" {
+				todo.Recurrence = y
+			}
+		}
+
+		switch p := TodoData["todo"].(type) {
+		case primitive.A:
+			if len(p) > 0 {
+				if k, ok := p[0].(primitive.M); ok {
+					if t, err := decodeTodo(k); err == nil {
+						todo.Checklist = t.Checklist
+						todo.ProjectID = t.ProjectID
+					}
+				}
+			}
+		}
+
+		tsData := sessions.Default(c)
+		userData := tsData.Get("session_data").(model.SessionData)
+		user, err := ts.tsDB.SendUserDetails(userData.UserID)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+
+		// the comments of a todo are only shown to its owner
+		var comments []model.Comment
+		if _, ok := findTodo(userTodos(user), todo.ID); ok {
+			comments, err = ts.tsDB.GetComments(comment.TargetTodo, todo.ID)
+			if err != nil {
+				_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+				return
+			}
+		}
+
+		c.HTML(http.StatusOK, "show-todo.html", gin.H{
+			"TodoID":       todo.ID,
+			"Task":         todo.ToDoTask,
+			"DateSchedule": todo.DateSchedule,
+			"StartTime":    todo.StartTime,
+			"EndTime":      todo.EndTime,
+			"Recurrence":   todo.Recurrence,
+			"Checklist":    todo.Checklist,
+			"Progress":     checklistProgress(todo.Checklist),
+			"ProjectID":    todo.ProjectID,
+			"Projects":     userProjects(user),
+			"Status":       "Done",
+			"Comments":     comment.Thread(comments),
+			"CommentURL":   fmt.Sprintf("/auth/user/todo-table/show-todo/%s/comments", todo.ID),
+			"UserID":       userData.UserID,
+		})
+	}
+}
+
+/*
+ShowTodoOccurrences : this expands every todo of the user, recurring or not, into
+its occurrences between the "from" and "to" dates (yyyy-mm-dd) of the query and
+returns them as json sorted by date and start time
+*/
+func (ts *TrackSpace) ShowTodoOccurrences() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tsData := sessions.Default(c)
+		userData := tsData.Get("session_data").(model.SessionData)
+
+		from, err := time.ParseInLocation("2006-01-02", c.Query("from"), time.Local)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: errors.New("invalid from date")})
+			return
+		}
+		to, err := time.ParseInLocation("2006-01-02", c.Query("to"), time.Local)
+		if err != nil || to.Before(from) {
+			_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: errors.New("invalid to date")})
+			return
+		}
+		// the whole of the last day is part of the range
+		to = to.Add(24*time.Hour - time.Second)
+
+		user, err := ts.tsDB.SendUserDetails(userData.UserID)
+		if err != nil {
+			log.Println("cannot get user todo data from the database")
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+
+		var occurrences []recur.Instance
+		for _, todo := range userTodos(user) {
+			instances, err := recur.Occurrences(todo, from, to)
+			if err != nil {
+				log.Printf("cannot expand todo %s : %v", todo.ID, err)
+				continue
+			}
+			occurrences = append(occurrences, instances...)
+		}
+		sort.Slice(occurrences, func(i, j int) bool {
+			if occurrences[i].Date != occurrences[j].Date {
+				return occurrences[i].Date < occurrences[j].Date
+			}
+			return occurrences[i].StartTime < occurrences[j].StartTime
+		})
+
+		c.JSON(http.StatusOK, gin.H{
+			"from":        from.Format("2006-01-02"),
+			"to":          to.Format("2006-01-02"),
+			"occurrences": occurrences,
+		})
+	}
+}
+
+/*
+ModifyTodoOccurrence : this marks one occurrence of a recurring todo as done or
+skipped, or resets it, without altering the rest of the series
+*/
+func (ts *TrackSpace) ModifyTodoOccurrence() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		todo, ok := ts.userTodo(c)
+		if !ok {
+			return
+		}
+		if err := c.Request.ParseForm(); err != nil {
+			_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: err})
+			return
+		}
+
+		occurrence := model.Occurrence{Date: c.Request.Form.Get("date")}
+		switch c.Request.Form.Get("status") {
+		case "done":
+			occurrence.Status = recur.StatusDone
+		case "skip":
+			occurrence.Status = recur.StatusSkipped
+		case "reset":
+			occurrence.Status = ""
+		default:
+			_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: errors.New("status must be done, skip or reset")})
+			return
+		}
+		day, err := time.ParseInLocation("2006-01-02", occurrence.Date, time.Local)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: errors.New("invalid occurrence date")})
+			return
+		}
+
+		if todo.Recurrence == "" {
+			_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: errors.New("todo is not recurring")})
+			return
+		}
+		rule, err := recur.Parse(todo.Recurrence)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		start, err := recur.StartOf(todo)
+		if err != nil || !rule.Includes(start, day) {
+			_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: errors.New("date is not an occurrence of the todo")})
+			return
+		}
+
+		err = ts.tsDB.SetTodoOccurrence(todo.ID, occurrence)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		ts.notifyTodo(c, wsmodel.ChangeUpdated, todo.ID)
+		c.JSON(http.StatusOK, gin.H{
+			"todo_id": todo.ID,
+			"date":    occurrence.Date,
+			"status":  occurrence.Status,
+		})
+	}
+}
+
+// userTodos : this decodes the todo list of a user document into todo models
+func userTodos(user primitive.M) []model.Todo {
+	var todos []model.Todo
+	switch p := user["todo"].(type) {
+	case primitive.A:
+		for _, x := range p {
+			switch k := x.(type) {
+			case primitive.M:
+				todo, err := decodeTodo(k)
+				if err != nil {
+					log.Printf("cannot decode todo : %v", err)
+					continue
+				}
+				todos = append(todos, todo)
+			}
+		}
+	}
+	return todos
+}
+
+// decodeTodo : this converts a stored todo document into a todo model
+func decodeTodo(doc primitive.M) (model.Todo, error) {
+	var todo model.Todo
+	err := decodeDocument(doc, &todo)
+	return todo, err
+}
+
+// decodeDocument : this converts a stored document into the given model
+func decodeDocument(doc primitive.M, out interface{}) error {
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(raw, out)
+}
+
+// userBoardColumns : this returns the todo board columns of a user document
+func userBoardColumns(user primitive.M) []model.BoardColumn {
+	var settings struct {
+		Board []model.BoardColumn `bson:"board"`
+	}
+	if err := decodeDocument(user, &settings); err != nil {
+		log.Printf("cannot decode todo board : %v", err)
+	}
+	return board.Columns(settings.Board)
+}
+
+// userBoardVersion : this returns the version of the todo board of the user, 0 until its first move
+func userBoardVersion(user primitive.M) int {
+	var settings struct {
+		BoardVersion int `bson:"board_version"`
+	}
+	if err := decodeDocument(user, &settings); err != nil {
+		log.Printf("cannot decode todo board version : %v", err)
+	}
+	return settings.BoardVersion
+}
+
+func findTodo(todos []model.Todo, id string) (model.Todo, bool) {
+	for _, todo := range todos {
+		if todo.ID == id {
+			return todo, true
+		}
+	}
+	return model.Todo{}, false
+}
+
+/*
+AddChecklistItem : this adds a step to the checklist of a todo
+*/
+func (ts *TrackSpace) AddChecklistItem() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		todo, ok := ts.userTodo(c)
+		if !ok {
+			return
+		}
+		if err := c.Request.ParseForm(); err != nil {
+			_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: err})
+			return
+		}
+		item := model.ChecklistItem{
+			ID:   primitive.NewObjectID().Hex(),
+			Text: strings.TrimSpace(c.Request.Form.Get("item")),
+		}
+		if item.Text == "" {
+			_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: errors.New("checklist item cannot be empty")})
+			return
+		}
+
+		err := ts.tsDB.AddChecklistItem(todo.ID, item)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		ts.notifyTodo(c, wsmodel.ChangeUpdated, todo.ID)
+		c.Redirect(http.StatusSeeOther, fmt.Sprintf("/auth/user/todo-table/%s/show-todo", todo.ID))
+	}
+}
+
+/*
+ToggleChecklistItem : this checks or unchecks a step of the checklist of a todo. The
+todo is marked as done once every step of its checklist is checked, and as not done
+again when one of them is unchecked
+*/
+func (ts *TrackSpace) ToggleChecklistItem() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		todo, ok := ts.userTodo(c)
+		if !ok {
+			return
+		}
+		if err := c.Request.ParseForm(); err != nil {
+			_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: err})
+			return
+		}
+		itemID := c.Param("item")
+		done := c.Request.Form.Get("done") == "true"
+
+		found := false
+		for _, item := range todo.Checklist {
+			if item.ID == itemID {
+				found = true
+			}
+		}
+		if !found {
+			_ = c.AbortWithError(http.StatusNotFound, gin.Error{Err: errors.New("checklist item not found")})
+			return
+		}
+
+		err := ts.tsDB.ToggleChecklistItem(todo.ID, itemID, done)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		ts.notifyTodo(c, wsmodel.ChangeUpdated, todo.ID)
+		c.Redirect(http.StatusSeeOther, fmt.Sprintf("/auth/user/todo-table/%s/show-todo", todo.ID))
+	}
+}
+
+/*
+ReorderChecklist : this changes the order of the steps of the checklist of a todo
+to the order of the "item" values of the form
+*/
+func (ts *TrackSpace) ReorderChecklist() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		todo, ok := ts.userTodo(c)
+		if !ok {
+			return
+		}
+		if err := c.Request.ParseForm(); err != nil {
+			_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: err})
+			return
+		}
+
+		err := ts.tsDB.ReorderChecklistItems(todo.ID, checklistOrder(todo.Checklist, c.Request.Form["item"]))
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		ts.notifyTodo(c, wsmodel.ChangeUpdated, todo.ID)
+		c.Redirect(http.StatusSeeOther, fmt.Sprintf("/auth/user/todo-table/%s/show-todo", todo.ID))
+	}
+}
+
+/*
+RemoveChecklistItem : this deletes a step from the checklist of a todo. The todo is
+marked as done once every step left is checked
+*/
+func (ts *TrackSpace) RemoveChecklistItem() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		todo, ok := ts.userTodo(c)
+		if !ok {
+			return
+		}
+
+		err := ts.tsDB.RemoveChecklistItem(todo.ID, c.Param("item"))
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		ts.notifyTodo(c, wsmodel.ChangeUpdated, todo.ID)
+		c.Redirect(http.StatusSeeOther, fmt.Sprintf("/auth/user/todo-table/%s/show-todo", todo.ID))
+	}
+}
+
+/*
+userTodo : this looks up the todo of the ":id" url parameter among the todos of the
+logged-in user, aborting the request when it is not one of them
+*/
+func (ts *TrackSpace) userTodo(c *gin.Context) (model.Todo, bool) {
+	tsData := sessions.Default(c)
+	userData := tsData.Get("session_data").(model.SessionData)
+
+	todoID := c.Param("id")
+	if !primitive.IsValidObjectID(todoID) {
+		_ = c.AbortWithError(http.StatusNotFound, gin.Error{Err: errors.New("invalid ID cannot convert the Object ID")})
+		return model.Todo{}, false
+	}
+	user, err := ts.tsDB.SendUserDetails(userData.UserID)
+	if err != nil {
+		_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+		return model.Todo{}, false
+	}
+	todo, ok := findTodo(userTodos(user), todoID)
+	if !ok {
+		_ = c.AbortWithError(http.StatusNotFound, gin.Error{Err: errors.New("todo not found")})
+		return model.Todo{}, false
+	}
+	return todo, true
+}
+
+// notifyTodo : this tells the live pages of the logged-in user that one of their todos changed
+func (ts *TrackSpace) notifyTodo(c *gin.Context, action, todoID string) {
+	userData := sessions.Default(c).Get("session_data").(model.SessionData)
+	ts.notifyChange(userData.UserID, wsmodel.ChangeTodo, action, todoID)
+}
+
+// checklistProgress : this returns the percentage of checked steps of a checklist
+func checklistProgress(items []model.ChecklistItem) int {
+	if len(items) == 0 {
+		return 0
+	}
+	done := 0
+	for _, item := range items {
+		if item.Done {
+			done++
+		}
+	}
+	return done * 100 / len(items)
+}
+
+/*
+checklistOrder : this returns the ids of the steps of a checklist in the order of the
+given ids. Unknown and repeated ids are skipped, steps missing from the ids keep their
+order at the end
+*/
+func checklistOrder(items []model.ChecklistItem, ids []string) []string {
+	known := make(map[string]bool, len(items))
+	for _, item := range items {
+		known[item.ID] = true
+	}
+	order := make([]string, 0, len(items))
+	added := make(map[string]bool, len(items))
+	for _, id := range ids {
+		if known[id] && !added[id] {
+			order = append(order, id)
+			added[id] = true
+		}
+	}
+	for _, item := range items {
+		if !added[item.ID] {
+			order = append(order, item.ID)
+			added[item.ID] = true
+		}
+	}
+	return order
+}
+
+// decodeProject : this converts a stored project document into a project model
+func decodeProject(doc primitive.M) (model.Project, error) {
+	var project model.Project
+	err := decodeDocument(doc, &project)
+	return project, err
+}
+
+// userProjects : this decodes the project list of a user document into project models
+func userProjects(user primitive.M) []model.Project {
+	var projects struct {
+		ProjectDetails []model.Project `bson:"project_details"`
+	}
+	if err := decodeDocument(user, &projects); err != nil {
+		log.Printf("cannot decode projects : %v", err)
+	}
+	return projects.ProjectDetails
+}
+
+func findProject(projects []model.Project, id string) (model.Project, bool) {
+	for _, project := range projects {
+		if project.ID == id {
+			return project, true
+		}
+	}
+	return model.Project{}, false
+}
+
+/*
+projectTodos : this returns the todos linked to a project together with the
+percentage of them that are done
+*/
+func projectTodos(todos []model.Todo, projectID string) ([]model.Todo, int) {
+	var linked []model.Todo
+	done := 0
+	for _, todo := range todos {
+		if todo.ProjectID != projectID {
+			continue
+		}
+		if todo.Status == "Done" {
+			done++
+		}
+		linked = append(linked, todo)
+	}
+	if len(linked) == 0 {
+		return nil, 0
+	}
+	return linked, done * 100 / len(linked)
+}
+
+/*
+SetTodoProject : this links a todo to one of the user projects or, with an empty
+"project-id", removes it from its project
+*/
+func (ts *TrackSpace) SetTodoProject() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		todo, ok := ts.userTodo(c)
+		if !ok {
+			return
+		}
+		tsData := sessions.Default(c)
+		userData := tsData.Get("session_data").(model.SessionData)
+
+		projectID := c.PostForm("project-id")
+		if projectID != "" {
+			user, err := ts.tsDB.SendUserDetails(userData.UserID)
+			if err != nil {
+				_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+				return
+			}
+			if _, ok := findProject(userProjects(user), projectID); !ok {
+				_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: errors.New("project not found")})
+				return
+			}
+		}
+
+		err := ts.tsDB.SetTodoProject(todo.ID, projectID)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		ts.notifyTodo(c, wsmodel.ChangeUpdated, todo.ID)
+		c.Redirect(http.StatusSeeOther, fmt.Sprintf("/auth/user/todo-table/%s/show-todo", todo.ID))
+	}
+}
+
+/*
+ModifyUserTodo - this method helps to post modified and changes in user projects
+and also update the todo status as well
+*/
+func (ts *TrackSpace) ModifyUserTodo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var todo model.Todo
+		sourceLink := c.Param("src")
+		ok := primitive.IsValidObjectID(c.Param("id"))
+		if sourceLink != "show-todo" && !ok {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: errors.New("invalid url parameters")})
+			return
+		}
+
+		todo.ID = c.Param("id")
+		ok = primitive.IsValidObjectID(todo.ID)
+		if !ok {
+			log.Println("invalid ID cannot convert the Object ID")
+			_ = c.AbortWithError(http.StatusNotFound, gin.Error{Err: errors.New("invalid ID cannot convert the Object ID")})
+		}
+		todo.ToDoTask = c.Request.Form.Get("task")
+		todo.DateSchedule = c.Request.Form.Get("schedule-date")
+		todo.StartTime = c.Request.Form.Get("start-time")
+		todo.EndTime = c.Request.Form.Get("end-time")
+		todo.Status = "Done"
+
+		err := ts.tsDB.ModifyTodoData(todo.ID, todo)
+		if err != nil {
+			log.Println("Error while storing using user project data")
+			return
+		}
+		ts.notifyTodo(c, wsmodel.ChangeUpdated, todo.ID)
+		c.HTML(http.StatusSeeOther, "dash.html", gin.H{
+			"updateTodo": fmt.Sprintf("%s planned schedule changed", todo.ToDoTask),
+		})
+	}
+}
+
+/*
+DeleteTodo : this is to delete selected todo task existing in the database
+*/
+func (ts *TrackSpace) DeleteTodo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var todo model.Todo
+		sourceLink := c.Param("src")
+		ok := primitive.IsValidObjectID(c.Param("id"))
+		if sourceLink != "todo-table" && !ok {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: errors.New("invalid url parameters")})
+			return
+		}
+
+		todo.ID = c.Param("id")
+		ok = primitive.IsValidObjectID(todo.ID)
+		if !ok {
+			log.Println("invalid ID cannot convert the Object ID")
+			_ = c.AbortWithError(http.StatusNotFound, gin.Error{Err: errors.New("project id is invalid")})
+		}
+		err := ts.tsDB.DeleteUserTodo(todo.ID)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		ts.notifyTodo(c, wsmodel.ChangeDeleted, todo.ID)
+		if err := ts.tsDB.DeleteTargetComments(comment.TargetTodo, todo.ID); err != nil {
+			log.Printf("cannot delete the comments of todo %s : %v", todo.ID, err)
+		}
+		c.HTML(http.StatusSeeOther, "dash.html", gin.H{
+			"deleteTodo": fmt.Sprintf(" %s schedule plan delete successfully. Go back to dashboard", todo.ID),
+		})
+	}
+}
+
+// commentTarget : the project or the todo comments are posted on
+type commentTarget struct {
+	Type    string
+	ID      string
+	OwnerID string
+	Name    string
+	// Link : page of the project or the todo, where its comments are shown
+	Link string
+	// viewers : the users who can open the page of the project or the todo
+	viewers map[string]bool
+}
+
+/*
+projectCommentTarget : this returns the project of the ":id" url parameter as a comment
+target. Everyone the project is shared with reads and writes its comments
+*/
+func (ts *TrackSpace) projectCommentTarget(c *gin.Context) (commentTarget, bool) {
+	project, owner, _, ok := ts.projectAccess(c)
+	if !ok {
+		return commentTarget{}, false
+	}
+	ownerID, _ := owner["_id"].(string)
+	target := commentTarget{
+		Type:    comment.TargetProject,
+		ID:      project.ID,
+		OwnerID: ownerID,
+		Name:    project.ProjectName,
+		Link:    fmt.Sprintf("/auth/user/project-table/%s/show-project", project.ID),
+		viewers: map[string]bool{ownerID: true},
+	}
+	for _, share := range project.Shares {
+		target.viewers[share.UserID] = true
+	}
+	return target, true
+}
+
+// todoCommentTarget : this returns the todo of the ":id" url parameter of the logged-in user as a comment target
+func (ts *TrackSpace) todoCommentTarget(c *gin.Context) (commentTarget, bool) {
+	todo, ok := ts.userTodo(c)
+	if !ok {
+		return commentTarget{}, false
+	}
+	userID := sessions.Default(c).Get("session_data").(model.SessionData).UserID
+	return commentTarget{
+		Type:    comment.TargetTodo,
+		ID:      todo.ID,
+		OwnerID: userID,
+		Name:    todo.ToDoTask,
+		Link:    fmt.Sprintf("/auth/user/todo-table/%s/show-todo", todo.ID),
+		viewers: map[string]bool{userID: true},
+	}, true
+}
+
+// AddProjectComment : this posts a comment, or a reply to the "parent-id" comment, on a project
+func (ts *TrackSpace) AddProjectComment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if target, ok := ts.projectCommentTarget(c); ok {
+			ts.addComment(c, target)
+		}
+	}
+}
+
+// ModifyProjectComment : this changes a comment of the logged-in user on a project
+func (ts *TrackSpace) ModifyProjectComment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if target, ok := ts.projectCommentTarget(c); ok {
+			ts.modifyComment(c, target)
+		}
+	}
+}
+
+// DeleteProjectComment : this deletes a comment of the logged-in user on a project
+func (ts *TrackSpace) DeleteProjectComment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if target, ok := ts.projectCommentTarget(c); ok {
+			ts.deleteComment(c, target)
+		}
+	}
+}
+
+// AddTodoComment : this posts a comment, or a reply to the "parent-id" comment, on a todo
+func (ts *TrackSpace) AddTodoComment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if target, ok := ts.todoCommentTarget(c); ok {
+			ts.addComment(c, target)
+		}
+	}
+}
+
+// ModifyTodoComment : this changes a comment of the logged-in user on a todo
+func (ts *TrackSpace) ModifyTodoComment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if target, ok := ts.todoCommentTarget(c); ok {
+			ts.modifyComment(c, target)
+		}
+	}
+}
+
+// DeleteTodoComment : this deletes a comment of the logged-in user on a todo
+func (ts *TrackSpace) DeleteTodoComment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if target, ok := ts.todoCommentTarget(c); ok {
+			ts.deleteComment(c, target)
+		}
+	}
+}
+
+func (ts *TrackSpace) addComment(c *gin.Context, target commentTarget) {
+	content, err := comment.ParseContent(c.PostForm("comment"))
+	if err != nil {
+		_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: err})
+		return
+	}
+	parentID := c.PostForm("parent-id")
+	if parentID != "" {
+		parent, err := ts.tsDB.GetComment(parentID)
+		if err != nil && err != mongo.ErrNoDocuments {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		if parent.TargetType != target.Type || parent.TargetID != target.ID {
+			_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: comment.ErrNotFound})
+			return
+		}
+	}
+	user, ok := ts.sessionUser(c)
+	if !ok {
+		return
+	}
+
+	now := time.Now().Format("2006-01-02 15:04")
+	cm := model.Comment{
+		ID:         primitive.NewObjectID().Hex(),
+		TargetType: target.Type,
+		TargetID:   target.ID,
+		OwnerID:    target.OwnerID,
+		ParentID:   parentID,
+		AuthorID:   user["_id"].(string),
+		AuthorName: displayName(user),
+		Content:    content,
+		Mentions:   comment.Mentions(content),
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := ts.tsDB.StoreComment(cm); err != nil {
+		_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+		return
+	}
+	ts.notifyMentions(c, target, cm, cm.Mentions)
+	c.Redirect(http.StatusSeeOther, fmt.Sprintf("%s#comment-%s", target.Link, cm.ID))
+}
+
+func (ts *TrackSpace) modifyComment(c *gin.Context, target commentTarget) {
+	stored, ok := ts.authoredComment(c, target)
+	if !ok {
+		return
+	}
+	content, err := comment.ParseContent(c.PostForm("comment"))
+	if err != nil {
+		_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: err})
+		return
+	}
+	mentions := comment.Mentions(content)
+	err = ts.tsDB.ModifyComment(stored.ID, stored.AuthorID, content, mentions, time.Now().Format("2006-01-02 15:04"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if err == mongo.ErrNoDocuments {
+			status = http.StatusNotFound
+			err = comment.ErrNotFound
+		}
+		_ = c.AbortWithError(status, gin.Error{Err: err})
+		return
+	}
+	// only the users mentioned by the edit are notified, the others already were
+	stored.Content = content
+	ts.notifyMentions(c, target, stored, comment.NewMentions(stored.Mentions, mentions))
+	c.Redirect(http.StatusSeeOther, fmt.Sprintf("%s#comment-%s", target.Link, stored.ID))
+}
+
+func (ts *TrackSpace) deleteComment(c *gin.Context, target commentTarget) {
+	stored, ok := ts.authoredComment(c, target)
+	if !ok {
+		return
+	}
+	if err := ts.tsDB.DeleteComment(stored.ID, stored.AuthorID); err != nil && err != mongo.ErrNoDocuments {
+		_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+		return
+	}
+	c.Redirect(http.StatusSeeOther, target.Link)
+}
+
+/*
+authoredComment : this loads the comment of the ":comment" url parameter, which only
+its author can change or delete
+*/
+func (ts *TrackSpace) authoredComment(c *gin.Context, target commentTarget) (model.Comment, bool) {
+	userID := sessions.Default(c).Get("session_data").(model.SessionData).UserID
+	cm, err := ts.tsDB.GetComment(c.Param("comment"))
+	if err != nil && err != mongo.ErrNoDocuments {
+		_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+		return model.Comment{}, false
+	}
+	if cm.TargetType != target.Type || cm.TargetID != target.ID || cm.Deleted {
+		_ = c.AbortWithError(http.StatusNotFound, gin.Error{Err: comment.ErrNotFound})
+		return model.Comment{}, false
+	}
+	if cm.AuthorID != userID {
+		_ = c.AbortWithError(http.StatusForbidden, gin.Error{Err: comment.ErrNotAuthor})
+		return model.Comment{}, false
+	}
+	return cm, true
+}
+
+/*
+notifyMentions : this sends an in-app notification and an email to every user
+mentioned in a comment who can open the project or the todo. The others are not told
+about the comment, its excerpt would show them content they cannot see
+*/
+func (ts *TrackSpace) notifyMentions(c *gin.Context, target commentTarget, cm model.Comment, emails []string) {
+	var notifications []model.Notification
+	for _, email := range emails {
+		mentioned, err := ts.tsDB.GetUserByEmail(email)
+		if err != nil {
+			continue
+		}
+		mentionedID, _ := mentioned["_id"].(string)
+		notification, ok := mentionNotification(target, cm, mentionedID)
+		if !ok {
+			continue
+		}
+		notifications = append(notifications, notification)
+
+		message := fmt.Sprintf(`
+			<strong>New Mention</strong><br>
+			Hi, %s:<br>
+			<p>%s mentioned you in a comment on the %s %s</p>
+			<blockquote>%s</blockquote>
+			<p><a href="%s%s">Open the %s</a></p>
+			`, html.EscapeString(displayName(mentioned)), html.EscapeString(cm.AuthorName), target.Type,
+			html.EscapeString(target.Name), html.EscapeString(comment.Excerpt(cm.Content, 500)),
+			baseURL(c), notification.Link, target.Type)
+		ts.AppConfig.MailChan <- model.Email{
+			Subject:  fmt.Sprintf("%s mentioned you on track-space", cm.AuthorName),
+			Content:  message,
+			Sender:   "official.trackspace@gmail.com",
+			Receiver: email,
+			Template: "email.html",
+		}
+	}
+	// the comment is stored already, a failing notification is not worth an error page
+	if err := ts.tsDB.StoreNotifications(notifications); err != nil {
+		log.Printf("cannot store mention notifications : %v", err)
+	}
+}
+
+/*
+mentionNotification : this returns the notification of a user mentioned in a comment,
+false for the author of the comment and for the users who cannot open its target
+*/
+func mentionNotification(target commentTarget, cm model.Comment, mentionedID string) (model.Notification, bool) {
+	if mentionedID == "" || mentionedID == cm.AuthorID || !target.viewers[mentionedID] {
+		return model.Notification{}, false
+	}
+	return model.Notification{
+		ID:        primitive.NewObjectID().Hex(),
+		UserID:    mentionedID,
+		Kind:      "mention",
+		Message:   fmt.Sprintf("%s mentioned you on the %s %s: %s", cm.AuthorName, target.Type, target.Name, comment.Excerpt(cm.Content, 140)),
+		Link:      fmt.Sprintf("%s#comment-%s", target.Link, cm.ID),
+		CreatedAt: time.Now().Format("2006-01-02 15:04"),
+	}, true
+}
+
+// maxNotifications : most notifications listed on the notifications page
+const maxNotifications = 50
+
+// ShowNotifications : this lists the latest notifications of the logged-in user
+func (ts *TrackSpace) ShowNotifications() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userData := sessions.Default(c).Get("session_data").(model.SessionData)
+		notifications, err := ts.tsDB.GetNotifications(userData.UserID, maxNotifications)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		c.HTML(http.StatusOK, "notifications.html", gin.H{
+			"Notifications": notifications,
+		})
+	}
+}
+
+/*
+ReadNotifications : this marks the "notification-id" notification of the logged-in
+user as read and opens its link, or marks them all as read without it
+*/
+func (ts *TrackSpace) ReadNotifications() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userData := sessions.Default(c).Get("session_data").(model.SessionData)
+		notificationID := c.PostForm("notification-id")
+		if notificationID == "" {
+			if err := ts.tsDB.MarkNotificationsRead(userData.UserID); err != nil {
+				_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+				return
+			}
+			c.Redirect(http.StatusSeeOther, "/auth/user/notifications")
+			return
+		}
+
+		notifications, err := ts.tsDB.GetNotifications(userData.UserID, maxNotifications)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		link := "/auth/user/notifications"
+		for _, notification := range notifications {
+			if notification.ID == notificationID && notification.Link != "" {
+				link = notification.Link
+			}
+		}
+		if err := ts.tsDB.MarkNotificationsRead(userData.UserID, notificationID); err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		c.Redirect(http.StatusSeeOther, link)
+	}
+}
+
+/*
+ShowTeams : this lists the teams of the logged-in user and the team invitations sent
+to the email of the user
+*/
+func (ts *TrackSpace) ShowTeams() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := ts.sessionUser(c)
+		if !ok {
+			return
+		}
+		teams, err := ts.tsDB.GetUserTeams(user["_id"].(string))
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		invites, err := ts.pendingInvites(user)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		c.HTML(http.StatusOK, "teams.html", gin.H{
+			"Teams":      teams,
+			"Invites":    invites,
+			"ActiveTeam": sessions.Default(c).Get("team_id"),
+			"UserID":     user["_id"],
+		})
+	}
+}
+
+// pendingInvite : an invitation to join a team, as listed to the invited user
+type pendingInvite struct {
+	TeamID   string
+	TeamName string
+	model.TeamInvite
+}
+
+// pendingInvites : this returns the team invitations sent to the email of a user
+func (ts *TrackSpace) pendingInvites(user primitive.M) ([]pendingInvite, error) {
+	email, _ := user["email"].(string)
+	if email == "" {
+		return nil, nil
+	}
+	teams, err := ts.tsDB.GetTeamInvites(email)
+	if err != nil {
+		return nil, err
+	}
+	var invites []pendingInvite
+	for _, tm := range teams {
+		for _, invite := range tm.Invites {
+			if team.SameEmail(invite.Email, email) {
+				invites = append(invites, pendingInvite{TeamID: tm.ID, TeamName: tm.Name, TeamInvite: invite})
+			}
+		}
+	}
+	return invites, nil
+}
+
+// CreateTeam : this creates a team owned by the logged-in user
+func (ts *TrackSpace) CreateTeam() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := ts.sessionUser(c)
+		if !ok {
+			return
+		}
+		name, err := team.ParseName(c.PostForm("team-name"))
+		if err != nil {
+			_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: err})
+			return
+		}
+		tm := model.Team{
+			ID:        primitive.NewObjectID().Hex(),
+			Name:      name,
+			Members:   []model.TeamMember{teamMember(user, team.RoleOwner)},
+			CreatedAt: time.Now().Format("2006-01-02"),
+		}
+		if err := ts.tsDB.CreateTeam(tm); err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		c.Redirect(http.StatusSeeOther, fmt.Sprintf("/auth/user/teams/%s", tm.ID))
+	}
+}
+
+// teamMember : this returns the membership of a user joining a team with the given role
+func teamMember(user primitive.M, role string) model.TeamMember {
+	member := model.TeamMember{Role: role, JoinedAt: time.Now().Format("2006-01-02")}
+	member.UserID, _ = user["_id"].(string)
+	member.Email, _ = user["email"].(string)
+	member.FirstName, _ = user["first_name"].(string)
+	member.LastName, _ = user["last_name"].(string)
+	return member
+}
+
+// ShowTeam : this shows the members, projects and todos of a team of the logged-in user
+func (ts *TrackSpace) ShowTeam() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tm, member, ok := ts.userTeam(c)
+		if !ok {
+			return
+		}
+		// only the members who can invite see the pending invitations and their links
+		var invites []model.TeamInvite
+		if team.CanManage(member.Role) {
+			invites = tm.Invites
+		}
+		c.HTML(http.StatusOK, "team.html", gin.H{
+			"Team":      tm,
+			"Projects":  tm.ProjectDetails,
+			"Todos":     tm.Todo,
+			"Members":   tm.Members,
+			"Invites":   invites,
+			"Role":      member.Role,
+			"UserID":    member.UserID,
+			"CanManage": team.CanManage(member.Role),
+			"IsOwner":   member.Role == team.RoleOwner,
+			"Active":    sessions.Default(c).Get("team_id") == tm.ID,
+		})
+	}
+}
+
+/*
+InviteTeamMember : this invites an email to join a team and mails the invitation with
+links to accept or decline it. Only owners and admins invite, and only owners invite
+other owners
+*/
+func (ts *TrackSpace) InviteTeamMember() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tm, member, ok := ts.userTeam(c)
+		if !ok {
+			return
+		}
+		role, err := team.ParseRole(c.PostForm("invite-role"))
+		if err != nil {
+			_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: err})
+			return
+		}
+		if !team.CanManage(member.Role) || !team.CanSetRole(member.Role, team.RoleMember, role) {
+			_ = c.AbortWithError(http.StatusForbidden, gin.Error{Err: team.ErrForbidden})
+			return
+		}
+		email := strings.TrimSpace(c.PostForm("invite-email"))
+		if err := ts.AppConfig.Validator.Var(email, "required,email"); err != nil {
+			_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: fmt.Errorf("%q is not a valid email", email)})
+			return
+		}
+		for _, m := range tm.Members {
+			if team.SameEmail(m.Email, email) {
+				_ = c.AbortWithError(http.StatusConflict, gin.Error{Err: team.ErrAlreadyMember})
+				return
+			}
+		}
+
+		invite := model.TeamInvite{
+			Token:     key.GenerateToken(),
+			Email:     email,
+			Role:      role,
+			InvitedBy: strings.TrimSpace(member.FirstName + " " + member.LastName),
+			CreatedAt: time.Now().Format("2006-01-02"),
+		}
+		if err := ts.tsDB.AddTeamInvite(tm.ID, invite); err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+
+		inviteURL := fmt.Sprintf("%s/auth/user/team-invites/%s", baseURL(c), invite.Token)
+		message := fmt.Sprintf(`
+			<strong>Team Invitation</strong><br>
+			Hi,<br>
+			<p>%s invited you to join the team %s on track-space as %s.
+			Log in with this email to see the projects and todos of the team.</p>
+			<p><a href="%s/accept">Accept the invitation</a> | <a href="%s/decline">Decline</a></p>
+			`, html.EscapeString(invite.InvitedBy), html.EscapeString(tm.Name), role, inviteURL, inviteURL)
+		ts.AppConfig.MailChan <- model.Email{
+			Subject:  fmt.Sprintf("Invitation to join %s", tm.Name),
+			Content:  message,
+			Sender:   "official.trackspace@gmail.com",
+			Receiver: email,
+			Template: "email.html",
+		}
+		c.Redirect(http.StatusSeeOther, fmt.Sprintf("/auth/user/teams/%s", tm.ID))
+	}
+}
+
+// RevokeTeamInvite : this cancels the pending invitation of the ":token" url parameter
+func (ts *TrackSpace) RevokeTeamInvite() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tm, member, ok := ts.userTeam(c)
+		if !ok {
+			return
+		}
+		if !team.CanManage(member.Role) {
+			_ = c.AbortWithError(http.StatusForbidden, gin.Error{Err: team.ErrForbidden})
+			return
+		}
+		if err := ts.tsDB.RemoveTeamInvite(tm.ID, c.Param("token")); err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		c.Redirect(http.StatusSeeOther, fmt.Sprintf("/auth/user/teams/%s", tm.ID))
+	}
+}
+
+/*
+TeamInvite : this asks the logged-in user to confirm the answer to a team invitation
+opened from the invitation email, so that following the link alone changes nothing
+*/
+func (ts *TrackSpace) TeamInvite() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tm, invite, _, ok := ts.userInvite(c)
+		if !ok {
+			return
+		}
+		c.HTML(http.StatusOK, "team-invite.html", gin.H{
+			"TeamName":  tm.Name,
+			"Invite":    invite,
+			"Answer":    c.Param("answer"),
+			"AnswerURL": fmt.Sprintf("/auth/user/team-invites/%s/%s", invite.Token, c.Param("answer")),
+		})
+	}
+}
+
+// AnswerTeamInvite : this accepts or declines a team invitation sent to the logged-in user
+func (ts *TrackSpace) AnswerTeamInvite() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tm, invite, user, ok := ts.userInvite(c)
+		if !ok {
+			return
+		}
+		if c.Param("answer") == "decline" {
+			if err := ts.tsDB.RemoveTeamInvite(tm.ID, invite.Token); err != nil {
+				_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+				return
+			}
+			c.Redirect(http.StatusSeeOther, "/auth/user/teams")
+			return
+		}
+
+		member := teamMember(user, invite.Role)
+		err := ts.tsDB.AcceptTeamInvite(tm.ID, invite.Token, member)
+		if err == mongo.ErrNoDocuments {
+			// the user joined in the meantime, the invitation is no longer needed
+			err = ts.tsDB.RemoveTeamInvite(tm.ID, invite.Token)
+		}
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		c.Redirect(http.StatusSeeOther, fmt.Sprintf("/auth/user/teams/%s", tm.ID))
+	}
+}
+
+/*
+userInvite : this loads the invitation of the ":token" url parameter, which only the
+user it was sent to can answer with "accept" or "decline"
+*/
+func (ts *TrackSpace) userInvite(c *gin.Context) (model.Team, model.TeamInvite, primitive.M, bool) {
+	if answer := c.Param("answer"); answer != "accept" && answer != "decline" {
+		_ = c.AbortWithError(http.StatusNotFound, gin.Error{Err: fmt.Errorf("unknown answer %q", answer)})
+		return model.Team{}, model.TeamInvite{}, nil, false
+	}
+	user, ok := ts.sessionUser(c)
+	if !ok {
+		return model.Team{}, model.TeamInvite{}, nil, false
+	}
+	tm, err := ts.tsDB.GetTeamByInvite(c.Param("token"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if err == mongo.ErrNoDocuments {
+			status = http.StatusNotFound
+			err = errors.New("this invitation was answered or revoked")
+		}
+		_ = c.AbortWithError(status, gin.Error{Err: err})
+		return model.Team{}, model.TeamInvite{}, nil, false
+	}
+	invite, found := team.InviteFor(tm, c.Param("token"))
+	email, _ := user["email"].(string)
+	if !found || !team.SameEmail(invite.Email, email) {
+		_ = c.AbortWithError(http.StatusForbidden, gin.Error{Err: team.ErrInviteEmail})
+		return model.Team{}, model.TeamInvite{}, nil, false
+	}
+	return tm, invite, user, true
+}
+
+/*
+SetTeamMemberRole : this changes the role of the member of the ":member" url parameter.
+Admins handle members and admins, owners handle everyone, and the last owner stays
+*/
+func (ts *TrackSpace) SetTeamMemberRole() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tm, actor, ok := ts.userTeam(c)
+		if !ok {
+			return
+		}
+		target, found := team.Member(tm, c.Param("member"))
+		if !found {
+			_ = c.AbortWithError(http.StatusNotFound, gin.Error{Err: errors.New("member not found")})
+			return
+		}
+		role, err := team.ParseRole(c.PostForm("member-role"))
+		if err != nil {
+			_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: err})
+			return
+		}
+		if !team.CanSetRole(actor.Role, target.Role, role) {
+			_ = c.AbortWithError(http.StatusForbidden, gin.Error{Err: team.ErrForbidden})
+			return
+		}
+		if !team.KeepsOwner(tm, target.UserID, role) {
+			_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: team.ErrLastOwner})
+			return
+		}
+		if err := ts.tsDB.SetTeamMemberRole(tm.ID, target.UserID, role); err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		c.Redirect(http.StatusSeeOther, fmt.Sprintf("/auth/user/teams/%s", tm.ID))
+	}
+}
+
+/*
+RemoveTeamMember : this removes the member of the ":member" url parameter from a team.
+Any member can leave a team, as long as the team keeps an owner
+*/
+func (ts *TrackSpace) RemoveTeamMember() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tm, actor, ok := ts.userTeam(c)
+		if !ok {
+			return
+		}
+		target, found := team.Member(tm, c.Param("member"))
+		if !found {
+			_ = c.AbortWithError(http.StatusNotFound, gin.Error{Err: errors.New("member not found")})
+			return
+		}
+		leaving := target.UserID == actor.UserID
+		if !leaving && !team.CanSetRole(actor.Role, target.Role, target.Role) {
+			_ = c.AbortWithError(http.StatusForbidden, gin.Error{Err: team.ErrForbidden})
+			return
+		}
+		if !team.KeepsOwner(tm, target.UserID, "") {
+			_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: team.ErrLastOwner})
+			return
+		}
+		if err := ts.tsDB.RemoveTeamMember(tm.ID, target.UserID); err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		if !leaving {
+			c.Redirect(http.StatusSeeOther, fmt.Sprintf("/auth/user/teams/%s", tm.ID))
+			return
+		}
+		tsData := sessions.Default(c)
+		if tsData.Get("team_id") == tm.ID {
+			tsData.Delete("team_id")
+			if err := tsData.Save(); err != nil {
+				log.Println("error from the session storage")
+			}
+		}
+		c.Redirect(http.StatusSeeOther, "/auth/user/teams")
+	}
+}
+
+/*
+SwitchTeam : this switches the dashboard of the logged-in user to the workspace of the
+"team-id" team, or back to the personal workspace when it is empty
+*/
+func (ts *TrackSpace) SwitchTeam() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tsData := sessions.Default(c)
+		userData := tsData.Get("session_data").(model.SessionData)
+		teamID := c.PostForm("team-id")
+		if teamID == "" {
+			tsData.Delete("team_id")
+		} else {
+			tm, err := ts.tsDB.GetTeam(teamID)
+			if err != nil && err != mongo.ErrNoDocuments {
+				_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+				return
+			}
+			if _, ok := team.Member(tm, userData.UserID); !ok {
+				_ = c.AbortWithError(http.StatusNotFound, gin.Error{Err: team.ErrNotMember})
+				return
+			}
+			tsData.Set("team_id", tm.ID)
+		}
+		if err := tsData.Save(); err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		c.Redirect(http.StatusSeeOther, "/auth/user/dashboard")
+	}
+}
+
+// CreateTeamProject : this adds a project to a team of the logged-in user
+func (ts *TrackSpace) CreateTeamProject() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tm, _, ok := ts.userTeam(c)
+		if !ok {
+			return
+		}
+		var project model.Project
+		project.ID = primitive.NewObjectID().Hex()
+		project.ProjectName = strings.ToLower(c.PostForm("project-name"))
+		project.ToolsUseAs = strings.ToLower(c.PostForm("project-tool-use"))
+		project.ProjectContent = c.PostForm("myText")
+		project.ContentFormat = render.FormatMarkdown
+		project.Status = "unmodified"
+		project.CreatedAt = time.Now().Format("2006-01-02")
+		project.UpdatedAt = time.Now().Format("2006-01-02")
+		project.Tags = []string{}
+
+		// Server side validation of the user input from a form
+		if err := ts.AppConfig.Validator.Struct(&project); err != nil {
+			if _, ok := err.(*validator.InvalidValidationError); !ok {
+				_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: err})
+				return
+			}
+		}
+		if err := ts.tsDB.StoreTeamProject(tm.ID, project); err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		c.Redirect(http.StatusSeeOther, fmt.Sprintf("/auth/user/teams/%s/projects/%s", tm.ID, project.ID))
+	}
+}
+
+// ShowTeamProject : this shows a project of a team to any of its members
+func (ts *TrackSpace) ShowTeamProject() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tm, member, project, ok := ts.teamProject(c)
+		if !ok {
+			return
+		}
+		rendered, err := render.Content(project.ProjectContent, project.ContentFormat, project.ToolsUseAs == "code")
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		c.HTML(http.StatusOK, "team-project.html", gin.H{
+			"Team":            tm,
+			"projectID":       project.ID,
+			"projectName":     project.ProjectName,
+			"projectContent":  project.ProjectContent,
+			"renderedContent": rendered,
+			"toolsUseAs":      project.ToolsUseAs,
+			"updatedAt":       project.UpdatedAt,
+			"CanManage":       team.CanManage(member.Role),
+		})
+	}
+}
+
+// ModifyTeamProject : this stores the changes a member makes to a project of a team
+func (ts *TrackSpace) ModifyTeamProject() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tm, _, stored, ok := ts.teamProject(c)
+		if !ok {
+			return
+		}
+		project := stored
+		project.ProjectName = strings.ToLower(c.PostForm("project-name"))
+		project.ToolsUseAs = strings.ToLower(c.PostForm("project-tool-use"))
+		project.ProjectContent = c.PostForm("myText")
+		project.ContentFormat = render.FormatMarkdown
+		project.Status = "modified"
+		project.UpdatedAt = time.Now().Format("2006-01-02")
+
+		if err := ts.AppConfig.Validator.Struct(&project); err != nil {
+			if _, ok := err.(*validator.InvalidValidationError); !ok {
+				_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: err})
+				return
+			}
+		}
+		if err := ts.tsDB.ModifyTeamProject(tm.ID, project); err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		c.Redirect(http.StatusSeeOther, fmt.Sprintf("/auth/user/teams/%s/projects/%s", tm.ID, project.ID))
+	}
+}
+
+// DeleteTeamProject : this removes a project of a team, which only its owners and admins can do
+func (ts *TrackSpace) DeleteTeamProject() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tm, member, project, ok := ts.teamProject(c)
+		if !ok {
+			return
+		}
+		if !team.CanManage(member.Role) {
+			_ = c.AbortWithError(http.StatusForbidden, gin.Error{Err: team.ErrForbidden})
+			return
+		}
+		if err := ts.tsDB.DeleteTeamProject(tm.ID, project.ID); err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		c.Redirect(http.StatusSeeOther, fmt.Sprintf("/auth/user/teams/%s", tm.ID))
+	}
+}
+
+// CreateTeamTodo : this adds a todo to the schedule of a team of the logged-in user
+func (ts *TrackSpace) CreateTeamTodo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tm, _, ok := ts.userTeam(c)
 		if !ok {
-			log.Println("invalid ID cannot convert the Object ID")
-			_ = c.AbortWithError(http.StatusNotFound, gin.Error{Err: errors.New("invalid ID cannot convert the Object ID")})
+			return
 		}
-		todo.ToDoTask = c.Request.Form.Get("task")
-		todo.DateSchedule = c.Request.Form.Get("schedule-date")
-		todo.StartTime = c.Request.Form.Get("start-time")
-		todo.EndTime = c.Request.Form.Get("end-time")
-		todo.Status = "Done"
-
-		err := ts.tsDB.ModifyTodoData(todo.ID, todo)
-		if err != nil {
-			log.Println("Error while storing using user project data")
+		var todo model.Todo
+		todo.ID = primitive.NewObjectID().Hex()
+		todo.ToDoTask = strings.TrimSpace(c.PostForm("task"))
+		todo.DateSchedule = c.PostForm("schedule-date")
+		todo.StartTime = c.PostForm("start-time")
+		todo.EndTime = c.PostForm("end-time")
+		todo.Status = "Not done"
+		if todo.ToDoTask == "" {
+			_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: errors.New("a todo needs a task")})
 			return
 		}
-		c.HTML(http.StatusSeeOther, "dash.html", gin.H{
-			"updateTodo": fmt.Sprintf("%s planned schedule changed", todo.ToDoTask),
-		})
+		if err := ts.tsDB.StoreTeamTodo(tm.ID, todo); err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		c.Redirect(http.StatusSeeOther, fmt.Sprintf("/auth/user/teams/%s", tm.ID))
 	}
 }
 
-/*
-DeleteTodo : this is to delete selected todo task existing in the database
-*/
-func (ts *TrackSpace) DeleteTodo() gin.HandlerFunc {
+// SetTeamTodoStatus : this marks a todo of a team as "Done" or "Not done"
+func (ts *TrackSpace) SetTeamTodoStatus() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var todo model.Todo
-		sourceLink := c.Param("src")
-		ok := primitive.IsValidObjectID(c.Param("id"))
-		if sourceLink != "todo-table" && !ok {
-			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: errors.New("invalid url parameters")})
+		tm, _, ok := ts.userTeam(c)
+		if !ok {
+			return
+		}
+		status := c.PostForm("status")
+		if status != "Done" && status != "Not done" {
+			_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: fmt.Errorf("unknown todo status %q", status)})
+			return
+		}
+		if _, found := findTodo(tm.Todo, c.Param("id")); !found {
+			_ = c.AbortWithError(http.StatusNotFound, gin.Error{Err: errors.New("todo not found")})
+			return
+		}
+		if err := ts.tsDB.SetTeamTodoStatus(tm.ID, c.Param("id"), status); err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
 			return
 		}
+		c.Redirect(http.StatusSeeOther, fmt.Sprintf("/auth/user/teams/%s", tm.ID))
+	}
+}
 
-		todo.ID = c.Param("id")
-		ok = primitive.IsValidObjectID(todo.ID)
+// DeleteTeamTodo : this removes a todo from the schedule of a team
+func (ts *TrackSpace) DeleteTeamTodo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tm, _, ok := ts.userTeam(c)
 		if !ok {
-			log.Println("invalid ID cannot convert the Object ID")
-			_ = c.AbortWithError(http.StatusNotFound, gin.Error{Err: errors.New("project id is invalid")})
+			return
 		}
-		err := ts.tsDB.DeleteUserTodo(todo.ID)
-		if err != nil {
+		if err := ts.tsDB.DeleteTeamTodo(tm.ID, c.Param("id")); err != nil {
 			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
 			return
 		}
-		c.HTML(http.StatusSeeOther, "dash.html", gin.H{
-			"deleteTodo": fmt.Sprintf(" %s schedule plan delete successfully. Go back to dashboard", todo.ID),
-		})
+		c.Redirect(http.StatusSeeOther, fmt.Sprintf("/auth/user/teams/%s", tm.ID))
+	}
+}
+
+/*
+userTeam : this loads the team of the ":team" url parameter with the membership of the
+logged-in user. Teams the user is not a member of are reported as not found
+*/
+func (ts *TrackSpace) userTeam(c *gin.Context) (model.Team, model.TeamMember, bool) {
+	userID := sessions.Default(c).Get("session_data").(model.SessionData).UserID
+	tm, err := ts.tsDB.GetTeam(c.Param("team"))
+	if err != nil && err != mongo.ErrNoDocuments {
+		_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+		return model.Team{}, model.TeamMember{}, false
 	}
+	member, ok := team.Member(tm, userID)
+	if !ok {
+		_ = c.AbortWithError(http.StatusNotFound, gin.Error{Err: team.ErrNotMember})
+		return model.Team{}, model.TeamMember{}, false
+	}
+	return tm, member, true
+}
+
+// teamProject : this loads the project of the ":id" url parameter of a team of the logged-in user
+func (ts *TrackSpace) teamProject(c *gin.Context) (model.Team, model.TeamMember, model.Project, bool) {
+	tm, member, ok := ts.userTeam(c)
+	if !ok {
+		return model.Team{}, model.TeamMember{}, model.Project{}, false
+	}
+	project, found := findProject(tm.ProjectDetails, c.Param("id"))
+	if !found {
+		_ = c.AbortWithError(http.StatusNotFound, gin.Error{Err: errors.New("project not found")})
+		return model.Team{}, model.TeamMember{}, model.Project{}, false
+	}
+	return tm, member, project, true
+}
+
+// sessionUser : this loads the document of the logged-in user
+func (ts *TrackSpace) sessionUser(c *gin.Context) (primitive.M, bool) {
+	userID := sessions.Default(c).Get("session_data").(model.SessionData).UserID
+	user, err := ts.tsDB.SendUserDetails(userID)
+	if err != nil {
+		_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+		return nil, false
+	}
+	return user, true
 }
 
 // ExecuteLogOut - to log out user from the dashboard
@@ -1148,9 +3913,14 @@ func (ts *TrackSpace) AdminPage() gin.HandlerFunc {
 
 		_ = ioutil.WriteFile("./static/json/stat.json", statData, 0o644)
 
-		c.HTML(http.StatusOK, "admin.html", gin.H{
+		page := gin.H{
 			"tsAdmin": tsUser,
-		})
+		}
+		// the moderation of the chat is only shown to the admin
+		if ts.isAdmin(c.GetString("_id")) && !ts.chatModeration(c, page) {
+			return
+		}
+		c.HTML(http.StatusOK, "admin.html", page)
 	}
 }
 
@@ -1200,7 +3970,32 @@ ChatRoom : this load up the page for user to experience a simple real time commu
 */
 func (ts *TrackSpace) ChatRoom() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.HTML(http.StatusOK, "chat.html", gin.H{})
+		// the page only shows the name and rooms, the connection to the chat room checks the login
+		var identity ws.Identity
+		var rooms []chatRoomEntry
+		if c.GetString("_id") != "" {
+			var ok bool
+			identity, ok = ts.chatIdentity(c)
+			if !ok {
+				return
+			}
+			rooms, ok = ts.chatRooms(c, identity.UserID)
+			if !ok {
+				return
+			}
+		}
+		var token string
+		if identity.UserID != "" {
+			token = chatToken(c)
+		}
+		c.HTML(http.StatusOK, "chat.html", gin.H{
+			"ChatName":   identity.Name,
+			"ChatUserID": identity.UserID,
+			"ChatAdmin":  identity.Admin,
+			"ChatToken":  token,
+			"Rooms":      rooms,
+			"Room":       c.Query("room"),
+		})
 	}
 }
 
@@ -1211,22 +4006,481 @@ share
 */
 func (ts *TrackSpace) ChatRoomEndpoint() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		var res wsmodel.SocketResponse
+		identity, ok := ts.chatIdentity(ctx)
+		if !ok {
+			return
+		}
+		// the token of the chat page proves the upgrade is not sent by another site with the session cookie
+		if !validChatToken(ctx) {
+			log.Printf("chat websocket rejected for user %s from %s : invalid chat token", identity.UserID, ctx.ClientIP())
+			_ = ctx.AbortWithError(http.StatusForbidden, gin.Error{Err: errors.New("invalid chat token")})
+			return
+		}
 		wsConn, err := ws.UpgradeSocketConn.Upgrade(ctx.Writer, ctx.Request, nil)
 		if err != nil {
-			log.Fatal("Unable to connect to socket")
+			// the upgrader already answered the request with the error
+			log.Printf("Unable to connect to socket : %v", err)
+			return
+		}
+		ts.AppConfig.Chat.Serve(wsconfig.NewSocketConnection(wsConn), identity)
+	}
+}
+
+// maxChatMessages : the largest page of the chat history a user can ask for
+const maxChatMessages = 100
+
+/*
+ChatHistory : this returns a page of the messages of the "room" sent before the
+"before" cursor, oldest first, with the cursor of the next older page
+*/
+func (ts *TrackSpace) ChatHistory() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identity, ok := ts.chatIdentity(c)
+		if !ok {
+			return
+		}
+		room := c.DefaultQuery("room", ws.DefaultRoom)
+		if err := ts.CanJoinRoom(identity.UserID, room); err != nil {
+			status := http.StatusInternalServerError
+			if err == ws.ErrUnknownRoom || err == ws.ErrNoRoomAccess {
+				status = http.StatusNotFound
+			}
+			c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		before, err := ws.ParseCursor(c.Query("before"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		limit := int64(ws.HistorySize)
+		if raw := c.Query("limit"); raw != "" {
+			limit, err = strconv.ParseInt(raw, 10, 64)
+			if err != nil || limit <= 0 {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+				return
+			}
+			if limit > maxChatMessages {
+				limit = maxChatMessages
+			}
+		}
+
+		messages, err := ts.tsDB.GetChatMessages(room, before.SentAt, before.ID, limit)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		// a short page is the start of the history
+		next := ""
+		if int64(len(messages)) == limit {
+			next = ws.CursorOf(messages[0]).String()
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"messages": messages,
+			"next":     next,
+		})
+	}
+}
+
+// chatToken : this returns the chat token of the session, created on the first visit of a page opening a websocket
+func chatToken(c *gin.Context) string {
+	session := sessions.Default(c)
+	token, _ := session.Get("chat_token").(string)
+	if token == "" {
+		token = key.GenerateToken()
+		session.Set("chat_token", token)
+		_ = session.Save()
+	}
+	return token
+}
+
+// validChatToken : this reports whether the "token" query is the chat token of the session
+func validChatToken(c *gin.Context) bool {
+	expected, _ := sessions.Default(c).Get("chat_token").(string)
+	return expected != "" && subtle.ConstantTimeCompare([]byte(expected), []byte(c.Query("token"))) == 1
+}
+
+/*
+chatIdentity : this returns the chat identity of the user of the JWT claims set by
+IsAuthorized, named after the first and last name of the profile. The admin logged in
+moderates the chat
+*/
+func (ts *TrackSpace) chatIdentity(c *gin.Context) (ws.Identity, bool) {
+	userID := c.GetString("_id")
+	if userID == "" {
+		_ = c.AbortWithError(http.StatusUnauthorized, gin.Error{Err: errors.New("the chat room is only open to logged-in users")})
+		return ws.Identity{}, false
+	}
+	user, err := ts.tsDB.SendUserDetails(userID)
+	if err == mongo.ErrNoDocuments && ts.isAdmin(userID) {
+		// the admin has no profile, it moderates the chat under the name of the application
+		return ws.Identity{UserID: userID, Name: "Track-space admin", Admin: true}, true
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		if err == mongo.ErrNoDocuments {
+			status = http.StatusUnauthorized
+		}
+		_ = c.AbortWithError(status, gin.Error{Err: err})
+		return ws.Identity{}, false
+	}
+	return ws.Identity{UserID: userID, Name: displayName(user)}, true
+}
+
+/*
+CanJoinRoom : this reports whether a user may read and send messages in a chat room:
+created rooms when public or invited to, teams the user is a member of, projects the
+user can view and direct messages with a registered user
+*/
+func (ts *TrackSpace) CanJoinRoom(userID, room string) error {
+	kind, key, err := ws.ParseRoom(room)
+	if err != nil {
+		return err
+	}
+	switch kind {
+	case ws.RoomCreated:
+		chatRoom, err := ts.tsDB.GetChatRoom(key)
+		if err == mongo.ErrNoDocuments {
+			return ws.ErrUnknownRoom
+		}
+		if err != nil {
+			return err
+		}
+		if !ws.RoomMember(chatRoom, userID) {
+			return ws.ErrNoRoomAccess
+		}
+	case ws.RoomTeam:
+		tm, err := ts.tsDB.GetTeam(key)
+		if err == mongo.ErrNoDocuments {
+			return ws.ErrUnknownRoom
+		}
+		if err != nil {
+			return err
+		}
+		if _, ok := team.Member(tm, userID); !ok {
+			return ws.ErrNoRoomAccess
+		}
+	case ws.RoomProject:
+		owner, err := ts.tsDB.GetProjectOwner(key)
+		if err == mongo.ErrNoDocuments {
+			return ws.ErrUnknownRoom
+		}
+		if err != nil {
+			return err
+		}
+		project, ok := findProject(userProjects(owner), key)
+		ownerID, _ := owner["_id"].(string)
+		if !ok || !access.CanView(access.RoleOf(project, ownerID, userID)) {
+			return ws.ErrNoRoomAccess
+		}
+	case ws.RoomDirect:
+		a, b, _ := ws.Participants(room)
+		other := a
+		if other == userID {
+			other = b
+		} else if b != userID {
+			return ws.ErrNoRoomAccess
+		}
+		if _, err := ts.tsDB.SendUserDetails(other); err != nil {
+			if err == mongo.ErrNoDocuments {
+				return ws.ErrUnknownRoom
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// chatRoomEntry : a room listed on the chat page
+type chatRoomEntry struct {
+	ID      string
+	Name    string
+	Kind    string
+	Private bool
+	// Room : the created room, for its owner to invite members
+	Room  model.ChatRoom
+	Owned bool
+}
+
+/*
+chatRooms : this lists the rooms a user can join: the general room, the created rooms
+open to the user, the teams of the user and the projects owned by or shared with the user
+*/
+func (ts *TrackSpace) chatRooms(c *gin.Context, userID string) ([]chatRoomEntry, bool) {
+	rooms := []chatRoomEntry{{ID: ws.DefaultRoom, Name: "General", Kind: ws.RoomGeneral}}
+
+	created, err := ts.tsDB.GetChatRooms(userID)
+	if err != nil {
+		_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+		return nil, false
+	}
+	for _, room := range created {
+		rooms = append(rooms, chatRoomEntry{
+			ID:      ws.ChatRoom(room.ID),
+			Name:    room.Name,
+			Kind:    ws.RoomCreated,
+			Private: room.Private,
+			Room:    room,
+			Owned:   room.OwnerID == userID,
+		})
+	}
+
+	teams, err := ts.tsDB.GetUserTeams(userID)
+	if err != nil {
+		_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+		return nil, false
+	}
+	for _, tm := range teams {
+		rooms = append(rooms, chatRoomEntry{ID: ws.TeamRoom(tm.ID), Name: tm.Name, Kind: ws.RoomTeam, Private: true})
+	}
+
+	user, err := ts.tsDB.SendUserDetails(userID)
+	if err != nil {
+		_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+		return nil, false
+	}
+	for _, project := range userProjects(user) {
+		rooms = append(rooms, chatRoomEntry{ID: ws.ProjectRoom(project.ID), Name: project.ProjectName, Kind: ws.RoomProject, Private: true})
+	}
+	shared, err := ts.tsDB.GetSharedProjects(userID)
+	if err != nil {
+		_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+		return nil, false
+	}
+	for _, sp := range shared {
+		rooms = append(rooms, chatRoomEntry{ID: ws.ProjectRoom(sp.Project.ID), Name: sp.Project.ProjectName, Kind: ws.RoomProject, Private: true})
+	}
+	return rooms, true
+}
+
+// CreateChatRoom : this creates a chat room open to every user, or only to the members invited when private
+func (ts *TrackSpace) CreateChatRoom() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := sessions.Default(c).Get("session_data").(model.SessionData).UserID
+		name, err := ws.ParseRoomName(c.PostForm("room-name"))
+		if err != nil {
+			_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: err})
+			return
+		}
+		room := model.ChatRoom{
+			ID:        primitive.NewObjectID().Hex(),
+			Name:      name,
+			Private:   c.PostForm("room-private") == "on",
+			OwnerID:   userID,
+			Members:   []string{userID},
+			CreatedAt: time.Now().Format("2006-01-02"),
+		}
+		if err := ts.tsDB.CreateChatRoom(room); err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		c.Redirect(http.StatusSeeOther, "/auth/user/chat?room="+url.QueryEscape(ws.ChatRoom(room.ID)))
+	}
+}
+
+// ownedChatRoom : this loads the chat room of the ":room" url parameter created by the logged-in user
+func (ts *TrackSpace) ownedChatRoom(c *gin.Context) (model.ChatRoom, bool) {
+	userID := sessions.Default(c).Get("session_data").(model.SessionData).UserID
+	room, err := ts.tsDB.GetChatRoom(c.Param("room"))
+	if err != nil && err != mongo.ErrNoDocuments {
+		_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+		return model.ChatRoom{}, false
+	}
+	if err == mongo.ErrNoDocuments || room.OwnerID != userID {
+		_ = c.AbortWithError(http.StatusNotFound, gin.Error{Err: ws.ErrUnknownRoom})
+		return model.ChatRoom{}, false
+	}
+	return room, true
+}
+
+/*
+InviteChatRoomMember : this lets the owner of a chat room add a registered user to its
+members, the user is notified with a link to the room
+*/
+func (ts *TrackSpace) InviteChatRoomMember() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		room, ok := ts.ownedChatRoom(c)
+		if !ok {
+			return
+		}
+		owner, ok := ts.sessionUser(c)
+		if !ok {
+			return
+		}
+		email := strings.TrimSpace(c.PostForm("member-email"))
+		invited, err := ts.tsDB.GetUserByEmail(email)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if err == mongo.ErrNoDocuments {
+				status = http.StatusNotFound
+				err = fmt.Errorf("no track-space user is registered with %s", email)
+			}
+			_ = c.AbortWithError(status, gin.Error{Err: err})
+			return
+		}
+		invitedID, _ := invited["_id"].(string)
+		if err := ts.tsDB.AddChatRoomMember(room.ID, invitedID); err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+
+		if invitedID != room.OwnerID {
+			notification := model.Notification{
+				ID:        primitive.NewObjectID().Hex(),
+				UserID:    invitedID,
+				Kind:      "chat-room",
+				Message:   fmt.Sprintf("%s added you to the chat room %s", displayName(owner), room.Name),
+				Link:      "/auth/user/chat?room=" + url.QueryEscape(ws.ChatRoom(room.ID)),
+				CreatedAt: time.Now().Format("2006-01-02 15:04"),
+			}
+			// the member is added already, a failing notification is not worth an error page
+			if err := ts.tsDB.StoreNotifications([]model.Notification{notification}); err != nil {
+				log.Printf("cannot store chat room notification : %v", err)
+			}
+		}
+		c.Redirect(http.StatusSeeOther, "/auth/user/chat?room="+url.QueryEscape(ws.ChatRoom(room.ID)))
+	}
+}
+
+/*
+RemoveChatRoomMember : this removes a member of a chat room, done by the owner of the
+room or by a member leaving it. The owner cannot leave the room
+*/
+func (ts *TrackSpace) RemoveChatRoomMember() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := sessions.Default(c).Get("session_data").(model.SessionData).UserID
+		room, err := ts.tsDB.GetChatRoom(c.Param("room"))
+		if err != nil {
+			status := http.StatusInternalServerError
+			if err == mongo.ErrNoDocuments {
+				status = http.StatusNotFound
+				err = ws.ErrUnknownRoom
+			}
+			_ = c.AbortWithError(status, gin.Error{Err: err})
+			return
+		}
+		member := c.Param("member")
+		if member == room.OwnerID || (userID != room.OwnerID && userID != member) {
+			_ = c.AbortWithError(http.StatusForbidden, gin.Error{Err: ws.ErrNoRoomAccess})
 			return
 		}
+		if err := ts.tsDB.RemoveChatRoomMember(room.ID, member); err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+			return
+		}
+		c.Redirect(http.StatusSeeOther, "/auth/user/chat")
+	}
+}
+
+// chatAuditSize, chatModerationMessages : the last moderations and messages listed on the admin page
+const (
+	chatAuditSize          = 50
+	chatModerationMessages = 20
+)
+
+// isAdmin : this reports whether the id of the JWT claims is the one of an admin, as set on the admin login
+func (ts *TrackSpace) isAdmin(userID string) bool {
+	if userID == "" {
+		return false
+	}
+	admins, err := ts.tsDB.GetAdminInfo()
+	if err != nil {
+		return false
+	}
+	for _, admin := range admins {
+		if fmt.Sprint(admin["_id"]) == userID {
+			return true
+		}
+	}
+	return false
+}
 
-		res.Message = `<p>chatroom handler upgrade</p>`
+// adminIdentity : this returns the chat identity of the logged-in admin, other users are forbidden
+func (ts *TrackSpace) adminIdentity(c *gin.Context) (ws.Identity, bool) {
+	identity, ok := ts.chatIdentity(c)
+	if !ok {
+		return ws.Identity{}, false
+	}
+	if !identity.Admin {
+		_ = c.AbortWithError(http.StatusForbidden, gin.Error{Err: ws.ErrNotAdmin})
+		return ws.Identity{}, false
+	}
+	return identity, true
+}
 
-		connect := wsconfig.SocketConnection{Conn: wsConn}
-		ws.Client[connect] = ""
+/*
+chatModeration : this adds the chat section of the admin page to page: the last messages
+of the "chat_room" query, the sanctions running, the word filter and the audit
+*/
+func (ts *TrackSpace) chatModeration(c *gin.Context, page gin.H) bool {
+	room := strings.TrimSpace(c.Query("chat_room"))
+	if room == "" {
+		room = ws.DefaultRoom
+	}
+	messages, err := ts.tsDB.GetChatMessages(room, time.Time{}, "", chatModerationMessages)
+	if err != nil {
+		_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+		return false
+	}
+	sanctions, err := ts.tsDB.GetChatSanctions(time.Now())
+	if err != nil {
+		_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+		return false
+	}
+	words, err := ts.tsDB.GetChatWordFilter()
+	if err != nil {
+		_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+		return false
+	}
+	audit, err := ts.tsDB.GetChatAudit(chatAuditSize)
+	if err != nil {
+		_ = c.AbortWithError(http.StatusInternalServerError, gin.Error{Err: err})
+		return false
+	}
+	page["ChatRoom"] = room
+	page["ChatMessages"] = messages
+	page["ChatSanctions"] = sanctions
+	page["ChatWords"] = strings.Join(words, "\n")
+	page["ChatAudit"] = audit
+	return true
+}
 
-		err = wsConn.WriteJSON(res.Message)
+/*
+ModerateChat : this lets an admin delete a message, mute, kick or ban a user, lift a
+sanction or set the word filter from the admin page, as over the chat socket
+*/
+func (ts *TrackSpace) ModerateChat() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		admin, ok := ts.adminIdentity(c)
+		if !ok {
+			return
+		}
+		duration, err := ws.ParseDuration(c.PostForm("duration"))
 		if err != nil {
+			_ = c.AbortWithError(http.StatusBadRequest, gin.Error{Err: err})
+			return
+		}
+		m := ws.Moderation{
+			Action:    c.PostForm("action"),
+			Admin:     admin,
+			UserID:    strings.TrimSpace(c.PostForm("user")),
+			Room:      strings.TrimSpace(c.PostForm("room")),
+			MessageID: c.PostForm("message"),
+			Duration:  duration,
+			Reason:    strings.TrimSpace(c.PostForm("reason")),
+			Sanction:  c.PostForm("sanction"),
+		}
+		if m.Action == ws.ModFilter {
+			m.Words = ws.ParseWords(c.PostForm("words"))
+		}
+		if err := ts.AppConfig.Chat.Moderate(m); err != nil {
+			status := http.StatusBadRequest
+			if err == ws.ErrChatStopped {
+				status = http.StatusServiceUnavailable
+			}
+			_ = c.AbortWithError(status, gin.Error{Err: err})
 			return
 		}
-		go ws.SendDataToChannel(&connect)
+		c.Redirect(http.StatusSeeOther, "/auth/admin?chat_room="+url.QueryEscape(m.Room)+"#chat")
 	}
 }
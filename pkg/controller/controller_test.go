@@ -3,7 +3,9 @@ package controller
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/yusuf/track-space/pkg/board"
 	"github.com/yusuf/track-space/pkg/config"
+	"github.com/yusuf/track-space/pkg/model"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -331,3 +333,130 @@ func TestTrackSpace_ChatRoom(t *testing.T) {
 		})
 	}
 }
+
+func Test_projectTodos(t *testing.T) {
+	todos := []model.Todo{
+		{ID: "a", ProjectID: "p1", Status: "Done"},
+		{ID: "b", ProjectID: "p1", Status: "Not done"},
+		{ID: "c", ProjectID: "p1", Status: "Not done"},
+		{ID: "d", ProjectID: "p2", Status: "Done"},
+		{ID: "e", Status: "Done"},
+	}
+	tests := []struct {
+		name      string
+		projectID string
+		wantCount int
+		wantDone  int
+	}{
+		{"partly-done", "p1", 3, 33},
+		{"all-done", "p2", 1, 100},
+		{"no-todos", "p3", 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			linked, progress := projectTodos(todos, tt.projectID)
+			assert.Equal(t, tt.wantCount, len(linked))
+			assert.Equal(t, tt.wantDone, progress)
+		})
+	}
+}
+
+func Test_movedTodo(t *testing.T) {
+	columns := []model.BoardColumn{{Key: "backlog", Title: "Backlog"}, {Key: "done", Title: "Done"}}
+	todos := []model.Todo{
+		{ID: "a", Column: "backlog", Position: 0, Status: "Not done"},
+		{ID: "b", Column: "backlog", Position: 1, Status: "Not done"},
+		{ID: "c", Column: "done", Position: 0, Status: "Done"},
+	}
+	tests := []struct {
+		name         string
+		todoID       string
+		column       string
+		position     int
+		wantColumn   string
+		wantPosition int
+	}{
+		{"clamped-to-the-end", "a", "done", 10, "done", 1},
+		{"to-the-top", "b", "backlog", 0, "backlog", 0},
+		{"dropped-in-place", "a", "backlog", 0, "backlog", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed, err := board.Move(todos, columns, tt.todoID, tt.column, tt.position)
+			assert.NoError(t, err)
+			moved := movedTodo(todos, changed, tt.todoID)
+			assert.Equal(t, tt.wantColumn, moved.Column)
+			assert.Equal(t, tt.wantPosition, moved.Position)
+		})
+	}
+}
+
+func Test_checklistProgress(t *testing.T) {
+	tests := []struct {
+		name  string
+		items []model.ChecklistItem
+		want  int
+	}{
+		{"empty", nil, 0},
+		{"none-checked", []model.ChecklistItem{{ID: "a"}, {ID: "b"}}, 0},
+		{"partly-checked", []model.ChecklistItem{{ID: "a", Done: true}, {ID: "b"}, {ID: "c"}}, 33},
+		{"all-checked", []model.ChecklistItem{{ID: "a", Done: true}, {ID: "b", Done: true}}, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, checklistProgress(tt.items))
+		})
+	}
+}
+
+func Test_checklistOrder(t *testing.T) {
+	items := []model.ChecklistItem{{ID: "a"}, {ID: "b"}, {ID: "c"}}
+	tests := []struct {
+		name string
+		ids  []string
+		want []string
+	}{
+		{"reversed", []string{"c", "b", "a"}, []string{"c", "b", "a"}},
+		{"duplicate-ids", []string{"b", "b", "a", "b"}, []string{"b", "a", "c"}},
+		{"unknown-ids", []string{"x", "c", "y"}, []string{"c", "a", "b"}},
+		{"missing-items-appended", []string{"b"}, []string{"b", "a", "c"}},
+		{"no-ids", nil, []string{"a", "b", "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, checklistOrder(items, tt.ids))
+		})
+	}
+}
+
+func Test_mentionNotification(t *testing.T) {
+	target := commentTarget{
+		Type:    "todo",
+		Name:    "salary review",
+		Link:    "/auth/user/todo-table/t1/show-todo",
+		viewers: map[string]bool{"owner": true, "viewer": true},
+	}
+	cm := model.Comment{ID: "c1", AuthorID: "owner", AuthorName: "Ada", Content: "private numbers for @viewer"}
+	tests := []struct {
+		name        string
+		mentionedID string
+		wantOK      bool
+	}{
+		{"viewer", "viewer", true},
+		{"non-viewer", "stranger", false},
+		{"author", "owner", false},
+		{"unknown-user", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notification, ok := mentionNotification(target, cm, tt.mentionedID)
+			assert.Equal(t, tt.wantOK, ok)
+			if !ok {
+				assert.Equal(t, model.Notification{}, notification)
+				return
+			}
+			assert.Equal(t, tt.mentionedID, notification.UserID)
+			assert.Equal(t, "/auth/user/todo-table/t1/show-todo#comment-c1", notification.Link)
+		})
+	}
+}
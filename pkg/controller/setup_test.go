@@ -37,8 +37,9 @@ func TrackSpaceSetUp() *gin.Engine {
 	log.Println("Application starting mail server listening to channel")
 	// Listening to the localhost mail server
 
-	//Listening to PayLoad from the websocket
-	go ws.GetDataFromChannel()
+	//Serving the clients of the chat room
+	appConfig.Chat = ws.NewHub(nil, nil)
+	go appConfig.Chat.Run()
 
 	appRouter := gin.New()
 	err := appRouter.SetTrustedProxies([]string{"127.0.0.1"})
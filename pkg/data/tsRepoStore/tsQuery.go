@@ -2,9 +2,14 @@ package tsRepoStore
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"regexp"
+	"strings"
 	"time"
 
+	"github.com/yusuf/track-space/pkg/blob"
+	"github.com/yusuf/track-space/pkg/data"
 	"github.com/yusuf/track-space/pkg/key"
 	"github.com/yusuf/track-space/pkg/model"
 	"go.mongodb.org/mongo-driver/bson"
@@ -173,6 +178,117 @@ func (tm *TsMongoDBRepo) SendUserDetails(id string) (primitive.M, error) {
 	return user, nil
 }
 
+/*
+SetCalendarToken : this stores the secret token of the user iCalendar feed url,
+replacing any previous token so old feed urls stop working
+*/
+func (tm *TsMongoDBRepo) SetCalendarToken(id, token string) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	filter := bson.D{{Key: "_id", Value: id}}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "calendar_token", Value: token}}}}
+
+	_, err := UserData(tm.TsMongoDB, "user").UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Printf("Error from SetCalendarToken : %v", err)
+		return err
+	}
+	return nil
+}
+
+/*
+GetUserByCalendarToken : this fetch the user that owns the iCalendar feed token
+*/
+func (tm *TsMongoDBRepo) GetUserByCalendarToken(token string) (primitive.M, error) {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	var user bson.M
+	filter := bson.D{{Key: "calendar_token", Value: token}}
+	err := UserData(tm.TsMongoDB, "user").FindOne(ctx, filter).Decode(&user)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			log.Printf("Error from GetUserByCalendarToken : %v", err)
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+/*
+UpdateReminderSettings : this stores how many minutes before a todo starts the user
+wants a reminder mail (0 turns reminders off) and whether to get a daily agenda
+*/
+func (tm *TsMongoDBRepo) UpdateReminderSettings(id string, lead int, dailyAgenda bool) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	filter := bson.D{{Key: "_id", Value: id}}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "reminder_lead", Value: lead},
+		{Key: "daily_agenda", Value: dailyAgenda},
+	}}}
+
+	_, err := UserData(tm.TsMongoDB, "user").UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Printf("Error from UpdateReminderSettings : %v", err)
+		return err
+	}
+	return nil
+}
+
+/*
+MarkAgendaSent : this records the date the daily agenda mail was last sent to the user
+*/
+func (tm *TsMongoDBRepo) MarkAgendaSent(id, date string) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	filter := bson.D{{Key: "_id", Value: id}}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "agenda_sent_on", Value: date}}}}
+
+	_, err := UserData(tm.TsMongoDB, "user").UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Printf("Error from MarkAgendaSent : %v", err)
+		return err
+	}
+	return nil
+}
+
+/*
+GetReminderUsers : this returns the users with reminder mails or the daily agenda
+turned on, with only their contact, reminder settings and todos
+*/
+func (tm *TsMongoDBRepo) GetReminderUsers() ([]primitive.M, error) {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	filter := bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "reminder_lead", Value: bson.D{{Key: "$gt", Value: 0}}}},
+		bson.D{{Key: "daily_agenda", Value: true}},
+	}}}
+	opt := options.Find().SetProjection(bson.D{
+		{Key: "email", Value: 1},
+		{Key: "first_name", Value: 1},
+		{Key: "reminder_lead", Value: 1},
+		{Key: "daily_agenda", Value: 1},
+		{Key: "agenda_sent_on", Value: 1},
+		{Key: "todo", Value: 1},
+	})
+	var documents []primitive.M
+	cursor, err := UserData(tm.TsMongoDB, "user").Find(ctx, filter, opt)
+	if err != nil {
+		log.Printf("Error from GetReminderUsers : %v", err)
+		return nil, err
+	}
+	if err = cursor.All(ctx, &documents); err != nil {
+		log.Printf("Error from GetReminderUsers : %v", err)
+		return nil, err
+	}
+	return documents, nil
+}
+
 /*
 StoreProjectData : this method help the user to store the created project and all it
 content on the workspace to the database
@@ -189,6 +305,12 @@ func (tm *TsMongoDBRepo) StoreProjectData(id string, project model.Project) erro
 			{Key: "project_name", Value: project.ProjectName},
 			{Key: "tools_use_as", Value: project.ToolsUseAs},
 			{Key: "project_content", Value: project.ProjectContent},
+			{Key: "content_format", Value: project.ContentFormat},
+			{Key: "tags", Value: storedTags(project.Tags)},
+			{Key: "folder", Value: project.Folder},
+			{Key: "pinned", Value: project.Pinned},
+			{Key: "attachments", Value: bson.A{}},
+			{Key: "shares", Value: bson.A{}},
 			{Key: "created_at", Value: project.CreatedAt},
 			{Key: "updated_at", Value: project.UpdatedAt},
 			{Key: "status", Value: project.Status},
@@ -240,6 +362,9 @@ func (tm *TsMongoDBRepo) ModifyProjectData(userId, id string, project model.Proj
 		{Key: "project_details.$.project_name", Value: project.ProjectName},
 		{Key: "project_details.$.tools_use_as", Value: project.ToolsUseAs},
 		{Key: "project_details.$.project_content", Value: project.ProjectContent},
+		{Key: "project_details.$.content_format", Value: project.ContentFormat},
+		{Key: "project_details.$.tags", Value: storedTags(project.Tags)},
+		{Key: "project_details.$.folder", Value: project.Folder},
 		{Key: "project_details.$.updated_at", Value: project.UpdatedAt},
 		{Key: "project_details.$.status", Value: project.Status},
 	}}}
@@ -251,212 +376,1904 @@ func (tm *TsMongoDBRepo) ModifyProjectData(userId, id string, project model.Proj
 	return nil
 }
 
+// storedTags : this returns the tags of a project to store, never null so they can be added to and pulled from
+func storedTags(tags []string) []string {
+	if tags == nil {
+		return []string{}
+	}
+	return tags
+}
+
 /*
-DeleteUserProject : this method will delete a select project by the user
+AddProjectAttachment : this records a file attached to a project and adds its size to
+the storage used by the user, in one update that only applies while the user stays
+within the quota
 */
-func (tm *TsMongoDBRepo) DeleteUserProject(projectId string) error {
+func (tm *TsMongoDBRepo) AddProjectAttachment(userId, projectId string, attachment model.Attachment, quota int64) error {
 	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancelCtx()
 
-	filter := bson.D{{Key: "project_details._id", Value: projectId}}
-	update := bson.D{{Key: "$pull", Value: bson.D{
-		{Key: "project_details", Value: bson.D{{Key: "_id", Value: projectId}}},
-	}}}
-	opt := options.Update().SetUpsert(false)
-	_, err := UserData(tm.TsMongoDB, "user").UpdateOne(ctx, filter, update, opt)
+	filter := bson.D{
+		{Key: "_id", Value: userId},
+		{Key: "project_details._id", Value: projectId},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "storage_used", Value: bson.D{{Key: "$exists", Value: false}}}},
+			bson.D{{Key: "storage_used", Value: bson.D{{Key: "$lte", Value: quota - attachment.Size}}}},
+		}},
+	}
+	update := bson.D{
+		{Key: "$push", Value: bson.D{{Key: "project_details.$.attachments", Value: attachment}}},
+		{Key: "$inc", Value: bson.D{{Key: "storage_used", Value: attachment.Size}}},
+	}
+	result, err := UserData(tm.TsMongoDB, "user").UpdateOne(ctx, filter, update)
 	if err != nil {
-		log.Fatalf("Error from DeleteUserProject : %v", err)
+		log.Printf("Error from AddProjectAttachment : %v", err)
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return blob.ErrQuotaExceeded
 	}
 	return nil
 }
 
 /*
-StoreTodoData : this method help the user to store the create todo schedule and all it
-set duration and date as well in the to the database
+RemoveProjectAttachment : this removes a file from a project and gives its size back
+to the storage quota of the user
 */
-func (tm *TsMongoDBRepo) StoreTodoData(todo model.Todo, id string) error {
+func (tm *TsMongoDBRepo) RemoveProjectAttachment(userId, projectId string, attachment model.Attachment) error {
 	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancelCtx()
-	filter := bson.D{{Key: "_id", Value: id}}
-	// update := bson.D{{Key: "$push", Value: bson.D{{Key: "todo", Value: todo}}}}
-	update := bson.D{{Key: "$push", Value: bson.D{
-		{Key: "todo", Value: bson.D{
-			{Key: "_id", Value: todo.ID},
-			{Key: "to_do_task", Value: todo.ToDoTask},
-			{Key: "schedule_date", Value: todo.DateSchedule},
-			{Key: "start_time", Value: todo.StartTime},
-			{Key: "end_time", Value: todo.EndTime},
-			{Key: "status", Value: todo.Status},
-		}},
-	}}}
 
+	filter := bson.D{
+		{Key: "_id", Value: userId},
+		{Key: "project_details", Value: bson.D{{Key: "$elemMatch", Value: bson.D{
+			{Key: "_id", Value: projectId},
+			{Key: "attachments._id", Value: attachment.ID},
+		}}}},
+	}
+	update := bson.D{
+		{Key: "$pull", Value: bson.D{{Key: "project_details.$.attachments", Value: bson.D{{Key: "_id", Value: attachment.ID}}}}},
+		{Key: "$inc", Value: bson.D{{Key: "storage_used", Value: -attachment.Size}}},
+	}
 	_, err := UserData(tm.TsMongoDB, "user").UpdateOne(ctx, filter, update)
 	if err != nil {
-		log.Fatal("cannot add document to the database")
+		log.Printf("Error from RemoveProjectAttachment : %v", err)
 		return err
 	}
 	return nil
 }
 
 /*
-GetTodoData : this method fetch one particular created schedule stored by a
-particular user in the database to check or make some modification to the
-date/time of the schedule
+GetProjectOwner : this fetch the owner of a project together with that project
+alone, the owner credentials and other data are left out
 */
-func (tm *TsMongoDBRepo) GetTodoData(todoId string) (primitive.M, error) {
+func (tm *TsMongoDBRepo) GetProjectOwner(projectId string) (primitive.M, error) {
 	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancelCtx()
-	filter := bson.D{{Key: "todo._id", Value: todoId}}
+
+	filter := bson.D{{Key: "project_details._id", Value: projectId}}
 	opt := options.FindOne().SetProjection(bson.D{
-		{Key: "_id", Value: todoId},
-		{Key: "todo", Value: bson.D{{Key: "$elemMatch", Value: bson.D{{Key: "_id", Value: todoId}}}}},
+		{Key: "first_name", Value: 1},
+		{Key: "last_name", Value: 1},
+		{Key: "email", Value: 1},
+		{Key: "project_details", Value: bson.D{{Key: "$elemMatch", Value: bson.D{{Key: "_id", Value: projectId}}}}},
 	})
 
-	var data bson.M
-	err := UserData(tm.TsMongoDB, "user").FindOne(ctx, filter, opt).Decode(&data)
+	var owner bson.M
+	err := UserData(tm.TsMongoDB, "user").FindOne(ctx, filter, opt).Decode(&owner)
 	if err != nil {
-		log.Panic(err)
+		if err != mongo.ErrNoDocuments {
+			log.Printf("Error from GetProjectOwner : %v", err)
+		}
+		return nil, err
 	}
-	return data, nil
+	return owner, nil
 }
 
 /*
-ModifyTodoData : this method is to keep track of the changes made by the
-user on a previous set schedule by updating it in the database
+GetUserByEmail : this fetch the id and name of the user registered with an email
 */
-func (tm *TsMongoDBRepo) ModifyTodoData(id string, todo model.Todo) error {
+func (tm *TsMongoDBRepo) GetUserByEmail(email string) (primitive.M, error) {
 	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancelCtx()
-	filter := bson.D{{Key: "todo._id", Value: id}}
-	// update := bson.D{{Key: "$set", Value: bson.D{{Key: "todo", Value: todo}}}}
-	update := bson.D{{Key: "$set", Value: bson.D{
-		{Key: "todo.$._id", Value: todo.ID},
-		{Key: "todo.$.to_do_task", Value: todo.ToDoTask},
-		{Key: "todo.$.date_schedule", Value: todo.DateSchedule},
-		{Key: "todo.$.start_time", Value: todo.StartTime},
-		{Key: "todo.$.end_time", Value: todo.EndTime},
-		{Key: "todo.$.status", Value: todo.Status},
-	}}}
-	_, err := UserData(tm.TsMongoDB, "user").UpdateOne(ctx, filter, update)
+
+	filter := bson.D{{Key: "email", Value: email}}
+	opt := options.FindOne().SetProjection(bson.D{
+		{Key: "first_name", Value: 1},
+		{Key: "last_name", Value: 1},
+		{Key: "email", Value: 1},
+	})
+
+	var user bson.M
+	err := UserData(tm.TsMongoDB, "user").FindOne(ctx, filter, opt).Decode(&user)
 	if err != nil {
-		log.Fatalf("Error from ModifyTodoData : %v", err)
+		if err != mongo.ErrNoDocuments {
+			log.Printf("Error from GetUserByEmail : %v", err)
+		}
+		return nil, err
 	}
-	return nil
+	return user, nil
 }
 
 /*
-DeleteUserTodo : this method will delete a select todo schedule by the user
+ShareProject : this shares a project of a user with another user, replacing the
+role the other user had on it
 */
-func (tm *TsMongoDBRepo) DeleteUserTodo(todoId string) error {
+func (tm *TsMongoDBRepo) ShareProject(userId, projectId string, share model.Share) error {
 	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancelCtx()
 
-	filter := bson.D{{Key: "todo._id", Value: todoId}}
-	update := bson.D{{Key: "$pull", Value: bson.D{
-		{Key: "todo", Value: bson.D{{Key: "_id", Value: todoId}}},
+	filter := bson.D{
+		{Key: "_id", Value: userId},
+		{Key: "project_details._id", Value: projectId},
+	}
+	pull := bson.D{{Key: "$pull", Value: bson.D{
+		{Key: "project_details.$.shares", Value: bson.D{{Key: "user_id", Value: share.UserID}}},
+	}}}
+	push := bson.D{{Key: "$push", Value: bson.D{
+		{Key: "project_details.$.shares", Value: share},
 	}}}
-	opt := options.Update().SetUpsert(false)
 
-	_, err := UserData(tm.TsMongoDB, "user").UpdateOne(ctx, filter, update, opt)
-	if err != nil {
-		log.Fatalf("Error from DeleteUserTodo : %v", err)
+	for _, update := range []bson.D{pull, push} {
+		result, err := UserData(tm.TsMongoDB, "user").UpdateOne(ctx, filter, update)
+		if err != nil {
+			log.Printf("Error from ShareProject : %v", err)
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return mongo.ErrNoDocuments
+		}
 	}
 	return nil
 }
 
 /*
-UpdateUserStat : this method is to store the statistic updates of the user activities
-on track space
+UnshareProject : this stops sharing a project of a user with another user
 */
-func (tm *TsMongoDBRepo) UpdateUserStat(data model.Data, id string) error {
+func (tm *TsMongoDBRepo) UnshareProject(userId, projectId, sharedUserId string) error {
 	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancelCtx()
-	filter := bson.D{{Key: "_id", Value: id}}
-	update := bson.D{{Key: "$addToSet", Value: bson.D{{Key: "data", Value: bson.D{
-		{Key: "date", Value: data.Date},
-		{Key: "code", Value: data.Code},
-		{Key: "article", Value: data.Article},
-		{Key: "text", Value: data.Text},
-		{Key: "todo", Value: data.Todo},
-		{Key: "total", Value: data.Total},
-	}}}}}
+
+	filter := bson.D{
+		{Key: "_id", Value: userId},
+		{Key: "project_details._id", Value: projectId},
+	}
+	update := bson.D{{Key: "$pull", Value: bson.D{
+		{Key: "project_details.$.shares", Value: bson.D{{Key: "user_id", Value: sharedUserId}}},
+	}}}
 
 	_, err := UserData(tm.TsMongoDB, "user").UpdateOne(ctx, filter, update)
 	if err != nil {
-		log.Println("cannot update user document")
-		panic(err)
+		log.Printf("Error from UnshareProject : %v", err)
+		return err
 	}
 	return nil
 }
 
 /*
-GetUserStatByID : this method is to get all the statistic information on a
-particular user
+GetSharedProjects : this returns the projects other users share with a user, with
+the name of their owner
 */
-func (tm *TsMongoDBRepo) GetUserStatByID(id string) (primitive.M, error) {
+func (tm *TsMongoDBRepo) GetSharedProjects(userId string) ([]model.SharedProject, error) {
 	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancelCtx()
 
-	var result bson.M
-	filter := bson.D{{Key: "_id", Value: id}}
-	err := UserData(tm.TsMongoDB, "user").FindOne(ctx, filter).Decode(&result)
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{{Key: "project_details.shares.user_id", Value: userId}}}},
+		{{Key: "$unwind", Value: "$project_details"}},
+		{{Key: "$match", Value: bson.D{{Key: "project_details.shares.user_id", Value: userId}}}},
+		{{Key: "$project", Value: bson.D{
+			{Key: "_id", Value: 0},
+			{Key: "owner_id", Value: "$_id"},
+			{Key: "owner_first_name", Value: "$first_name"},
+			{Key: "owner_last_name", Value: "$last_name"},
+			{Key: "project", Value: "$project_details"},
+		}}},
+	}
+	cursor, err := UserData(tm.TsMongoDB, "user").Aggregate(ctx, pipeline)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			log.Println(err)
-		}
+		log.Printf("Error from GetSharedProjects : %v", err)
+		return nil, err
 	}
-
-	return result, nil
+	var projects []model.SharedProject
+	if err = cursor.All(ctx, &projects); err != nil {
+		log.Printf("Error from GetSharedProjects : %v", err)
+		return nil, err
+	}
+	return projects, nil
 }
 
-func (tm *TsMongoDBRepo) GetAllUserData() ([]primitive.M, error) {
+/*
+SetProjectPublicToken : this stores the secret of the read-only public link of a project,
+the empty token removes it so the link stops working
+*/
+func (tm *TsMongoDBRepo) SetProjectPublicToken(userId, projectId, token string) error {
 	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancelCtx()
 
-	var documents []bson.M
-	cursor, err := UserData(tm.TsMongoDB, "user").Find(ctx, bson.D{})
-	if err != nil {
-		log.Panic(err)
+	filter := bson.D{
+		{Key: "_id", Value: userId},
+		{Key: "project_details._id", Value: projectId},
 	}
-	if err = cursor.All(ctx, &documents); err != nil {
-		log.Panic(err)
-		return nil, err
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "project_details.$.public_token", Value: token}}}}
+	if token == "" {
+		update = bson.D{{Key: "$unset", Value: bson.D{{Key: "project_details.$.public_token", Value: ""}}}}
 	}
 
-	return documents, nil
+	_, err := UserData(tm.TsMongoDB, "user").UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Printf("Error from SetProjectPublicToken : %v", err)
+		return err
+	}
+	return nil
 }
 
-func (tm *TsMongoDBRepo) GetAdminInfo() ([]primitive.M, error) {
+/*
+GetProjectByPublicToken : this fetch the owner of the project with the given public
+link secret together with that project alone
+*/
+func (tm *TsMongoDBRepo) GetProjectByPublicToken(token string) (primitive.M, error) {
 	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancelCtx()
 
-	var result []bson.M
-	cursor, err := AdminData(tm.TsMongoDB, "admin").Find(ctx, bson.D{})
-	if err != nil {
-		log.Panic(err)
-	}
+	filter := bson.D{{Key: "project_details.public_token", Value: token}}
+	opt := options.FindOne().SetProjection(bson.D{
+		{Key: "first_name", Value: 1},
+		{Key: "last_name", Value: 1},
+		{Key: "project_details", Value: bson.D{{Key: "$elemMatch", Value: bson.D{{Key: "public_token", Value: token}}}}},
+	})
 
-	if err = cursor.All(ctx, &result); err != nil {
-		log.Panic(err)
+	var owner bson.M
+	err := UserData(tm.TsMongoDB, "user").FindOne(ctx, filter, opt).Decode(&owner)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			log.Printf("Error from GetProjectByPublicToken : %v", err)
+		}
 		return nil, err
 	}
-	return result, nil
+	return owner, nil
 }
 
-func (tm *TsMongoDBRepo) AdminDeleteUserData(id string) error {
+/*
+GetUserProfile : this returns the user document without the credentials and without
+the projects, todos and statistics arrays, which are streamed separately
+*/
+func (tm *TsMongoDBRepo) GetUserProfile(id string) (primitive.M, error) {
 	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancelCtx()
 
-	filter := bson.D{{Key: "_id", Value: id}}
-	var deletedProject bson.M
-	err := UserData(tm.TsMongoDB, "user").FindOneAndDelete(ctx, filter).Decode(&deletedProject)
+	opt := options.FindOne().SetProjection(bson.D{
+		{Key: "password", Value: 0},
+		{Key: "token", Value: 0},
+		{Key: "renew_token", Value: 0},
+		{Key: "calendar_token", Value: 0},
+		{Key: "project_details", Value: 0},
+		{Key: "todo", Value: 0},
+		{Key: "data", Value: 0},
+	})
+	var profile bson.M
+	err := UserData(tm.TsMongoDB, "user").FindOne(ctx, bson.D{{Key: "_id", Value: id}}, opt).Decode(&profile)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			log.Panic(err)
+		log.Printf("Error from GetUserProfile : %v", err)
+		return nil, err
+	}
+	return profile, nil
+}
+
+/*
+StreamUserProjects : this calls fn with every project of the user, reading them from
+a cursor one at a time so the whole list is never held in memory
+*/
+func (tm *TsMongoDBRepo) StreamUserProjects(id string, fn func(project model.Project) error) error {
+	return tm.streamUserArray(id, "project_details", func(cursor *mongo.Cursor) error {
+		var project model.Project
+		if err := cursor.Decode(&project); err != nil {
 			return err
 		}
-		log.Fatal(err)
+		return fn(project)
+	})
+}
+
+// StreamUserTodos : this calls fn with every todo of the user, one at a time
+func (tm *TsMongoDBRepo) StreamUserTodos(id string, fn func(todo model.Todo) error) error {
+	return tm.streamUserArray(id, "todo", func(cursor *mongo.Cursor) error {
+		var todo model.Todo
+		if err := cursor.Decode(&todo); err != nil {
+			return err
+		}
+		return fn(todo)
+	})
+}
+
+// StreamUserStats : this calls fn with every daily statistic of the user, one at a time
+func (tm *TsMongoDBRepo) StreamUserStats(id string, fn func(stat model.Data) error) error {
+	return tm.streamUserArray(id, "data", func(cursor *mongo.Cursor) error {
+		var stat model.Data
+		if err := cursor.Decode(&stat); err != nil {
+			return err
+		}
+		return fn(stat)
+	})
+}
+
+// streamUserArray : this unwinds an array of the user document and visits its elements one by one
+func (tm *TsMongoDBRepo) streamUserArray(id, field string, visit func(cursor *mongo.Cursor) error) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{{Key: "_id", Value: id}}}},
+		{{Key: "$project", Value: bson.D{{Key: field, Value: 1}}}},
+		{{Key: "$unwind", Value: "$" + field}},
+		{{Key: "$replaceRoot", Value: bson.D{{Key: "newRoot", Value: "$" + field}}}},
+	}
+	cursor, err := UserData(tm.TsMongoDB, "user").Aggregate(ctx, pipeline, options.Aggregate().SetBatchSize(50))
+	if err != nil {
+		log.Printf("Error from streamUserArray : %v", err)
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		if err := visit(cursor); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+/*
+GetProjectsByTag : this returns the projects of a user carrying the given tag
+*/
+func (tm *TsMongoDBRepo) GetProjectsByTag(userId, tag string) ([]model.Project, error) {
+	return tm.findUserProjects(userId, bson.D{{Key: "project_details.tags", Value: tag}})
+}
+
+/*
+GetProjectsByFolder : this returns the projects of a user stored in the given folder
+or in any of its sub folders
+*/
+func (tm *TsMongoDBRepo) GetProjectsByFolder(userId, folder string) ([]model.Project, error) {
+	pattern := fmt.Sprintf("^%s(/|$)", regexp.QuoteMeta(folder))
+	return tm.findUserProjects(userId, bson.D{{Key: "project_details.folder", Value: primitive.Regex{Pattern: pattern}}})
+}
+
+// findUserProjects : this returns the projects of a user matching the given filter
+func (tm *TsMongoDBRepo) findUserProjects(userId string, match bson.D) ([]model.Project, error) {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{{Key: "_id", Value: userId}}}},
+		{{Key: "$unwind", Value: "$project_details"}},
+		{{Key: "$match", Value: match}},
+		{{Key: "$replaceRoot", Value: bson.D{{Key: "newRoot", Value: "$project_details"}}}},
+	}
+	cursor, err := UserData(tm.TsMongoDB, "user").Aggregate(ctx, pipeline)
+	if err != nil {
+		log.Printf("Error from findUserProjects : %v", err)
+		return nil, err
+	}
+	var projects []model.Project
+	if err = cursor.All(ctx, &projects); err != nil {
+		log.Printf("Error from findUserProjects : %v", err)
+		return nil, err
+	}
+	return projects, nil
+}
+
+/*
+SetProjectPinned : this pins a project to the top of the project table or unpins it
+*/
+func (tm *TsMongoDBRepo) SetProjectPinned(userId, projectId string, pinned bool) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	filter := bson.D{
+		{Key: "_id", Value: userId},
+		{Key: "project_details._id", Value: projectId},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "project_details.$.pinned", Value: pinned}}}}
+
+	_, err := UserData(tm.TsMongoDB, "user").UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Printf("Error from SetProjectPinned : %v", err)
+		return err
+	}
+	return nil
+}
+
+/*
+RetagProjects : this adds and removes tags on several projects of a user at once.
+The tags are added first, so a tag both added and removed ends up removed
+*/
+func (tm *TsMongoDBRepo) RetagProjects(userId string, projectIds, addTags, removeTags []string) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	filter := bson.D{{Key: "_id", Value: userId}}
+	opt := options.Update().SetArrayFilters(options.ArrayFilters{
+		Filters: []interface{}{bson.D{{Key: "p._id", Value: bson.D{{Key: "$in", Value: projectIds}}}}},
+	})
+
+	// projects saved without tags may hold a null, which cannot be added to or pulled from
+	untagged := bson.D{{Key: "$set", Value: bson.D{{Key: "project_details.$[p].tags", Value: bson.A{}}}}}
+	untaggedOpt := options.Update().SetArrayFilters(options.ArrayFilters{
+		Filters: []interface{}{bson.D{
+			{Key: "p._id", Value: bson.D{{Key: "$in", Value: projectIds}}},
+			{Key: "p.tags", Value: nil},
+		}},
+	})
+	_, err := UserData(tm.TsMongoDB, "user").UpdateOne(ctx, filter, untagged, untaggedOpt)
+	if err != nil {
+		log.Printf("Error from RetagProjects : %v", err)
+		return err
+	}
+
+	if len(addTags) > 0 {
+		update := bson.D{{Key: "$addToSet", Value: bson.D{
+			{Key: "project_details.$[p].tags", Value: bson.D{{Key: "$each", Value: addTags}}},
+		}}}
+		_, err := UserData(tm.TsMongoDB, "user").UpdateOne(ctx, filter, update, opt)
+		if err != nil {
+			log.Printf("Error from RetagProjects : %v", err)
+			return err
+		}
+	}
+	if len(removeTags) > 0 {
+		update := bson.D{{Key: "$pull", Value: bson.D{
+			{Key: "project_details.$[p].tags", Value: bson.D{{Key: "$in", Value: removeTags}}},
+		}}}
+		_, err := UserData(tm.TsMongoDB, "user").UpdateOne(ctx, filter, update, opt)
+		if err != nil {
+			log.Printf("Error from RetagProjects : %v", err)
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+DeleteUserProject : this method will delete a select project by the user
+*/
+func (tm *TsMongoDBRepo) DeleteUserProject(projectId string) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	filter := bson.D{{Key: "project_details._id", Value: projectId}}
+	update := bson.D{{Key: "$pull", Value: bson.D{
+		{Key: "project_details", Value: bson.D{{Key: "_id", Value: projectId}}},
+	}}}
+	opt := options.Update().SetUpsert(false)
+	_, err := UserData(tm.TsMongoDB, "user").UpdateOne(ctx, filter, update, opt)
+	if err != nil {
+		log.Fatalf("Error from DeleteUserProject : %v", err)
+	}
+
+	// the todos of a deleted project stay on the schedule without a project
+	unlink := bson.D{{Key: "$set", Value: bson.D{{Key: "todo.$[t].project_id", Value: ""}}}}
+	unlinkOpt := options.Update().SetArrayFilters(options.ArrayFilters{
+		Filters: []interface{}{bson.D{{Key: "t.project_id", Value: projectId}}},
+	})
+	_, err = UserData(tm.TsMongoDB, "user").UpdateOne(ctx, bson.D{{Key: "todo.project_id", Value: projectId}}, unlink, unlinkOpt)
+	if err != nil {
+		log.Printf("Error from DeleteUserProject : %v", err)
+		return err
+	}
+	return nil
+}
+
+/*
+StoreTodoData : this method help the user to store the create todo schedule and all it
+set duration and date as well in the to the database
+*/
+func (tm *TsMongoDBRepo) StoreTodoData(todo model.Todo, id string) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+	filter := bson.D{{Key: "_id", Value: id}}
+	// update := bson.D{{Key: "$push", Value: bson.D{{Key: "todo", Value: todo}}}}
+	update := bson.D{{Key: "$push", Value: bson.D{
+		{Key: "todo", Value: bson.D{
+			{Key: "_id", Value: todo.ID},
+			{Key: "to_do_task", Value: todo.ToDoTask},
+			{Key: "schedule_date", Value: todo.DateSchedule},
+			{Key: "start_time", Value: todo.StartTime},
+			{Key: "end_time", Value: todo.EndTime},
+			{Key: "status", Value: todo.Status},
+			{Key: "recurrence", Value: todo.Recurrence},
+			{Key: "occurrences", Value: bson.A{}},
+			{Key: "uid", Value: todo.UID},
+			{Key: "reminded", Value: bson.A{}},
+			{Key: "column", Value: todo.Column},
+			{Key: "position", Value: todo.Position},
+			{Key: "checklist", Value: bson.A{}},
+			{Key: "project_id", Value: todo.ProjectID},
+		}},
+	}}, {Key: "$inc", Value: bson.D{{Key: "board_version", Value: 1}}}}
+
+	_, err := UserData(tm.TsMongoDB, "user").UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Fatal("cannot add document to the database")
+		return err
+	}
+	return nil
+}
+
+/*
+GetTodoData : this method fetch one particular created schedule stored by a
+particular user in the database to check or make some modification to the
+date/time of the schedule
+*/
+func (tm *TsMongoDBRepo) GetTodoData(todoId string) (primitive.M, error) {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+	filter := bson.D{{Key: "todo._id", Value: todoId}}
+	opt := options.FindOne().SetProjection(bson.D{
+		{Key: "_id", Value: todoId},
+		{Key: "todo", Value: bson.D{{Key: "$elemMatch", Value: bson.D{{Key: "_id", Value: todoId}}}}},
+	})
+
+	var data bson.M
+	err := UserData(tm.TsMongoDB, "user").FindOne(ctx, filter, opt).Decode(&data)
+	if err != nil {
+		log.Panic(err)
+	}
+	return data, nil
+}
+
+/*
+ModifyTodoData : this method is to keep track of the changes made by the
+user on a previous set schedule by updating it in the database
+*/
+func (tm *TsMongoDBRepo) ModifyTodoData(id string, todo model.Todo) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+	filter := bson.D{{Key: "todo._id", Value: id}}
+	// update := bson.D{{Key: "$set", Value: bson.D{{Key: "todo", Value: todo}}}}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "todo.$._id", Value: todo.ID},
+		{Key: "todo.$.to_do_task", Value: todo.ToDoTask},
+		{Key: "todo.$.date_schedule", Value: todo.DateSchedule},
+		{Key: "todo.$.start_time", Value: todo.StartTime},
+		{Key: "todo.$.end_time", Value: todo.EndTime},
+		{Key: "todo.$.status", Value: todo.Status},
+	}}, {Key: "$inc", Value: bson.D{{Key: "board_version", Value: 1}}}}
+	_, err := UserData(tm.TsMongoDB, "user").UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Fatalf("Error from ModifyTodoData : %v", err)
+	}
+	return nil
+}
+
+/*
+SetTodoOccurrence : this method records the completion or skip of a single occurrence
+of a recurring todo without changing the rest of the series. An empty status resets
+the occurrence back to the series default
+*/
+func (tm *TsMongoDBRepo) SetTodoOccurrence(todoId string, occurrence model.Occurrence) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	// the occurrence replaces the one of the same date, or is removed, in one update
+	others := bson.D{{Key: "$filter", Value: bson.D{
+		{Key: "input", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$$t.occurrences", bson.A{}}}}},
+		{Key: "as", Value: "o"},
+		{Key: "cond", Value: bson.D{{Key: "$ne", Value: bson.A{"$$o.date", bson.D{{Key: "$literal", Value: occurrence.Date}}}}}},
+	}}}
+	occurrences := bson.A{others}
+	if occurrence.Status != "" {
+		occurrences = append(occurrences, bson.D{{Key: "$literal", Value: bson.A{bson.D{
+			{Key: "date", Value: occurrence.Date},
+			{Key: "status", Value: occurrence.Status},
+		}}}})
+	}
+	filter := bson.D{{Key: "todo._id", Value: todoId}}
+	update := todoPipeline(todoId, bson.D{
+		{Key: "occurrences", Value: bson.D{{Key: "$concatArrays", Value: occurrences}}},
+	})
+
+	_, err := UserData(tm.TsMongoDB, "user").UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Printf("Error from SetTodoOccurrence : %v", err)
+		return err
+	}
+	return nil
+}
+
+/*
+todoPipeline : this returns an update setting the given fields on the todo of the
+given id, the fields are expressions where "$$t" is the todo. Values taken from the
+user go inside "$literal" so they are never read as field paths
+*/
+func todoPipeline(todoId string, fields bson.D) mongo.Pipeline {
+	return mongo.Pipeline{
+		{{Key: "$set", Value: bson.D{{Key: "todo", Value: bson.D{{Key: "$map", Value: bson.D{
+			{Key: "input", Value: "$todo"},
+			{Key: "as", Value: "t"},
+			{Key: "in", Value: bson.D{{Key: "$cond", Value: bson.A{
+				bson.D{{Key: "$eq", Value: bson.A{"$$t._id", bson.D{{Key: "$literal", Value: todoId}}}}},
+				bson.D{{Key: "$mergeObjects", Value: bson.A{"$$t", fields}}},
+				"$$t",
+			}}}},
+		}}}}}}},
+	}
+}
+
+/*
+MarkTodoReminded : this records that the reminder mail for the occurrence of a todo
+on the given date was sent, so it is not sent again after a restart. The dates before
+since can no longer get a reminder and are dropped at the same time
+*/
+func (tm *TsMongoDBRepo) MarkTodoReminded(todoId, date, since string) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	kept := bson.D{{Key: "$filter", Value: bson.D{
+		{Key: "input", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$$t.reminded", bson.A{}}}}},
+		{Key: "as", Value: "d"},
+		{Key: "cond", Value: bson.D{{Key: "$and", Value: bson.A{
+			bson.D{{Key: "$gte", Value: bson.A{"$$d", bson.D{{Key: "$literal", Value: since}}}}},
+			bson.D{{Key: "$ne", Value: bson.A{"$$d", bson.D{{Key: "$literal", Value: date}}}}},
+		}}}},
+	}}}
+	filter := bson.D{{Key: "todo._id", Value: todoId}}
+	update := todoPipeline(todoId, bson.D{
+		{Key: "reminded", Value: bson.D{{Key: "$concatArrays", Value: bson.A{kept, bson.D{{Key: "$literal", Value: bson.A{date}}}}}}},
+	})
+
+	_, err := UserData(tm.TsMongoDB, "user").UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Printf("Error from MarkTodoReminded : %v", err)
+		return err
+	}
+	return nil
+}
+
+/*
+UpdateBoardColumns : this stores the columns and work in progress limits of the user todo board
+*/
+func (tm *TsMongoDBRepo) UpdateBoardColumns(id string, columns []model.BoardColumn) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	board := bson.A{}
+	for _, col := range columns {
+		board = append(board, bson.D{
+			{Key: "key", Value: col.Key},
+			{Key: "title", Value: col.Title},
+			{Key: "wip_limit", Value: col.WIPLimit},
+		})
+	}
+	filter := bson.D{{Key: "_id", Value: id}}
+	// the moves made on the previous columns no longer apply
+	update := bson.D{
+		{Key: "$set", Value: bson.D{{Key: "board", Value: board}}},
+		{Key: "$inc", Value: bson.D{{Key: "board_version", Value: 1}}},
+	}
+
+	_, err := UserData(tm.TsMongoDB, "user").UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Printf("Error from UpdateBoardColumns : %v", err)
+		return err
+	}
+	return nil
+}
+
+/*
+MoveTodoOnBoard : this stores the new column, position and status of the todos changed
+by a move on the board made on the given board version. All of them are written by a
+single update of the user document, which only matches while the board is still at
+that version and moves it to the next one; data.ErrBoardChanged is returned once
+another move or a change of the columns got there first. Every other write of the
+todos of the user, their status included, moves the board version as well
+*/
+func (tm *TsMongoDBRepo) MoveTodoOnBoard(id string, version int, todos []model.Todo) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	if len(todos) == 0 {
+		return nil
+	}
+	set := bson.D{}
+	var arrayFilters []interface{}
+	for i, todo := range todos {
+		name := fmt.Sprintf("t%d", i)
+		set = append(set,
+			bson.E{Key: "todo.$[" + name + "].column", Value: todo.Column},
+			bson.E{Key: "todo.$[" + name + "].position", Value: todo.Position},
+			bson.E{Key: "todo.$[" + name + "].status", Value: todo.Status},
+		)
+		arrayFilters = append(arrayFilters, bson.D{{Key: name + "._id", Value: todo.ID}})
+	}
+	var current interface{} = version
+	if version == 0 {
+		// boards never moved have no version yet
+		current = bson.D{{Key: "$in", Value: bson.A{0, nil}}}
+	}
+	filter := bson.D{{Key: "_id", Value: id}, {Key: "board_version", Value: current}}
+	update := bson.D{
+		{Key: "$set", Value: set},
+		{Key: "$inc", Value: bson.D{{Key: "board_version", Value: 1}}},
+	}
+	opt := options.Update().SetArrayFilters(options.ArrayFilters{Filters: arrayFilters})
+
+	result, err := UserData(tm.TsMongoDB, "user").UpdateOne(ctx, filter, update, opt)
+	if err != nil {
+		log.Printf("Error from MoveTodoOnBoard : %v", err)
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return data.ErrBoardChanged
+	}
+	return nil
+}
+
+/*
+SetTodoStatus : this changes only the status of a todo
+*/
+func (tm *TsMongoDBRepo) SetTodoStatus(todoId, status string) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	filter := bson.D{{Key: "todo._id", Value: todoId}}
+	update := bson.D{
+		{Key: "$set", Value: bson.D{{Key: "todo.$.status", Value: status}}},
+		{Key: "$inc", Value: bson.D{{Key: "board_version", Value: 1}}},
+	}
+
+	_, err := UserData(tm.TsMongoDB, "user").UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Printf("Error from SetTodoStatus : %v", err)
+		return err
+	}
+	return nil
+}
+
+/*
+SetTodoProject : this links a todo to one of the user projects, an empty project id
+removes the link
+*/
+func (tm *TsMongoDBRepo) SetTodoProject(todoId, projectId string) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	filter := bson.D{{Key: "todo._id", Value: todoId}}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "todo.$.project_id", Value: projectId}}}}
+
+	_, err := UserData(tm.TsMongoDB, "user").UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Printf("Error from SetTodoProject : %v", err)
+		return err
+	}
+	return nil
+}
+
+/*
+AddChecklistItem : this adds a step at the end of the checklist of a todo
+*/
+func (tm *TsMongoDBRepo) AddChecklistItem(todoId string, item model.ChecklistItem) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	filter := bson.D{{Key: "todo._id", Value: todoId}}
+	update := bson.D{{Key: "$push", Value: bson.D{
+		{Key: "todo.$.checklist", Value: bson.D{
+			{Key: "_id", Value: item.ID},
+			{Key: "text", Value: item.Text},
+			{Key: "done", Value: item.Done},
+		}},
+	}}}
+
+	_, err := UserData(tm.TsMongoDB, "user").UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Printf("Error from AddChecklistItem : %v", err)
+		return err
+	}
+	return nil
+}
+
+/*
+ToggleChecklistItem : this checks or unchecks one step of the checklist of a todo. In
+the same update the todo is marked as done once every step is checked, and back as not
+done when a step of a done todo is unchecked
+*/
+func (tm *TsMongoDBRepo) ToggleChecklistItem(todoId, itemId string, done bool) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	toggled := bson.D{{Key: "$map", Value: bson.D{
+		{Key: "input", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$$t.checklist", bson.A{}}}}},
+		{Key: "as", Value: "i"},
+		{Key: "in", Value: bson.D{{Key: "$cond", Value: bson.A{
+			bson.D{{Key: "$eq", Value: bson.A{"$$i._id", bson.D{{Key: "$literal", Value: itemId}}}}},
+			bson.D{{Key: "$mergeObjects", Value: bson.A{"$$i", bson.D{{Key: "done", Value: bson.D{{Key: "$literal", Value: done}}}}}}},
+			"$$i",
+		}}}},
+	}}}
+	filter := bson.D{{Key: "todo._id", Value: todoId}}
+	update := checklistPipeline(todoId, toggled, !done)
+
+	_, err := UserData(tm.TsMongoDB, "user").UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Printf("Error from ToggleChecklistItem : %v", err)
+		return err
+	}
+	return nil
+}
+
+/*
+ReorderChecklistItems : this puts the steps of the checklist of a todo in the order
+of the given item ids. Steps missing from the ids keep their order at the end. The
+order is applied to the stored steps in one update, so a step added or checked at
+the same time is kept
+*/
+func (tm *TsMongoDBRepo) ReorderChecklistItems(todoId string, itemIds []string) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	if itemIds == nil {
+		itemIds = []string{}
+	}
+	listed := bson.D{{Key: "$reduce", Value: bson.D{
+		{Key: "input", Value: bson.D{{Key: "$literal", Value: itemIds}}},
+		{Key: "initialValue", Value: bson.A{}},
+		{Key: "in", Value: bson.D{{Key: "$concatArrays", Value: bson.A{"$$value", bson.D{{Key: "$filter", Value: bson.D{
+			{Key: "input", Value: "$$t.checklist"},
+			{Key: "as", Value: "i"},
+			{Key: "cond", Value: bson.D{{Key: "$eq", Value: bson.A{"$$i._id", "$$this"}}}},
+		}}}}}}},
+	}}}
+	unlisted := bson.D{{Key: "$filter", Value: bson.D{
+		{Key: "input", Value: "$$t.checklist"},
+		{Key: "as", Value: "i"},
+		{Key: "cond", Value: bson.D{{Key: "$not", Value: bson.A{bson.D{{Key: "$in", Value: bson.A{"$$i._id", bson.D{{Key: "$literal", Value: itemIds}}}}}}}}},
+	}}}
+	filter := bson.D{{Key: "todo._id", Value: todoId}}
+	update := todoPipeline(todoId, bson.D{
+		{Key: "checklist", Value: bson.D{{Key: "$concatArrays", Value: bson.A{listed, unlisted}}}},
+	})
+
+	_, err := UserData(tm.TsMongoDB, "user").UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Printf("Error from ReorderChecklistItems : %v", err)
+		return err
+	}
+	return nil
+}
+
+/*
+RemoveChecklistItem : this deletes one step from the checklist of a todo. In the same
+update the todo is marked as done when every step left is checked
+*/
+func (tm *TsMongoDBRepo) RemoveChecklistItem(todoId, itemId string) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	left := bson.D{{Key: "$filter", Value: bson.D{
+		{Key: "input", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$$t.checklist", bson.A{}}}}},
+		{Key: "as", Value: "i"},
+		{Key: "cond", Value: bson.D{{Key: "$ne", Value: bson.A{"$$i._id", bson.D{{Key: "$literal", Value: itemId}}}}}},
+	}}}
+	filter := bson.D{{Key: "todo._id", Value: todoId}}
+	update := checklistPipeline(todoId, left, false)
+
+	_, err := UserData(tm.TsMongoDB, "user").UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Printf("Error from RemoveChecklistItem : %v", err)
+		return err
+	}
+	return nil
+}
+
+/*
+checklistPipeline : this returns an update setting the checklist of the todo of the
+given id to the checklist expression, then its status from the steps: done once they
+are all checked, not done again when reopen is set and they are not. The board version
+moves along since the status may change
+*/
+func checklistPipeline(todoId string, checklist bson.D, reopen bool) mongo.Pipeline {
+	allChecked := bson.D{{Key: "$and", Value: bson.A{
+		bson.D{{Key: "$gt", Value: bson.A{bson.D{{Key: "$size", Value: "$$t.checklist"}}, 0}}},
+		bson.D{{Key: "$allElementsTrue", Value: bson.A{bson.D{{Key: "$map", Value: bson.D{
+			{Key: "input", Value: "$$t.checklist"},
+			{Key: "as", Value: "i"},
+			{Key: "in", Value: "$$i.done"},
+		}}}}}},
+	}}}
+	otherwise := interface{}("$$t.status")
+	if reopen {
+		otherwise = bson.D{{Key: "$cond", Value: bson.A{
+			bson.D{{Key: "$eq", Value: bson.A{"$$t.status", "Done"}}},
+			"Not done",
+			"$$t.status",
+		}}}
+	}
+	update := todoPipeline(todoId, bson.D{{Key: "checklist", Value: checklist}})
+	update = append(update, todoPipeline(todoId, bson.D{
+		{Key: "status", Value: bson.D{{Key: "$cond", Value: bson.A{allChecked, "Done", otherwise}}}},
+	})...)
+	return append(update, bson.D{{Key: "$set", Value: bson.D{
+		{Key: "board_version", Value: bson.D{{Key: "$add", Value: bson.A{bson.D{{Key: "$ifNull", Value: bson.A{"$board_version", 0}}}, 1}}}},
+	}}})
+}
+
+/*
+DeleteUserTodo : this method will delete a select todo schedule by the user
+*/
+func (tm *TsMongoDBRepo) DeleteUserTodo(todoId string) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	filter := bson.D{{Key: "todo._id", Value: todoId}}
+	update := bson.D{{Key: "$pull", Value: bson.D{
+		{Key: "todo", Value: bson.D{{Key: "_id", Value: todoId}}},
+	}}, {Key: "$inc", Value: bson.D{{Key: "board_version", Value: 1}}}}
+	opt := options.Update().SetUpsert(false)
+
+	_, err := UserData(tm.TsMongoDB, "user").UpdateOne(ctx, filter, update, opt)
+	if err != nil {
+		log.Fatalf("Error from DeleteUserTodo : %v", err)
+	}
+	return nil
+}
+
+/*
+UpdateUserStat : this method is to store the statistic updates of the user activities
+on track space
+*/
+func (tm *TsMongoDBRepo) UpdateUserStat(data model.Data, id string) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+	filter := bson.D{{Key: "_id", Value: id}}
+	update := bson.D{{Key: "$addToSet", Value: bson.D{{Key: "data", Value: bson.D{
+		{Key: "date", Value: data.Date},
+		{Key: "code", Value: data.Code},
+		{Key: "article", Value: data.Article},
+		{Key: "text", Value: data.Text},
+		{Key: "todo", Value: data.Todo},
+		{Key: "total", Value: data.Total},
+	}}}}}
+
+	_, err := UserData(tm.TsMongoDB, "user").UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Println("cannot update user document")
+		panic(err)
+	}
+	return nil
+}
+
+/*
+GetUserStatByID : this method is to get all the statistic information on a
+particular user
+*/
+func (tm *TsMongoDBRepo) GetUserStatByID(id string) (primitive.M, error) {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	var result bson.M
+	filter := bson.D{{Key: "_id", Value: id}}
+	err := UserData(tm.TsMongoDB, "user").FindOne(ctx, filter).Decode(&result)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			log.Println(err)
+		}
+	}
+
+	return result, nil
+}
+
+func (tm *TsMongoDBRepo) GetAllUserData() ([]primitive.M, error) {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	var documents []bson.M
+	cursor, err := UserData(tm.TsMongoDB, "user").Find(ctx, bson.D{})
+	if err != nil {
+		log.Panic(err)
+	}
+	if err = cursor.All(ctx, &documents); err != nil {
+		log.Panic(err)
+		return nil, err
+	}
+
+	return documents, nil
+}
+
+func (tm *TsMongoDBRepo) GetAdminInfo() ([]primitive.M, error) {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	var result []bson.M
+	cursor, err := AdminData(tm.TsMongoDB, "admin").Find(ctx, bson.D{})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if err = cursor.All(ctx, &result); err != nil {
+		log.Panic(err)
+		return nil, err
+	}
+	return result, nil
+}
+
+func (tm *TsMongoDBRepo) AdminDeleteUserData(id string) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	filter := bson.D{{Key: "_id", Value: id}}
+	var deletedProject bson.M
+	err := UserData(tm.TsMongoDB, "user").FindOneAndDelete(ctx, filter).Decode(&deletedProject)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			log.Panic(err)
+			return err
+		}
+		log.Fatal(err)
+		return err
+	}
+	return nil
+}
+
+/*
+CreateTeam : this stores a new team with its first members
+*/
+func (tm *TsMongoDBRepo) CreateTeam(team model.Team) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	// the arrays are stored empty rather than null so that $push works on them
+	if team.Invites == nil {
+		team.Invites = []model.TeamInvite{}
+	}
+	if team.ProjectDetails == nil {
+		team.ProjectDetails = []model.Project{}
+	}
+	if team.Todo == nil {
+		team.Todo = []model.Todo{}
+	}
+	_, err := UserData(tm.TsMongoDB, "team").InsertOne(ctx, team)
+	if err != nil {
+		log.Printf("Error from CreateTeam : %v", err)
+		return err
+	}
+	return nil
+}
+
+/*
+GetTeam : this fetch a team with its members, projects and todos
+*/
+func (tm *TsMongoDBRepo) GetTeam(teamId string) (model.Team, error) {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	var team model.Team
+	filter := bson.D{{Key: "_id", Value: teamId}}
+	err := UserData(tm.TsMongoDB, "team").FindOne(ctx, filter).Decode(&team)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			log.Printf("Error from GetTeam : %v", err)
+		}
+		return model.Team{}, err
+	}
+	return team, nil
+}
+
+/*
+GetUserTeams : this returns the teams a user is a member of, without their projects
+and todos
+*/
+func (tm *TsMongoDBRepo) GetUserTeams(userId string) ([]model.Team, error) {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	filter := bson.D{{Key: "members.user_id", Value: userId}}
+	opt := options.Find().
+		SetProjection(bson.D{{Key: "project_details", Value: 0}, {Key: "todo", Value: 0}}).
+		SetSort(bson.D{{Key: "name", Value: 1}})
+	return tm.findTeams(ctx, filter, opt)
+}
+
+/*
+GetTeamInvites : this returns the teams with a pending invitation for an email,
+without their projects and todos
+*/
+func (tm *TsMongoDBRepo) GetTeamInvites(email string) ([]model.Team, error) {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	pattern := fmt.Sprintf("^%s$", regexp.QuoteMeta(strings.TrimSpace(email)))
+	filter := bson.D{{Key: "invites.email", Value: primitive.Regex{Pattern: pattern, Options: "i"}}}
+	opt := options.Find().SetProjection(bson.D{{Key: "project_details", Value: 0}, {Key: "todo", Value: 0}})
+	return tm.findTeams(ctx, filter, opt)
+}
+
+func (tm *TsMongoDBRepo) findTeams(ctx context.Context, filter bson.D, opt *options.FindOptions) ([]model.Team, error) {
+	cursor, err := UserData(tm.TsMongoDB, "team").Find(ctx, filter, opt)
+	if err != nil {
+		log.Printf("Error from findTeams : %v", err)
+		return nil, err
+	}
+	var teams []model.Team
+	if err = cursor.All(ctx, &teams); err != nil {
+		log.Printf("Error from findTeams : %v", err)
+		return nil, err
+	}
+	return teams, nil
+}
+
+/*
+GetTeamByInvite : this fetch the team holding the invitation with the given token
+*/
+func (tm *TsMongoDBRepo) GetTeamByInvite(token string) (model.Team, error) {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	var team model.Team
+	filter := bson.D{{Key: "invites.token", Value: token}}
+	opt := options.FindOne().SetProjection(bson.D{{Key: "project_details", Value: 0}, {Key: "todo", Value: 0}})
+	err := UserData(tm.TsMongoDB, "team").FindOne(ctx, filter, opt).Decode(&team)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			log.Printf("Error from GetTeamByInvite : %v", err)
+		}
+		return model.Team{}, err
+	}
+	return team, nil
+}
+
+/*
+AddTeamInvite : this records an invitation to join a team, replacing any pending
+invitation sent to the same email
+*/
+func (tm *TsMongoDBRepo) AddTeamInvite(teamId string, invite model.TeamInvite) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	filter := bson.D{{Key: "_id", Value: teamId}}
+	pull := bson.D{{Key: "$pull", Value: bson.D{{Key: "invites", Value: bson.D{{Key: "email", Value: invite.Email}}}}}}
+	push := bson.D{{Key: "$push", Value: bson.D{{Key: "invites", Value: invite}}}}
+	for _, update := range []bson.D{pull, push} {
+		if _, err := UserData(tm.TsMongoDB, "team").UpdateOne(ctx, filter, update); err != nil {
+			log.Printf("Error from AddTeamInvite : %v", err)
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+RemoveTeamInvite : this drops a pending invitation, once declined or revoked
+*/
+func (tm *TsMongoDBRepo) RemoveTeamInvite(teamId, token string) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	filter := bson.D{{Key: "_id", Value: teamId}}
+	update := bson.D{{Key: "$pull", Value: bson.D{{Key: "invites", Value: bson.D{{Key: "token", Value: token}}}}}}
+	_, err := UserData(tm.TsMongoDB, "team").UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Printf("Error from RemoveTeamInvite : %v", err)
+		return err
+	}
+	return nil
+}
+
+/*
+AcceptTeamInvite : this turns a pending invitation into a membership in one update,
+which only applies while the invitation is pending and the user not yet a member
+*/
+func (tm *TsMongoDBRepo) AcceptTeamInvite(teamId, token string, member model.TeamMember) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	filter := bson.D{
+		{Key: "_id", Value: teamId},
+		{Key: "invites.token", Value: token},
+		{Key: "members.user_id", Value: bson.D{{Key: "$ne", Value: member.UserID}}},
+	}
+	update := bson.D{
+		{Key: "$pull", Value: bson.D{{Key: "invites", Value: bson.D{{Key: "token", Value: token}}}}},
+		{Key: "$push", Value: bson.D{{Key: "members", Value: member}}},
+	}
+	result, err := UserData(tm.TsMongoDB, "team").UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Printf("Error from AcceptTeamInvite : %v", err)
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+/*
+SetTeamMemberRole : this changes the role of a member of a team
+*/
+func (tm *TsMongoDBRepo) SetTeamMemberRole(teamId, userId, role string) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	filter := bson.D{
+		{Key: "_id", Value: teamId},
+		{Key: "members.user_id", Value: userId},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "members.$.role", Value: role}}}}
+	_, err := UserData(tm.TsMongoDB, "team").UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Printf("Error from SetTeamMemberRole : %v", err)
+		return err
+	}
+	return nil
+}
+
+/*
+RemoveTeamMember : this removes a member from a team
+*/
+func (tm *TsMongoDBRepo) RemoveTeamMember(teamId, userId string) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	filter := bson.D{{Key: "_id", Value: teamId}}
+	update := bson.D{{Key: "$pull", Value: bson.D{{Key: "members", Value: bson.D{{Key: "user_id", Value: userId}}}}}}
+	_, err := UserData(tm.TsMongoDB, "team").UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Printf("Error from RemoveTeamMember : %v", err)
+		return err
+	}
+	return nil
+}
+
+/*
+StoreTeamProject : this adds a project owned by a team
+*/
+func (tm *TsMongoDBRepo) StoreTeamProject(teamId string, project model.Project) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	filter := bson.D{{Key: "_id", Value: teamId}}
+	update := bson.D{{Key: "$push", Value: bson.D{{Key: "project_details", Value: project}}}}
+	_, err := UserData(tm.TsMongoDB, "team").UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Printf("Error from StoreTeamProject : %v", err)
+		return err
+	}
+	return nil
+}
+
+/*
+ModifyTeamProject : this stores the changes made by a member to a team project
+*/
+func (tm *TsMongoDBRepo) ModifyTeamProject(teamId string, project model.Project) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	filter := bson.D{
+		{Key: "_id", Value: teamId},
+		{Key: "project_details._id", Value: project.ID},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "project_details.$.project_name", Value: project.ProjectName},
+		{Key: "project_details.$.tools_use_as", Value: project.ToolsUseAs},
+		{Key: "project_details.$.project_content", Value: project.ProjectContent},
+		{Key: "project_details.$.content_format", Value: project.ContentFormat},
+		{Key: "project_details.$.updated_at", Value: project.UpdatedAt},
+		{Key: "project_details.$.status", Value: project.Status},
+	}}}
+	_, err := UserData(tm.TsMongoDB, "team").UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Printf("Error from ModifyTeamProject : %v", err)
+		return err
+	}
+	return nil
+}
+
+/*
+DeleteTeamProject : this removes a project of a team
+*/
+func (tm *TsMongoDBRepo) DeleteTeamProject(teamId, projectId string) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	filter := bson.D{{Key: "_id", Value: teamId}}
+	update := bson.D{{Key: "$pull", Value: bson.D{{Key: "project_details", Value: bson.D{{Key: "_id", Value: projectId}}}}}}
+	_, err := UserData(tm.TsMongoDB, "team").UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Printf("Error from DeleteTeamProject : %v", err)
+		return err
+	}
+	return nil
+}
+
+/*
+StoreTeamTodo : this adds a todo to the schedule of a team
+*/
+func (tm *TsMongoDBRepo) StoreTeamTodo(teamId string, todo model.Todo) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	filter := bson.D{{Key: "_id", Value: teamId}}
+	update := bson.D{{Key: "$push", Value: bson.D{{Key: "todo", Value: todo}}}}
+	_, err := UserData(tm.TsMongoDB, "team").UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Printf("Error from StoreTeamTodo : %v", err)
+		return err
+	}
+	return nil
+}
+
+/*
+SetTeamTodoStatus : this changes the status of a todo of a team
+*/
+func (tm *TsMongoDBRepo) SetTeamTodoStatus(teamId, todoId, status string) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	filter := bson.D{
+		{Key: "_id", Value: teamId},
+		{Key: "todo._id", Value: todoId},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "todo.$.status", Value: status}}}}
+	_, err := UserData(tm.TsMongoDB, "team").UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Printf("Error from SetTeamTodoStatus : %v", err)
+		return err
+	}
+	return nil
+}
+
+/*
+DeleteTeamTodo : this removes a todo from the schedule of a team
+*/
+func (tm *TsMongoDBRepo) DeleteTeamTodo(teamId, todoId string) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	filter := bson.D{{Key: "_id", Value: teamId}}
+	update := bson.D{{Key: "$pull", Value: bson.D{{Key: "todo", Value: bson.D{{Key: "_id", Value: todoId}}}}}}
+	_, err := UserData(tm.TsMongoDB, "team").UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Printf("Error from DeleteTeamTodo : %v", err)
+		return err
+	}
+	return nil
+}
+
+/*
+StoreComment : this stores a comment on a project or a todo
+*/
+func (tm *TsMongoDBRepo) StoreComment(comment model.Comment) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	if comment.Mentions == nil {
+		comment.Mentions = []string{}
+	}
+	_, err := UserData(tm.TsMongoDB, "comment").InsertOne(ctx, comment)
+	if err != nil {
+		log.Printf("Error from StoreComment : %v", err)
+		return err
+	}
+	return nil
+}
+
+/*
+GetComment : this fetch a single comment
+*/
+func (tm *TsMongoDBRepo) GetComment(commentId string) (model.Comment, error) {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	var comment model.Comment
+	filter := bson.D{{Key: "_id", Value: commentId}}
+	err := UserData(tm.TsMongoDB, "comment").FindOne(ctx, filter).Decode(&comment)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			log.Printf("Error from GetComment : %v", err)
+		}
+		return model.Comment{}, err
+	}
+	return comment, nil
+}
+
+/*
+GetComments : this returns the comments of a project or a todo, oldest first
+*/
+func (tm *TsMongoDBRepo) GetComments(targetType, targetId string) ([]model.Comment, error) {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	filter := bson.D{
+		{Key: "target_type", Value: targetType},
+		{Key: "target_id", Value: targetId},
+	}
+	opt := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	cursor, err := UserData(tm.TsMongoDB, "comment").Find(ctx, filter, opt)
+	if err != nil {
+		log.Printf("Error from GetComments : %v", err)
+		return nil, err
+	}
+	var comments []model.Comment
+	if err = cursor.All(ctx, &comments); err != nil {
+		log.Printf("Error from GetComments : %v", err)
+		return nil, err
+	}
+	return comments, nil
+}
+
+/*
+ModifyComment : this changes the content of a comment, only for its author
+*/
+func (tm *TsMongoDBRepo) ModifyComment(commentId, authorId, content string, mentions []string, updatedAt string) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	if mentions == nil {
+		mentions = []string{}
+	}
+	filter := bson.D{
+		{Key: "_id", Value: commentId},
+		{Key: "author_id", Value: authorId},
+		{Key: "deleted", Value: false},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "content", Value: content},
+		{Key: "mentions", Value: mentions},
+		{Key: "updated_at", Value: updatedAt},
+	}}}
+	result, err := UserData(tm.TsMongoDB, "comment").UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Printf("Error from ModifyComment : %v", err)
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+/*
+DeleteComment : this clears a comment of its author. The comment itself stays as a
+placeholder so that the replies to it keep their place in the thread
+*/
+func (tm *TsMongoDBRepo) DeleteComment(commentId, authorId string) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	filter := bson.D{
+		{Key: "_id", Value: commentId},
+		{Key: "author_id", Value: authorId},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "content", Value: ""},
+		{Key: "mentions", Value: []string{}},
+		{Key: "deleted", Value: true},
+	}}}
+	result, err := UserData(tm.TsMongoDB, "comment").UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Printf("Error from DeleteComment : %v", err)
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+/*
+DeleteTargetComments : this removes all the comments of a deleted project or todo
+*/
+func (tm *TsMongoDBRepo) DeleteTargetComments(targetType, targetId string) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	filter := bson.D{
+		{Key: "target_type", Value: targetType},
+		{Key: "target_id", Value: targetId},
+	}
+	_, err := UserData(tm.TsMongoDB, "comment").DeleteMany(ctx, filter)
+	if err != nil {
+		log.Printf("Error from DeleteTargetComments : %v", err)
+		return err
+	}
+	return nil
+}
+
+/*
+StoreNotifications : this stores new notifications for one or more users
+*/
+func (tm *TsMongoDBRepo) StoreNotifications(notifications []model.Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	docs := make([]interface{}, len(notifications))
+	for i, notification := range notifications {
+		docs[i] = notification
+	}
+	_, err := UserData(tm.TsMongoDB, "notification").InsertMany(ctx, docs)
+	if err != nil {
+		log.Printf("Error from StoreNotifications : %v", err)
+		return err
+	}
+	return nil
+}
+
+/*
+GetNotifications : this returns the latest notifications of a user, newest first
+*/
+func (tm *TsMongoDBRepo) GetNotifications(userId string, limit int64) ([]model.Notification, error) {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	filter := bson.D{{Key: "user_id", Value: userId}}
+	opt := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(limit)
+	cursor, err := UserData(tm.TsMongoDB, "notification").Find(ctx, filter, opt)
+	if err != nil {
+		log.Printf("Error from GetNotifications : %v", err)
+		return nil, err
+	}
+	var notifications []model.Notification
+	if err = cursor.All(ctx, &notifications); err != nil {
+		log.Printf("Error from GetNotifications : %v", err)
+		return nil, err
+	}
+	return notifications, nil
+}
+
+/*
+CountUnreadNotifications : this counts the notifications a user has not read yet
+*/
+func (tm *TsMongoDBRepo) CountUnreadNotifications(userId string) (int64, error) {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	filter := bson.D{
+		{Key: "user_id", Value: userId},
+		{Key: "read", Value: false},
+	}
+	count, err := UserData(tm.TsMongoDB, "notification").CountDocuments(ctx, filter)
+	if err != nil {
+		log.Printf("Error from CountUnreadNotifications : %v", err)
+		return 0, err
+	}
+	return count, nil
+}
+
+/*
+MarkNotificationsRead : this marks notifications of a user as read, all of them when
+no notification id is given
+*/
+func (tm *TsMongoDBRepo) MarkNotificationsRead(userId string, notificationIds ...string) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	filter := bson.D{
+		{Key: "user_id", Value: userId},
+		{Key: "read", Value: false},
+	}
+	if len(notificationIds) > 0 {
+		filter = append(filter, bson.E{Key: "_id", Value: bson.D{{Key: "$in", Value: notificationIds}}})
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "read", Value: true}}}}
+	_, err := UserData(tm.TsMongoDB, "notification").UpdateMany(ctx, filter, update)
+	if err != nil {
+		log.Printf("Error from MarkNotificationsRead : %v", err)
+		return err
+	}
+	return nil
+}
+
+// StoreChatMessage : this keeps a message of the chat room
+func (tm *TsMongoDBRepo) StoreChatMessage(msg model.ChatMessage) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	_, err := UserData(tm.TsMongoDB, "chat_messages").InsertOne(ctx, msg)
+	if err != nil {
+		log.Printf("Error from StoreChatMessage : %v", err)
+		return err
+	}
+	return nil
+}
+
+/*
+GetChatMessages : this returns at most limit messages of a room sent before the message
+sent at before with the id beforeID, oldest first. The zero time returns the latest messages
+*/
+func (tm *TsMongoDBRepo) GetChatMessages(room string, before time.Time, beforeID string, limit int64) ([]model.ChatMessage, error) {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	filter := bson.D{{Key: "room", Value: room}}
+	if !before.IsZero() {
+		filter = append(filter, bson.E{Key: "$or", Value: bson.A{
+			bson.D{{Key: "sent_at", Value: bson.D{{Key: "$lt", Value: before}}}},
+			bson.D{
+				{Key: "sent_at", Value: before},
+				{Key: "_id", Value: bson.D{{Key: "$lt", Value: beforeID}}},
+			},
+		}})
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "sent_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(limit)
+
+	cursor, err := UserData(tm.TsMongoDB, "chat_messages").Find(ctx, filter, opts)
+	if err != nil {
+		log.Printf("Error from GetChatMessages : %v", err)
+		return nil, err
+	}
+	messages := []model.ChatMessage{}
+	if err := cursor.All(ctx, &messages); err != nil {
+		log.Printf("Error from GetChatMessages : %v", err)
+		return nil, err
+	}
+	// the page is read newest first, it is returned in the order messages were sent
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, nil
+}
+
+// CreateChatRoom : this stores a chat room created by a user
+func (tm *TsMongoDBRepo) CreateChatRoom(room model.ChatRoom) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	if room.Members == nil {
+		room.Members = []string{}
+	}
+	_, err := UserData(tm.TsMongoDB, "chat_rooms").InsertOne(ctx, room)
+	if err != nil {
+		log.Printf("Error from CreateChatRoom : %v", err)
+		return err
+	}
+	return nil
+}
+
+// GetChatRoom : this returns a chat room created by a user
+func (tm *TsMongoDBRepo) GetChatRoom(roomId string) (model.ChatRoom, error) {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	var room model.ChatRoom
+	err := UserData(tm.TsMongoDB, "chat_rooms").FindOne(ctx, bson.D{{Key: "_id", Value: roomId}}).Decode(&room)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			log.Printf("Error from GetChatRoom : %v", err)
+		}
+		return model.ChatRoom{}, err
+	}
+	return room, nil
+}
+
+// GetChatRooms : this returns the public chat rooms and the private ones a user is a member of, by name
+func (tm *TsMongoDBRepo) GetChatRooms(userId string) ([]model.ChatRoom, error) {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	filter := bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "private", Value: false}},
+		bson.D{{Key: "owner_id", Value: userId}},
+		bson.D{{Key: "members", Value: userId}},
+	}}}
+	opt := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+	cursor, err := UserData(tm.TsMongoDB, "chat_rooms").Find(ctx, filter, opt)
+	if err != nil {
+		log.Printf("Error from GetChatRooms : %v", err)
+		return nil, err
+	}
+	rooms := []model.ChatRoom{}
+	if err := cursor.All(ctx, &rooms); err != nil {
+		log.Printf("Error from GetChatRooms : %v", err)
+		return nil, err
+	}
+	return rooms, nil
+}
+
+// AddChatRoomMember : this adds a user to the members of a chat room
+func (tm *TsMongoDBRepo) AddChatRoomMember(roomId, userId string) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	update := bson.D{{Key: "$addToSet", Value: bson.D{{Key: "members", Value: userId}}}}
+	result, err := UserData(tm.TsMongoDB, "chat_rooms").UpdateOne(ctx, bson.D{{Key: "_id", Value: roomId}}, update)
+	if err != nil {
+		log.Printf("Error from AddChatRoomMember : %v", err)
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// RemoveChatRoomMember : this removes a user from the members of a chat room
+func (tm *TsMongoDBRepo) RemoveChatRoomMember(roomId, userId string) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	update := bson.D{{Key: "$pull", Value: bson.D{{Key: "members", Value: userId}}}}
+	_, err := UserData(tm.TsMongoDB, "chat_rooms").UpdateOne(ctx, bson.D{{Key: "_id", Value: roomId}}, update)
+	if err != nil {
+		log.Printf("Error from RemoveChatRoomMember : %v", err)
+		return err
+	}
+	return nil
+}
+
+// StoreReadReceipt : this keeps the last message a user read in a chat room, replacing the previous one
+func (tm *TsMongoDBRepo) StoreReadReceipt(receipt model.ReadReceipt) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	filter := bson.D{{Key: "_id", Value: receipt.ID}}
+	opt := options.Replace().SetUpsert(true)
+	_, err := UserData(tm.TsMongoDB, "chat_receipts").ReplaceOne(ctx, filter, receipt, opt)
+	if err != nil {
+		log.Printf("Error from StoreReadReceipt : %v", err)
+		return err
+	}
+	return nil
+}
+
+// GetReadReceipts : this returns the last message read by every user of a chat room
+func (tm *TsMongoDBRepo) GetReadReceipts(room string) ([]model.ReadReceipt, error) {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	cursor, err := UserData(tm.TsMongoDB, "chat_receipts").Find(ctx, bson.D{{Key: "room", Value: room}})
+	if err != nil {
+		log.Printf("Error from GetReadReceipts : %v", err)
+		return nil, err
+	}
+	receipts := []model.ReadReceipt{}
+	if err := cursor.All(ctx, &receipts); err != nil {
+		log.Printf("Error from GetReadReceipts : %v", err)
+		return nil, err
+	}
+	return receipts, nil
+}
+
+// DeleteChatMessage : this removes a message of a chat room deleted by an admin
+func (tm *TsMongoDBRepo) DeleteChatMessage(room, id string) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	filter := bson.D{{Key: "_id", Value: id}, {Key: "room", Value: room}}
+	result, err := UserData(tm.TsMongoDB, "chat_messages").DeleteOne(ctx, filter)
+	if err != nil {
+		log.Printf("Error from DeleteChatMessage : %v", err)
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// StoreChatSanction : this stores the mute, kick or ban of a user, replacing the one of the same id
+func (tm *TsMongoDBRepo) StoreChatSanction(sanction model.ChatSanction) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	filter := bson.D{{Key: "_id", Value: sanction.ID}}
+	opt := options.Replace().SetUpsert(true)
+	_, err := UserData(tm.TsMongoDB, "chat_sanctions").ReplaceOne(ctx, filter, sanction, opt)
+	if err != nil {
+		log.Printf("Error from StoreChatSanction : %v", err)
+		return err
+	}
+	return nil
+}
+
+// DeleteChatSanction : this removes a mute, kick or ban lifted or run out
+func (tm *TsMongoDBRepo) DeleteChatSanction(id string) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	_, err := UserData(tm.TsMongoDB, "chat_sanctions").DeleteOne(ctx, bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		log.Printf("Error from DeleteChatSanction : %v", err)
+		return err
+	}
+	return nil
+}
+
+/*
+GetChatSanctions : this returns the mutes, kicks and bans still running at now, the
+bans without an end included, the latest first
+*/
+func (tm *TsMongoDBRepo) GetChatSanctions(now time.Time) ([]model.ChatSanction, error) {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	filter := bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "until", Value: time.Time{}}},
+		bson.D{{Key: "until", Value: bson.D{{Key: "$gt", Value: now}}}},
+	}}}
+	opts := options.Find().SetSort(bson.D{{Key: "issued_at", Value: -1}})
+	cursor, err := UserData(tm.TsMongoDB, "chat_sanctions").Find(ctx, filter, opts)
+	if err != nil {
+		log.Printf("Error from GetChatSanctions : %v", err)
+		return nil, err
+	}
+	sanctions := []model.ChatSanction{}
+	if err := cursor.All(ctx, &sanctions); err != nil {
+		log.Printf("Error from GetChatSanctions : %v", err)
+		return nil, err
+	}
+	return sanctions, nil
+}
+
+// StoreChatAudit : this stores the audit entry of a moderation of the chat
+func (tm *TsMongoDBRepo) StoreChatAudit(entry model.ChatAudit) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	_, err := UserData(tm.TsMongoDB, "chat_audit").InsertOne(ctx, entry)
+	if err != nil {
+		log.Printf("Error from StoreChatAudit : %v", err)
+		return err
+	}
+	return nil
+}
+
+// GetChatAudit : this returns the last moderations of the chat, the latest first
+func (tm *TsMongoDBRepo) GetChatAudit(limit int64) ([]model.ChatAudit, error) {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(limit)
+	cursor, err := UserData(tm.TsMongoDB, "chat_audit").Find(ctx, bson.D{}, opts)
+	if err != nil {
+		log.Printf("Error from GetChatAudit : %v", err)
+		return nil, err
+	}
+	entries := []model.ChatAudit{}
+	if err := cursor.All(ctx, &entries); err != nil {
+		log.Printf("Error from GetChatAudit : %v", err)
+		return nil, err
+	}
+	return entries, nil
+}
+
+// StoreChatWordFilter : this replaces the words masked in the chat messages
+func (tm *TsMongoDBRepo) StoreChatWordFilter(words []string) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	if words == nil {
+		words = []string{}
+	}
+	filter := bson.D{{Key: "_id", Value: "word_filter"}}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "words", Value: words}}}}
+	opt := options.Update().SetUpsert(true)
+	_, err := UserData(tm.TsMongoDB, "chat_settings").UpdateOne(ctx, filter, update, opt)
+	if err != nil {
+		log.Printf("Error from StoreChatWordFilter : %v", err)
 		return err
 	}
 	return nil
 }
+
+// GetChatWordFilter : this returns the words masked in the chat messages, none until an admin sets them
+func (tm *TsMongoDBRepo) GetChatWordFilter() ([]string, error) {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancelCtx()
+
+	var setting struct {
+		Words []string `bson:"words"`
+	}
+	err := UserData(tm.TsMongoDB, "chat_settings").FindOne(ctx, bson.D{{Key: "_id", Value: "word_filter"}}).Decode(&setting)
+	if err == mongo.ErrNoDocuments {
+		return []string{}, nil
+	}
+	if err != nil {
+		log.Printf("Error from GetChatWordFilter : %v", err)
+		return nil, err
+	}
+	return setting.Words, nil
+}
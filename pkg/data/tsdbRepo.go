@@ -1,10 +1,16 @@
 package data
 
 import (
+	"errors"
+	"time"
+
 	"github.com/yusuf/track-space/pkg/model"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// ErrBoardChanged : the todo board was changed by another move since it was read
+var ErrBoardChanged = errors.New("the board changed meanwhile, reload it")
+
 // TrackSpaceDBRepo : interface for all the database queries
 type TrackSpaceDBRepo interface {
 	// Queries for user to interact with the database
@@ -15,18 +21,53 @@ type TrackSpaceDBRepo interface {
 	VerifyLogin(id, hashedPassword, postPassword string) (bool, string)
 	ResetUserPassword(email, newPassword string) error
 	SendUserDetails(id string) (primitive.M, error)
+	SetCalendarToken(id, token string) error
+	GetUserByCalendarToken(token string) (primitive.M, error)
+	UpdateReminderSettings(id string, lead int, dailyAgenda bool) error
+	MarkAgendaSent(id, date string) error
+	GetReminderUsers() ([]primitive.M, error)
 
 	// Queries for User Project
 
 	StoreProjectData(id string, project model.Project) error
 	GetProjectData(projectId string) (primitive.M, error)
 	ModifyProjectData(userId string, id string, project model.Project) error
+	GetProjectsByTag(userId, tag string) ([]model.Project, error)
+	GetProjectsByFolder(userId, folder string) ([]model.Project, error)
+	SetProjectPinned(userId, projectId string, pinned bool) error
+	RetagProjects(userId string, projectIds, addTags, removeTags []string) error
+	AddProjectAttachment(userId, projectId string, attachment model.Attachment, quota int64) error
+	RemoveProjectAttachment(userId, projectId string, attachment model.Attachment) error
+	GetProjectOwner(projectId string) (primitive.M, error)
+	GetUserByEmail(email string) (primitive.M, error)
+	ShareProject(userId, projectId string, share model.Share) error
+	UnshareProject(userId, projectId, sharedUserId string) error
+	GetSharedProjects(userId string) ([]model.SharedProject, error)
+	SetProjectPublicToken(userId, projectId, token string) error
+	GetProjectByPublicToken(token string) (primitive.M, error)
 
 	// Queries for User Todo Task
 
 	StoreTodoData(todo model.Todo, id string) error
 	GetTodoData(todoId string) (primitive.M, error)
 	ModifyTodoData(id string, todo model.Todo) error
+	SetTodoOccurrence(todoId string, occurrence model.Occurrence) error
+	MarkTodoReminded(todoId, date, since string) error
+	UpdateBoardColumns(id string, columns []model.BoardColumn) error
+	MoveTodoOnBoard(id string, version int, todos []model.Todo) error
+	SetTodoStatus(todoId, status string) error
+	SetTodoProject(todoId, projectId string) error
+	AddChecklistItem(todoId string, item model.ChecklistItem) error
+	ToggleChecklistItem(todoId, itemId string, done bool) error
+	ReorderChecklistItems(todoId string, itemIds []string) error
+	RemoveChecklistItem(todoId, itemId string) error
+
+	// Queries for User Workspace Export
+
+	GetUserProfile(id string) (primitive.M, error)
+	StreamUserProjects(id string, fn func(project model.Project) error) error
+	StreamUserTodos(id string, fn func(todo model.Todo) error) error
+	StreamUserStats(id string, fn func(stat model.Data) error) error
 
 	// Queries for User Statistics
 
@@ -38,6 +79,58 @@ type TrackSpaceDBRepo interface {
 	DeleteUserProject(projectId string) error
 	DeleteUserTodo(todoId string) error
 
+	// Queries for Teams
+
+	CreateTeam(team model.Team) error
+	GetTeam(teamId string) (model.Team, error)
+	GetUserTeams(userId string) ([]model.Team, error)
+	GetTeamInvites(email string) ([]model.Team, error)
+	GetTeamByInvite(token string) (model.Team, error)
+	AddTeamInvite(teamId string, invite model.TeamInvite) error
+	RemoveTeamInvite(teamId, token string) error
+	AcceptTeamInvite(teamId, token string, member model.TeamMember) error
+	SetTeamMemberRole(teamId, userId, role string) error
+	RemoveTeamMember(teamId, userId string) error
+	StoreTeamProject(teamId string, project model.Project) error
+	ModifyTeamProject(teamId string, project model.Project) error
+	DeleteTeamProject(teamId, projectId string) error
+	StoreTeamTodo(teamId string, todo model.Todo) error
+	SetTeamTodoStatus(teamId, todoId, status string) error
+	DeleteTeamTodo(teamId, todoId string) error
+
+	// Queries for Comments and Notifications
+
+	StoreComment(comment model.Comment) error
+	GetComment(commentId string) (model.Comment, error)
+	GetComments(targetType, targetId string) ([]model.Comment, error)
+	ModifyComment(commentId, authorId, content string, mentions []string, updatedAt string) error
+	DeleteComment(commentId, authorId string) error
+	DeleteTargetComments(targetType, targetId string) error
+	StoreNotifications(notifications []model.Notification) error
+	GetNotifications(userId string, limit int64) ([]model.Notification, error)
+	CountUnreadNotifications(userId string) (int64, error)
+	MarkNotificationsRead(userId string, notificationIds ...string) error
+
+	// Queries for chat rooms, their history and their moderation
+
+	StoreChatMessage(msg model.ChatMessage) error
+	GetChatMessages(room string, before time.Time, beforeID string, limit int64) ([]model.ChatMessage, error)
+	StoreReadReceipt(receipt model.ReadReceipt) error
+	GetReadReceipts(room string) ([]model.ReadReceipt, error)
+	CreateChatRoom(room model.ChatRoom) error
+	GetChatRoom(roomId string) (model.ChatRoom, error)
+	GetChatRooms(userId string) ([]model.ChatRoom, error)
+	AddChatRoomMember(roomId, userId string) error
+	RemoveChatRoomMember(roomId, userId string) error
+	DeleteChatMessage(room, id string) error
+	StoreChatSanction(sanction model.ChatSanction) error
+	DeleteChatSanction(id string) error
+	GetChatSanctions(now time.Time) ([]model.ChatSanction, error)
+	StoreChatAudit(entry model.ChatAudit) error
+	GetChatAudit(limit int64) ([]model.ChatAudit, error)
+	StoreChatWordFilter(words []string) error
+	GetChatWordFilter() ([]string, error)
+
 	// Queries for Admin
 
 	GetAllUserData() ([]primitive.M, error)
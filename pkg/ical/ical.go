@@ -0,0 +1,330 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/yusuf/track-space/pkg/model"
+	"github.com/yusuf/track-space/pkg/recur"
+)
+
+const (
+	dateLayout      = "20060102"
+	localTimeLayout = "20060102T150405"
+	utcTimeLayout   = "20060102T150405Z"
+	// maxLineOctets : content lines longer than this are folded as RFC 5545 requires
+	maxLineOctets = 75
+)
+
+// UID : this returns the iCalendar UID of a todo, keeping the UID of imported todos
+func UID(todo model.Todo) string {
+	if todo.UID != "" {
+		return todo.UID
+	}
+	return todo.ID + "@track-space"
+}
+
+/*
+Encode : this writes the todos as an iCalendar (.ics) document. Every todo becomes a
+VEVENT carrying its recurrence rule; skipped occurrences are written as EXDATE
+*/
+func Encode(w io.Writer, name string, todos []model.Todo) error {
+	enc := NewEncoder(w, name)
+	for _, todo := range todos {
+		if err := enc.Encode(todo); err != nil {
+			return err
+		}
+	}
+	return enc.Close()
+}
+
+// Encoder : writes an iCalendar document one todo at a time
+type Encoder struct {
+	bw     *bufio.Writer
+	name   string
+	stamp  string
+	opened bool
+}
+
+// NewEncoder : this returns an encoder writing a calendar of the given name to w
+func NewEncoder(w io.Writer, name string) *Encoder {
+	return &Encoder{
+		bw:    bufio.NewWriter(w),
+		name:  name,
+		stamp: time.Now().UTC().Format(utcTimeLayout),
+	}
+}
+
+func (e *Encoder) open() {
+	if e.opened {
+		return
+	}
+	e.opened = true
+	writeLine(e.bw, "BEGIN:VCALENDAR")
+	writeLine(e.bw, "VERSION:2.0")
+	writeLine(e.bw, "PRODID:-//track-space//todo//EN")
+	writeLine(e.bw, "CALSCALE:GREGORIAN")
+	writeLine(e.bw, "X-WR-CALNAME:"+escapeText(e.name))
+}
+
+/*
+Encode : this writes a todo as a VEVENT. Todos without a valid schedule cannot be
+placed on a calendar and are left out
+*/
+func (e *Encoder) Encode(todo model.Todo) error {
+	e.open()
+	start, err := recur.StartOf(todo)
+	if err != nil {
+		return nil
+	}
+	bw := e.bw
+	writeLine(bw, "BEGIN:VEVENT")
+	writeLine(bw, "UID:"+escapeText(UID(todo)))
+	writeLine(bw, "DTSTAMP:"+e.stamp)
+	writeLine(bw, "SUMMARY:"+escapeText(todo.ToDoTask))
+	if todo.StartTime == "" {
+		writeLine(bw, "DTSTART;VALUE=DATE:"+start.Format(dateLayout))
+	} else {
+		writeLine(bw, "DTSTART:"+start.Format(localTimeLayout))
+		if end, err := time.ParseInLocation("2006-01-02 15:04", todo.DateSchedule+" "+todo.EndTime, time.Local); err == nil && end.After(start) {
+			writeLine(bw, "DTEND:"+end.Format(localTimeLayout))
+		}
+	}
+	if todo.Recurrence != "" {
+		writeLine(bw, "RRULE:"+todo.Recurrence)
+		for _, o := range todo.Occurrences {
+			if o.Status != recur.StatusSkipped {
+				continue
+			}
+			day, err := time.ParseInLocation("2006-01-02", o.Date, time.Local)
+			if err != nil {
+				continue
+			}
+			skipped := time.Date(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), 0, 0, time.Local)
+			if todo.StartTime == "" {
+				writeLine(bw, "EXDATE;VALUE=DATE:"+skipped.Format(dateLayout))
+			} else {
+				writeLine(bw, "EXDATE:"+skipped.Format(localTimeLayout))
+			}
+		}
+	}
+	if todo.Status == "Done" {
+		writeLine(bw, "STATUS:CONFIRMED")
+		writeLine(bw, "X-TRACKSPACE-STATUS:COMPLETED")
+	}
+	writeLine(bw, "END:VEVENT")
+	return nil
+}
+
+// Close : this ends the calendar document and flushes it to the writer
+func (e *Encoder) Close() error {
+	e.open()
+	writeLine(e.bw, "END:VCALENDAR")
+	return e.bw.Flush()
+}
+
+/*
+Decode : this reads VEVENT and VTODO entries from an iCalendar document and converts
+them to todos. The UID of every entry is kept on the todo for deduplication. Recurrence
+rules outside the subset track-space supports are dropped, keeping the first occurrence
+*/
+func Decode(r io.Reader) ([]model.Todo, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		todos   []model.Todo
+		current map[string]property
+		inside  bool
+	)
+	for _, line := range lines {
+		prop, err := parseLine(line)
+		if err != nil {
+			continue
+		}
+		switch {
+		case prop.name == "BEGIN" && (prop.value == "VEVENT" || prop.value == "VTODO"):
+			inside = true
+			current = make(map[string]property)
+		case prop.name == "END" && (prop.value == "VEVENT" || prop.value == "VTODO"):
+			if !inside {
+				continue
+			}
+			inside = false
+			todo, err := toTodo(current)
+			if err != nil {
+				continue
+			}
+			todos = append(todos, todo)
+		case inside:
+			if _, ok := current[prop.name]; !ok {
+				current[prop.name] = prop
+			}
+		}
+	}
+	if len(todos) == 0 {
+		return nil, errors.New("no VEVENT or VTODO entries found")
+	}
+	return todos, nil
+}
+
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+func toTodo(props map[string]property) (model.Todo, error) {
+	var todo model.Todo
+	uid, ok := props["UID"]
+	if !ok || uid.value == "" {
+		return todo, errors.New("entry without UID")
+	}
+	todo.UID = unescapeText(uid.value)
+	todo.ToDoTask = unescapeText(props["SUMMARY"].value)
+
+	startProp, ok := props["DTSTART"]
+	if !ok {
+		startProp, ok = props["DUE"]
+	}
+	if !ok {
+		return todo, errors.New("entry without start date")
+	}
+	start, allDay, err := parseTime(startProp)
+	if err != nil {
+		return todo, err
+	}
+	todo.DateSchedule = start.Format("2006-01-02")
+	if !allDay {
+		todo.StartTime = start.Format("15:04")
+	}
+
+	endProp, ok := props["DTEND"]
+	if !ok {
+		endProp, ok = props["DUE"]
+	}
+	if ok && !allDay {
+		if end, _, err := parseTime(endProp); err == nil && end.After(start) {
+			todo.EndTime = end.Format("15:04")
+		}
+	}
+
+	if rrule, ok := props["RRULE"]; ok {
+		if rule, err := recur.Parse(rrule.value); err == nil {
+			todo.Recurrence = rule.String()
+		}
+	}
+
+	todo.Status = "Not done"
+	status := props["STATUS"].value
+	if status == "COMPLETED" || props["X-TRACKSPACE-STATUS"].value == "COMPLETED" {
+		todo.Status = "Done"
+	}
+	return todo, nil
+}
+
+func parseTime(prop property) (time.Time, bool, error) {
+	value := prop.value
+	if prop.params["VALUE"] == "DATE" || len(value) == len(dateLayout) {
+		t, err := time.ParseInLocation(dateLayout, value, time.Local)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(utcTimeLayout, value)
+		return t.Local(), false, err
+	}
+	loc := time.Local
+	if tzid, ok := prop.params["TZID"]; ok {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	t, err := time.ParseInLocation(localTimeLayout, value, loc)
+	return t.Local(), false, err
+}
+
+// unfold : this joins folded content lines back together
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+func parseLine(line string) (property, error) {
+	var prop property
+	colon := indexOutsideQuotes(line, ':')
+	if colon < 0 {
+		return prop, fmt.Errorf("invalid content line %q", line)
+	}
+	prop.value = line[colon+1:]
+	parts := strings.Split(line[:colon], ";")
+	prop.name = strings.ToUpper(parts[0])
+	prop.params = make(map[string]string)
+	for _, p := range parts[1:] {
+		kv := strings.SplitN(p, "=", 2)
+		if len(kv) == 2 {
+			prop.params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+		}
+	}
+	return prop, nil
+}
+
+func indexOutsideQuotes(s string, c byte) int {
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case c:
+			if !quoted {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func writeLine(w *bufio.Writer, line string) {
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		// never split a multi-byte character
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		_, _ = w.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		// the leading space of a continuation line counts towards its octets
+		limit = maxLineOctets - 1
+	}
+	_, _ = w.WriteString(line + "\r\n")
+}
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`, "\r", "")
+
+var textUnescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+func unescapeText(s string) string {
+	return textUnescaper.Replace(s)
+}
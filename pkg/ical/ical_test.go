@@ -0,0 +1,118 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/yusuf/track-space/pkg/model"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	todos := []model.Todo{
+		{
+			ID:           "6350f1a2b3c4d5e6f7a8b9c0",
+			ToDoTask:     "weekly review, with team; notes",
+			DateSchedule: "2026-10-05",
+			StartTime:    "14:00",
+			EndTime:      "15:30",
+			Status:       "Not done",
+			Recurrence:   "FREQ=WEEKLY;BYDAY=MO;COUNT=4",
+		},
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, "track-space", todos); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "UID:6350f1a2b3c4d5e6f7a8b9c0@track-space\r\n") {
+		t.Errorf("Encode() missing UID:\n%s", buf.String())
+	}
+
+	got, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("Decode() returned %d todos, want 1", len(got))
+	}
+	want := todos[0]
+	if got[0].UID != UID(want) || got[0].ToDoTask != want.ToDoTask || got[0].DateSchedule != want.DateSchedule ||
+		got[0].StartTime != want.StartTime || got[0].EndTime != want.EndTime || got[0].Recurrence != want.Recurrence {
+		t.Errorf("Decode() = %+v, want %+v", got[0], want)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	data := "BEGIN:VCALENDAR\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:abc@example.com\r\n" +
+		"SUMMARY:All day \r\n planning\r\n" +
+		"DTSTART;VALUE=DATE:20261020\r\n" +
+		"RRULE:FREQ=YEARLY\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VTODO\r\n" +
+		"UID:def@example.com\r\n" +
+		"SUMMARY:Ship release\r\n" +
+		"DUE;TZID=UTC:20261021T170000\r\n" +
+		"STATUS:COMPLETED\r\n" +
+		"END:VTODO\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"SUMMARY:no uid\r\n" +
+		"DTSTART:20261022T090000\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+
+	got, err := Decode(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("Decode() returned %d todos, want 2", len(got))
+	}
+	if got[0].ToDoTask != "All day planning" || got[0].DateSchedule != "2026-10-20" || got[0].StartTime != "" {
+		t.Errorf("Decode() all day entry = %+v", got[0])
+	}
+	if got[0].Recurrence != "" {
+		t.Errorf("Decode() kept unsupported recurrence %q", got[0].Recurrence)
+	}
+	if got[1].UID != "def@example.com" || got[1].Status != "Done" {
+		t.Errorf("Decode() todo entry = %+v", got[1])
+	}
+}
+
+func TestEncodeFoldsLongLines(t *testing.T) {
+	todos := []model.Todo{
+		{
+			ID:           "6350f1a2b3c4d5e6f7a8b9c1",
+			ToDoTask:     strings.Repeat("plan the quarterly review é ", 12),
+			DateSchedule: "2026-10-05",
+			StartTime:    "14:00",
+			EndTime:      "15:30",
+			Status:       "Not done",
+		},
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, "track-space", todos); err != nil {
+		t.Fatal(err)
+	}
+	folded := 0
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line of %d octets: %q", len(line), line)
+		}
+		if strings.HasPrefix(line, " ") {
+			folded++
+		}
+	}
+	if folded < 2 {
+		t.Fatalf("Encode() folded %d lines, want the summary folded more than once:\n%s", folded, buf.String())
+	}
+
+	got, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].ToDoTask != todos[0].ToDoTask {
+		t.Errorf("Decode() = %+v, want the summary %q", got, todos[0].ToDoTask)
+	}
+}
@@ -1,8 +1,11 @@
 package key
 
 import (
-	"golang.org/x/crypto/bcrypt"
+	"crypto/rand"
+	"encoding/hex"
 	"log"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 /*
@@ -39,3 +42,15 @@ func VerifyPassword(inputPassword, hashedPassword string) (bool, string) {
 
 	return validHash, hashMsg
 }
+
+/*
+GenerateToken : this creates a random hex token that is hard to guess, for secret urls
+such as the calendar feed
+*/
+func GenerateToken() string {
+	keyByte := make([]byte, 32)
+	if _, err := rand.Read(keyByte); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(keyByte)
+}
@@ -56,3 +56,13 @@ func TestVerifyPassword(t *testing.T) {
 		})
 	}
 }
+
+func TestGenerateToken(t *testing.T) {
+	first, second := GenerateToken(), GenerateToken()
+	if len(first) != 64 {
+		t.Errorf("GenerateToken() length = %v, want 64", len(first))
+	}
+	if first == second {
+		t.Errorf("GenerateToken() returned the same token twice")
+	}
+}
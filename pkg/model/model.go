@@ -1,41 +1,140 @@
 package model
 
+import "time"
+
 type Auth struct {
 	Token string
 }
 
 // User : Master struct model for user
 type User struct {
-	ID             string    `bson:"_id" Usage:"required,alphanumeric"`
-	FirstName      string    `bson:"first_name" Usage:"required,alpha"`
-	LastName       string    `bson:"last_name" Usage:"required,alpha"`
-	Email          string    `bson:"email" Usage:"required,email"`
-	Password       string    `bson:"password" Usage:"min=8,max=20"`
-	YrsOfExp       string    `bson:"yrs_of_exp" Usage:"numeric"`
-	Country        string    `bson:"country" Usage:"required,alpha"`
-	PhoneNumber    string    `bson:"phone_number" Usage:"required"`
-	IPAddress      string    `bson:"ip_address"`
-	Address        string    `bson:"address" Usage:"required"`
-	Profession     string    `bson:"profession"`
-	Stack          []string  `bson:"stack"`
-	ProjectDetails []Project `bson:"project_details"`
-	Todo           []Todo    `bson:"todo"`
-	Data           []Data    `bson:"data"`
-	CreatedAt      string    `bson:"created_at" Usage:"datetime=2006-01-02"`
-	UpdatedAt      string    `bson:"updated_at" Usage:"datetime=2006-01-02"`
-	Token          string    `bson:"token" Usage:"jwt"`
-	RenewToken     string    `bson:"renew_token" Usage:"jwt"`
+	ID             string        `bson:"_id" Usage:"required,alphanumeric"`
+	FirstName      string        `bson:"first_name" Usage:"required,alpha"`
+	LastName       string        `bson:"last_name" Usage:"required,alpha"`
+	Email          string        `bson:"email" Usage:"required,email"`
+	Password       string        `bson:"password" Usage:"min=8,max=20"`
+	YrsOfExp       string        `bson:"yrs_of_exp" Usage:"numeric"`
+	Country        string        `bson:"country" Usage:"required,alpha"`
+	PhoneNumber    string        `bson:"phone_number" Usage:"required"`
+	IPAddress      string        `bson:"ip_address"`
+	Address        string        `bson:"address" Usage:"required"`
+	Profession     string        `bson:"profession"`
+	Stack          []string      `bson:"stack"`
+	ProjectDetails []Project     `bson:"project_details"`
+	Todo           []Todo        `bson:"todo"`
+	Data           []Data        `bson:"data"`
+	CreatedAt      string        `bson:"created_at" Usage:"datetime=2006-01-02"`
+	UpdatedAt      string        `bson:"updated_at" Usage:"datetime=2006-01-02"`
+	Token          string        `bson:"token" Usage:"jwt"`
+	RenewToken     string        `bson:"renew_token" Usage:"jwt"`
+	CalendarToken  string        `bson:"calendar_token"`
+	ReminderLead   int           `bson:"reminder_lead"`
+	DailyAgenda    bool          `bson:"daily_agenda"`
+	AgendaSentOn   string        `bson:"agenda_sent_on"`
+	Board          []BoardColumn `bson:"board"`
+	BoardVersion   int           `bson:"board_version"`
+	StorageUsed    int64         `bson:"storage_used"`
 }
 
 // Project : Struct model for user project
 type Project struct {
-	ID             string `bson:"_id"`
-	ProjectName    string `bson:"project_name" Usage:"required"`
-	ProjectContent string `bson:"project_content"`
-	ToolsUseAs     string `bson:"tools_use_as" Usage:"required"`
-	UpdatedAt      string `bson:"updated_at"`
-	CreatedAt      string `bson:"created_at"`
-	Status         string `bson:"status"`
+	ID             string       `bson:"_id"`
+	ProjectName    string       `bson:"project_name" Usage:"required"`
+	ProjectContent string       `bson:"project_content"`
+	ContentFormat  string       `bson:"content_format"`
+	ToolsUseAs     string       `bson:"tools_use_as" Usage:"required"`
+	UpdatedAt      string       `bson:"updated_at"`
+	CreatedAt      string       `bson:"created_at"`
+	Status         string       `bson:"status"`
+	Tags           []string     `bson:"tags"`
+	Folder         string       `bson:"folder"`
+	Pinned         bool         `bson:"pinned"`
+	Attachments    []Attachment `bson:"attachments"`
+	Shares         []Share      `bson:"shares"`
+	PublicToken    string       `bson:"public_token,omitempty"`
+}
+
+// Share : struct model for a user a project is shared with, as viewer or editor
+type Share struct {
+	UserID   string `bson:"user_id"`
+	Email    string `bson:"email"`
+	Role     string `bson:"role"`
+	SharedAt string `bson:"shared_at"`
+}
+
+// SharedProject : struct model for a project another user shares with the logged-in user
+type SharedProject struct {
+	OwnerID        string  `bson:"owner_id"`
+	OwnerFirstName string  `bson:"owner_first_name"`
+	OwnerLastName  string  `bson:"owner_last_name"`
+	Project        Project `bson:"project"`
+}
+
+// Team : struct model for a team sharing its projects and todos among its members
+type Team struct {
+	ID             string       `bson:"_id"`
+	Name           string       `bson:"name"`
+	Members        []TeamMember `bson:"members"`
+	Invites        []TeamInvite `bson:"invites"`
+	ProjectDetails []Project    `bson:"project_details"`
+	Todo           []Todo       `bson:"todo"`
+	CreatedAt      string       `bson:"created_at"`
+}
+
+// TeamMember : struct model for a user belonging to a team with a role
+type TeamMember struct {
+	UserID    string `bson:"user_id"`
+	Email     string `bson:"email"`
+	FirstName string `bson:"first_name"`
+	LastName  string `bson:"last_name"`
+	Role      string `bson:"role"`
+	JoinedAt  string `bson:"joined_at"`
+}
+
+// TeamInvite : struct model for a pending invitation to join a team, answered through its secret token
+type TeamInvite struct {
+	Token     string `bson:"token"`
+	Email     string `bson:"email"`
+	Role      string `bson:"role"`
+	InvitedBy string `bson:"invited_by"`
+	CreatedAt string `bson:"created_at"`
+}
+
+// Comment : struct model for a comment on a project or a todo, replies point to their parent comment
+type Comment struct {
+	ID         string   `bson:"_id"`
+	TargetType string   `bson:"target_type"`
+	TargetID   string   `bson:"target_id"`
+	OwnerID    string   `bson:"owner_id"`
+	ParentID   string   `bson:"parent_id"`
+	AuthorID   string   `bson:"author_id"`
+	AuthorName string   `bson:"author_name"`
+	Content    string   `bson:"content"`
+	Mentions   []string `bson:"mentions"`
+	CreatedAt  string   `bson:"created_at"`
+	UpdatedAt  string   `bson:"updated_at"`
+	Deleted    bool     `bson:"deleted"`
+}
+
+// Notification : struct model for an in-app notification of a user, like a mention in a comment
+type Notification struct {
+	ID        string `bson:"_id"`
+	UserID    string `bson:"user_id"`
+	Kind      string `bson:"kind"`
+	Message   string `bson:"message"`
+	Link      string `bson:"link"`
+	Read      bool   `bson:"read"`
+	CreatedAt string `bson:"created_at"`
+}
+
+// Attachment : struct model for a file attached to a project, its content lives in the blob store
+type Attachment struct {
+	ID          string `bson:"_id"`
+	Name        string `bson:"name"`
+	ContentType string `bson:"content_type"`
+	Size        int64  `bson:"size"`
+	Key         string `bson:"key"`
+	UploadedAt  string `bson:"uploaded_at"`
 }
 
 // Data : Struct model to navigate all user activity
@@ -61,12 +160,40 @@ type Email struct {
 
 // Todo : struct model for todo schedule for use
 type Todo struct {
-	ID           string `bson:"_id"`
-	ToDoTask     string `bson:"to_do_task"`
-	DateSchedule string `bson:"schedule_date"`
-	StartTime    string `bson:"start_time"`
-	EndTime      string `bson:"end_time"`
-	Status       string `bson:"status"`
+	ID           string          `bson:"_id"`
+	ToDoTask     string          `bson:"to_do_task"`
+	DateSchedule string          `bson:"schedule_date"`
+	StartTime    string          `bson:"start_time"`
+	EndTime      string          `bson:"end_time"`
+	Status       string          `bson:"status"`
+	Recurrence   string          `bson:"recurrence"`
+	Occurrences  []Occurrence    `bson:"occurrences"`
+	UID          string          `bson:"uid"`
+	Reminded     []string        `bson:"reminded"`
+	Column       string          `bson:"column"`
+	Position     int             `bson:"position"`
+	Checklist    []ChecklistItem `bson:"checklist"`
+	ProjectID    string          `bson:"project_id"`
+}
+
+// ChecklistItem : struct model for one step of a todo checklist
+type ChecklistItem struct {
+	ID   string `bson:"_id"`
+	Text string `bson:"text" Usage:"required"`
+	Done bool   `bson:"done"`
+}
+
+// BoardColumn : struct model for a column of the user todo board
+type BoardColumn struct {
+	Key      string `bson:"key"`
+	Title    string `bson:"title"`
+	WIPLimit int    `bson:"wip_limit"`
+}
+
+// Occurrence : struct model for the completion or skip of one occurrence of a recurring todo
+type Occurrence struct {
+	Date   string `bson:"date"`
+	Status string `bson:"status"`
 }
 
 type SessionData struct {
@@ -74,3 +201,67 @@ type SessionData struct {
 	Email    string
 	Password string
 }
+
+// ChatMessage : struct model for a message of the chat room, kept in the chat_messages collection
+type ChatMessage struct {
+	ID         string    `bson:"_id" json:"id"`
+	Room       string    `bson:"room" json:"room"`
+	SenderID   string    `bson:"sender_id" json:"sender_id"`
+	SenderName string    `bson:"sender_name" json:"sender"`
+	Message    string    `bson:"message" json:"message"`
+	SentAt     time.Time `bson:"sent_at" json:"sent_at"`
+}
+
+// ChatRoom : struct model for a chat room created by a user, open to everyone or to its invited members
+type ChatRoom struct {
+	ID        string   `bson:"_id"`
+	Name      string   `bson:"name"`
+	Private   bool     `bson:"private"`
+	OwnerID   string   `bson:"owner_id"`
+	Members   []string `bson:"members"`
+	CreatedAt string   `bson:"created_at"`
+}
+
+/*
+ReadReceipt : struct model for the last message a user read in a chat room, kept in the
+chat_receipts collection once per user and room
+*/
+type ReadReceipt struct {
+	ID        string    `bson:"_id" json:"-"`
+	Room      string    `bson:"room" json:"room"`
+	UserID    string    `bson:"user_id" json:"user_id"`
+	UserName  string    `bson:"user_name" json:"user"`
+	MessageID string    `bson:"message_id" json:"message_id"`
+	SentAt    time.Time `bson:"sent_at" json:"sent_at"`
+	ReadAt    time.Time `bson:"read_at" json:"read_at"`
+}
+
+/*
+ChatSanction : struct model for a user muted, kicked or banned from a chat room by an
+admin, kept in the chat_sanctions collection until it is lifted. A ban without an
+end lasts until lifted
+*/
+type ChatSanction struct {
+	ID       string    `bson:"_id" json:"id"`
+	Kind     string    `bson:"kind" json:"kind"`
+	UserID   string    `bson:"user_id" json:"user_id"`
+	Room     string    `bson:"room,omitempty" json:"room,omitempty"`
+	Reason   string    `bson:"reason" json:"reason"`
+	AdminID  string    `bson:"admin_id" json:"admin_id"`
+	Until    time.Time `bson:"until" json:"until"`
+	IssuedAt time.Time `bson:"issued_at" json:"issued_at"`
+}
+
+// ChatAudit : struct model for a moderation action of an admin on the chat, kept in the chat_audit collection
+type ChatAudit struct {
+	ID        string    `bson:"_id"`
+	Action    string    `bson:"action"`
+	AdminID   string    `bson:"admin_id"`
+	AdminName string    `bson:"admin_name"`
+	UserID    string    `bson:"user_id,omitempty"`
+	Room      string    `bson:"room,omitempty"`
+	MessageID string    `bson:"message_id,omitempty"`
+	Detail    string    `bson:"detail,omitempty"`
+	Reason    string    `bson:"reason,omitempty"`
+	CreatedAt time.Time `bson:"created_at"`
+}
@@ -0,0 +1,394 @@
+package recur
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yusuf/track-space/pkg/model"
+)
+
+// Supported frequencies of the RFC 5545 RRULE subset used by track-space
+const (
+	Daily   = "DAILY"
+	Weekly  = "WEEKLY"
+	Monthly = "MONTHLY"
+)
+
+// Status of a single occurrence of a recurring todo
+const (
+	StatusDone    = "Done"
+	StatusSkipped = "Skipped"
+	StatusPending = "Not done"
+)
+
+// maxIterations : guard to stop expanding a rule that never reaches the requested range
+const maxIterations = 50000
+
+var dayCodes = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+var weekdayCodes = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+/*
+Rule : a parsed recurrence rule. It supports FREQ (DAILY, WEEKLY, MONTHLY),
+INTERVAL, BYDAY (weekly rules only), UNTIL and COUNT
+*/
+type Rule struct {
+	Freq     string
+	Interval int
+	ByDay    []time.Weekday
+	Until    time.Time
+	Count    int
+}
+
+/*
+Instance : one expanded occurrence of a todo within a requested date range
+*/
+type Instance struct {
+	TodoID    string `json:"todo_id"`
+	Task      string `json:"task"`
+	Date      string `json:"date"`
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+	Status    string `json:"status"`
+	Recurring bool   `json:"recurring"`
+}
+
+/*
+Parse : this converts an RRULE string such as "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=10"
+into a Rule. The "RRULE:" prefix is optional
+*/
+func Parse(value string) (Rule, error) {
+	var rule Rule
+	value = strings.TrimSpace(value)
+	value = strings.TrimPrefix(strings.ToUpper(value), "RRULE:")
+	if value == "" {
+		return rule, errors.New("empty recurrence rule")
+	}
+
+	for _, part := range strings.Split(value, ";") {
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return rule, fmt.Errorf("invalid recurrence rule part %q", part)
+		}
+		switch kv[0] {
+		case "FREQ":
+			switch kv[1] {
+			case Daily, Weekly, Monthly:
+				rule.Freq = kv[1]
+			default:
+				return rule, fmt.Errorf("unsupported recurrence frequency %q", kv[1])
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(kv[1])
+			if err != nil || n < 1 {
+				return rule, fmt.Errorf("invalid recurrence interval %q", kv[1])
+			}
+			rule.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(kv[1])
+			if err != nil || n < 1 {
+				return rule, fmt.Errorf("invalid recurrence count %q", kv[1])
+			}
+			rule.Count = n
+		case "UNTIL":
+			until, err := parseUntil(kv[1])
+			if err != nil {
+				return rule, err
+			}
+			rule.Until = until
+		case "BYDAY":
+			for _, code := range strings.Split(kv[1], ",") {
+				day, ok := dayCodes[code]
+				if !ok {
+					return rule, fmt.Errorf("unsupported recurrence day %q", code)
+				}
+				rule.ByDay = append(rule.ByDay, day)
+			}
+		case "WKST":
+			// weeks always start on Monday in track-space
+		default:
+			return rule, fmt.Errorf("unsupported recurrence rule part %q", kv[0])
+		}
+	}
+
+	if rule.Freq == "" {
+		return rule, errors.New("recurrence rule has no frequency")
+	}
+	if rule.Count > 0 && !rule.Until.IsZero() {
+		return rule, errors.New("recurrence rule cannot have both COUNT and UNTIL")
+	}
+	if len(rule.ByDay) > 0 && rule.Freq != Weekly {
+		return rule, errors.New("BYDAY is only supported on weekly recurrence")
+	}
+	if rule.Interval == 0 {
+		rule.Interval = 1
+	}
+	return rule, nil
+}
+
+func parseUntil(value string) (time.Time, error) {
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"20060102T150405", "20060102", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			if layout == "20060102" || layout == "2006-01-02" {
+				// a date-only UNTIL includes the whole day
+				t = t.Add(24*time.Hour - time.Second)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid recurrence end date %q", value)
+}
+
+// String : this formats the rule back into an RRULE value
+func (r Rule) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		var days []string
+		for _, d := range r.ByDay {
+			days = append(days, weekdayCodes[d])
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	return strings.Join(parts, ";")
+}
+
+/*
+Between : this expands the rule from the first occurrence at start and returns
+every occurrence that falls between from and to (both inclusive). The start
+always counts as the first occurrence of the series
+*/
+func (r Rule) Between(start, from, to time.Time) []time.Time {
+	var result []time.Time
+	count := 0
+	emit := func(t time.Time) bool {
+		if t.Before(start) {
+			return true
+		}
+		if !r.Until.IsZero() && t.After(r.Until) {
+			return false
+		}
+		if t.After(to) {
+			return false
+		}
+		count++
+		if r.Count > 0 && count > r.Count {
+			return false
+		}
+		if !t.Before(from) {
+			result = append(result, t)
+		}
+		return true
+	}
+
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+
+	switch r.Freq {
+	case Daily:
+		for i := 0; i < maxIterations; i++ {
+			if !emit(start.AddDate(0, 0, i*interval)) {
+				break
+			}
+		}
+	case Weekly:
+		days := r.ByDay
+		if len(days) == 0 {
+			days = []time.Weekday{start.Weekday()}
+		}
+		// weeks start on Monday
+		offset := (int(start.Weekday()) + 6) % 7
+		weekStart := start.AddDate(0, 0, -offset)
+	weeks:
+		for i := 0; i < maxIterations; i++ {
+			week := weekStart.AddDate(0, 0, i*7*interval)
+			for d := 0; d < 7; d++ {
+				day := week.AddDate(0, 0, d)
+				if !containsDay(days, day.Weekday()) {
+					continue
+				}
+				if !emit(day) {
+					break weeks
+				}
+			}
+		}
+	case Monthly:
+		for i := 0; i < maxIterations; i++ {
+			month := time.Date(start.Year(), start.Month()+time.Month(i*interval), 1,
+				start.Hour(), start.Minute(), start.Second(), 0, start.Location())
+			day := month.AddDate(0, 0, start.Day()-1)
+			if day.Month() != month.Month() {
+				// months without this day are skipped as RFC 5545 requires
+				if month.After(to) {
+					break
+				}
+				continue
+			}
+			if !emit(day) {
+				break
+			}
+		}
+	}
+	return result
+}
+
+// Includes : this reports whether the given day is an occurrence of the rule
+func (r Rule) Includes(start time.Time, day time.Time) bool {
+	from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, start.Location())
+	to := from.Add(24*time.Hour - time.Second)
+	return len(r.Between(start, from, to)) > 0
+}
+
+func containsDay(days []time.Weekday, day time.Weekday) bool {
+	for _, d := range days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+FromPreset : this builds an RRULE from the options of the todo form. The preset
+is one of daily, weekdays, weekly or monthly; days are RRULE day codes for weekly
+rules; until is a yyyy-mm-dd date and count the number of occurrences
+*/
+func FromPreset(preset string, days []string, interval, until, count string) (string, error) {
+	var rule Rule
+	switch strings.ToLower(preset) {
+	case "", "none":
+		return "", nil
+	case "daily":
+		rule.Freq = Daily
+	case "weekdays":
+		rule.Freq = Weekly
+		rule.ByDay = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+	case "weekly":
+		rule.Freq = Weekly
+		for _, code := range days {
+			day, ok := dayCodes[strings.ToUpper(code)]
+			if !ok {
+				return "", fmt.Errorf("unsupported recurrence day %q", code)
+			}
+			rule.ByDay = append(rule.ByDay, day)
+		}
+	case "monthly":
+		rule.Freq = Monthly
+	default:
+		return "", fmt.Errorf("unsupported recurrence %q", preset)
+	}
+
+	rule.Interval = 1
+	if interval != "" {
+		n, err := strconv.Atoi(interval)
+		if err != nil || n < 1 {
+			return "", fmt.Errorf("invalid recurrence interval %q", interval)
+		}
+		rule.Interval = n
+	}
+	if until != "" {
+		t, err := time.ParseInLocation("2006-01-02", until, time.Local)
+		if err != nil {
+			return "", fmt.Errorf("invalid recurrence end date %q", until)
+		}
+		rule.Until = t.Add(24*time.Hour - time.Second)
+	}
+	if count != "" {
+		n, err := strconv.Atoi(count)
+		if err != nil || n < 1 {
+			return "", fmt.Errorf("invalid recurrence count %q", count)
+		}
+		rule.Count = n
+	}
+	if rule.Count > 0 && !rule.Until.IsZero() {
+		return "", errors.New("choose either an end date or a number of occurrences")
+	}
+	return rule.String(), nil
+}
+
+// StartOf : this returns the first occurrence of a todo from its schedule date and start time
+func StartOf(todo model.Todo) (time.Time, error) {
+	if todo.StartTime == "" {
+		return time.ParseInLocation("2006-01-02", todo.DateSchedule, time.Local)
+	}
+	return time.ParseInLocation("2006-01-02 15:04", todo.DateSchedule+" "+todo.StartTime, time.Local)
+}
+
+/*
+Occurrences : this expands a todo into its occurrences between from and to. A todo
+without a recurrence rule has a single occurrence on its schedule date. Completion
+or skip of single occurrences is taken from todo.Occurrences without changing the series
+*/
+func Occurrences(todo model.Todo, from, to time.Time) ([]Instance, error) {
+	start, err := StartOf(todo)
+	if err != nil {
+		return nil, err
+	}
+
+	var dates []time.Time
+	if todo.Recurrence == "" {
+		if !start.Before(from) && !start.After(to) {
+			dates = append(dates, start)
+		}
+	} else {
+		rule, err := Parse(todo.Recurrence)
+		if err != nil {
+			return nil, err
+		}
+		dates = rule.Between(start, from, to)
+	}
+
+	overrides := make(map[string]string)
+	for _, o := range todo.Occurrences {
+		overrides[o.Date] = o.Status
+	}
+
+	var instances []Instance
+	for _, d := range dates {
+		date := d.Format("2006-01-02")
+		status := todo.Status
+		if todo.Recurrence != "" {
+			status = StatusPending
+			if s, ok := overrides[date]; ok {
+				status = s
+			}
+		}
+		instances = append(instances, Instance{
+			TodoID:    todo.ID,
+			Task:      todo.ToDoTask,
+			Date:      date,
+			StartTime: todo.StartTime,
+			EndTime:   todo.EndTime,
+			Status:    status,
+			Recurring: todo.Recurrence != "",
+		})
+	}
+	return instances, nil
+}
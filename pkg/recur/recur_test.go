@@ -0,0 +1,148 @@
+package recur
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yusuf/track-space/pkg/model"
+)
+
+func day(value string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func dates(times []time.Time) []string {
+	var result []string
+	for _, t := range times {
+		result = append(result, t.Format("2006-01-02"))
+	}
+	return result
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"daily", "FREQ=DAILY", "FREQ=DAILY", false},
+		{"prefix", "RRULE:FREQ=WEEKLY;BYDAY=MO,WE", "FREQ=WEEKLY;BYDAY=MO,WE", false},
+		{"interval-count", "FREQ=MONTHLY;INTERVAL=2;COUNT=3", "FREQ=MONTHLY;INTERVAL=2;COUNT=3", false},
+		{"no-freq", "COUNT=3", "", true},
+		{"yearly", "FREQ=YEARLY", "", true},
+		{"count-and-until", "FREQ=DAILY;COUNT=2;UNTIL=20261231", "", true},
+		{"byday-monthly", "FREQ=MONTHLY;BYDAY=MO", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && rule.String() != tt.want {
+				t.Errorf("Parse() = %v, want %v", rule.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestRule_Between(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  string
+		start string
+		from  string
+		to    string
+		want  []string
+	}{
+		{"daily-count", "FREQ=DAILY;COUNT=3", "2026-10-01", "2026-09-01", "2026-12-01",
+			[]string{"2026-10-01", "2026-10-02", "2026-10-03"}},
+		{"daily-window", "FREQ=DAILY;INTERVAL=2", "2026-10-01", "2026-10-04", "2026-10-08",
+			[]string{"2026-10-05", "2026-10-07"}},
+		{"weekdays", "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR", "2026-10-01", "2026-10-01", "2026-10-07",
+			[]string{"2026-10-01", "2026-10-02", "2026-10-05", "2026-10-06", "2026-10-07"}},
+		{"weekly-until", "FREQ=WEEKLY;UNTIL=20261015", "2026-10-01", "2026-09-01", "2026-12-01",
+			[]string{"2026-10-01", "2026-10-08", "2026-10-15"}},
+		{"weekly-count-before-window", "FREQ=WEEKLY;BYDAY=TU,TH;COUNT=4", "2026-10-06", "2026-10-13", "2026-12-01",
+			[]string{"2026-10-13", "2026-10-15"}},
+		{"monthly-skips-short-months", "FREQ=MONTHLY;COUNT=3", "2026-01-31", "2026-01-01", "2026-12-31",
+			[]string{"2026-01-31", "2026-03-31", "2026-05-31"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := dates(rule.Between(day(tt.start), day(tt.from), day(tt.to)))
+			if len(got) != len(tt.want) {
+				t.Fatalf("Between() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Between() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestFromPreset(t *testing.T) {
+	tests := []struct {
+		name    string
+		preset  string
+		days    []string
+		count   string
+		want    string
+		wantErr bool
+	}{
+		{"none", "none", nil, "", "", false},
+		{"weekdays", "weekdays", nil, "", "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR", false},
+		{"weekly", "weekly", []string{"mo", "fr"}, "5", "FREQ=WEEKLY;BYDAY=MO,FR;COUNT=5", false},
+		{"bad-day", "weekly", []string{"xx"}, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FromPreset(tt.preset, tt.days, "", "", tt.count)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FromPreset() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("FromPreset() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOccurrences(t *testing.T) {
+	todo := model.Todo{
+		ID:           "todo",
+		ToDoTask:     "stand-up",
+		DateSchedule: "2026-10-05",
+		StartTime:    "09:00",
+		EndTime:      "09:15",
+		Status:       "Not done",
+		Recurrence:   "FREQ=DAILY;COUNT=3",
+		Occurrences: []model.Occurrence{
+			{Date: "2026-10-06", Status: StatusSkipped},
+		},
+	}
+	got, err := Occurrences(todo, day("2026-10-01"), day("2026-10-31"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{StatusPending, StatusSkipped, StatusPending}
+	if len(got) != len(want) {
+		t.Fatalf("Occurrences() returned %d instances, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if got[i].Status != want[i] {
+			t.Errorf("Occurrences()[%d].Status = %v, want %v", i, got[i].Status, want[i])
+		}
+	}
+}
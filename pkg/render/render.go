@@ -0,0 +1,89 @@
+package render
+
+import (
+	"bytes"
+	"html/template"
+	"regexp"
+
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/extension"
+	goldmarkhtml "github.com/yuin/goldmark/renderer/html"
+)
+
+const (
+	// FormatMarkdown : project content written in Markdown
+	FormatMarkdown = "markdown"
+	// FormatHTML : project content saved as HTML by the former rich text editor
+	FormatHTML = "html"
+
+	// highlightStyle : chroma style of the code blocks, static/css/highlight.css is generated from it
+	highlightStyle = "github"
+)
+
+var (
+	plain = goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithRendererOptions(goldmarkhtml.WithUnsafe()),
+	)
+	highlighted = goldmark.New(
+		goldmark.WithExtensions(
+			extension.GFM,
+			highlighting.NewHighlighting(
+				highlighting.WithStyle(highlightStyle),
+				highlighting.WithFormatOptions(chromahtml.WithClasses(true)),
+			),
+		),
+		goldmark.WithRendererOptions(goldmarkhtml.WithUnsafe()),
+	)
+
+	policy = newPolicy()
+)
+
+/*
+newPolicy : the allow-list of the rendered content, the user generated content policy
+plus the class names the highlighter and the code fences write
+*/
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^[a-zA-Z0-9 _-]+$`)).OnElements("pre", "code", "span")
+	// task list items of GitHub flavored Markdown
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+	return p
+}
+
+/*
+Markdown : this renders Markdown to sanitized html. Raw html inside the Markdown
+is kept only when the allow-list permits it. The fenced code blocks are syntax
+highlighted when highlight is true
+*/
+func Markdown(source string, highlight bool) (template.HTML, error) {
+	md := plain
+	if highlight {
+		md = highlighted
+	}
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(source), &buf); err != nil {
+		return "", err
+	}
+	return template.HTML(policy.SanitizeBytes(buf.Bytes())), nil
+}
+
+// Sanitize : this strips from html every element and attribute not on the allow-list
+func Sanitize(source string) template.HTML {
+	return template.HTML(policy.Sanitize(source))
+}
+
+/*
+Content : this renders stored project content according to its format, content
+without a format was saved by the former rich text editor as html
+*/
+func Content(source, format string, highlight bool) (template.HTML, error) {
+	if format == FormatMarkdown {
+		return Markdown(source, highlight)
+	}
+	return Sanitize(source), nil
+}
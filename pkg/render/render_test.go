@@ -0,0 +1,78 @@
+package render
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarkdown(t *testing.T) {
+	tests := []struct {
+		name      string
+		source    string
+		highlight bool
+		contains  []string
+		excludes  []string
+	}{
+		{
+			name:     "heading",
+			source:   "# Title\n\nsome *text*",
+			contains: []string{"<h1", "Title</h1>", "<em>text</em>"},
+		},
+		{
+			name:     "script",
+			source:   "hello <script>alert(1)</script>",
+			excludes: []string{"<script", "alert(1)</script>"},
+		},
+		{
+			name:     "event-handler",
+			source:   `<img src="x.png" onerror="alert(1)">`,
+			contains: []string{`<img src="x.png"`},
+			excludes: []string{"onerror"},
+		},
+		{
+			name:     "javascript-link",
+			source:   "[click](javascript:alert(1))",
+			excludes: []string{"javascript:"},
+		},
+		{
+			name:      "highlight",
+			source:    "```go\nfunc main() {}\n```",
+			highlight: true,
+			contains:  []string{`class="chroma"`, "main"},
+		},
+		{
+			name:     "no-highlight",
+			source:   "```go\nfunc main() {}\n```",
+			contains: []string{`<code class="language-go">`},
+			excludes: []string{"chroma"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Markdown(tt.source, tt.highlight)
+			if err != nil {
+				t.Fatalf("Markdown() error = %v", err)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(string(got), want) {
+					t.Errorf("Markdown() = %q, want it to contain %q", got, want)
+				}
+			}
+			for _, unwanted := range tt.excludes {
+				if strings.Contains(string(got), unwanted) {
+					t.Errorf("Markdown() = %q, want it without %q", got, unwanted)
+				}
+			}
+		})
+	}
+}
+
+func TestContent(t *testing.T) {
+	got, err := Content(`<p onclick="x()">old <b>rich</b> text</p><iframe src="evil"></iframe>`, "", false)
+	if err != nil {
+		t.Fatalf("Content() error = %v", err)
+	}
+	if want := "<p>old <b>rich</b> text</p>"; string(got) != want {
+		t.Errorf("Content() = %q, want %q", got, want)
+	}
+}
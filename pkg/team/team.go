@@ -0,0 +1,115 @@
+package team
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yusuf/track-space/pkg/model"
+)
+
+// roles of the members of a team
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
+// maxNameLength : longest team name
+const maxNameLength = 60
+
+var (
+	ErrNotMember     = errors.New("team not found")
+	ErrForbidden     = errors.New("only the owners and admins of the team can do this")
+	ErrInvalidRole   = errors.New("a team member is an owner, an admin or a member")
+	ErrInvalidName   = errors.New("a team name has 1 to 60 characters")
+	ErrLastOwner     = errors.New("a team needs at least one owner")
+	ErrAlreadyMember = errors.New("this user is already a member of the team")
+	ErrInviteEmail   = errors.New("this invitation was sent to another email")
+)
+
+// ParseName : this trims a team name and checks its length
+func ParseName(raw string) (string, error) {
+	name := strings.Join(strings.Fields(raw), " ")
+	if name == "" || utf8.RuneCountInString(name) > maxNameLength {
+		return "", ErrInvalidName
+	}
+	return name, nil
+}
+
+// ParseRole : this checks a role typed in a form
+func ParseRole(raw string) (string, error) {
+	switch role := strings.ToLower(strings.TrimSpace(raw)); role {
+	case RoleOwner, RoleAdmin, RoleMember:
+		return role, nil
+	}
+	return "", ErrInvalidRole
+}
+
+// RoleOf : this returns the role of a user in a team, the empty role when the user is not a member
+func RoleOf(t model.Team, userID string) string {
+	if member, ok := Member(t, userID); ok {
+		return member.Role
+	}
+	return ""
+}
+
+// Member : this returns the membership of a user in a team
+func Member(t model.Team, userID string) (model.TeamMember, bool) {
+	for _, member := range t.Members {
+		if member.UserID == userID && userID != "" {
+			return member, true
+		}
+	}
+	return model.TeamMember{}, false
+}
+
+// CanManage : this reports whether a role lets a member invite, remove and change the role of members
+func CanManage(role string) bool {
+	return role == RoleOwner || role == RoleAdmin
+}
+
+/*
+CanSetRole : this reports whether a member of role actor may give role to a member
+who has role current. Owners do anything, admins only handle members and admins
+*/
+func CanSetRole(actor, current, role string) bool {
+	switch actor {
+	case RoleOwner:
+		return true
+	case RoleAdmin:
+		return current != RoleOwner && role != RoleOwner
+	}
+	return false
+}
+
+/*
+KeepsOwner : this reports whether a team still has an owner once the member userID
+is removed or given role
+*/
+func KeepsOwner(t model.Team, userID, role string) bool {
+	for _, member := range t.Members {
+		if member.Role != RoleOwner {
+			continue
+		}
+		if member.UserID != userID || role == RoleOwner {
+			return true
+		}
+	}
+	return false
+}
+
+// InviteFor : this returns the pending invitation of a team with the given token
+func InviteFor(t model.Team, token string) (model.TeamInvite, bool) {
+	for _, invite := range t.Invites {
+		if invite.Token == token && token != "" {
+			return invite, true
+		}
+	}
+	return model.TeamInvite{}, false
+}
+
+// SameEmail : this compares two email addresses the way mail servers match them
+func SameEmail(a, b string) bool {
+	return strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b))
+}
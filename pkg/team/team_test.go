@@ -0,0 +1,108 @@
+package team
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yusuf/track-space/pkg/model"
+)
+
+func testTeam() model.Team {
+	return model.Team{
+		Members: []model.TeamMember{
+			{UserID: "u1", Role: RoleOwner},
+			{UserID: "u2", Role: RoleAdmin},
+			{UserID: "u3", Role: RoleMember},
+		},
+		Invites: []model.TeamInvite{{Token: "t1", Email: "new@example.com", Role: RoleMember}},
+	}
+}
+
+func TestParseName(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    string
+		wantErr bool
+	}{
+		{"  Core   team ", "Core team", false},
+		{"", "", true},
+		{"   ", "", true},
+		{strings.Repeat("a", 61), "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseName(tt.raw)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseName(%q) = %q, %v", tt.raw, got, err)
+		}
+	}
+}
+
+func TestRoleOf(t *testing.T) {
+	team := testTeam()
+	tests := []struct {
+		userID    string
+		want      string
+		canManage bool
+	}{
+		{"u1", RoleOwner, true},
+		{"u2", RoleAdmin, true},
+		{"u3", RoleMember, false},
+		{"u4", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		role := RoleOf(team, tt.userID)
+		if role != tt.want || CanManage(role) != tt.canManage {
+			t.Errorf("RoleOf(%q) = %q, CanManage = %v", tt.userID, role, CanManage(role))
+		}
+	}
+}
+
+func TestCanSetRole(t *testing.T) {
+	tests := []struct {
+		name                 string
+		actor, current, role string
+		want                 bool
+	}{
+		{"owner promotes to owner", RoleOwner, RoleMember, RoleOwner, true},
+		{"owner demotes owner", RoleOwner, RoleOwner, RoleMember, true},
+		{"admin promotes member", RoleAdmin, RoleMember, RoleAdmin, true},
+		{"admin cannot make owners", RoleAdmin, RoleMember, RoleOwner, false},
+		{"admin cannot demote owners", RoleAdmin, RoleOwner, RoleMember, false},
+		{"member cannot change roles", RoleMember, RoleMember, RoleAdmin, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CanSetRole(tt.actor, tt.current, tt.role); got != tt.want {
+				t.Errorf("CanSetRole() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeepsOwner(t *testing.T) {
+	team := testTeam()
+	if KeepsOwner(team, "u1", RoleMember) {
+		t.Error("demoting the only owner keeps an owner")
+	}
+	if KeepsOwner(team, "u1", "") {
+		t.Error("removing the only owner keeps an owner")
+	}
+	if !KeepsOwner(team, "u3", "") {
+		t.Error("removing a member loses the owner")
+	}
+	team.Members[1].Role = RoleOwner
+	if !KeepsOwner(team, "u1", "") {
+		t.Error("removing one of two owners loses the owner")
+	}
+}
+
+func TestInviteFor(t *testing.T) {
+	team := testTeam()
+	if invite, ok := InviteFor(team, "t1"); !ok || !SameEmail(invite.Email, " NEW@example.com") {
+		t.Errorf("InviteFor() = %+v, %v", invite, ok)
+	}
+	if _, ok := InviteFor(team, ""); ok {
+		t.Error("InviteFor() found an invitation for the empty token")
+	}
+}
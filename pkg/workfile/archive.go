@@ -0,0 +1,214 @@
+package workfile
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/yusuf/track-space/pkg/ical"
+	"github.com/yusuf/track-space/pkg/model"
+)
+
+// Source : the part of the repository a workspace export reads from
+type Source interface {
+	GetUserProfile(id string) (primitive.M, error)
+	StreamUserProjects(id string, fn func(project model.Project) error) error
+	StreamUserTodos(id string, fn func(todo model.Todo) error) error
+	StreamUserStats(id string, fn func(stat model.Data) error) error
+}
+
+// projectEntry : the description of an exported project written to projects.json
+type projectEntry struct {
+	ID            string   `bson:"_id"`
+	ProjectName   string   `bson:"project_name"`
+	ToolsUseAs    string   `bson:"tools_use_as"`
+	ContentFormat string   `bson:"content_format"`
+	Status        string   `bson:"status"`
+	Tags          []string `bson:"tags"`
+	Folder        string   `bson:"folder"`
+	Pinned        bool     `bson:"pinned"`
+	CreatedAt     string   `bson:"created_at"`
+	UpdatedAt     string   `bson:"updated_at"`
+	File          string   `bson:"file"`
+}
+
+/*
+WriteArchive : this writes the whole workspace of a user to w as a zip archive:
+
+	profile.json        the user profile without credentials
+	projects/...        one file per project, see ProjectFile
+	projects.json       the details of every project and the file holding it
+	todos.json          every todo
+	todos.ics           the todos as an iCalendar document
+	stats.csv           the daily activity statistics
+
+The projects, todos and statistics are streamed from the source one at a time
+and written straight into the archive
+*/
+func WriteArchive(w io.Writer, src Source, userID string) error {
+	zw := zip.NewWriter(w)
+
+	profile, err := src.GetUserProfile(userID)
+	if err != nil {
+		return err
+	}
+	if err := writeJSON(zw, "profile.json", profile); err != nil {
+		return err
+	}
+
+	var entries []projectEntry
+	namer := NewNamer()
+	err = src.StreamUserProjects(userID, func(project model.Project) error {
+		name, content := ProjectFile(project)
+		name = namer.Unique(name)
+		f, err := create(zw, name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, content); err != nil {
+			return err
+		}
+		entries = append(entries, projectEntry{
+			ID:            project.ID,
+			ProjectName:   project.ProjectName,
+			ToolsUseAs:    project.ToolsUseAs,
+			ContentFormat: project.ContentFormat,
+			Status:        project.Status,
+			Tags:          project.Tags,
+			Folder:        project.Folder,
+			Pinned:        project.Pinned,
+			CreatedAt:     project.CreatedAt,
+			UpdatedAt:     project.UpdatedAt,
+			File:          name,
+		})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := writeJSONArray(zw, "projects.json", func(item func(v interface{}) error) error {
+		for _, entry := range entries {
+			if err := item(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if err := writeJSONArray(zw, "todos.json", func(item func(v interface{}) error) error {
+		return src.StreamUserTodos(userID, func(todo model.Todo) error {
+			return item(todo)
+		})
+	}); err != nil {
+		return err
+	}
+
+	f, err := create(zw, "todos.ics")
+	if err != nil {
+		return err
+	}
+	enc := ical.NewEncoder(f, "Track Space")
+	if err := src.StreamUserTodos(userID, enc.Encode); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+
+	if err := writeStats(zw, src, userID); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func create(zw *zip.Writer, name string) (io.Writer, error) {
+	return zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	})
+}
+
+// writeJSON : this writes a document with the field names it is stored with
+func writeJSON(zw *zip.Writer, name string, v interface{}) error {
+	f, err := create(zw, name)
+	if err != nil {
+		return err
+	}
+	data, err := bson.MarshalExtJSONIndent(v, false, false, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// writeJSONArray : this writes the documents handed to item as a json array, one by one
+func writeJSONArray(zw *zip.Writer, name string, items func(item func(v interface{}) error) error) error {
+	f, err := create(zw, name)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(f, "["); err != nil {
+		return err
+	}
+	first := true
+	err = items(func(v interface{}) error {
+		data, err := bson.MarshalExtJSONIndent(v, false, false, "  ", "  ")
+		if err != nil {
+			return err
+		}
+		sep := ",\n  "
+		if first {
+			sep = "\n  "
+			first = false
+		}
+		if _, err := io.WriteString(f, sep); err != nil {
+			return err
+		}
+		_, err = f.Write(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	end := "\n]\n"
+	if first {
+		end = "]\n"
+	}
+	_, err = io.WriteString(f, end)
+	return err
+}
+
+func writeStats(zw *zip.Writer, src Source, userID string) error {
+	f, err := create(zw, "stats.csv")
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(f)
+	if err := cw.Write([]string{"date", "code", "article", "text", "todo", "total"}); err != nil {
+		return err
+	}
+	err = src.StreamUserStats(userID, func(stat model.Data) error {
+		return cw.Write([]string{
+			stat.Date,
+			strconv.Itoa(stat.Code),
+			strconv.Itoa(stat.Article),
+			strconv.Itoa(stat.Text),
+			strconv.Itoa(stat.Todo),
+			strconv.Itoa(stat.Total),
+		})
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,218 @@
+package workfile
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yusuf/track-space/pkg/catalog"
+	"github.com/yusuf/track-space/pkg/model"
+	"github.com/yusuf/track-space/pkg/render"
+)
+
+const (
+	// MaxImportSize : largest upload accepted by an import, zip or files together
+	MaxImportSize = 20 << 20
+	// MaxFileSize : largest file turned into a project
+	MaxFileSize = 1 << 20
+	// MaxImportFiles : most files read from one import
+	MaxImportFiles = 200
+)
+
+// status of a file of an import
+const (
+	Imported = "imported"
+	Skipped  = "skipped"
+	Failed   = "failed"
+)
+
+var (
+	ErrTooManyFiles = fmt.Errorf("an import can hold at most %d files", MaxImportFiles)
+	ErrFileTooLarge = fmt.Errorf("file is larger than %d KB", MaxFileSize>>10)
+	ErrNotText      = errors.New("file is not a text file")
+	ErrUnsupported  = errors.New("unsupported file type")
+)
+
+// File : a file of an import, read from a zip archive or uploaded on its own
+type File struct {
+	// Path : slash separated path of the file inside the imported directory
+	Path string
+	Size int64
+	Open func() (io.ReadCloser, error)
+}
+
+// Result : what happened to one file of an import
+type Result struct {
+	Path    string
+	Project string
+	Status  string
+	Reason  string
+}
+
+/*
+ZipFiles : this lists the regular files of a zip archive. Only the size the archive
+declares is checked here, the content is limited again while it is read
+*/
+func ZipFiles(r io.ReaderAt, size int64) ([]File, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	var files []File
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if len(files) == MaxImportFiles {
+			return nil, ErrTooManyFiles
+		}
+		f := f
+		files = append(files, File{
+			Path: f.Name,
+			Size: int64(f.UncompressedSize64),
+			Open: func() (io.ReadCloser, error) { return f.Open() },
+		})
+	}
+	return files, nil
+}
+
+/*
+Ignored : this reports whether a file is left out of an import without a word,
+hidden files and the metadata written by operating systems and by the workspace
+export
+*/
+func Ignored(p string) bool {
+	for _, segment := range strings.Split(p, "/") {
+		if strings.HasPrefix(segment, ".") || segment == "__MACOSX" {
+			return true
+		}
+	}
+	switch p {
+	case "profile.json", "projects.json", "todos.json", "todos.ics", "stats.csv":
+		return true
+	}
+	return false
+}
+
+/*
+ToolsFor : this infers tools_use_as from the extension of a file name, Markdown is
+an article, plain text is text and any known source extension is code
+*/
+func ToolsFor(name string) (string, bool) {
+	ext := strings.ToLower(path.Ext(name))
+	switch {
+	case ext == ".md" || ext == ".markdown":
+		return "article", true
+	case ext == ".txt":
+		return "text", true
+	case sourceExtensions[ext]:
+		return "code", true
+	}
+	return "", false
+}
+
+/*
+Prepare : this reads an imported file into a project, without id nor dates. The
+directories of the file become the folder of the project and its tags. Source
+files are stored as a fenced code block so they render highlighted and export
+back to the same file
+*/
+func Prepare(f File) (model.Project, error) {
+	var project model.Project
+	p := path.Clean(strings.TrimPrefix(strings.ReplaceAll(f.Path, "\\", "/"), "/"))
+	dir, name := path.Split(p)
+
+	tools, ok := ToolsFor(name)
+	if !ok {
+		return project, ErrUnsupported
+	}
+	if f.Size > MaxFileSize {
+		return project, ErrFileTooLarge
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return project, err
+	}
+	defer rc.Close()
+	content, err := io.ReadAll(io.LimitReader(rc, MaxFileSize+1))
+	if err != nil {
+		return project, err
+	}
+	if len(content) > MaxFileSize {
+		return project, ErrFileTooLarge
+	}
+	if !utf8.Valid(content) {
+		return project, ErrNotText
+	}
+
+	folder, err := catalog.NormalizeFolder(exportedFolder(strings.TrimSuffix(dir, "/")))
+	if err != nil {
+		return project, err
+	}
+	tags, err := catalog.NormalizeTags(folderTags(folder))
+	if err != nil {
+		return project, err
+	}
+
+	project.ProjectName = strings.ToLower(name)
+	project.ToolsUseAs = tools
+	project.ContentFormat = render.FormatMarkdown
+	project.Folder = folder
+	project.Tags = tags
+	text := strings.ReplaceAll(string(content), "\r\n", "\n")
+	if tools == "code" {
+		project.ProjectContent = fence(text, strings.TrimPrefix(path.Ext(name), "."))
+	} else {
+		project.ProjectName = strings.TrimSuffix(project.ProjectName, path.Ext(name))
+		project.ProjectContent = text
+	}
+	return project, nil
+}
+
+/*
+folderTags : this turns the directories of a folder into tags. Directory names can be
+longer than tags, they are cut to the length of a tag
+*/
+func folderTags(folder string) []string {
+	var tags []string
+	if folder == "" {
+		return tags
+	}
+	for _, segment := range strings.Split(folder, "/") {
+		tag := []rune(strings.Join(strings.Fields(segment), "-"))
+		if len(tag) > catalog.MaxTagLength {
+			tag = tag[:catalog.MaxTagLength]
+		}
+		tags = append(tags, strings.TrimRight(string(tag), "-"))
+	}
+	return tags
+}
+
+// exportedFolder : this drops the "projects/<tools_use_as>" directories of the workspace export
+func exportedFolder(dir string) string {
+	segments := strings.SplitN(dir, "/", 3)
+	if len(segments) >= 2 && segments[0] == "projects" {
+		switch segments[1] {
+		case "code", "text", "article":
+			return strings.Join(segments[2:], "/")
+		}
+	}
+	return dir
+}
+
+// fence : this wraps source code in a fenced block longer than any fence inside it
+func fence(source, lang string) string {
+	marker := "```"
+	for strings.Contains(source, marker) {
+		marker += "`"
+	}
+	if !strings.HasSuffix(source, "\n") {
+		source += "\n"
+	}
+	return marker + lang + "\n" + source + marker
+}
@@ -0,0 +1,121 @@
+package workfile
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/yusuf/track-space/pkg/render"
+)
+
+func textFile(p, content string) File {
+	return File{
+		Path: p,
+		Size: int64(len(content)),
+		Open: func() (io.ReadCloser, error) { return io.NopCloser(strings.NewReader(content)), nil },
+	}
+}
+
+func TestPrepare(t *testing.T) {
+	tests := []struct {
+		name        string
+		file        File
+		wantName    string
+		wantTools   string
+		wantFolder  string
+		wantTags    []string
+		wantContent string
+		wantErr     error
+		wantAnyErr  bool
+	}{
+		{
+			name: "markdown", file: textFile("Notes/Go Tips.md", "# Tips"),
+			wantName: "go tips", wantTools: "article", wantFolder: "Notes", wantTags: []string{"notes"}, wantContent: "# Tips",
+		},
+		{
+			name: "source", file: textFile("api/server/main.go", "package main\n"),
+			wantName: "main.go", wantTools: "code", wantFolder: "api/server", wantTags: []string{"api", "server"},
+			wantContent: "```go\npackage main\n```",
+		},
+		{
+			name: "exported", file: textFile("projects/text/work/todo.txt", "call back"),
+			wantName: "todo", wantTools: "text", wantFolder: "work", wantTags: []string{"work"}, wantContent: "call back",
+		},
+		{
+			name: "long-directory", file: textFile("Quarterly Planning And Review Notes 2024/plan.md", "# Plan"),
+			wantName: "plan", wantTools: "article", wantFolder: "Quarterly Planning And Review Notes 2024",
+			wantTags: []string{"quarterly-planning-and-review-no"}, wantContent: "# Plan",
+		},
+		{name: "unsupported", file: textFile("logo.png", "x"), wantErr: ErrUnsupported},
+		{name: "binary", file: textFile("data.txt", "\xff\xfe"), wantErr: ErrNotText},
+		{name: "too-large", file: textFile("big.md", strings.Repeat("a", MaxFileSize+1)), wantErr: ErrFileTooLarge},
+		{name: "traversal", file: textFile("../../etc/passwd.txt", "x"), wantAnyErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			project, err := Prepare(tt.file)
+			if tt.wantAnyErr {
+				if err == nil {
+					t.Fatal("Prepare() error = nil, want an error")
+				}
+				return
+			}
+			if err != tt.wantErr {
+				t.Fatalf("Prepare() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if project.ProjectName != tt.wantName || project.ToolsUseAs != tt.wantTools || project.Folder != tt.wantFolder ||
+				project.ProjectContent != tt.wantContent || strings.Join(project.Tags, ",") != strings.Join(tt.wantTags, ",") {
+				t.Errorf("Prepare() = %+v", project)
+			}
+			if project.ContentFormat != render.FormatMarkdown {
+				t.Errorf("Prepare() format = %q", project.ContentFormat)
+			}
+		})
+	}
+}
+
+func TestImportExportRoundTrip(t *testing.T) {
+	project, err := Prepare(textFile("api/main.go", "package main\n\nfunc main() {}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, content := ProjectFile(project)
+	if p != "projects/code/api/main.go" || content != "package main\n\nfunc main() {}\n" {
+		t.Errorf("ProjectFile() = %q, %q", p, content)
+	}
+}
+
+func TestZipFiles(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range []string{"a/", "a/b.md", "c.go"} {
+		w, _ := zw.Create(name)
+		if !strings.HasSuffix(name, "/") {
+			_, _ = io.WriteString(w, "x")
+		}
+	}
+	_ = zw.Close()
+
+	files, err := ZipFiles(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("ZipFiles() error = %v", err)
+	}
+	if len(files) != 2 || files[0].Path != "a/b.md" || files[1].Path != "c.go" {
+		t.Errorf("ZipFiles() = %+v", files)
+	}
+}
+
+func TestIgnored(t *testing.T) {
+	for p, want := range map[string]bool{
+		"__MACOSX/a.md": true, "a/.DS_Store": true, "projects.json": true, "a/projects.json": false, "a/b.md": false,
+	} {
+		if got := Ignored(p); got != want {
+			t.Errorf("Ignored(%q) = %v, want %v", p, got, want)
+		}
+	}
+}
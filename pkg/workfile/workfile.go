@@ -0,0 +1,129 @@
+package workfile
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"unicode"
+
+	"github.com/yusuf/track-space/pkg/model"
+	"github.com/yusuf/track-space/pkg/render"
+)
+
+// maxSlugLength : longest file or folder name written for a project
+const maxSlugLength = 60
+
+/*
+sourceExtensions : extensions kept on the names of code projects, the content of
+such a project is written as a plain source file instead of Markdown
+*/
+var sourceExtensions = map[string]bool{
+	".c": true, ".cpp": true, ".cs": true, ".css": true, ".go": true, ".h": true,
+	".html": true, ".java": true, ".js": true, ".json": true, ".kt": true, ".php": true,
+	".py": true, ".rb": true, ".rs": true, ".sh": true, ".sql": true, ".swift": true,
+	".ts": true, ".yaml": true, ".yml": true,
+}
+
+/*
+Slug : this turns a name into a lower case file name made of letters, digits, dots
+and dashes, "untitled" when nothing is left of it
+*/
+func Slug(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.' || r == '_':
+			b.WriteRune(r)
+			dash = false
+		case !dash && b.Len() > 0:
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+	slug := strings.Trim(b.String(), "-.")
+	if len(slug) > maxSlugLength {
+		slug = strings.Trim(slug[:maxSlugLength], "-.")
+	}
+	if slug == "" {
+		return "untitled"
+	}
+	return slug
+}
+
+/*
+ProjectFile : this returns the path of a project inside the archive and the content
+to write there. Projects are grouped by tools_use_as and by folder. Code projects
+named like a source file ("main.go") holding a single fenced code block are written
+as that source file, projects saved as html keep the .html extension and everything
+else is written as Markdown
+*/
+func ProjectFile(project model.Project) (string, string) {
+	dir := []string{"projects", Slug(project.ToolsUseAs)}
+	for _, segment := range strings.Split(project.Folder, "/") {
+		if segment != "" {
+			dir = append(dir, Slug(segment))
+		}
+	}
+
+	name := Slug(project.ProjectName)
+	content := project.ProjectContent
+	ext := path.Ext(name)
+	switch {
+	case project.ContentFormat != render.FormatMarkdown:
+		name = strings.TrimSuffix(name, ext) + ".html"
+	case project.ToolsUseAs == "code" && sourceExtensions[ext]:
+		if source, ok := Unfence(content); ok {
+			content = source
+		} else {
+			name += ".md"
+		}
+	default:
+		name += ".md"
+	}
+	return path.Join(append(dir, name)...), content
+}
+
+/*
+Unfence : this returns the inside of a Markdown document made of a single fenced
+code block, and false for any other document
+*/
+func Unfence(markdown string) (string, bool) {
+	text := strings.TrimSpace(strings.ReplaceAll(markdown, "\r\n", "\n"))
+	lines := strings.Split(text, "\n")
+	if len(lines) < 2 {
+		return "", false
+	}
+	fence := lines[0][:len(lines[0])-len(strings.TrimLeft(lines[0], "`~"))]
+	if len(fence) < 3 || strings.Trim(fence, string(fence[0])) != "" || lines[len(lines)-1] != fence {
+		return "", false
+	}
+	body := lines[1 : len(lines)-1]
+	for _, line := range body {
+		if strings.HasPrefix(line, fence) {
+			return "", false
+		}
+	}
+	return strings.Join(body, "\n") + "\n", true
+}
+
+// Namer : hands out unique paths, numbering the names that are already taken
+type Namer struct {
+	used map[string]bool
+}
+
+// NewNamer : this returns a namer with no path taken yet
+func NewNamer() *Namer {
+	return &Namer{used: make(map[string]bool)}
+}
+
+// Unique : this returns the path itself or, when taken, the path numbered "name-2.ext", "name-3.ext", ...
+func (n *Namer) Unique(p string) string {
+	candidate := p
+	ext := path.Ext(p)
+	for i := 2; n.used[candidate]; i++ {
+		candidate = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(p, ext), i, ext)
+	}
+	n.used[candidate] = true
+	return candidate
+}
@@ -0,0 +1,167 @@
+package workfile
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/yusuf/track-space/pkg/model"
+	"github.com/yusuf/track-space/pkg/render"
+)
+
+func TestSlug(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"My Project!", "my-project"},
+		{"main.go", "main.go"},
+		{"../../etc/passwd", "etc-passwd"},
+		{"..", "untitled"},
+		{"  ", "untitled"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Slug(tt.name); got != tt.want {
+				t.Errorf("Slug() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProjectFile(t *testing.T) {
+	tests := []struct {
+		name        string
+		project     model.Project
+		wantPath    string
+		wantContent string
+	}{
+		{
+			name:        "article",
+			project:     model.Project{ProjectName: "Go Tips", ToolsUseAs: "article", ProjectContent: "# Tips", ContentFormat: render.FormatMarkdown},
+			wantPath:    "projects/article/go-tips.md",
+			wantContent: "# Tips",
+		},
+		{
+			name:        "source",
+			project:     model.Project{ProjectName: "main.go", ToolsUseAs: "code", Folder: "work/api", ProjectContent: "```go\npackage main\n```", ContentFormat: render.FormatMarkdown},
+			wantPath:    "projects/code/work/api/main.go",
+			wantContent: "package main\n",
+		},
+		{
+			name:        "code-with-prose",
+			project:     model.Project{ProjectName: "main.go", ToolsUseAs: "code", ProjectContent: "notes\n```go\npackage main\n```", ContentFormat: render.FormatMarkdown},
+			wantPath:    "projects/code/main.go.md",
+			wantContent: "notes\n```go\npackage main\n```",
+		},
+		{
+			name:        "legacy-html",
+			project:     model.Project{ProjectName: "essay", ToolsUseAs: "text", ProjectContent: "<p>hi</p>"},
+			wantPath:    "projects/text/essay.html",
+			wantContent: "<p>hi</p>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotPath, gotContent := ProjectFile(tt.project)
+			if gotPath != tt.wantPath || gotContent != tt.wantContent {
+				t.Errorf("ProjectFile() = %q, %q, want %q, %q", gotPath, gotContent, tt.wantPath, tt.wantContent)
+			}
+		})
+	}
+}
+
+func TestNamer(t *testing.T) {
+	namer := NewNamer()
+	for _, want := range []string{"a/b.md", "a/b-2.md", "a/b-3.md"} {
+		if got := namer.Unique("a/b.md"); got != want {
+			t.Errorf("Unique() = %q, want %q", got, want)
+		}
+	}
+}
+
+type fakeSource struct {
+	projects []model.Project
+	todos    []model.Todo
+	stats    []model.Data
+}
+
+func (f fakeSource) GetUserProfile(id string) (primitive.M, error) {
+	return primitive.M{"_id": id, "first_name": "Ada"}, nil
+}
+
+func (f fakeSource) StreamUserProjects(id string, fn func(project model.Project) error) error {
+	for _, project := range f.projects {
+		if err := fn(project); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f fakeSource) StreamUserTodos(id string, fn func(todo model.Todo) error) error {
+	for _, todo := range f.todos {
+		if err := fn(todo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f fakeSource) StreamUserStats(id string, fn func(stat model.Data) error) error {
+	for _, stat := range f.stats {
+		if err := fn(stat); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestWriteArchive(t *testing.T) {
+	src := fakeSource{
+		projects: []model.Project{
+			{ID: "p1", ProjectName: "notes", ToolsUseAs: "text", ProjectContent: "one", ContentFormat: render.FormatMarkdown},
+			{ID: "p2", ProjectName: "notes", ToolsUseAs: "text", ProjectContent: "two", ContentFormat: render.FormatMarkdown},
+		},
+		todos: []model.Todo{{ID: "t1", ToDoTask: "stand-up", DateSchedule: "2026-10-19", StartTime: "09:00", EndTime: "09:15"}},
+		stats: []model.Data{{Date: "2026-10-19", Code: 1, Total: 1}},
+	}
+	var buf bytes.Buffer
+	if err := WriteArchive(&buf, src, "u1"); err != nil {
+		t.Fatalf("WriteArchive() error = %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("cannot read the archive : %v", err)
+	}
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(rc)
+		_ = rc.Close()
+		files[f.Name] = string(data)
+	}
+
+	want := map[string]string{
+		"profile.json":             `"first_name": "Ada"`,
+		"projects/text/notes.md":   "one",
+		"projects/text/notes-2.md": "two",
+		"projects.json":            `"file": "projects/text/notes-2.md"`,
+		"todos.json":               `"to_do_task": "stand-up"`,
+		"todos.ics":                "SUMMARY:stand-up",
+		"stats.csv":                "2026-10-19,1,0,0,0,1",
+	}
+	for name, contains := range want {
+		if !strings.Contains(files[name], contains) {
+			t.Errorf("archive file %s = %q, want it to contain %q", name, files[name], contains)
+		}
+	}
+}
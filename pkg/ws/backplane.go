@@ -0,0 +1,247 @@
+package ws
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/yusuf/track-space/pkg/model"
+	"github.com/yusuf/track-space/pkg/wsmodel"
+)
+
+const (
+	// eventQueue : events waiting to be published, or read by a hub, before new ones are dropped
+	eventQueue = 1024
+	// presenceRefresh : how often an instance publishes its users even when they did not change
+	presenceRefresh = 30 * time.Second
+	// remoteExpiry : the silence after which the users of another instance are forgotten, a crashed instance never says goodbye
+	remoteExpiry = 3 * presenceRefresh
+)
+
+// kinds of the events shared by the hubs of the instances
+const (
+	// eventRoom : a response to the clients of a room, like a message or a receipt
+	eventRoom = "room"
+	// eventAll : a response to every client
+	eventAll = "all"
+	// eventPresence : the users connected to the instance publishing, also published once it starts
+	eventPresence = "presence"
+	// eventStopped : the instance publishing stopped, its users are gone
+	eventStopped  = "stopped"
+	eventSanction = "sanction"
+	eventLift     = "lift"
+	eventFilter   = "filter"
+	// eventUser : a response to the live pages of a user, like a project changed
+	eventUser = "user"
+)
+
+/*
+Backplane : the publish and subscribe channel between the hubs of every instance of the
+application, so the clients connected to another instance get the messages, presence
+and moderation of the chat too. A hub ignores the events it published itself
+*/
+type Backplane interface {
+	// Publish : this sends an event to every hub subscribed, it may wait for the network
+	Publish(event Event) error
+	// Subscribe : this returns the events published from now on, until cancel is called
+	Subscribe() (events <-chan Event, cancel func(), err error)
+}
+
+// Event : what a hub shares with the hubs of the other instances
+type Event struct {
+	// Origin : the id of the hub publishing
+	Origin string `bson:"origin"`
+	Kind   string `bson:"kind"`
+	Room   string `bson:"room,omitempty"`
+	// UserID : the user whose live pages get the response of an eventUser
+	UserID string `bson:"user_id,omitempty"`
+	// Except : the user whose clients do not get the response, like the one typing
+	Except   string                  `bson:"except,omitempty"`
+	Response *wsmodel.SocketResponse `bson:"response,omitempty"`
+	Users    []wsmodel.ChatUser      `bson:"users,omitempty"`
+	Sanction *model.ChatSanction     `bson:"sanction,omitempty"`
+	Words    []string                `bson:"words,omitempty"`
+}
+
+// remotePresence : the users of another instance as it last published them
+type remotePresence struct {
+	users []wsmodel.ChatUser
+	seen  time.Time
+}
+
+/*
+LocalBackplane : a backplane within the process, for the hubs of a single instance. An
+event is dropped for a hub too slow to read it
+*/
+type LocalBackplane struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]bool
+}
+
+// NewLocalBackplane : this returns a backplane for the hubs of this process
+func NewLocalBackplane() *LocalBackplane {
+	return &LocalBackplane{subscribers: make(map[chan Event]bool)}
+}
+
+// Publish : this queues an event for every hub subscribed, without ever waiting for them
+func (b *LocalBackplane) Publish(event Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for events := range b.subscribers {
+		select {
+		case events <- event:
+		default:
+			log.Printf("chat backplane subscriber is too slow, %s event of %s dropped", event.Kind, event.Origin)
+		}
+	}
+	return nil
+}
+
+// Subscribe : this returns the events published from now on, the channel is closed by cancel
+func (b *LocalBackplane) Subscribe() (<-chan Event, func(), error) {
+	events := make(chan Event, eventQueue)
+	b.mu.Lock()
+	b.subscribers[events] = true
+	b.mu.Unlock()
+	cancel := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if b.subscribers[events] {
+			delete(b.subscribers, events)
+			close(events)
+		}
+	}
+	return events, cancel, nil
+}
+
+/*
+UseBackplane : this shares the chat of the hub with the hubs of the other instances
+through b, it is called before Run. Without a backplane the hub only reaches the
+clients of its own instance
+*/
+func (h *Hub) UseBackplane(b Backplane) {
+	h.backplane = b
+}
+
+// subscribe : this returns the events of the other hubs, none without a backplane
+func (h *Hub) subscribe() (<-chan Event, func()) {
+	if h.backplane == nil {
+		return nil, func() {}
+	}
+	events, cancel, err := h.backplane.Subscribe()
+	if err != nil {
+		log.Printf("cannot subscribe to the chat backplane, the chat only reaches the clients of this instance : %v", err)
+		return nil, func() {}
+	}
+	return events, cancel
+}
+
+// publish : this queues an event for the other hubs, without ever blocking the hub
+func (h *Hub) publish(event Event) {
+	if h.backplane == nil {
+		return
+	}
+	event.Origin = h.id
+	select {
+	case h.outbox <- event:
+	default:
+		log.Printf("chat backplane is too slow, %s event dropped", event.Kind)
+	}
+}
+
+// publishEvents : this publishes the queued events in order until the queue is closed
+func (h *Hub) publishEvents(published chan struct{}) {
+	defer close(published)
+	for event := range h.outbox {
+		if err := h.backplane.Publish(event); err != nil {
+			log.Printf("cannot publish %s event to the chat backplane : %v", event.Kind, err)
+		}
+	}
+}
+
+// publishRoom : this sends a response to the clients of a room on every instance
+func (h *Hub) publishRoom(room, except string, resp wsmodel.SocketResponse) {
+	h.broadcastRoom(room, except, resp)
+	h.publish(Event{Kind: eventRoom, Room: room, Except: except, Response: &resp})
+}
+
+// publishPresence : this shares the users of this instance when they changed, or every presenceRefresh
+func (h *Hub) publishPresence() {
+	if h.backplane == nil {
+		return
+	}
+	users := h.localUsers()
+	statuses := statusesOf(users)
+	if samePresence(statuses, h.published) && h.now().Sub(h.publishedAt) < presenceRefresh {
+		return
+	}
+	h.published, h.publishedAt = statuses, h.now()
+	h.publish(Event{Kind: eventPresence, Users: users})
+}
+
+// expireRemotes : this forgets the users of the instances silent for remoteExpiry
+func (h *Hub) expireRemotes() {
+	for id, remote := range h.remote {
+		if h.now().Sub(remote.seen) >= remoteExpiry {
+			delete(h.remote, id)
+		}
+	}
+}
+
+/*
+receive : this applies an event of another hub to the clients of this one, keeping the
+histories, sanctions and word filter in memory the same on every instance. The hub
+publishing stored them already
+*/
+func (h *Hub) receive(event Event) {
+	if event.Origin == h.id {
+		return
+	}
+	switch event.Kind {
+	case eventRoom:
+		if event.Response == nil {
+			return
+		}
+		resp := *event.Response
+		if hist, ok := h.histories[event.Room]; ok {
+			switch {
+			case resp.Condition == "message" && resp.Chat != nil:
+				hist.add(*resp.Chat)
+			case resp.Condition == "read" && resp.Receipt != nil:
+				hist.receipts[resp.Receipt.UserID] = *resp.Receipt
+			case resp.Condition == "deleted":
+				hist.remove(resp.MessageID)
+			}
+		}
+		h.broadcastRoom(event.Room, event.Except, resp)
+	case eventAll:
+		if event.Response != nil {
+			h.broadcastAll(*event.Response)
+		}
+	case eventPresence:
+		if _, known := h.remote[event.Origin]; !known {
+			// an instance just started, it learns the users of this one at once
+			h.publishedAt = time.Time{}
+		}
+		h.remote[event.Origin] = remotePresence{users: event.Users, seen: h.now()}
+		h.refreshPresence()
+	case eventStopped:
+		delete(h.remote, event.Origin)
+		h.refreshPresence()
+	case eventSanction:
+		if event.Sanction != nil {
+			h.sanctions[event.Sanction.ID] = *event.Sanction
+			h.enforce(*event.Sanction)
+		}
+	case eventLift:
+		if event.Sanction != nil {
+			h.lift(*event.Sanction)
+		}
+	case eventFilter:
+		h.filter = NewWordFilter(event.Words)
+	case eventUser:
+		if event.Response != nil {
+			h.notifyWatchers(event.UserID, *event.Response)
+		}
+	}
+}
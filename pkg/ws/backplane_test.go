@@ -0,0 +1,158 @@
+package ws
+
+import (
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/yusuf/track-space/pkg/wsmodel"
+)
+
+// replicas : this starts hubs sharing a backplane, as instances behind a load balancer
+func replicas(t *testing.T, n int) []*Hub {
+	t.Helper()
+	backplane := NewLocalBackplane()
+	hubs := make([]*Hub, n)
+	for i := range hubs {
+		hubs[i] = NewHub(nil, teamRooms{"id-alice": true, "id-bob": true})
+		hubs[i].UseBackplane(backplane)
+		go hubs[i].Run()
+	}
+	t.Cleanup(func() {
+		for _, hub := range hubs {
+			hub.Stop()
+		}
+	})
+	return hubs
+}
+
+func TestLocalBackplane(t *testing.T) {
+	backplane := NewLocalBackplane()
+	first, cancelFirst, _ := backplane.Subscribe()
+	second, cancelSecond, _ := backplane.Subscribe()
+	defer cancelSecond()
+
+	_ = backplane.Publish(Event{Origin: "a", Kind: eventAll})
+	for _, events := range []<-chan Event{first, second} {
+		if got := <-events; got.Origin != "a" || got.Kind != eventAll {
+			t.Errorf("event = %+v, want the one published", got)
+		}
+	}
+	cancelFirst()
+	cancelFirst()
+	if _, ok := <-first; ok {
+		t.Error("events of a cancelled subscription are still sent")
+	}
+	_ = backplane.Publish(Event{Origin: "b", Kind: eventAll})
+	if got := <-second; got.Origin != "b" {
+		t.Errorf("event = %+v, want the one of b", got)
+	}
+}
+
+func TestHub_BackplaneRooms(t *testing.T) {
+	hubs := replicas(t, 2)
+	alice, bob := newFakeConn(), newFakeConn()
+	serve(hubs[0], alice, "alice")
+	serve(hubs[1], bob, "bob")
+	// each instance lists the users of the other one
+	alice.expectUsers(t, "alice", "bob")
+	bob.expectUsers(t, "alice", "bob")
+
+	alice.send(t, wsmodel.SocketPayLoad{Condition: "sendMessage", Message: "hello from one"})
+	if got := bob.expect(t, "message"); got.Chat == nil || got.Chat.Message != "hello from one" || got.Chat.SenderName != "alice" {
+		t.Fatalf("bob got %+v, want the message of alice", got)
+	}
+
+	room := TeamRoom("t1")
+	for _, conn := range []*fakeConn{alice, bob} {
+		conn.send(t, wsmodel.SocketPayLoad{Condition: "join", Room: room})
+		conn.expectIn(t, "history", room)
+	}
+	bob.send(t, wsmodel.SocketPayLoad{Condition: "typingStarted", Room: room})
+	alice.expectIn(t, "typingStarted", room)
+	bob.send(t, wsmodel.SocketPayLoad{Condition: "sendMessage", Room: room, Message: "team only"})
+	chat := alice.expectIn(t, "message", room).Chat
+
+	// a client joining later gets the messages of the other instance in the history
+	carol := newFakeConn()
+	serve(hubs[0], carol, "carol")
+	if history := carol.expect(t, "history").History; len(history) != 1 || history[0].Message != "hello from one" {
+		t.Errorf("history = %+v, want the general message", history)
+	}
+	alice.send(t, wsmodel.SocketPayLoad{Condition: "leave", Room: room})
+	alice.expect(t, "left")
+	alice.send(t, wsmodel.SocketPayLoad{Condition: "join", Room: room})
+	if history := alice.expectIn(t, "history", room).History; len(history) != 1 || history[0].ID != chat.ID {
+		t.Errorf("history of %s = %+v, want the message of bob", room, history)
+	}
+
+	hubs[1].Broadcast(wsmodel.SocketResponse{Condition: "notice", Message: "maintenance"})
+	alice.expect(t, "notice")
+}
+
+func TestHub_BackplaneDirectMessages(t *testing.T) {
+	hubs := replicas(t, 2)
+	alice, bob := newFakeConn(), newFakeConn()
+	serve(hubs[0], alice, "alice")
+	serve(hubs[1], bob, "bob")
+	alice.expectUsers(t, "alice", "bob")
+
+	alice.send(t, wsmodel.SocketPayLoad{Condition: "join", To: "id-bob"})
+	room := DirectRoom("id-alice", "id-bob")
+	alice.expectIn(t, "history", room)
+	alice.send(t, wsmodel.SocketPayLoad{Condition: "sendMessage", Room: room, Message: "psst"})
+	if got := bob.expectIn(t, "message", room); got.Chat.Message != "psst" {
+		t.Errorf("bob got %q, want psst", got.Chat.Message)
+	}
+}
+
+func TestHub_BackplanePresence(t *testing.T) {
+	hubs := replicas(t, 2)
+	alice, bob, other := newFakeConn(), newFakeConn(), newFakeConn()
+	serve(hubs[0], alice, "alice")
+	serve(hubs[1], bob, "bob")
+	alice.expectUsers(t, "alice", "bob")
+
+	// bob is online as long as one of his connections is, whatever the instance
+	serve(hubs[0], other, "bob")
+	bob.send(t, wsmodel.SocketPayLoad{Condition: "heartbeat", Status: StatusAway})
+	other.send(t, wsmodel.SocketPayLoad{Condition: "heartbeat", Status: StatusOnline})
+	alice.expectStatus(t, "bob", StatusOnline)
+	other.send(t, wsmodel.SocketPayLoad{Condition: "serveroffline"})
+	alice.expectStatus(t, "bob", StatusAway)
+
+	// an instance stopping takes its users with it
+	hubs[1].Stop()
+	alice.expectUsers(t, "alice")
+}
+
+func TestHub_BackplaneModeration(t *testing.T) {
+	hubs := replicas(t, 2)
+	admin, bob := newFakeConn(), newFakeConn()
+	serveAdmin(hubs[0], admin)
+	serve(hubs[1], bob, "bob")
+	admin.expectUsers(t, "admin", "bob")
+
+	admin.send(t, wsmodel.SocketPayLoad{Condition: ModFilter, Message: "heck"})
+	admin.expect(t, "moderated")
+	admin.send(t, wsmodel.SocketPayLoad{Condition: ModKick, To: "id-bob", Duration: "1h"})
+	admin.expect(t, "moderated")
+	bob.expect(t, "kicked")
+	bob.expectClosed(t)
+	if bob.closeCode != websocket.ClosePolicyViolation {
+		t.Errorf("close code = %d, want %d", bob.closeCode, websocket.ClosePolicyViolation)
+	}
+	admin.expectUsers(t, "admin")
+
+	// the kick holds on the instance bob comes back to
+	again := newFakeConn()
+	serve(hubs[1], again, "bob")
+	again.expect(t, "error")
+	again.expectClosed(t)
+
+	carol := newFakeConn()
+	serve(hubs[1], carol, "carol")
+	carol.send(t, wsmodel.SocketPayLoad{Condition: "sendMessage", Message: "what the heck"})
+	if got := admin.expect(t, "message").Chat.Message; got != "what the ****" {
+		t.Errorf("message = %q, want the filter of the other instance applied", got)
+	}
+}
@@ -0,0 +1,159 @@
+package ws
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yusuf/track-space/pkg/model"
+)
+
+// DefaultRoom : the room of the chat every client is in
+const DefaultRoom = "general"
+
+// HistorySize : the last messages of a room sent to a client once connected
+const HistorySize = 50
+
+// persistQueue : messages and receipts waiting to be stored before new ones are dropped from the store
+const persistQueue = 1024
+
+// ErrInvalidCursor : the cursor of a page of the history cannot be read
+var ErrInvalidCursor = errors.New("invalid chat history cursor")
+
+// Store : where the messages of the chat rooms, the receipts of their readers and the moderation of the admins are kept
+type Store interface {
+	StoreChatMessage(msg model.ChatMessage) error
+	// GetChatMessages : the last messages of room sent before the one sent at before with the id beforeID,
+	// oldest first. The zero time returns the latest messages
+	GetChatMessages(room string, before time.Time, beforeID string, limit int64) ([]model.ChatMessage, error)
+	// StoreReadReceipt : this replaces the receipt of the user in the room
+	StoreReadReceipt(receipt model.ReadReceipt) error
+	GetReadReceipts(room string) ([]model.ReadReceipt, error)
+	// DeleteChatMessage : this removes a message of room deleted by an admin
+	DeleteChatMessage(room, id string) error
+	// StoreChatSanction : this replaces the mute, kick or ban of the same id
+	StoreChatSanction(sanction model.ChatSanction) error
+	DeleteChatSanction(id string) error
+	// GetChatSanctions : the mutes, kicks and bans still running at now
+	GetChatSanctions(now time.Time) ([]model.ChatSanction, error)
+	StoreChatAudit(entry model.ChatAudit) error
+	StoreChatWordFilter(words []string) error
+	GetChatWordFilter() ([]string, error)
+}
+
+/*
+Cursor : the position of a message in the history of a room. Messages sent in the
+same millisecond are told apart by their id
+*/
+type Cursor struct {
+	SentAt time.Time
+	ID     string
+}
+
+// CursorOf : this returns the cursor of the history right before msg
+func CursorOf(msg model.ChatMessage) Cursor {
+	return Cursor{SentAt: msg.SentAt, ID: msg.ID}
+}
+
+// String : this encodes a cursor for a url, as unix milliseconds and the message id
+func (c Cursor) String() string {
+	if c.SentAt.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("%d_%s", c.SentAt.UnixMilli(), c.ID)
+}
+
+// ParseCursor : this reads an encoded cursor, the empty cursor starts from the latest message
+func ParseCursor(raw string) (Cursor, error) {
+	if raw == "" {
+		return Cursor{}, nil
+	}
+	millis, id, _ := strings.Cut(raw, "_")
+	ms, err := strconv.ParseInt(millis, 10, 64)
+	if err != nil || ms <= 0 {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return Cursor{SentAt: time.UnixMilli(ms).UTC(), ID: id}, nil
+}
+
+// history : the last messages of a room, oldest first, and the last one read by every user
+type history struct {
+	messages []model.ChatMessage
+	size     int
+	receipts map[string]model.ReadReceipt
+}
+
+func newHistory(messages []model.ChatMessage, receipts []model.ReadReceipt) *history {
+	h := &history{size: HistorySize, receipts: make(map[string]model.ReadReceipt)}
+	for _, msg := range messages {
+		h.add(msg)
+	}
+	for _, receipt := range receipts {
+		h.receipts[receipt.UserID] = receipt
+	}
+	return h
+}
+
+func (h *history) add(msg model.ChatMessage) {
+	h.messages = append(h.messages, msg)
+	if len(h.messages) > h.size {
+		h.messages = append([]model.ChatMessage(nil), h.messages[len(h.messages)-h.size:]...)
+	}
+}
+
+// remove : this removes a message of the history, it reports false when it is not among the last ones
+func (h *history) remove(id string) bool {
+	for i, msg := range h.messages {
+		if msg.ID == id {
+			h.messages = append(h.messages[:i:i], h.messages[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// list : this returns a copy of the messages, safe to hand to another goroutine
+func (h *history) list() []model.ChatMessage {
+	return append([]model.ChatMessage{}, h.messages...)
+}
+
+/*
+read : this moves the receipt of a user to a message of the history, it reports false
+when the message is not among the last ones or is older than the one already read
+*/
+func (h *history) read(receipt model.ReadReceipt) (model.ReadReceipt, bool) {
+	for _, msg := range h.messages {
+		if msg.ID != receipt.MessageID {
+			continue
+		}
+		current, ok := h.receipts[receipt.UserID]
+		if ok && !CursorOf(msg).after(Cursor{SentAt: current.SentAt, ID: current.MessageID}) {
+			return model.ReadReceipt{}, false
+		}
+		receipt.SentAt = msg.SentAt
+		h.receipts[receipt.UserID] = receipt
+		return receipt, true
+	}
+	return model.ReadReceipt{}, false
+}
+
+// receiptList : this returns the receipts of the room sorted by user
+func (h *history) receiptList() []model.ReadReceipt {
+	receipts := make([]model.ReadReceipt, 0, len(h.receipts))
+	for _, receipt := range h.receipts {
+		receipts = append(receipts, receipt)
+	}
+	sort.Slice(receipts, func(i, j int) bool { return receipts[i].UserID < receipts[j].UserID })
+	return receipts
+}
+
+// after : this reports whether the message of c was sent after the one of other
+func (c Cursor) after(other Cursor) bool {
+	if !c.SentAt.Equal(other.SentAt) {
+		return c.SentAt.After(other.SentAt)
+	}
+	return c.ID > other.ID
+}
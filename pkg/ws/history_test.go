@@ -0,0 +1,179 @@
+package ws
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yusuf/track-space/pkg/model"
+	"github.com/yusuf/track-space/pkg/wsmodel"
+)
+
+// memoryStore : a store keeping the messages, receipts and moderation in memory
+type memoryStore struct {
+	mu        sync.Mutex
+	messages  []model.ChatMessage
+	receipts  []model.ReadReceipt
+	stored    chan model.ChatMessage
+	sanctions map[string]model.ChatSanction
+	audit     []model.ChatAudit
+	words     []string
+}
+
+func (m *memoryStore) StoreReadReceipt(receipt model.ReadReceipt) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, r := range m.receipts {
+		if r.ID == receipt.ID {
+			m.receipts[i] = receipt
+			return nil
+		}
+	}
+	m.receipts = append(m.receipts, receipt)
+	return nil
+}
+
+func (m *memoryStore) GetReadReceipts(room string) ([]model.ReadReceipt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var receipts []model.ReadReceipt
+	for _, r := range m.receipts {
+		if r.Room == room {
+			receipts = append(receipts, r)
+		}
+	}
+	return receipts, nil
+}
+
+func (m *memoryStore) StoreChatMessage(msg model.ChatMessage) error {
+	m.mu.Lock()
+	m.messages = append(m.messages, msg)
+	m.mu.Unlock()
+	if m.stored != nil {
+		m.stored <- msg
+	}
+	return nil
+}
+
+func (m *memoryStore) GetChatMessages(room string, before time.Time, beforeID string, limit int64) ([]model.ChatMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var page []model.ChatMessage
+	for _, msg := range m.messages {
+		if msg.Room == room && (before.IsZero() || msg.SentAt.Before(before)) {
+			page = append(page, msg)
+		}
+	}
+	if int64(len(page)) > limit {
+		page = page[int64(len(page))-limit:]
+	}
+	return page, nil
+}
+
+func chatMessage(i int) model.ChatMessage {
+	return model.ChatMessage{
+		ID:      string(rune('a' + i)),
+		Room:    DefaultRoom,
+		Message: "message",
+		SentAt:  time.Date(2022, 9, 1, 10, 0, i, 0, time.UTC),
+	}
+}
+
+func TestCursor(t *testing.T) {
+	sent := time.Date(2022, 9, 1, 10, 0, 0, int(250*time.Millisecond), time.UTC)
+	tests := []struct {
+		name    string
+		raw     string
+		want    Cursor
+		wantErr error
+	}{
+		{"empty", "", Cursor{}, nil},
+		{"cursor", "1662026400250_63106d", Cursor{SentAt: sent, ID: "63106d"}, nil},
+		{"no id", "1662026400250", Cursor{SentAt: sent}, nil},
+		{"not a number", "yesterday_63106d", Cursor{}, ErrInvalidCursor},
+		{"negative", "-5_63106d", Cursor{}, ErrInvalidCursor},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCursor(tt.raw)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseCursor(%q) error = %v, want %v", tt.raw, err, tt.wantErr)
+			}
+			if !got.SentAt.Equal(tt.want.SentAt) || got.ID != tt.want.ID {
+				t.Errorf("ParseCursor(%q) = %+v, want %+v", tt.raw, got, tt.want)
+			}
+		})
+	}
+
+	cursor := CursorOf(model.ChatMessage{ID: "63106d", SentAt: sent})
+	if got, want := cursor.String(), "1662026400250_63106d"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if got := (Cursor{}).String(); got != "" {
+		t.Errorf("empty String() = %q, want \"\"", got)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	h := newHistory(nil, nil)
+	h.size = 3
+	if got := h.list(); got == nil || len(got) != 0 {
+		t.Errorf("empty list() = %v, want an empty slice", got)
+	}
+	for i := 0; i < 5; i++ {
+		h.add(chatMessage(i))
+	}
+	want := []model.ChatMessage{chatMessage(2), chatMessage(3), chatMessage(4)}
+	got := h.list()
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("list() = %v, want %v", got, want)
+	}
+	got[0].Message = "changed"
+	if h.list()[0].Message == "changed" {
+		t.Error("list() shares the messages of the history")
+	}
+}
+
+func TestHub_History(t *testing.T) {
+	store := &memoryStore{stored: make(chan model.ChatMessage, 1)}
+	for i := 0; i < HistorySize+5; i++ {
+		store.messages = append(store.messages, chatMessage(i))
+	}
+	hub := NewHub(store, nil)
+	go hub.Run()
+
+	alice := newFakeConn()
+	serve(hub, alice, "alice")
+	history := alice.expect(t, "history").History
+	if len(history) != HistorySize || history[0].ID != chatMessage(5).ID {
+		t.Fatalf("history starts with %v of %d messages, want the last %d stored", history[0], len(history), HistorySize)
+	}
+
+	alice.send(t, wsmodel.SocketPayLoad{Condition: "sendMessage", Message: "  "})
+	alice.send(t, wsmodel.SocketPayLoad{Condition: "sendMessage", Message: " hello "})
+	chat := alice.expect(t, "message").Chat
+	if chat == nil || chat.Message != "hello" || chat.SenderID != "id-alice" || chat.SenderName != "alice" || chat.Room != DefaultRoom {
+		t.Fatalf("message chat = %+v, want hello from alice", chat)
+	}
+	select {
+	case stored := <-store.stored:
+		if !reflect.DeepEqual(stored, *chat) {
+			t.Errorf("stored %+v, want %+v", stored, *chat)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("message not stored")
+	}
+
+	bob := newFakeConn()
+	serve(hub, bob, "bob")
+	history = bob.expect(t, "history").History
+	if last := history[len(history)-1]; last.ID != chat.ID {
+		t.Errorf("last message of the history = %+v, want %+v", last, *chat)
+	}
+	hub.Stop()
+	if got := len(store.messages); got != HistorySize+6 {
+		t.Errorf("%d messages stored, want %d", got, HistorySize+6)
+	}
+}
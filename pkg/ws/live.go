@@ -0,0 +1,90 @@
+package ws
+
+import (
+	"github.com/gorilla/websocket"
+	"github.com/yusuf/track-space/pkg/wsmodel"
+)
+
+// notification : a response for the live pages of a user
+type notification struct {
+	userID string
+	resp   wsmodel.SocketResponse
+}
+
+/*
+Watch : this makes conn a live page of the user until the connection ends or the hub
+stops. Live pages are not in the chat, they only get the responses sent to their user
+with Notify, like a project or todo changed from another tab or device
+*/
+func (h *Hub) Watch(conn Conn, userID string) {
+	c := &Client{Identity: Identity{UserID: userID}, conn: conn, send: make(chan wsmodel.SocketResponse, sendQueue)}
+	select {
+	case h.watch <- c:
+	case <-h.done:
+		_ = conn.Close()
+		return
+	}
+	h.writers.Add(1)
+	go func() {
+		defer h.writers.Done()
+		c.write(h.pingPeriod)
+	}()
+	defer func() {
+		select {
+		case h.unwatch <- c:
+		case <-h.done:
+		}
+	}()
+
+	// the pages never send anything, reading keeps the pongs coming and ends with the connection
+	for {
+		var payload wsmodel.SocketPayLoad
+		if err := conn.ReadJSON(&payload); err != nil {
+			return
+		}
+	}
+}
+
+// Notify : this sends a response to the live pages of a user, on every instance
+func (h *Hub) Notify(userID string, resp wsmodel.SocketResponse) {
+	select {
+	case h.notify <- notification{userID: userID, resp: resp}:
+	case <-h.done:
+	}
+}
+
+// notifyWatchers : this queues a response for the live pages of a user of this instance
+func (h *Hub) notifyWatchers(userID string, resp wsmodel.SocketResponse) {
+	for c := range h.watchers[userID] {
+		select {
+		case c.send <- resp:
+		default:
+			// a page too slow to read is dropped, it reloads what it missed once reconnected
+			h.dropWatcher(c)
+			_ = c.conn.Close()
+		}
+	}
+}
+
+// dropWatcher : this removes a live page from the hub, its writer then closes the connection
+func (h *Hub) dropWatcher(c *Client) {
+	pages := h.watchers[c.UserID]
+	if !pages[c] {
+		return
+	}
+	delete(pages, c)
+	if len(pages) == 0 {
+		delete(h.watchers, c.UserID)
+	}
+	close(c.send)
+}
+
+// stopWatchers : this disconnects every live page with a "going away" close frame
+func (h *Hub) stopWatchers() {
+	for _, pages := range h.watchers {
+		for c := range pages {
+			c.closeCode, c.closeReason = websocket.CloseGoingAway, "server shutting down"
+			h.dropWatcher(c)
+		}
+	}
+}
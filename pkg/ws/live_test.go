@@ -0,0 +1,146 @@
+package ws
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/yusuf/track-space/pkg/wsmodel"
+)
+
+// watch : this connects conn as a live page of the user name, the returned channel is closed once Watch returns
+func watch(h *Hub, conn *fakeConn, name string) chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		h.Watch(conn, "id-"+name)
+		close(done)
+	}()
+	return done
+}
+
+func changed(id string) wsmodel.SocketResponse {
+	return wsmodel.SocketResponse{Condition: "changed", Change: &wsmodel.Change{Kind: "project", Action: "updated", ID: id}}
+}
+
+// expectChange : this notifies the user name until conn gets the change, the page may not be watching yet
+func (f *fakeConn) expectChange(t *testing.T, h *Hub, name, id string) {
+	t.Helper()
+	deadline := time.After(2 * time.Second)
+	for {
+		h.Notify("id-"+name, changed(id))
+		select {
+		case resp := <-f.out:
+			if resp.Condition == "changed" && resp.Change != nil && resp.Change.ID == id {
+				return
+			}
+		case <-time.After(10 * time.Millisecond):
+		case <-deadline:
+			t.Fatalf("change of %s not received", id)
+		}
+	}
+}
+
+// expectID : this waits for the change of id, skipping the earlier ones sent again by expectChange
+func (f *fakeConn) expectID(t *testing.T, id string) {
+	t.Helper()
+	for f.expect(t, "changed").Change.ID != id {
+	}
+}
+
+func TestHub_Watch(t *testing.T) {
+	hub := NewHub(nil, nil)
+	go hub.Run()
+	defer hub.Stop()
+
+	tab, phone, other := newFakeConn(), newFakeConn(), newFakeConn()
+	watch(hub, tab, "alice")
+	watch(hub, phone, "alice")
+	watch(hub, other, "bob")
+	tab.expectChange(t, hub, "alice", "p1")
+	phone.expectChange(t, hub, "alice", "p1")
+	other.expectChange(t, hub, "bob", "p2")
+
+	// every page of alice gets her changes, bob never does
+	hub.Notify("id-alice", changed("p3"))
+	tab.expectID(t, "p3")
+	phone.expectID(t, "p3")
+	select {
+	case resp := <-other.out:
+		if resp.Change.ID != "p2" {
+			t.Errorf("bob got %+v", resp.Change)
+		}
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// live pages are not in the chat
+	if got := hub.Users(); len(got) != 0 {
+		t.Errorf("Users() = %v, want none", got)
+	}
+}
+
+func TestHub_WatchNotInChat(t *testing.T) {
+	hub := NewHub(nil, nil)
+	go hub.Run()
+	defer hub.Stop()
+
+	page, chat := newFakeConn(), newFakeConn()
+	watch(hub, page, "alice")
+	page.expectChange(t, hub, "alice", "p1")
+	serve(hub, chat, "alice")
+	chat.expect(t, "username")
+
+	// the chat client gets no change, the page gets no chat
+	hub.Notify("id-alice", changed("p2"))
+	page.expectID(t, "p2")
+	hub.Broadcast(wsmodel.SocketResponse{Condition: "notice"})
+	chat.expect(t, "notice")
+	for len(chat.out) > 0 {
+		if resp := <-chat.out; resp.Condition == "changed" {
+			t.Errorf("chat client got %+v", resp)
+		}
+	}
+	select {
+	case resp := <-page.out:
+		if resp.Condition != "changed" {
+			t.Errorf("live page got %+v", resp)
+		}
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestHub_WatchEnds(t *testing.T) {
+	hub := NewHub(nil, nil)
+	go hub.Run()
+
+	left, staying := newFakeConn(), newFakeConn()
+	leftDone := watch(hub, left, "alice")
+	watch(hub, staying, "alice")
+	left.expectChange(t, hub, "alice", "p1")
+	staying.expectChange(t, hub, "alice", "p1")
+
+	_ = left.Close()
+	select {
+	case <-leftDone:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Watch did not return once the page closed")
+	}
+	hub.Notify("id-alice", changed("p2"))
+	staying.expectID(t, "p2")
+
+	hub.Stop()
+	staying.expectClosed(t)
+	if staying.closeCode != websocket.CloseGoingAway {
+		t.Errorf("close code = %d, want %d", staying.closeCode, websocket.CloseGoingAway)
+	}
+}
+
+func TestHub_BackplaneWatch(t *testing.T) {
+	hubs := replicas(t, 2)
+	page, elsewhere := newFakeConn(), newFakeConn()
+	watch(hubs[0], page, "alice")
+	watch(hubs[1], elsewhere, "alice")
+	page.expectChange(t, hubs[0], "alice", "p1")
+
+	// a change made on the first instance reaches the page opened on the second one
+	elsewhere.expectChange(t, hubs[0], "alice", "p2")
+}
@@ -0,0 +1,406 @@
+package ws
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/yusuf/track-space/pkg/model"
+	"github.com/yusuf/track-space/pkg/wsmodel"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// moderation actions of the admins, also the conditions of their socket payloads
+const (
+	ModDelete = "deleteMessage"
+	ModMute   = "mute"
+	ModKick   = "kick"
+	ModBan    = "ban"
+	// ModLift : this ends a mute, kick or ban before it runs out
+	ModLift = "lift"
+	// ModFilter : this replaces the words masked in the messages
+	ModFilter = "filter"
+)
+
+// MaxSanction : the longest a mute or a kick lasts, bans may last until lifted
+const MaxSanction = 30 * 24 * time.Hour
+
+var (
+	ErrNotAdmin      = errors.New("only admins can moderate the chat")
+	ErrMuted         = errors.New("you are muted")
+	ErrKicked        = errors.New("you are kicked from the chat")
+	ErrBanned        = errors.New("you are banned from this chat room")
+	ErrDuration      = errors.New("a mute or a kick lasts from a minute to 30 days, written like 10m or 2h")
+	ErrNoTarget      = errors.New("choose a user other than yourself")
+	ErrBanGeneral    = errors.New("users are kicked from the general room, not banned")
+	ErrNoMessage     = errors.New("chat message not found")
+	ErrNoSanction    = errors.New("mute, kick or ban not found")
+	ErrUnknownAction = errors.New("unknown moderation action")
+	ErrChatStopped   = errors.New("the chat is not running")
+)
+
+/*
+Moderation : an action of an admin on the chat, sent over the socket or from the admin
+page. Every action is kept in the audit of the store
+*/
+type Moderation struct {
+	Action string
+	Admin  Identity
+	// UserID : the user muted, kicked or banned
+	UserID    string
+	Room      string
+	MessageID string
+	// Duration : how long a mute, kick or ban lasts, a ban without one lasts until lifted
+	Duration time.Duration
+	Reason   string
+	// Sanction : the id of the mute, kick or ban a ModLift ends
+	Sanction string
+	// Words : the words masked from now on by a ModFilter
+	Words []string
+}
+
+// moderationRequest : a moderation of the admin page waiting for the hub, with the reply of its outcome
+type moderationRequest struct {
+	moderation Moderation
+	reply      chan error
+}
+
+// SanctionID : this returns the id of the mute or kick of a user, or of the ban of a user from room
+func SanctionID(kind, userID, room string) string {
+	if kind == ModBan {
+		return kind + ":" + room + ":" + userID
+	}
+	return kind + ":" + userID
+}
+
+// ParseDuration : this reads the duration of a sanction as typed by an admin, like "10m" or "2h", empty for none
+func ParseDuration(raw string) (time.Duration, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		return 0, ErrDuration
+	}
+	return d, nil
+}
+
+// sanction : this builds the mute, kick or ban of a moderation issued at now
+func (m Moderation) sanction(now time.Time) (model.ChatSanction, error) {
+	if m.UserID == "" || m.UserID == m.Admin.UserID {
+		return model.ChatSanction{}, ErrNoTarget
+	}
+	room := ""
+	switch m.Action {
+	case ModMute, ModKick:
+		if m.Duration < time.Minute || m.Duration > MaxSanction {
+			return model.ChatSanction{}, ErrDuration
+		}
+	case ModBan:
+		kind, _, err := ParseRoom(m.Room)
+		if err != nil {
+			return model.ChatSanction{}, err
+		}
+		if kind == RoomGeneral {
+			return model.ChatSanction{}, ErrBanGeneral
+		}
+		room = m.Room
+	}
+	s := model.ChatSanction{
+		ID:       SanctionID(m.Action, m.UserID, room),
+		Kind:     m.Action,
+		UserID:   m.UserID,
+		Room:     room,
+		Reason:   m.Reason,
+		AdminID:  m.Admin.UserID,
+		IssuedAt: now,
+	}
+	if m.Duration > 0 {
+		s.Until = now.Add(m.Duration)
+	}
+	return s, nil
+}
+
+// audit : this returns the audit entry of a moderation done at now
+func (m Moderation) audit(id string, now time.Time) model.ChatAudit {
+	entry := model.ChatAudit{
+		ID:        id,
+		Action:    m.Action,
+		AdminID:   m.Admin.UserID,
+		AdminName: m.Admin.Name,
+		UserID:    m.UserID,
+		Room:      m.Room,
+		MessageID: m.MessageID,
+		Reason:    m.Reason,
+		CreatedAt: now,
+	}
+	switch m.Action {
+	case ModMute, ModKick, ModBan:
+		if m.Duration > 0 {
+			entry.Detail = "for " + m.Duration.String()
+		}
+	case ModLift:
+		entry.Detail = m.Sanction
+	case ModFilter:
+		entry.Detail = strings.Join(m.Words, ", ")
+	}
+	return entry
+}
+
+// active : this reports whether a sanction still runs at now
+func active(s model.ChatSanction, now time.Time) bool {
+	return s.Until.IsZero() || now.Before(s.Until)
+}
+
+// sanctionError : this returns the error sent to a user held by a sanction, with its end
+func sanctionError(err error, s model.ChatSanction) string {
+	msg := err.Error()
+	if !s.Until.IsZero() {
+		msg += " until " + s.Until.UTC().Format("2006-01-02 15:04 MST")
+	}
+	if s.Reason != "" {
+		msg += fmt.Sprintf(" (%s)", s.Reason)
+	}
+	return msg
+}
+
+/*
+Moderate : this applies the moderation of an admin, done outside of the chat like on the
+admin page, as if sent over the socket
+*/
+func (h *Hub) Moderate(m Moderation) error {
+	reply := make(chan error, 1)
+	select {
+	case h.moderation <- moderationRequest{moderation: m, reply: reply}:
+		return <-reply
+	case <-h.done:
+		return ErrChatStopped
+	}
+}
+
+// moderationOf : this reads the moderation of a socket payload, the reason of a sanction or the words of a filter are its message
+func moderationOf(admin Identity, payload wsmodel.SocketPayLoad) (Moderation, error) {
+	d, err := ParseDuration(payload.Duration)
+	if err != nil {
+		return Moderation{}, err
+	}
+	m := Moderation{
+		Action:    payload.Condition,
+		Admin:     admin,
+		UserID:    payload.To,
+		Room:      payload.Room,
+		MessageID: payload.MessageID,
+		Duration:  d,
+		Sanction:  payload.Sanction,
+	}
+	if m.Action == ModFilter {
+		m.Words = ParseWords(payload.Message)
+	} else {
+		m.Reason = strings.TrimSpace(payload.Message)
+	}
+	return m, nil
+}
+
+/*
+moderate : this applies the moderation of an admin and keeps its audit entry. Deleted
+messages leave the history of their room, muted users cannot send, kicked users are
+disconnected and cannot connect again and banned users leave the room and cannot join it
+until their sanction runs out or is lifted
+*/
+func (h *Hub) moderate(m Moderation) error {
+	if !m.Admin.Admin {
+		return ErrNotAdmin
+	}
+	now := h.now().UTC().Truncate(time.Millisecond)
+	switch m.Action {
+	case ModDelete:
+		if m.Room == "" {
+			m.Room = DefaultRoom
+		}
+		if _, _, err := ParseRoom(m.Room); err != nil {
+			return err
+		}
+		hist, ok := h.histories[m.Room]
+		removed := ok && hist.remove(m.MessageID)
+		// older messages are only in the store
+		if m.MessageID == "" || (!removed && h.store == nil) {
+			return ErrNoMessage
+		}
+		h.save(persistOp{deleted: &model.ChatMessage{ID: m.MessageID, Room: m.Room}})
+		h.publishRoom(m.Room, "", wsmodel.SocketResponse{Condition: "deleted", Room: m.Room, MessageID: m.MessageID})
+	case ModMute, ModKick, ModBan:
+		s, err := m.sanction(now)
+		if err != nil {
+			return err
+		}
+		h.sanctions[s.ID] = s
+		h.save(persistOp{sanction: &s})
+		h.enforce(s)
+		h.publish(Event{Kind: eventSanction, Sanction: &s})
+	case ModLift:
+		s, ok := h.sanctions[m.Sanction]
+		if !ok {
+			return ErrNoSanction
+		}
+		h.save(persistOp{lifted: s.ID})
+		h.lift(s)
+		h.publish(Event{Kind: eventLift, Sanction: &s})
+		m.UserID, m.Room = s.UserID, s.Room
+	case ModFilter:
+		h.filter = NewWordFilter(m.Words)
+		h.save(persistOp{words: h.filter.Words()})
+		h.publish(Event{Kind: eventFilter, Words: h.filter.Words()})
+	default:
+		return ErrUnknownAction
+	}
+	entry := m.audit(primitive.NewObjectID().Hex(), now)
+	h.save(persistOp{audit: &entry})
+	return nil
+}
+
+// enforce : this tells the clients of a user of their new sanction, a kick disconnects them and a ban takes them out of the room
+func (h *Hub) enforce(s model.ChatSanction) {
+	condition, err := "muted", ErrMuted
+	switch s.Kind {
+	case ModKick:
+		condition, err = "kicked", ErrKicked
+	case ModBan:
+		condition, err = "banned", ErrBanned
+	}
+	resp := wsmodel.SocketResponse{Condition: condition, Room: s.Room, Message: sanctionError(err, s), Sanction: &s}
+	kicked := false
+	for c := range h.clients {
+		if c.UserID != s.UserID {
+			continue
+		}
+		switch s.Kind {
+		case ModMute:
+			h.deliver(c, resp)
+		case ModKick:
+			h.deliver(c, resp)
+			if h.clients[c] {
+				c.closeCode, c.closeReason = websocket.ClosePolicyViolation, ErrKicked.Error()
+				h.drop(c)
+			}
+			kicked = true
+		case ModBan:
+			if c.rooms[s.Room] {
+				h.leave(c, s.Room)
+			}
+			h.deliver(c, resp)
+		}
+	}
+	if kicked {
+		h.broadcastUsers()
+	}
+}
+
+// lift : this ends a sanction and tells the clients of its user
+func (h *Hub) lift(s model.ChatSanction) {
+	delete(h.sanctions, s.ID)
+	for c := range h.clients {
+		if c.UserID == s.UserID {
+			h.deliver(c, wsmodel.SocketResponse{Condition: "lifted", Room: s.Room, Sanction: &s})
+		}
+	}
+}
+
+// sanctionOf : this returns the mute, kick or ban of a user still running, forgetting it once run out
+func (h *Hub) sanctionOf(kind, userID, room string) (model.ChatSanction, bool) {
+	s, ok := h.sanctions[SanctionID(kind, userID, room)]
+	if !ok {
+		return model.ChatSanction{}, false
+	}
+	if !active(s, h.now()) {
+		delete(h.sanctions, s.ID)
+		h.save(persistOp{lifted: s.ID})
+		return model.ChatSanction{}, false
+	}
+	return s, true
+}
+
+// loadModeration : this reads the sanctions still running and the word filter from the store
+func (h *Hub) loadModeration() {
+	if h.store == nil {
+		return
+	}
+	sanctions, err := h.store.GetChatSanctions(h.now())
+	if err != nil {
+		log.Printf("cannot load the chat sanctions : %v", err)
+	}
+	for _, s := range sanctions {
+		h.sanctions[s.ID] = s
+	}
+	words, err := h.store.GetChatWordFilter()
+	if err != nil {
+		log.Printf("cannot load the chat word filter : %v", err)
+		return
+	}
+	h.filter = NewWordFilter(words)
+}
+
+/*
+WordFilter : the words masked in the chat messages, whole words whatever their case.
+The nil filter masks nothing
+*/
+type WordFilter struct {
+	words []string
+	re    *regexp.Regexp
+}
+
+// ParseWords : this reads the words of a filter separated by commas or new lines, lower case and sorted once each
+func ParseWords(raw string) []string {
+	seen := make(map[string]bool)
+	words := []string{}
+	for _, word := range strings.FieldsFunc(raw, func(r rune) bool { return r == ',' || r == '\n' || r == '\r' }) {
+		word = strings.ToLower(strings.TrimSpace(word))
+		if word == "" || seen[word] {
+			continue
+		}
+		seen[word] = true
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
+}
+
+// NewWordFilter : this returns the filter masking words
+func NewWordFilter(words []string) *WordFilter {
+	if len(words) == 0 {
+		return nil
+	}
+	// the longest words are tried first so that a word never masks only the start of another
+	alternatives := make([]string, len(words))
+	for i, word := range words {
+		alternatives[i] = regexp.QuoteMeta(word)
+	}
+	sort.Slice(alternatives, func(i, j int) bool { return len(alternatives[i]) > len(alternatives[j]) })
+	return &WordFilter{
+		words: append([]string(nil), words...),
+		re:    regexp.MustCompile(`(?i)\b(?:` + strings.Join(alternatives, "|") + `)\b`),
+	}
+}
+
+// Words : this returns the words masked by the filter
+func (f *WordFilter) Words() []string {
+	if f == nil {
+		return []string{}
+	}
+	return append([]string{}, f.words...)
+}
+
+// Clean : this replaces every filtered word of text by as many asterisks as it has characters
+func (f *WordFilter) Clean(text string) string {
+	if f == nil {
+		return text
+	}
+	return f.re.ReplaceAllStringFunc(text, func(word string) string {
+		return strings.Repeat("*", len([]rune(word)))
+	})
+}
@@ -0,0 +1,350 @@
+package ws
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/yusuf/track-space/pkg/model"
+	"github.com/yusuf/track-space/pkg/wsmodel"
+)
+
+func (m *memoryStore) DeleteChatMessage(room, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, msg := range m.messages {
+		if msg.Room == room && msg.ID == id {
+			m.messages = append(m.messages[:i:i], m.messages[i+1:]...)
+			return nil
+		}
+	}
+	return errors.New("no message")
+}
+
+func (m *memoryStore) StoreChatSanction(sanction model.ChatSanction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.sanctions == nil {
+		m.sanctions = make(map[string]model.ChatSanction)
+	}
+	m.sanctions[sanction.ID] = sanction
+	return nil
+}
+
+func (m *memoryStore) DeleteChatSanction(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sanctions, id)
+	return nil
+}
+
+func (m *memoryStore) GetChatSanctions(now time.Time) ([]model.ChatSanction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var sanctions []model.ChatSanction
+	for _, s := range m.sanctions {
+		if active(s, now) {
+			sanctions = append(sanctions, s)
+		}
+	}
+	return sanctions, nil
+}
+
+func (m *memoryStore) StoreChatAudit(entry model.ChatAudit) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.audit = append(m.audit, entry)
+	return nil
+}
+
+func (m *memoryStore) StoreChatWordFilter(words []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.words = words
+	return nil
+}
+
+func (m *memoryStore) GetChatWordFilter() ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.words, nil
+}
+
+// serveAdmin : this connects conn of an admin to the hub
+func serveAdmin(h *Hub, conn *fakeConn) {
+	go h.Serve(conn, Identity{UserID: "id-admin", Name: "admin", Admin: true})
+}
+
+func TestWordFilter(t *testing.T) {
+	words := ParseWords(" Darn,heck\n\ndarn , dang it\r\n")
+	if want := []string{"dang it", "darn", "heck"}; !reflect.DeepEqual(words, want) {
+		t.Fatalf("ParseWords() = %q, want %q", words, want)
+	}
+	filter := NewWordFilter(words)
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"what the HECK", "what the ****"},
+		{"darn, dang it!", "****, *******!"},
+		{"heckle and darned stay", "heckle and darned stay"},
+		{"nothing to hide", "nothing to hide"},
+	}
+	for _, tt := range tests {
+		if got := filter.Clean(tt.text); got != tt.want {
+			t.Errorf("Clean(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+	var none *WordFilter
+	if got := none.Clean("heck"); got != "heck" {
+		t.Errorf("nil filter Clean() = %q, want the text unchanged", got)
+	}
+	if NewWordFilter(nil) != nil {
+		t.Error("NewWordFilter(nil) is not the nil filter")
+	}
+}
+
+func TestModeration_Sanction(t *testing.T) {
+	now := time.Date(2022, 9, 1, 10, 0, 0, 0, time.UTC)
+	admin := Identity{UserID: "id-admin", Name: "admin", Admin: true}
+	tests := []struct {
+		name    string
+		m       Moderation
+		wantID  string
+		wantErr error
+	}{
+		{"mute", Moderation{Action: ModMute, Admin: admin, UserID: "bob", Duration: time.Hour}, "mute:bob", nil},
+		{"kick too short", Moderation{Action: ModKick, Admin: admin, UserID: "bob", Duration: time.Second}, "", ErrDuration},
+		{"mute too long", Moderation{Action: ModMute, Admin: admin, UserID: "bob", Duration: MaxSanction + time.Hour}, "", ErrDuration},
+		{"oneself", Moderation{Action: ModMute, Admin: admin, UserID: "id-admin", Duration: time.Hour}, "", ErrNoTarget},
+		{"ban until lifted", Moderation{Action: ModBan, Admin: admin, UserID: "bob", Room: TeamRoom("t1")}, "ban:team:t1:bob", nil},
+		{"ban from general", Moderation{Action: ModBan, Admin: admin, UserID: "bob", Room: DefaultRoom}, "", ErrBanGeneral},
+		{"ban from nowhere", Moderation{Action: ModBan, Admin: admin, UserID: "bob", Room: "lobby"}, "", ErrUnknownRoom},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := tt.m.sanction(now)
+			if !errors.Is(err, tt.wantErr) || s.ID != tt.wantID {
+				t.Fatalf("sanction() = %q, %v, want %q, %v", s.ID, err, tt.wantID, tt.wantErr)
+			}
+			if err == nil && s.Until.IsZero() != (tt.m.Duration == 0) {
+				t.Errorf("sanction() until = %v for a duration of %v", s.Until, tt.m.Duration)
+			}
+		})
+	}
+}
+
+func TestHub_ModerationNeedsAdmin(t *testing.T) {
+	hub := NewHub(nil, nil)
+	go hub.Run()
+	defer hub.Stop()
+
+	alice, bob := newFakeConn(), newFakeConn()
+	serve(hub, alice, "alice")
+	serve(hub, bob, "bob")
+	alice.expectUsers(t, "alice", "bob")
+
+	alice.send(t, wsmodel.SocketPayLoad{Condition: ModKick, To: "id-bob", Duration: "1h"})
+	if got := alice.expect(t, "error"); got.Message != ErrNotAdmin.Error() {
+		t.Errorf("kick error = %q, want %q", got.Message, ErrNotAdmin)
+	}
+	if err := hub.Moderate(Moderation{Action: ModMute, Admin: Identity{UserID: "id-alice"}, UserID: "id-bob", Duration: time.Hour}); err != ErrNotAdmin {
+		t.Errorf("Moderate() error = %v, want %v", err, ErrNotAdmin)
+	}
+}
+
+func TestHub_Mute(t *testing.T) {
+	clk := &clock{now: time.Date(2022, 9, 1, 10, 0, 0, 0, time.UTC)}
+	store := &memoryStore{}
+	hub := NewHub(store, nil)
+	hub.now = clk.Now
+	go hub.Run()
+
+	admin, bob := newFakeConn(), newFakeConn()
+	serveAdmin(hub, admin)
+	serve(hub, bob, "bob")
+	admin.expectUsers(t, "admin", "bob")
+
+	admin.send(t, wsmodel.SocketPayLoad{Condition: ModMute, To: "id-bob", Duration: "10m", Message: "spam"})
+	admin.expect(t, "moderated")
+	if got := bob.expect(t, "muted"); got.Sanction == nil || got.Sanction.ID != SanctionID(ModMute, "id-bob", "") {
+		t.Fatalf("muted response = %+v, want the mute of bob", got)
+	}
+	bob.send(t, wsmodel.SocketPayLoad{Condition: "sendMessage", Message: "buy now"})
+	bob.expect(t, "error")
+
+	// the mute runs out on its own
+	clk.Add(11 * time.Minute)
+	bob.send(t, wsmodel.SocketPayLoad{Condition: "sendMessage", Message: "sorry"})
+	if got := admin.expect(t, "message"); got.Chat.Message != "sorry" {
+		t.Errorf("message after the mute = %q, want sorry", got.Chat.Message)
+	}
+	hub.Stop()
+
+	if len(store.audit) != 1 || store.audit[0].Action != ModMute || store.audit[0].UserID != "id-bob" || store.audit[0].Reason != "spam" {
+		t.Errorf("audit = %+v, want the mute of bob for spam", store.audit)
+	}
+	if len(store.sanctions) != 0 {
+		t.Errorf("sanctions stored = %v, want the mute run out", store.sanctions)
+	}
+}
+
+func TestHub_Kick(t *testing.T) {
+	hub := NewHub(nil, nil)
+	go hub.Run()
+	defer hub.Stop()
+
+	admin, bob := newFakeConn(), newFakeConn()
+	serveAdmin(hub, admin)
+	serve(hub, bob, "bob")
+	admin.expectUsers(t, "admin", "bob")
+
+	if err := hub.Moderate(Moderation{Action: ModKick, Admin: Identity{UserID: "id-admin", Name: "admin", Admin: true}, UserID: "id-bob", Duration: time.Hour}); err != nil {
+		t.Fatalf("Moderate() error = %v", err)
+	}
+	bob.expect(t, "kicked")
+	bob.expectClosed(t)
+	if bob.closeCode != websocket.ClosePolicyViolation {
+		t.Errorf("close code = %d, want %d", bob.closeCode, websocket.ClosePolicyViolation)
+	}
+	admin.expectUsers(t, "admin")
+
+	// coming back is refused until the kick runs out
+	again := newFakeConn()
+	serve(hub, again, "bob")
+	again.expect(t, "error")
+	again.expectClosed(t)
+	if got := hub.Users(); !reflect.DeepEqual(got, []string{"admin"}) {
+		t.Errorf("Users() = %v, want only the admin", got)
+	}
+}
+
+func TestHub_Ban(t *testing.T) {
+	hub := NewHub(nil, teamRooms{"id-bob": true, "id-admin": true})
+	go hub.Run()
+	defer hub.Stop()
+
+	admin, bob := newFakeConn(), newFakeConn()
+	serveAdmin(hub, admin)
+	serve(hub, bob, "bob")
+	admin.expectUsers(t, "admin", "bob")
+	room := TeamRoom("t1")
+	for _, conn := range []*fakeConn{admin, bob} {
+		conn.send(t, wsmodel.SocketPayLoad{Condition: "join", Room: room})
+		conn.expectIn(t, "history", room)
+	}
+
+	admin.send(t, wsmodel.SocketPayLoad{Condition: ModBan, To: "id-bob", Room: room})
+	admin.expect(t, "moderated")
+	ban := bob.expectIn(t, "banned", room).Sanction
+	bob.send(t, wsmodel.SocketPayLoad{Condition: "sendMessage", Room: room, Message: "still here?"})
+	if got := bob.expect(t, "error"); got.Message != ErrNotJoined.Error() {
+		t.Errorf("send error = %q, want %q", got.Message, ErrNotJoined)
+	}
+	bob.send(t, wsmodel.SocketPayLoad{Condition: "join", Room: room})
+	if got := bob.expect(t, "error"); got.Message != ErrBanned.Error() {
+		t.Errorf("join error = %q, want %q", got.Message, ErrBanned)
+	}
+
+	admin.send(t, wsmodel.SocketPayLoad{Condition: ModLift, Sanction: ban.ID})
+	admin.expect(t, "moderated")
+	bob.expect(t, "lifted")
+	bob.send(t, wsmodel.SocketPayLoad{Condition: "join", Room: room})
+	bob.expectIn(t, "history", room)
+
+	admin.send(t, wsmodel.SocketPayLoad{Condition: ModLift, Sanction: ban.ID})
+	if got := admin.expect(t, "error"); got.Message != ErrNoSanction.Error() {
+		t.Errorf("second lift error = %q, want %q", got.Message, ErrNoSanction)
+	}
+}
+
+func TestHub_DeleteMessage(t *testing.T) {
+	store := &memoryStore{stored: make(chan model.ChatMessage, 1)}
+	hub := NewHub(store, nil)
+	go hub.Run()
+
+	admin, alice := newFakeConn(), newFakeConn()
+	serveAdmin(hub, admin)
+	serve(hub, alice, "alice")
+	admin.expectUsers(t, "admin", "alice")
+
+	alice.send(t, wsmodel.SocketPayLoad{Condition: "sendMessage", Message: "oops"})
+	chat := admin.expect(t, "message").Chat
+	<-store.stored
+	admin.send(t, wsmodel.SocketPayLoad{Condition: ModDelete, MessageID: chat.ID})
+	for _, conn := range []*fakeConn{admin, alice} {
+		if got := conn.expect(t, "deleted"); got.MessageID != chat.ID || got.Room != DefaultRoom {
+			t.Errorf("deleted response = %+v, want %s of the general room", got, chat.ID)
+		}
+	}
+
+	bob := newFakeConn()
+	serve(hub, bob, "bob")
+	if history := bob.expect(t, "history").History; len(history) != 0 {
+		t.Errorf("history = %+v, want the message deleted", history)
+	}
+	hub.Stop()
+	if len(store.messages) != 0 {
+		t.Errorf("messages stored = %+v, want the message deleted", store.messages)
+	}
+	if len(store.audit) != 1 || store.audit[0].MessageID != chat.ID {
+		t.Errorf("audit = %+v, want the deletion of %s", store.audit, chat.ID)
+	}
+}
+
+func TestHub_WordFilter(t *testing.T) {
+	store := &memoryStore{words: []string{"heck"}}
+	hub := NewHub(store, nil)
+	go hub.Run()
+
+	alice := newFakeConn()
+	serve(hub, alice, "alice")
+	alice.send(t, wsmodel.SocketPayLoad{Condition: "sendMessage", Message: "what the heck"})
+	if got := alice.expect(t, "message").Chat.Message; got != "what the ****" {
+		t.Errorf("message = %q, want the stored filter applied", got)
+	}
+
+	admin := Identity{UserID: "id-admin", Name: "admin", Admin: true}
+	if err := hub.Moderate(Moderation{Action: ModFilter, Admin: admin, Words: []string{"darn"}}); err != nil {
+		t.Fatalf("Moderate() error = %v", err)
+	}
+	alice.send(t, wsmodel.SocketPayLoad{Condition: "sendMessage", Message: "heck, darn"})
+	if got := alice.expect(t, "message").Chat.Message; got != "heck, ****" {
+		t.Errorf("message = %q, want only the new words masked", got)
+	}
+	hub.Stop()
+	if !reflect.DeepEqual(store.words, []string{"darn"}) || len(store.audit) != 1 || store.audit[0].Detail != "darn" {
+		t.Errorf("words stored = %v with audit %+v, want darn", store.words, store.audit)
+	}
+}
+
+func TestHub_ModerationDropsFullAdmin(t *testing.T) {
+	// handle is called without Run, so the queue of the admin is full when the deletion is sent to the room
+	hub := NewHub(nil, nil)
+	conn := newFakeConn()
+	admin := &Client{
+		Identity: Identity{UserID: "id-admin", Name: "admin", Admin: true},
+		conn:     conn,
+		send:     make(chan wsmodel.SocketResponse, 1),
+		rooms:    make(map[string]bool),
+	}
+	hub.clients[admin] = true
+	hub.join(admin, DefaultRoom, nil, nil)
+	hub.histories[DefaultRoom].add(model.ChatMessage{ID: "m1", Room: DefaultRoom, Message: "oops"})
+
+	hub.handle(incoming{client: admin, payload: wsmodel.SocketPayLoad{Condition: ModDelete, MessageID: "m1"}})
+	if hub.clients[admin] {
+		t.Error("admin with a full queue is still a client")
+	}
+	conn.expectClosed(t)
+	// the queue kept the history and was closed once, the answer to the admin is never sent
+	if resp, ok := <-admin.send; !ok || resp.Condition != "history" {
+		t.Errorf("queued response = %+v, want the history", resp)
+	}
+	if _, ok := <-admin.send; ok {
+		t.Error("queue of the dropped admin is still open")
+	}
+	hub.drop(admin)
+}
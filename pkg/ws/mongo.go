@@ -0,0 +1,156 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// mongoTimeout : deadline of every write and lookup of the backplane
+	mongoTimeout = 10 * time.Second
+	// tailRetry : the pause before the collection is tailed again once the cursor died
+	tailRetry = time.Second
+	// namespaceExists : the code of the error creating a collection already there
+	namespaceExists = 48
+	// publishAttempts : the inserts tried by a publish while other instances take the next number
+	publishAttempts = 20
+)
+
+/*
+MongoBackplane : a backplane tailing a capped collection of the database shared by every
+instance. The capped collection keeps its documents in the order they were inserted and
+drops the oldest ones once full, so it never grows. Every event is numbered one after
+the last one inserted, so the numbers follow the order of the collection whatever the
+clocks of the instances
+*/
+type MongoBackplane struct {
+	events *mongo.Collection
+}
+
+// mongoEvent : an event as kept in the capped collection, its id is its number
+type mongoEvent struct {
+	Seq   int64 `bson:"_id"`
+	Event `bson:",inline"`
+}
+
+/*
+NewMongoBackplane : this returns a backplane on the capped collection name of db, created
+of size bytes when missing. The collection starts with an event, a tailable cursor
+on an empty collection dies at once
+*/
+func NewMongoBackplane(db *mongo.Database, name string, size int64) (*MongoBackplane, error) {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancelCtx()
+
+	err := db.CreateCollection(ctx, name, options.CreateCollection().SetCapped(true).SetSizeInBytes(size))
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && cmdErr.Code == namespaceExists {
+		return &MongoBackplane{events: db.Collection(name)}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	b := &MongoBackplane{events: db.Collection(name)}
+	if err := b.Publish(Event{Kind: eventAll}); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+/*
+Publish : this inserts an event in the capped collection with the number following the
+last event. The number is unique, when another instance took it first the event is
+numbered again after the new last one
+*/
+func (b *MongoBackplane) Publish(event Event) error {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancelCtx()
+
+	for attempt := 1; ; attempt++ {
+		last, err := b.lastSeq(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = b.events.InsertOne(ctx, mongoEvent{Seq: last + 1, Event: event})
+		if !mongo.IsDuplicateKeyError(err) || attempt == publishAttempts {
+			return err
+		}
+	}
+}
+
+// Subscribe : this tails the events inserted from now on until cancel is called, which closes the channel
+func (b *MongoBackplane) Subscribe() (<-chan Event, func(), error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	last, err := b.lastSeq(ctx)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	events := make(chan Event, eventQueue)
+	go b.tail(ctx, last, events)
+	return events, cancel, nil
+}
+
+/*
+lastSeq : this returns the number of the last event inserted, 0 when there is none or
+when it was inserted by a version of the application that did not number its events
+*/
+func (b *MongoBackplane) lastSeq(ctx context.Context) (int64, error) {
+	ctx, cancelCtx := context.WithTimeout(ctx, mongoTimeout)
+	defer cancelCtx()
+
+	opt := options.FindOne().SetSort(bson.D{{Key: "$natural", Value: -1}}).SetProjection(bson.D{{Key: "_id", Value: 1}})
+	raw, err := b.events.FindOne(ctx, bson.D{}, opt).DecodeBytes()
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	seq, _ := raw.Lookup("_id").AsInt64OK()
+	return seq, nil
+}
+
+/*
+tail : this sends the events numbered after last to events until ctx is done. The
+cursor waits for new events on the server; once it dies, from a network error or the
+collection wrapping around, the events after the number of the last one read are
+tailed again
+*/
+func (b *MongoBackplane) tail(ctx context.Context, last int64, events chan<- Event) {
+	defer close(events)
+	opts := options.Find().SetCursorType(options.TailableAwait).SetMaxAwaitTime(tailRetry)
+	for ctx.Err() == nil {
+		filter := bson.D{{Key: "_id", Value: bson.D{{Key: "$gt", Value: last}}}}
+		cursor, err := b.events.Find(ctx, filter, opts)
+		if err == nil {
+			for cursor.Next(ctx) {
+				var event mongoEvent
+				if err := cursor.Decode(&event); err != nil {
+					log.Printf("cannot read a chat backplane event : %v", err)
+					continue
+				}
+				last = event.Seq
+				select {
+				case events <- event.Event:
+				case <-ctx.Done():
+				}
+			}
+			err = cursor.Err()
+			_ = cursor.Close(context.Background())
+		}
+		if err != nil && ctx.Err() == nil {
+			log.Printf("chat backplane cursor died, tailing again : %v", err)
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(tailRetry):
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package ws
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ParseOrigins : this reads a comma separated list of origins such as "https://trackspace.app, http://localhost:8080"
+func ParseOrigins(raw string) []string {
+	var origins []string
+	for _, origin := range strings.Split(raw, ",") {
+		origin = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(origin)), "/")
+		if origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+/*
+CheckOrigin : this returns the origin check of the websocket upgrades. Browsers are let
+in from the allowed origins, or from the host of the request when none is allowed.
+Requests without an Origin header do not come from a browser page and are let in,
+the session token still has to match. Rejected origins are logged
+*/
+func CheckOrigin(allowed []string) func(rq *http.Request) bool {
+	return func(rq *http.Request) bool {
+		origin := rq.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		if allowedOrigin(origin, rq.Host, allowed) {
+			return true
+		}
+		log.Printf("chat websocket rejected from origin %q for %s", origin, rq.RemoteAddr)
+		return false
+	}
+}
+
+func allowedOrigin(origin, host string, allowed []string) bool {
+	origin = strings.TrimSuffix(strings.ToLower(origin), "/")
+	if len(allowed) == 0 {
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, host)
+	}
+	for _, a := range allowed {
+		if origin == a {
+			return true
+		}
+	}
+	return false
+}
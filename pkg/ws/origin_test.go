@@ -0,0 +1,51 @@
+package ws
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseOrigins(t *testing.T) {
+	got := ParseOrigins(" https://TrackSpace.app/ ,, http://localhost:8080 ")
+	want := []string{"https://trackspace.app", "http://localhost:8080"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseOrigins() = %v, want %v", got, want)
+	}
+	if got := ParseOrigins(""); got != nil {
+		t.Errorf("ParseOrigins(\"\") = %v, want nil", got)
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	allowed := []string{"https://trackspace.app", "http://localhost:8080"}
+	tests := []struct {
+		name    string
+		allowed []string
+		host    string
+		origin  string
+		want    bool
+	}{
+		{"allowed", allowed, "api.trackspace.app", "https://trackspace.app", true},
+		{"allowed case", allowed, "api.trackspace.app", "HTTPS://TrackSpace.app", true},
+		{"other port", allowed, "localhost:8080", "http://localhost:9090", false},
+		{"other scheme", allowed, "trackspace.app", "http://trackspace.app", false},
+		{"other site", allowed, "trackspace.app", "https://evil.example", false},
+		{"no origin", allowed, "trackspace.app", "", true},
+		{"same host", nil, "localhost:8080", "http://localhost:8080", true},
+		{"other host", nil, "localhost:8080", "http://evil.example", false},
+		{"invalid origin", nil, "localhost:8080", "://localhost:8080", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rq := httptest.NewRequest("GET", "/auth/ts", nil)
+			rq.Host = tt.host
+			if tt.origin != "" {
+				rq.Header.Set("Origin", tt.origin)
+			}
+			if got := CheckOrigin(tt.allowed)(rq); got != tt.want {
+				t.Errorf("CheckOrigin(%v) of %q on %q = %v, want %v", tt.allowed, tt.origin, tt.host, got, tt.want)
+			}
+		})
+	}
+}
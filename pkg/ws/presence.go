@@ -0,0 +1,68 @@
+package ws
+
+import (
+	"time"
+
+	"github.com/yusuf/track-space/pkg/wsmodel"
+)
+
+// presence of a chat user
+const (
+	StatusOnline  = "online"
+	StatusAway    = "away"
+	StatusOffline = "offline"
+)
+
+const (
+	// HeartbeatInterval : how often the browser reports its status with a "heartbeat"
+	HeartbeatInterval = 20 * time.Second
+	// AwayAfter : the silence after which a connection is away, a few heartbeats missed
+	AwayAfter = 3 * HeartbeatInterval
+	// presenceTick : how often the hub looks for connections gone silent
+	presenceTick = 5 * time.Second
+)
+
+// status : this returns whether a connection is online or away at now
+func (c *Client) status(now time.Time, awayAfter time.Duration) string {
+	if c.away || now.Sub(c.lastSeen) >= awayAfter {
+		return StatusAway
+	}
+	return StatusOnline
+}
+
+/*
+presence : this returns the status of every user connected. A user is online when one
+of their connections is, away when all of them are idle
+*/
+func presence(clients map[*Client]bool, now time.Time, awayAfter time.Duration) map[string]string {
+	statuses := make(map[string]string)
+	for c := range clients {
+		if c.Name == "" || statuses[c.UserID] == StatusOnline {
+			continue
+		}
+		statuses[c.UserID] = c.status(now, awayAfter)
+	}
+	return statuses
+}
+
+// samePresence : this reports whether two presences hold the same users with the same status
+func samePresence(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for user, status := range a {
+		if b[user] != status {
+			return false
+		}
+	}
+	return true
+}
+
+// statusesOf : this returns the status of every user of a user list
+func statusesOf(users []wsmodel.ChatUser) map[string]string {
+	statuses := make(map[string]string, len(users))
+	for _, user := range users {
+		statuses[user.ID] = user.Status
+	}
+	return statuses
+}
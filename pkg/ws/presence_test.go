@@ -0,0 +1,200 @@
+package ws
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yusuf/track-space/pkg/model"
+	"github.com/yusuf/track-space/pkg/wsmodel"
+)
+
+// clock : a time moved forward by the tests
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Add(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+func TestPresence(t *testing.T) {
+	now := time.Date(2022, 9, 1, 10, 0, 0, 0, time.UTC)
+	client := func(id string, silent time.Duration, away bool) *Client {
+		return &Client{Identity: Identity{UserID: id, Name: id}, lastSeen: now.Add(-silent), away: away}
+	}
+	clients := map[*Client]bool{
+		client("alice", 0, false):            true,
+		client("bob", AwayAfter, false):      true,
+		client("carol", 0, true):             true,
+		client("dave", 0, true):              true,
+		client("dave", time.Second, false):   true,
+		client("erin", 2*AwayAfter, false):   true,
+		client("erin", AwayAfter+1, false):   true,
+		{Identity: Identity{UserID: "anon"}}: true,
+	}
+	want := map[string]string{
+		"alice": StatusOnline,
+		"bob":   StatusAway,
+		"carol": StatusAway,
+		"dave":  StatusOnline,
+		"erin":  StatusAway,
+	}
+	if got := presence(clients, now, AwayAfter); !reflect.DeepEqual(got, want) {
+		t.Errorf("presence() = %v, want %v", got, want)
+	}
+}
+
+// expectStatus : this waits for the user list to give name the status
+func (f *fakeConn) expectStatus(t *testing.T, name, status string) {
+	t.Helper()
+	for {
+		for _, user := range f.expect(t, "username").Users {
+			if user.Name == name && user.Status == status {
+				return
+			}
+		}
+	}
+}
+
+func TestHub_Presence(t *testing.T) {
+	clk := &clock{now: time.Date(2022, 9, 1, 10, 0, 0, 0, time.UTC)}
+	hub := NewHub(nil, nil)
+	hub.now, hub.tick = clk.Now, 10*time.Millisecond
+	go hub.Run()
+	defer hub.Stop()
+
+	alice, bob := newFakeConn(), newFakeConn()
+	serve(hub, alice, "alice")
+	serve(hub, bob, "bob")
+	bob.expectStatus(t, "alice", StatusOnline)
+
+	alice.send(t, wsmodel.SocketPayLoad{Condition: "heartbeat", Status: StatusAway})
+	bob.expectStatus(t, "alice", StatusAway)
+	alice.send(t, wsmodel.SocketPayLoad{Condition: "heartbeat", Status: StatusOnline})
+	bob.expectStatus(t, "alice", StatusOnline)
+
+	// bob keeps sending heartbeats, alice goes silent
+	clk.Add(AwayAfter / 2)
+	bob.send(t, wsmodel.SocketPayLoad{Condition: "heartbeat", Status: StatusOnline})
+	clk.Add(AwayAfter / 2)
+	bob.expectStatus(t, "alice", StatusAway)
+	alice.send(t, wsmodel.SocketPayLoad{Condition: "heartbeat"})
+	bob.expectStatus(t, "alice", StatusOnline)
+
+	_ = alice.Close()
+	bob.expectUsers(t, "bob")
+}
+
+func TestHub_Typing(t *testing.T) {
+	hub := NewHub(nil, nil)
+	go hub.Run()
+	defer hub.Stop()
+
+	alice, bob := newFakeConn(), newFakeConn()
+	serve(hub, alice, "alice")
+	serve(hub, bob, "bob")
+	bob.expectUsers(t, "alice", "bob")
+
+	// typing in a room not joined is ignored
+	alice.send(t, wsmodel.SocketPayLoad{Condition: "typingStarted", Room: TeamRoom("t1")})
+	alice.send(t, wsmodel.SocketPayLoad{Condition: "typingStarted"})
+	got := bob.expect(t, "typingStarted")
+	if got.Room != DefaultRoom || got.User == nil || got.User.ID != "id-alice" {
+		t.Errorf("typingStarted = %+v, want alice in the general room", got)
+	}
+	alice.send(t, wsmodel.SocketPayLoad{Condition: "typingStopped"})
+	bob.expect(t, "typingStopped")
+
+	// alice is not told about her own typing
+	alice.send(t, wsmodel.SocketPayLoad{Condition: "sendMessage", Message: "done"})
+	for done := false; !done; {
+		select {
+		case resp := <-alice.out:
+			if resp.Condition == "typingStarted" || resp.Condition == "typingStopped" {
+				t.Fatalf("alice got %+v", resp)
+			}
+			done = resp.Chat != nil && resp.Chat.Message == "done"
+		case <-time.After(2 * time.Second):
+			t.Fatal("no message received")
+		}
+	}
+}
+
+func TestHub_ReadReceipts(t *testing.T) {
+	store := &memoryStore{}
+	hub := NewHub(store, nil)
+	go hub.Run()
+
+	alice, bob := newFakeConn(), newFakeConn()
+	serve(hub, alice, "alice")
+	serve(hub, bob, "bob")
+	bob.expectUsers(t, "alice", "bob")
+
+	alice.send(t, wsmodel.SocketPayLoad{Condition: "sendMessage", Message: "first"})
+	first := *bob.expect(t, "message").Chat
+	alice.send(t, wsmodel.SocketPayLoad{Condition: "sendMessage", Message: "second"})
+	second := *bob.expect(t, "message").Chat
+
+	bob.send(t, wsmodel.SocketPayLoad{Condition: "read", MessageID: second.ID})
+	got := alice.expect(t, "read").Receipt
+	if got == nil || got.UserID != "id-bob" || got.MessageID != second.ID || !got.SentAt.Equal(second.SentAt) {
+		t.Fatalf("receipt = %+v, want bob read %s", got, second.ID)
+	}
+	bob.expect(t, "read")
+
+	// going back or to an unknown message does not move the receipt
+	bob.send(t, wsmodel.SocketPayLoad{Condition: "read", MessageID: first.ID})
+	bob.send(t, wsmodel.SocketPayLoad{Condition: "read", MessageID: "unknown"})
+	alice.send(t, wsmodel.SocketPayLoad{Condition: "read", MessageID: first.ID})
+	if got := bob.expect(t, "read").Receipt; got.UserID != "id-alice" {
+		t.Errorf("receipt = %+v, want the one of alice", got)
+	}
+
+	carol := newFakeConn()
+	serve(hub, carol, "carol")
+	var readers []string
+	for _, receipt := range carol.expect(t, "history").Receipts {
+		readers = append(readers, receipt.UserID+"="+receipt.MessageID)
+	}
+	if want := []string{"id-alice=" + first.ID, "id-bob=" + second.ID}; !reflect.DeepEqual(readers, want) {
+		t.Errorf("receipts = %v, want %v", readers, want)
+	}
+
+	hub.Stop()
+	stored, _ := store.GetReadReceipts(DefaultRoom)
+	if len(stored) != 2 {
+		t.Errorf("%d receipts stored, want 2", len(stored))
+	}
+}
+
+func TestHistory_Read(t *testing.T) {
+	h := newHistory([]model.ChatMessage{chatMessage(0), chatMessage(1)}, nil)
+	receipt := model.ReadReceipt{UserID: "alice", MessageID: chatMessage(1).ID}
+	if _, ok := h.read(receipt); !ok {
+		t.Fatal("read() of the last message not ok")
+	}
+	receipt.MessageID = chatMessage(0).ID
+	if _, ok := h.read(receipt); ok {
+		t.Error("read() of an older message ok")
+	}
+	receipt.MessageID = chatMessage(1).ID
+	if _, ok := h.read(receipt); ok {
+		t.Error("read() of the same message ok")
+	}
+	h.add(chatMessage(2))
+	receipt.MessageID = chatMessage(2).ID
+	if got, ok := h.read(receipt); !ok || !got.SentAt.Equal(chatMessage(2).SentAt) {
+		t.Errorf("read() of a newer message = %+v, %v", got, ok)
+	}
+}
@@ -0,0 +1,127 @@
+package ws
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yusuf/track-space/pkg/model"
+)
+
+// maxRoomName : the longest name of a created room, in characters
+const maxRoomName = 60
+
+// kinds of chat rooms, the prefix of the room ids
+const (
+	// RoomGeneral : the room every client is in
+	RoomGeneral = DefaultRoom
+	// RoomCreated : the rooms created by users, open to everyone or invite-only
+	RoomCreated = "room"
+	RoomTeam    = "team"
+	RoomProject = "project"
+	// RoomDirect : the direct messages between two users
+	RoomDirect = "dm"
+)
+
+var (
+	ErrUnknownRoom  = errors.New("chat room not found")
+	ErrNoRoomAccess = errors.New("you are not a member of this chat room")
+	ErrNotJoined    = errors.New("join the chat room before sending to it")
+	ErrSelfMessage  = errors.New("direct messages are sent to another user")
+	ErrRoomName     = errors.New("a chat room name has 1 to 60 characters")
+)
+
+/*
+Rooms : decides who may join the rooms of the chat. The hub asks it from the goroutine
+of the connection, so it may look the room up in the database
+*/
+type Rooms interface {
+	// CanJoinRoom : nil when the user may read and send messages in room
+	CanJoinRoom(userID, room string) error
+}
+
+// ChatRoom : this returns the id of a room created by a user, public or private
+func ChatRoom(roomID string) string {
+	return RoomCreated + ":" + roomID
+}
+
+// TeamRoom : this returns the id of the room of the members of a team
+func TeamRoom(teamID string) string {
+	return RoomTeam + ":" + teamID
+}
+
+// ProjectRoom : this returns the id of the room of the owner and collaborators of a project
+func ProjectRoom(projectID string) string {
+	return RoomProject + ":" + projectID
+}
+
+// DirectRoom : this returns the id of the direct messages between two users, the same both ways
+func DirectRoom(userID, otherID string) string {
+	if otherID < userID {
+		userID, otherID = otherID, userID
+	}
+	return RoomDirect + ":" + userID + ":" + otherID
+}
+
+/*
+ParseRoom : this splits a room id into its kind and key: the id of the created room,
+team or project, or both user ids of direct messages. The general room has no key
+*/
+func ParseRoom(room string) (kind, key string, err error) {
+	if room == DefaultRoom {
+		return RoomGeneral, "", nil
+	}
+	prefix, key, ok := strings.Cut(room, ":")
+	if !ok || key == "" {
+		return "", "", ErrUnknownRoom
+	}
+	switch prefix {
+	case RoomCreated, RoomTeam, RoomProject:
+		return prefix, key, nil
+	case RoomDirect:
+		a, b, ok := strings.Cut(key, ":")
+		if !ok || a == "" || b == "" || a == b || DirectRoom(a, b) != room {
+			return "", "", ErrUnknownRoom
+		}
+		return RoomDirect, key, nil
+	}
+	return "", "", ErrUnknownRoom
+}
+
+// Participants : this returns both users of a direct messages room
+func Participants(room string) (string, string, bool) {
+	kind, key, err := ParseRoom(room)
+	if err != nil || kind != RoomDirect {
+		return "", "", false
+	}
+	a, b, _ := strings.Cut(key, ":")
+	return a, b, true
+}
+
+// inRoom : this reports whether a user is one of the two users of a direct messages room
+func inRoom(room, userID string) bool {
+	a, b, ok := Participants(room)
+	return ok && (userID == a || userID == b)
+}
+
+// ParseRoomName : this checks the name of a room created by a user, as typed in a form
+func ParseRoomName(raw string) (string, error) {
+	name := strings.TrimSpace(raw)
+	if name == "" || utf8.RuneCountInString(name) > maxRoomName {
+		return "", ErrRoomName
+	}
+	return name, nil
+}
+
+// RoomMember : this reports whether a user may join a created room, every user may join a public one
+func RoomMember(room model.ChatRoom, userID string) bool {
+	if !room.Private || userID == room.OwnerID {
+		return true
+	}
+	for _, member := range room.Members {
+		if member == userID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,239 @@
+package ws
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yusuf/track-space/pkg/model"
+	"github.com/yusuf/track-space/pkg/wsmodel"
+)
+
+func TestParseRoom(t *testing.T) {
+	tests := []struct {
+		name     string
+		room     string
+		wantKind string
+		wantKey  string
+		wantErr  error
+	}{
+		{"general", DefaultRoom, RoomGeneral, "", nil},
+		{"created", ChatRoom("r1"), RoomCreated, "r1", nil},
+		{"team", TeamRoom("t1"), RoomTeam, "t1", nil},
+		{"project", ProjectRoom("p1"), RoomProject, "p1", nil},
+		{"direct", DirectRoom("bob", "alice"), RoomDirect, "alice:bob", nil},
+		{"direct out of order", "dm:bob:alice", "", "", ErrUnknownRoom},
+		{"direct with oneself", "dm:bob:bob", "", "", ErrUnknownRoom},
+		{"direct with nobody", "dm:bob", "", "", ErrUnknownRoom},
+		{"no key", "team:", "", "", ErrUnknownRoom},
+		{"unknown kind", "lobby:1", "", "", ErrUnknownRoom},
+		{"empty", "", "", "", ErrUnknownRoom},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind, key, err := ParseRoom(tt.room)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseRoom(%q) error = %v, want %v", tt.room, err, tt.wantErr)
+			}
+			if kind != tt.wantKind || key != tt.wantKey {
+				t.Errorf("ParseRoom(%q) = %q, %q, want %q, %q", tt.room, kind, key, tt.wantKind, tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestDirectRoom(t *testing.T) {
+	if DirectRoom("alice", "bob") != DirectRoom("bob", "alice") {
+		t.Error("DirectRoom depends on the order of the users")
+	}
+	a, b, ok := Participants(DirectRoom("bob", "alice"))
+	if !ok || a != "alice" || b != "bob" {
+		t.Errorf("Participants() = %q, %q, %v, want alice, bob, true", a, b, ok)
+	}
+	if _, _, ok := Participants(TeamRoom("t1")); ok {
+		t.Error("Participants() of a team room is ok")
+	}
+}
+
+func TestParseRoomName(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    string
+		wantErr error
+	}{
+		{"trimmed", "  go gophers ", "go gophers", nil},
+		{"empty", "   ", "", ErrRoomName},
+		{"longest", strings.Repeat("é", maxRoomName), strings.Repeat("é", maxRoomName), nil},
+		{"too long", strings.Repeat("a", maxRoomName+1), "", ErrRoomName},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRoomName(tt.raw)
+			if got != tt.want || !errors.Is(err, tt.wantErr) {
+				t.Errorf("ParseRoomName(%q) = %q, %v, want %q, %v", tt.raw, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRoomMember(t *testing.T) {
+	private := model.ChatRoom{Private: true, OwnerID: "owner", Members: []string{"owner", "alice"}}
+	tests := []struct {
+		name   string
+		room   model.ChatRoom
+		userID string
+		want   bool
+	}{
+		{"public", model.ChatRoom{OwnerID: "owner"}, "bob", true},
+		{"private owner", private, "owner", true},
+		{"private member", private, "alice", true},
+		{"private stranger", private, "bob", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RoomMember(tt.room, tt.userID); got != tt.want {
+				t.Errorf("RoomMember(%q) = %v, want %v", tt.userID, got, tt.want)
+			}
+		})
+	}
+}
+
+// expectIn : this waits for a response of the condition about room
+func (f *fakeConn) expectIn(t *testing.T, condition, room string) wsmodel.SocketResponse {
+	t.Helper()
+	for {
+		if resp := f.expect(t, condition); resp.Room == room {
+			return resp
+		}
+	}
+}
+
+// teamRooms : rooms letting in the members of team t1 only
+type teamRooms map[string]bool
+
+func (r teamRooms) CanJoinRoom(userID, room string) error {
+	kind, _, err := ParseRoom(room)
+	if err != nil {
+		return err
+	}
+	if kind == RoomTeam && !r[userID] {
+		return ErrNoRoomAccess
+	}
+	return nil
+}
+
+func TestHub_Rooms(t *testing.T) {
+	hub := NewHub(nil, teamRooms{"id-alice": true, "id-bob": true})
+	go hub.Run()
+	defer hub.Stop()
+
+	alice, bob, carol := newFakeConn(), newFakeConn(), newFakeConn()
+	serve(hub, alice, "alice")
+	serve(hub, bob, "bob")
+	serve(hub, carol, "carol")
+	carol.expectUsers(t, "alice", "bob", "carol")
+
+	room := TeamRoom("t1")
+	carol.send(t, wsmodel.SocketPayLoad{Condition: "join", Room: room})
+	if got := carol.expect(t, "error"); got.Room != room || got.Message != ErrNoRoomAccess.Error() {
+		t.Errorf("join error = %+v, want %v", got, ErrNoRoomAccess)
+	}
+	carol.send(t, wsmodel.SocketPayLoad{Condition: "sendMessage", Room: room, Message: "let me in"})
+	if got := carol.expect(t, "error"); got.Message != ErrNotJoined.Error() {
+		t.Errorf("send error = %q, want %q", got.Message, ErrNotJoined)
+	}
+
+	for _, conn := range []*fakeConn{alice, bob} {
+		conn.send(t, wsmodel.SocketPayLoad{Condition: "join", Room: room})
+		conn.expectIn(t, "history", room)
+	}
+	alice.send(t, wsmodel.SocketPayLoad{Condition: "sendMessage", Room: room, Message: "team only"})
+	for _, conn := range []*fakeConn{alice, bob} {
+		if got := conn.expect(t, "message"); got.Room != room || got.Chat.Message != "team only" {
+			t.Errorf("message = %+v, want team only in %s", got, room)
+		}
+	}
+
+	// the general room is still shared by everyone, carol only ever sees its messages
+	bob.send(t, wsmodel.SocketPayLoad{Condition: "sendMessage", Message: "hello all"})
+	if got := carol.expect(t, "message"); got.Room != DefaultRoom || got.Chat.Message != "hello all" {
+		t.Errorf("carol got %+v, want hello all in the general room", got)
+	}
+
+	bob.send(t, wsmodel.SocketPayLoad{Condition: "leave", Room: room})
+	bob.expect(t, "left")
+	alice.send(t, wsmodel.SocketPayLoad{Condition: "sendMessage", Room: room, Message: "bob left"})
+	alice.expect(t, "message")
+	bob.send(t, wsmodel.SocketPayLoad{Condition: "sendMessage", Message: "still here"})
+	if got := bob.expect(t, "message"); got.Chat.Message != "still here" {
+		t.Errorf("bob got %q after leaving, want still here", got.Chat.Message)
+	}
+}
+
+func TestHub_DirectMessages(t *testing.T) {
+	hub := NewHub(nil, nil)
+	go hub.Run()
+	defer hub.Stop()
+
+	alice, bob, carol := newFakeConn(), newFakeConn(), newFakeConn()
+	serve(hub, alice, "alice")
+	serve(hub, bob, "bob")
+	serve(hub, carol, "carol")
+	carol.expectUsers(t, "alice", "bob", "carol")
+
+	alice.send(t, wsmodel.SocketPayLoad{Condition: "join", To: "id-alice"})
+	if got := alice.expect(t, "error"); got.Message != ErrSelfMessage.Error() {
+		t.Errorf("join error = %q, want %q", got.Message, ErrSelfMessage)
+	}
+	// carol is not one of the users of the room
+	room := DirectRoom("id-alice", "id-bob")
+	carol.send(t, wsmodel.SocketPayLoad{Condition: "join", Room: room})
+	carol.expect(t, "error")
+	// without Rooms, only the general room and direct messages are open
+	carol.send(t, wsmodel.SocketPayLoad{Condition: "join", Room: TeamRoom("t1")})
+	carol.expect(t, "error")
+
+	alice.send(t, wsmodel.SocketPayLoad{Condition: "join", To: "id-bob"})
+	alice.expectIn(t, "history", room)
+	alice.send(t, wsmodel.SocketPayLoad{Condition: "sendMessage", Room: room, Message: "psst"})
+	alice.expect(t, "message")
+	// bob never joined, the direct message reaches him anyway
+	if got := bob.expect(t, "message"); got.Room != room || got.Chat.SenderID != "id-alice" {
+		t.Errorf("bob got %+v, want the direct message of alice", got)
+	}
+
+	alice.send(t, wsmodel.SocketPayLoad{Condition: "sendMessage", Message: "public"})
+	if got := carol.expect(t, "message"); got.Chat.Message != "public" {
+		t.Errorf("carol got %q, want only the public message", got.Chat.Message)
+	}
+}
+
+func TestHub_RoomHistory(t *testing.T) {
+	store := &memoryStore{}
+	room := DirectRoom("id-alice", "id-bob")
+	for i := 0; i < 3; i++ {
+		msg := chatMessage(i)
+		msg.Room = room
+		store.messages = append(store.messages, msg)
+	}
+	hub := NewHub(store, nil)
+	go hub.Run()
+	defer hub.Stop()
+
+	alice := newFakeConn()
+	serve(hub, alice, "alice")
+	if got := alice.expect(t, "history"); got.Room != DefaultRoom || len(got.History) != 0 {
+		t.Errorf("general history = %+v, want no message", got)
+	}
+	alice.send(t, wsmodel.SocketPayLoad{Condition: "join", To: "id-bob"})
+	got := alice.expectIn(t, "history", room)
+	var ids []string
+	for _, msg := range got.History {
+		ids = append(ids, msg.ID)
+	}
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("history of %s = %v, want %v", room, ids, want)
+	}
+}
@@ -2,101 +2,804 @@ package ws
 
 import (
 	"fmt"
+	"html"
 	"log"
-	"net/http"
 	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/yusuf/track-space/pkg/model"
 	"github.com/yusuf/track-space/pkg/wsconfig"
 	"github.com/yusuf/track-space/pkg/wsmodel"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-// initialize the websocket connection and channel
-var webSkChan = make(chan wsmodel.SocketPayLoad)
-var Client = make(map[wsconfig.SocketConnection]string)
+// sendQueue : responses waiting for a slow client before it is dropped
+const sendQueue = 256
 
-// UpgradeSocketConn : variable to upgrade ChatRoom controller with a web socket connection
+/*
+UpgradeSocketConn : variable to upgrade ChatRoom controller with a web socket connection,
+only pages of the same host are let in until CheckOrigin is set with the allowed origins
+*/
 var UpgradeSocketConn = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin: func(rq *http.Request) bool {
-		return true
-	},
+	ReadBufferSize:   1024,
+	WriteBufferSize:  1024,
+	CheckOrigin:      CheckOrigin(nil),
 	HandshakeTimeout: 100 * time.Second,
 }
 
-// GetDataFromChannel - goroutine method that will get payload data via channel
-func GetDataFromChannel() {
-	var resp wsmodel.SocketResponse
-	for {
-		getdata := <-webSkChan
-		switch getdata.Condition {
-		case "username":
-			Client[getdata.SocketConn] = getdata.UserName
-			users := GetAllUsers()
-			resp.Condition = "username"
-			resp.ConnectedUser = users
-			BroadCastToAll(resp)
-		case "sendMessage":
-			resp.Message = fmt.Sprintf("<em>%v</em> : %v", getdata.UserName, getdata.Message)
-			resp.Condition = "message"
-			BroadCastToAll(resp)
+/*
+Conn : the connection of a chat client, a wsconfig.SocketConnection in the application.
+Reads fail once the client is silent for too long, which the pings prevent
+*/
+type Conn interface {
+	ReadJSON(v interface{}) error
+	WriteJSON(v interface{}) error
+	Ping() error
+	// CloseWith : this sends a close frame before closing the connection
+	CloseWith(code int, reason string) error
+	Close() error
+}
+
+/*
+Identity : the logged-in user a connection belongs to, as the application knows it.
+The names typed by the browser are never trusted
+*/
+type Identity struct {
+	UserID string
+	Name   string
+	// Admin : the user may delete messages, mute, kick and ban users
+	Admin bool
+}
+
+// Client : a connection of the chat with its user and its queue of responses to write
+type Client struct {
+	Identity
+	conn Conn
+	send chan wsmodel.SocketResponse
+	// rooms : the rooms joined, only used by Run
+	rooms map[string]bool
+	// lastSeen, away : when the client was last heard of and whether it said it was idle, only used by Run
+	lastSeen time.Time
+	away     bool
+	// closeCode, closeReason : the close frame sent once the queue is closed, set by Run
+	closeCode   int
+	closeReason string
+}
+
+/*
+incoming : a payload read from a client, handled by the hub. A join carries the room
+asked for, whether the user may join it and its last stored messages and receipts
+*/
+type incoming struct {
+	client   *Client
+	payload  wsmodel.SocketPayLoad
+	room     string
+	err      error
+	stored   []model.ChatMessage
+	receipts []model.ReadReceipt
+}
+
+// persistOp : a message, a receipt or a moderation waiting to be stored
+type persistOp struct {
+	msg      *model.ChatMessage
+	receipt  *model.ReadReceipt
+	deleted  *model.ChatMessage
+	sanction *model.ChatSanction
+	lifted   string
+	audit    *model.ChatAudit
+	words    []string
+}
+
+/*
+Hub : the clients of the chat and the rooms they joined. A single goroutine, Run, owns
+the clients; connections only talk to it through channels. Every client has
+its own buffered send queue emptied by a writer goroutine, so a slow or dead client
+is dropped once its queue is full without blocking the others. Every client is in the
+general room, the other rooms are joined once Rooms lets the user in. Messages are
+kept in the store, the last HistorySize of a room are sent to the clients joining it.
+Users are online while their browser sends heartbeats, the user list is sent again
+on every connection, disconnection and change of presence. Admins moderate the chat
+over their connection or through Moderate. Live pages, outside of the chat, get the
+changes of the projects and todos of their user. With a backplane the hub shares all of it
+with the hubs of the other instances
+*/
+type Hub struct {
+	register   chan *Client
+	unregister chan *Client
+	broadcast  chan wsmodel.SocketResponse
+	incoming   chan incoming
+	users      chan chan []string
+	moderation chan moderationRequest
+	watch      chan *Client
+	unwatch    chan *Client
+	notify     chan notification
+	stop       chan struct{}
+	done       chan struct{}
+	// clients : the connected clients, only used by Run
+	clients map[*Client]bool
+	// watchers : the live pages of every user, only used by Run
+	watchers map[string]map[*Client]bool
+	// members : the clients of every room joined, only used by Run
+	members map[string]map[*Client]bool
+	// histories : the last messages of the rooms joined, only used by Run
+	histories map[string]*history
+	// statuses : the presence of the users as last sent, only used by Run
+	statuses map[string]string
+	// sanctions, filter : the mutes, kicks and bans running and the words masked, only used by Run
+	sanctions map[string]model.ChatSanction
+	filter    *WordFilter
+	// id, backplane, outbox : this instance and the events shared with the other ones
+	id        string
+	backplane Backplane
+	outbox    chan Event
+	// remote : the users of the other instances, published : the users of this one as last published, only used by Run
+	remote      map[string]remotePresence
+	published   map[string]string
+	publishedAt time.Time
+	store       Store
+	rooms       Rooms
+	persist     chan persistOp
+	now         func() time.Time
+	awayAfter   time.Duration
+	tick        time.Duration
+	// writers : the writer goroutines of the clients, Stop waits for their close frames
+	writers    sync.WaitGroup
+	pingPeriod time.Duration
+}
 
-		case "serveroffline":
-			resp.Condition = "serveroffline"
-			delete(Client, getdata.SocketConn)
-			users := GetAllUsers()
-			resp.ConnectedUser = users
+/*
+NewHub : this returns a hub keeping its messages in store and asking rooms who may
+join them, which serves clients once Run is started. A nil store keeps no message
+beyond the history in memory, without rooms only the general room and direct
+messages are open
+*/
+func NewHub(store Store, rooms Rooms) *Hub {
+	return &Hub{
+		histories:  map[string]*history{DefaultRoom: newHistory(nil, nil)},
+		members:    make(map[string]map[*Client]bool),
+		statuses:   make(map[string]string),
+		sanctions:  make(map[string]model.ChatSanction),
+		id:         primitive.NewObjectID().Hex(),
+		outbox:     make(chan Event, eventQueue),
+		remote:     make(map[string]remotePresence),
+		store:      store,
+		rooms:      rooms,
+		persist:    make(chan persistOp, persistQueue),
+		now:        time.Now,
+		awayAfter:  AwayAfter,
+		tick:       presenceTick,
+		pingPeriod: wsconfig.PingPeriod,
+		register:   make(chan *Client),
+		unregister: make(chan *Client),
+		broadcast:  make(chan wsmodel.SocketResponse),
+		incoming:   make(chan incoming),
+		users:      make(chan chan []string),
+		moderation: make(chan moderationRequest),
+		watch:      make(chan *Client),
+		unwatch:    make(chan *Client),
+		notify:     make(chan notification),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+		clients:    make(map[*Client]bool),
+		watchers:   make(map[string]map[*Client]bool),
+	}
+}
 
+// Run : this handles the clients of the hub until Stop is called
+func (h *Hub) Run() {
+	defer close(h.done)
+	h.loadHistory()
+	h.loadModeration()
+	stored := make(chan struct{})
+	go h.storeMessages(stored)
+	published := make(chan struct{})
+	go h.publishEvents(published)
+	defer func() {
+		// the messages still queued are stored and the events published before the hub is done
+		close(h.persist)
+		<-stored
+		close(h.outbox)
+		<-published
+	}()
+	events, stopEvents := h.subscribe()
+	defer stopEvents()
+	// the other instances learn this one started and publish their users
+	h.publishPresence()
+	ticker := time.NewTicker(h.tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case c := <-h.register:
+			if s, ok := h.sanctionOf(ModKick, c.UserID, ""); ok {
+				// the queue of the client is still empty, it is never registered
+				c.send <- wsmodel.SocketResponse{Condition: "error", Message: sanctionError(ErrKicked, s)}
+				c.closeCode, c.closeReason = websocket.ClosePolicyViolation, ErrKicked.Error()
+				close(c.send)
+				continue
+			}
+			h.clients[c] = true
+			c.lastSeen = h.now()
+			h.join(c, DefaultRoom, nil, nil)
+			h.broadcastUsers()
+		case c := <-h.unregister:
+			if _, ok := h.clients[c]; ok {
+				h.drop(c)
+				h.broadcastUsers()
+			}
+		case resp := <-h.broadcast:
+			h.broadcastAll(resp)
+			h.publish(Event{Kind: eventAll, Response: &resp})
+		case event, ok := <-events:
+			if !ok {
+				log.Printf("chat backplane closed, the chat only reaches the clients of this instance")
+				events = nil
+				continue
+			}
+			h.receive(event)
+		case in := <-h.incoming:
+			h.handle(in)
+		case reply := <-h.users:
+			reply <- h.userNames()
+		case req := <-h.moderation:
+			req.reply <- h.moderate(req.moderation)
+		case c := <-h.watch:
+			if h.watchers[c.UserID] == nil {
+				h.watchers[c.UserID] = make(map[*Client]bool)
+			}
+			h.watchers[c.UserID][c] = true
+		case c := <-h.unwatch:
+			h.dropWatcher(c)
+		case n := <-h.notify:
+			h.notifyWatchers(n.userID, n.resp)
+			h.publish(Event{Kind: eventUser, UserID: n.userID, Response: &n.resp})
+		case <-ticker.C:
+			// connections gone silent turn away without sending anything, instances gone silent leave
+			h.expireRemotes()
+			h.refreshPresence()
+		case <-h.stop:
+			for c := range h.clients {
+				c.closeCode, c.closeReason = websocket.CloseGoingAway, "server shutting down"
+				h.drop(c)
+			}
+			h.stopWatchers()
+			// the other instances forget the users of this one at once
+			h.publish(Event{Kind: eventStopped})
+			return
 		}
 	}
 }
 
-// GetAllUsers To get the list of user connected to the web socket
-func GetAllUsers() []string {
-	var userSlices []string
-	for _, user := range Client {
-		if user != "" {
-			userSlices = append(userSlices, user)
+/*
+Stop : this stops the hub and disconnects every client, it returns once their queued
+responses and a "going away" close frame are written or have timed out
+*/
+func (h *Hub) Stop() {
+	select {
+	case <-h.stop:
+	default:
+		close(h.stop)
+	}
+	<-h.done
+	h.writers.Wait()
+}
+
+/*
+Serve : this makes conn a client of the chat room for the user of id until the
+connection ends or the hub stops
+*/
+func (h *Hub) Serve(conn Conn, id Identity) {
+	c := &Client{Identity: id, conn: conn, send: make(chan wsmodel.SocketResponse, sendQueue), rooms: make(map[string]bool)}
+	select {
+	case h.register <- c:
+	case <-h.done:
+		_ = conn.Close()
+		return
+	}
+	// the writer is counted before the hub can stop, so Stop always waits for it
+	h.writers.Add(1)
+	go func() {
+		defer h.writers.Done()
+		c.write(h.pingPeriod)
+	}()
+	defer func() {
+		select {
+		case h.unregister <- c:
+		case <-h.done:
+		}
+	}()
+
+	for {
+		var payload wsmodel.SocketPayLoad
+		if err := conn.ReadJSON(&payload); err != nil {
+			return
 		}
+		in := incoming{client: c, payload: payload}
+		if payload.Condition == "join" {
+			// the database is only read here, the hub never waits for it
+			in = h.joining(c, payload)
+		}
+		select {
+		case h.incoming <- in:
+		case <-h.done:
+			return
+		}
+	}
+}
+
+// Broadcast : this sends a response to every client of the chat room
+func (h *Hub) Broadcast(resp wsmodel.SocketResponse) {
+	select {
+	case h.broadcast <- resp:
+	case <-h.done:
 	}
-	sort.Strings(userSlices)
-	return userSlices
 }
 
-// BroadCastToAll - this  functions write a responses to all connected users
-func BroadCastToAll(resp wsmodel.SocketResponse) {
-	for x := range Client {
-		err := x.WriteJSON(resp)
+// Users : this returns the sorted names of the users in the chat room
+func (h *Hub) Users() []string {
+	reply := make(chan []string, 1)
+	select {
+	case h.users <- reply:
+		return <-reply
+	case <-h.done:
+		return nil
+	}
+}
+
+/*
+handle : this answers a payload sent by a client. Messages are signed with the name
+of the user of the connection, whatever user name the payload carries, and only sent
+to rooms the client joined
+*/
+func (h *Hub) handle(in incoming) {
+	if !h.clients[in.client] {
+		return
+	}
+	in.client.lastSeen = h.now()
+	switch in.payload.Condition {
+	case "heartbeat":
+		in.client.away = in.payload.Status == StatusAway
+		h.refreshPresence()
+	case "username":
+		// the name comes from the profile, the client only asks for the user list
+		h.deliver(in.client, h.usersResponse())
+	case "join":
+		if in.err != nil {
+			h.deliver(in.client, wsmodel.SocketResponse{Condition: "error", Room: in.payload.Room, Message: in.err.Error()})
+			return
+		}
+		if s, ok := h.sanctionOf(ModBan, in.client.UserID, in.room); ok {
+			h.deliver(in.client, wsmodel.SocketResponse{Condition: "error", Room: in.room, Message: sanctionError(ErrBanned, s)})
+			return
+		}
+		h.join(in.client, in.room, in.stored, in.receipts)
+	case "leave":
+		if in.client.rooms[in.payload.Room] {
+			h.leave(in.client, in.payload.Room)
+			h.deliver(in.client, wsmodel.SocketResponse{Condition: "left", Room: in.payload.Room})
+		}
+	case "sendMessage":
+		room := in.payload.Room
+		if room == "" {
+			room = DefaultRoom
+		}
+		if !in.client.rooms[room] {
+			h.deliver(in.client, wsmodel.SocketResponse{Condition: "error", Room: room, Message: ErrNotJoined.Error()})
+			return
+		}
+		if s, ok := h.sanctionOf(ModMute, in.client.UserID, ""); ok {
+			h.deliver(in.client, wsmodel.SocketResponse{Condition: "error", Room: room, Message: sanctionError(ErrMuted, s)})
+			return
+		}
+		text := h.filter.Clean(strings.TrimSpace(in.payload.Message))
+		if text == "" {
+			return
+		}
+		msg := model.ChatMessage{
+			ID:         primitive.NewObjectID().Hex(),
+			Room:       room,
+			SenderID:   in.client.UserID,
+			SenderName: in.client.Name,
+			Message:    text,
+			// the store keeps milliseconds, the history in memory does the same
+			SentAt: time.Now().UTC().Truncate(time.Millisecond),
+		}
+		h.histories[room].add(msg)
+		h.save(persistOp{msg: &msg})
+		h.publishRoom(room, "", wsmodel.SocketResponse{
+			Condition: "message",
+			Message:   fmt.Sprintf("<em>%v</em> : %v", html.EscapeString(msg.SenderName), html.EscapeString(msg.Message)),
+			Room:      room,
+			Chat:      &msg,
+		})
+	case "typingStarted", "typingStopped":
+		room := in.payload.Room
+		if room == "" {
+			room = DefaultRoom
+		}
+		if _, muted := h.sanctionOf(ModMute, in.client.UserID, ""); muted || !in.client.rooms[room] {
+			return
+		}
+		user := wsmodel.ChatUser{ID: in.client.UserID, Name: in.client.Name}
+		h.publishRoom(room, in.client.UserID, wsmodel.SocketResponse{Condition: in.payload.Condition, Room: room, User: &user})
+	case "read":
+		room := in.payload.Room
+		if room == "" {
+			room = DefaultRoom
+		}
+		if !in.client.rooms[room] {
+			return
+		}
+		receipt, ok := h.histories[room].read(model.ReadReceipt{
+			ID:        room + "/" + in.client.UserID,
+			Room:      room,
+			UserID:    in.client.UserID,
+			UserName:  in.client.Name,
+			MessageID: in.payload.MessageID,
+			ReadAt:    h.now().UTC().Truncate(time.Millisecond),
+		})
+		if !ok {
+			return
+		}
+		h.save(persistOp{receipt: &receipt})
+		h.publishRoom(room, "", wsmodel.SocketResponse{Condition: "read", Room: room, Receipt: &receipt})
+	case ModDelete, ModMute, ModKick, ModBan, ModLift, ModFilter:
+		m, err := moderationOf(in.client.Identity, in.payload)
+		if err == nil {
+			err = h.moderate(m)
+		}
 		if err != nil {
-			log.Println("error write a json response")
-			_ = x.Close()
-			delete(Client, x)
+			h.deliver(in.client, wsmodel.SocketResponse{Condition: "error", Room: in.payload.Room, Message: err.Error()})
 			return
 		}
+		h.deliver(in.client, wsmodel.SocketResponse{Condition: "moderated", Room: in.payload.Room, Message: in.payload.Condition})
+	case "serveroffline":
+		h.drop(in.client)
+		h.broadcastUsers()
 	}
 }
 
-// SendDataToChannel - goroutine method that will send back response data
-// back to the channel
-func SendDataToChannel(socketConn *wsconfig.SocketConnection) {
-	defer func() {
-		if r := recover(); r != nil {
-			log.Println("Recovery from a failed program")
-			return
+/*
+joining : this reads the room of a join payload, checks the user may join it and loads
+its last stored messages. It runs on the goroutine of the connection
+*/
+func (h *Hub) joining(c *Client, payload wsmodel.SocketPayLoad) incoming {
+	in := incoming{client: c, payload: payload}
+	in.room, in.err = h.roomOf(c, payload)
+	if in.err != nil || h.store == nil || in.room == DefaultRoom {
+		return in
+	}
+	stored, err := h.store.GetChatMessages(in.room, time.Time{}, "", HistorySize)
+	if err != nil {
+		log.Printf("cannot load the history of chat room %s : %v", in.room, err)
+		return in
+	}
+	receipts, err := h.store.GetReadReceipts(in.room)
+	if err != nil {
+		log.Printf("cannot load the read receipts of chat room %s : %v", in.room, err)
+	}
+	in.stored, in.receipts = stored, receipts
+	return in
+}
+
+// roomOf : this returns the room a join payload asks for when the user may join it
+func (h *Hub) roomOf(c *Client, payload wsmodel.SocketPayLoad) (string, error) {
+	room := payload.Room
+	if payload.To != "" {
+		if payload.To == c.UserID {
+			return "", ErrSelfMessage
 		}
-	}()
+		room = DirectRoom(c.UserID, payload.To)
+	}
+	kind, _, err := ParseRoom(room)
+	switch {
+	case err != nil:
+		return "", err
+	case kind == RoomGeneral:
+		return room, nil
+	case kind == RoomDirect && !inRoom(room, c.UserID):
+		return "", ErrNoRoomAccess
+	case h.rooms != nil:
+		if err := h.rooms.CanJoinRoom(c.UserID, room); err != nil {
+			return "", err
+		}
+		return room, nil
+	case kind == RoomDirect:
+		return room, nil
+	}
+	return "", ErrNoRoomAccess
+}
+
+/*
+join : this adds a client to a room and sends it the last messages of the room with
+their receipts. The history in memory is started from the stored messages and receipts
+when nobody is in the room
+*/
+func (h *Hub) join(c *Client, room string, stored []model.ChatMessage, receipts []model.ReadReceipt) {
+	hist, ok := h.histories[room]
+	if !ok {
+		hist = newHistory(stored, receipts)
+		h.histories[room] = hist
+	}
+	if h.members[room] == nil {
+		h.members[room] = make(map[*Client]bool)
+	}
+	h.members[room][c] = true
+	c.rooms[room] = true
+	h.deliver(c, wsmodel.SocketResponse{Condition: "history", Room: room, History: hist.list(), Receipts: hist.receiptList()})
+}
+
+/*
+leave : this removes a client from a room. A room left empty forgets its history in
+memory when the messages are kept in the store, it is read again on the next join
+*/
+func (h *Hub) leave(c *Client, room string) {
+	delete(c.rooms, room)
+	delete(h.members[room], c)
+	if len(h.members[room]) > 0 {
+		return
+	}
+	delete(h.members, room)
+	if h.store != nil && room != DefaultRoom {
+		delete(h.histories, room)
+	}
+}
+
+func (h *Hub) broadcastUsers() {
+	h.statuses = statusesOf(h.chatUsers())
+	h.broadcastAll(h.usersResponse())
+	h.publishPresence()
+}
+
+/*
+refreshPresence : this sends the user list again when a user turned online or away,
+the other instances are told when a user of this one did
+*/
+func (h *Hub) refreshPresence() {
+	if !samePresence(h.statuses, statusesOf(h.chatUsers())) {
+		h.broadcastUsers()
+		return
+	}
+	h.publishPresence()
+}
+
+func (h *Hub) usersResponse() wsmodel.SocketResponse {
+	users := h.chatUsers()
+	names := make([]string, 0, len(users))
+	for _, user := range users {
+		names = append(names, user.Name)
+	}
+	return wsmodel.SocketResponse{Condition: "username", ConnectedUser: names, Users: users}
+}
+
+/*
+broadcastRoom : this sends a response to the clients of a room but those of the user
+except. Direct messages also reach every connection of both users, so a message is
+seen without opening the room, unless the user is banned from it
+*/
+func (h *Hub) broadcastRoom(room, except string, resp wsmodel.SocketResponse) {
+	a, b, direct := Participants(room)
+	for c := range h.clients {
+		if except != "" && c.UserID == except {
+			continue
+		}
+		if h.members[room][c] {
+			h.deliver(c, resp)
+			continue
+		}
+		if direct && (c.UserID == a || c.UserID == b) {
+			if _, banned := h.sanctionOf(ModBan, c.UserID, room); !banned {
+				h.deliver(c, resp)
+			}
+		}
+	}
+}
+
+func (h *Hub) broadcastAll(resp wsmodel.SocketResponse) {
+	for c := range h.clients {
+		h.deliver(c, resp)
+	}
+}
+
+/*
+deliver : this queues a response for a client, dropping the client when its queue is full.
+A client dropped already, even earlier in the same payload, gets nothing
+*/
+func (h *Hub) deliver(c *Client, resp wsmodel.SocketResponse) {
+	if !h.clients[c] {
+		return
+	}
+	select {
+	case c.send <- resp:
+	default:
+		// the writer may be stuck on the connection, closing it unblocks the writer
+		h.drop(c)
+		_ = c.conn.Close()
+	}
+}
+
+// drop : this removes a client from the hub and its rooms, its writer then closes the connection
+func (h *Hub) drop(c *Client) {
+	if !h.clients[c] {
+		return
+	}
+	for room := range c.rooms {
+		h.leave(c, room)
+	}
+	delete(h.clients, c)
+	close(c.send)
+}
+
+// userNames : this returns the sorted names of the users connected, once per user
+func (h *Hub) userNames() []string {
+	names := []string{}
+	for _, user := range h.chatUsers() {
+		names = append(names, user.Name)
+	}
+	return names
+}
+
+/*
+chatUsers : this returns the users connected to any instance sorted by name, once per
+user with their presence, online when one of their connections is
+*/
+func (h *Hub) chatUsers() []wsmodel.ChatUser {
+	users := h.localUsers()
+	if len(h.remote) == 0 {
+		return users
+	}
+	index := make(map[string]int, len(users))
+	for i, user := range users {
+		index[user.ID] = i
+	}
+	for _, remote := range h.remote {
+		for _, user := range remote.users {
+			i, ok := index[user.ID]
+			switch {
+			case !ok:
+				index[user.ID] = len(users)
+				users = append(users, user)
+			case user.Status == StatusOnline:
+				users[i].Status = StatusOnline
+			}
+		}
+	}
+	sortUsers(users)
+	return users
+}
 
-	var payload wsmodel.SocketPayLoad
+// localUsers : this returns the users connected to this instance sorted by name, once per user with their presence
+func (h *Hub) localUsers() []wsmodel.ChatUser {
+	users := []wsmodel.ChatUser{}
+	statuses := presence(h.clients, h.now(), h.awayAfter)
+	for c := range h.clients {
+		status, ok := statuses[c.UserID]
+		if !ok {
+			continue
+		}
+		delete(statuses, c.UserID)
+		users = append(users, wsmodel.ChatUser{ID: c.UserID, Name: c.Name, Status: status})
+	}
+	sortUsers(users)
+	return users
+}
 
+func sortUsers(users []wsmodel.ChatUser) {
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].Name != users[j].Name {
+			return users[i].Name < users[j].Name
+		}
+		return users[i].ID < users[j].ID
+	})
+}
+
+/*
+write : this writes the queued responses of a client to its connection and pings it
+every pingPeriod. The connection is closed with a close frame once the queue is
+closed, or at once when a write or a ping fails, which ends the reads of Serve
+*/
+func (c *Client) write(pingPeriod time.Duration) {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
 	for {
-		err := socketConn.ReadJSON(&payload)
-		if err != nil {
-		} else {
-			payload.SocketConn = *socketConn
-			webSkChan <- payload
+		select {
+		case resp, ok := <-c.send:
+			if !ok {
+				code, reason := websocket.CloseNormalClosure, ""
+				if c.closeCode != 0 {
+					code, reason = c.closeCode, c.closeReason
+				}
+				_ = c.conn.CloseWith(code, reason)
+				return
+			}
+			if err := c.conn.WriteJSON(resp); err != nil {
+				_ = c.conn.Close()
+				return
+			}
+		case <-ticker.C:
+			if err := c.conn.Ping(); err != nil {
+				_ = c.conn.Close()
+				return
+			}
 		}
+	}
+}
+
+// loadHistory : this fills the history in memory of the general room with the last stored messages and receipts
+func (h *Hub) loadHistory() {
+	if h.store == nil {
+		return
+	}
+	messages, err := h.store.GetChatMessages(DefaultRoom, time.Time{}, "", HistorySize)
+	if err != nil {
+		log.Printf("cannot load the chat history : %v", err)
+		return
+	}
+	receipts, err := h.store.GetReadReceipts(DefaultRoom)
+	if err != nil {
+		log.Printf("cannot load the read receipts of the chat : %v", err)
+	}
+	h.histories[DefaultRoom] = newHistory(messages, receipts)
+}
+
+// save : this queues a message or a receipt for the store, without ever blocking the hub
+func (h *Hub) save(op persistOp) {
+	if h.store == nil {
+		return
+	}
+	select {
+	case h.persist <- op:
+	default:
+		log.Printf("chat store is too slow, %s is not kept", op)
+	}
+}
+
+// storeMessages : this writes the queued messages and receipts to the store in order until the queue is closed
+func (h *Hub) storeMessages(stored chan struct{}) {
+	defer close(stored)
+	for op := range h.persist {
+		if err := op.apply(h.store); err != nil {
+			log.Printf("cannot store %s : %v", op, err)
+		}
+	}
+}
+
+// apply : this writes the message, receipt or moderation of op to store
+func (op persistOp) apply(store Store) error {
+	switch {
+	case op.msg != nil:
+		return store.StoreChatMessage(*op.msg)
+	case op.receipt != nil:
+		return store.StoreReadReceipt(*op.receipt)
+	case op.deleted != nil:
+		return store.DeleteChatMessage(op.deleted.Room, op.deleted.ID)
+	case op.sanction != nil:
+		return store.StoreChatSanction(*op.sanction)
+	case op.lifted != "":
+		return store.DeleteChatSanction(op.lifted)
+	case op.audit != nil:
+		return store.StoreChatAudit(*op.audit)
+	}
+	return store.StoreChatWordFilter(op.words)
+}
 
+func (op persistOp) String() string {
+	switch {
+	case op.msg != nil:
+		return "chat message " + op.msg.ID
+	case op.receipt != nil:
+		return "read receipt " + op.receipt.ID
+	case op.deleted != nil:
+		return "deletion of chat message " + op.deleted.ID
+	case op.sanction != nil:
+		return "chat sanction " + op.sanction.ID
+	case op.lifted != "":
+		return "lift of chat sanction " + op.lifted
+	case op.audit != nil:
+		return "chat audit " + op.audit.ID
 	}
+	return "chat word filter"
 }
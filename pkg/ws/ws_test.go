@@ -0,0 +1,340 @@
+package ws
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/yusuf/track-space/pkg/wsmodel"
+)
+
+/*
+fakeConn : a connection fed through channels, writes block while blocked is set and
+pings fail while deaf is set
+*/
+type fakeConn struct {
+	in      chan wsmodel.SocketPayLoad
+	out     chan wsmodel.SocketResponse
+	closed  chan struct{}
+	once    sync.Once
+	blocked bool
+	failing bool
+	deaf    bool
+	pings   chan struct{}
+	// closeCode : the code of the close frame sent, read once closed
+	closeCode int
+}
+
+func newFakeConn() *fakeConn {
+	return &fakeConn{
+		in:     make(chan wsmodel.SocketPayLoad),
+		out:    make(chan wsmodel.SocketResponse, 2*sendQueue),
+		closed: make(chan struct{}),
+		pings:  make(chan struct{}, 1),
+	}
+}
+
+func (f *fakeConn) ReadJSON(v interface{}) error {
+	select {
+	case payload := <-f.in:
+		data, _ := json.Marshal(payload)
+		return json.Unmarshal(data, v)
+	case <-f.closed:
+		return errors.New("closed")
+	}
+}
+
+func (f *fakeConn) WriteJSON(v interface{}) error {
+	if f.failing {
+		return errors.New("broken pipe")
+	}
+	if f.blocked {
+		<-f.closed
+		return errors.New("closed")
+	}
+	data, _ := json.Marshal(v)
+	var resp wsmodel.SocketResponse
+	_ = json.Unmarshal(data, &resp)
+	f.out <- resp
+	return nil
+}
+
+func (f *fakeConn) Ping() error {
+	if f.deaf {
+		return errors.New("i/o timeout")
+	}
+	select {
+	case f.pings <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (f *fakeConn) CloseWith(code int, reason string) error {
+	f.once.Do(func() {
+		f.closeCode = code
+		close(f.closed)
+	})
+	return nil
+}
+
+func (f *fakeConn) Close() error {
+	f.once.Do(func() { close(f.closed) })
+	return nil
+}
+
+func (f *fakeConn) send(t *testing.T, payload wsmodel.SocketPayLoad) {
+	t.Helper()
+	select {
+	case f.in <- payload:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("payload %+v not read", payload)
+	}
+}
+
+func (f *fakeConn) expect(t *testing.T, condition string) wsmodel.SocketResponse {
+	t.Helper()
+	for {
+		select {
+		case resp := <-f.out:
+			if resp.Condition == condition {
+				return resp
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no %q response received", condition)
+		}
+	}
+}
+
+// expectUsers : this waits for the user list to be the given names
+func (f *fakeConn) expectUsers(t *testing.T, names ...string) {
+	t.Helper()
+	for !reflect.DeepEqual(f.expect(t, "username").ConnectedUser, names) {
+	}
+}
+
+func (f *fakeConn) expectClosed(t *testing.T) {
+	t.Helper()
+	select {
+	case <-f.closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("connection not closed")
+	}
+}
+
+// serve : this connects conn of the user name to the hub, the returned channel is closed once Serve returns
+func serve(h *Hub, conn *fakeConn, name string) chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		h.Serve(conn, Identity{UserID: "id-" + name, Name: name})
+		close(done)
+	}()
+	return done
+}
+
+func TestHub_Broadcast(t *testing.T) {
+	hub := NewHub(nil, nil)
+	go hub.Run()
+	defer hub.Stop()
+
+	alice, bob := newFakeConn(), newFakeConn()
+	serve(hub, alice, "alice")
+	alice.expect(t, "username")
+	serve(hub, bob, "bob")
+	if got, want := bob.expect(t, "username").ConnectedUser, []string{"alice", "bob"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("user list = %v, want %v", got, want)
+	}
+	if got, want := hub.Users(), []string{"alice", "bob"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Users() = %v, want %v", got, want)
+	}
+
+	alice.send(t, wsmodel.SocketPayLoad{Condition: "sendMessage", UserName: "alice", Message: "<b>hi</b>"})
+	want := "<em>alice</em> : &lt;b&gt;hi&lt;/b&gt;"
+	for _, conn := range []*fakeConn{alice, bob} {
+		if got := conn.expect(t, "message"); got.Message != want {
+			t.Errorf("message = %q, want %q", got.Message, want)
+		}
+	}
+
+	hub.Broadcast(wsmodel.SocketResponse{Condition: "notice", Message: "maintenance"})
+	alice.expect(t, "notice")
+	bob.expect(t, "notice")
+}
+
+func TestHub_DropsSlowClient(t *testing.T) {
+	hub := NewHub(nil, nil)
+	go hub.Run()
+	defer hub.Stop()
+
+	fast, slow := newFakeConn(), newFakeConn()
+	slow.blocked = true
+	serve(hub, fast, "fast")
+	fast.expect(t, "username")
+	slowDone := serve(hub, slow, "slow")
+	fast.expectUsers(t, "fast", "slow")
+
+	// the slow client stops reading its queue, the fast one must still get every message
+	for i := 0; i < sendQueue+10; i++ {
+		hub.Broadcast(wsmodel.SocketResponse{Condition: "message", Message: "tick"})
+		fast.expect(t, "message")
+	}
+	slow.expectClosed(t)
+	select {
+	case <-slowDone:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Serve of the slow client did not return")
+	}
+}
+
+func TestHub_DropsFailingClient(t *testing.T) {
+	hub := NewHub(nil, nil)
+	go hub.Run()
+	defer hub.Stop()
+
+	broken := newFakeConn()
+	broken.failing = true
+	done := serve(hub, broken, "broken")
+	broken.expectClosed(t)
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Serve of the failing client did not return")
+	}
+
+	other := newFakeConn()
+	serve(hub, other, "other")
+	other.expect(t, "username")
+	hub.Broadcast(wsmodel.SocketResponse{Condition: "message"})
+	other.expect(t, "message")
+}
+
+func TestHub_Leave(t *testing.T) {
+	hub := NewHub(nil, nil)
+	go hub.Run()
+	defer hub.Stop()
+
+	alice, bob := newFakeConn(), newFakeConn()
+	serve(hub, alice, "alice")
+	alice.expect(t, "username")
+	bobDone := serve(hub, bob, "bob")
+	bob.expect(t, "username")
+
+	bob.send(t, wsmodel.SocketPayLoad{Condition: "serveroffline"})
+	bob.expectClosed(t)
+	<-bobDone
+	alice.expectUsers(t, "alice")
+	if bob.closeCode != websocket.CloseNormalClosure {
+		t.Errorf("close code = %d, want %d", bob.closeCode, websocket.CloseNormalClosure)
+	}
+}
+
+func TestHub_Stop(t *testing.T) {
+	hub := NewHub(nil, nil)
+	go hub.Run()
+
+	conns := make([]*fakeConn, 5)
+	done := make([]chan struct{}, len(conns))
+	for i := range conns {
+		conns[i] = newFakeConn()
+		done[i] = serve(hub, conns[i], string(rune('a'+i)))
+		conns[i].expect(t, "username")
+	}
+	hub.Stop()
+	for i := range conns {
+		// Stop returns once the close frames are sent
+		select {
+		case <-conns[i].closed:
+		default:
+			t.Fatalf("connection %d still open after Stop", i)
+		}
+		if conns[i].closeCode != websocket.CloseGoingAway {
+			t.Errorf("close code = %d, want %d", conns[i].closeCode, websocket.CloseGoingAway)
+		}
+		<-done[i]
+	}
+
+	// a hub that stopped refuses new clients instead of blocking them
+	late := newFakeConn()
+	<-serve(hub, late, "late")
+	late.expectClosed(t)
+	if users := hub.Users(); users != nil {
+		t.Errorf("Users() after Stop = %v", users)
+	}
+}
+
+func TestHub_Concurrent(t *testing.T) {
+	hub := NewHub(nil, nil)
+	go hub.Run()
+	defer hub.Stop()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			conn := newFakeConn()
+			done := serve(hub, conn, string(rune('a'+i)))
+			conn.expect(t, "username")
+			conn.send(t, wsmodel.SocketPayLoad{Condition: "username"})
+			conn.send(t, wsmodel.SocketPayLoad{Condition: "sendMessage", UserName: "x", Message: "hello"})
+			hub.Users()
+			_ = conn.Close()
+			<-done
+		}(i)
+	}
+	wg.Wait()
+	if users := hub.Users(); len(users) != 0 {
+		t.Errorf("Users() = %v once everyone left", users)
+	}
+}
+
+func TestHub_Identity(t *testing.T) {
+	hub := NewHub(nil, nil)
+	go hub.Run()
+	defer hub.Stop()
+
+	alice, mallory := newFakeConn(), newFakeConn()
+	serve(hub, alice, "alice")
+	alice.expect(t, "username")
+	serve(hub, mallory, "mallory")
+	mallory.expect(t, "username")
+
+	// the user name of the payload is ignored, the message is signed by the connection
+	mallory.send(t, wsmodel.SocketPayLoad{Condition: "sendMessage", UserName: "alice", Message: "trust me"})
+	if got, want := alice.expect(t, "message").Message, "<em>mallory</em> : trust me"; got != want {
+		t.Errorf("message = %q, want %q", got, want)
+	}
+
+	// a user connected twice is listed once
+	tab := newFakeConn()
+	serve(hub, tab, "alice")
+	if got, want := tab.expect(t, "username").ConnectedUser, []string{"alice", "mallory"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("user list = %v, want %v", got, want)
+	}
+}
+
+func TestHub_Ping(t *testing.T) {
+	hub := NewHub(nil, nil)
+	hub.pingPeriod = 10 * time.Millisecond
+	go hub.Run()
+	defer hub.Stop()
+
+	alice, bob := newFakeConn(), newFakeConn()
+	bob.deaf = true
+	serve(hub, alice, "alice")
+	bobDone := serve(hub, bob, "bob")
+
+	select {
+	case <-alice.pings:
+	case <-time.After(2 * time.Second):
+		t.Fatal("alice never pinged")
+	}
+	// a client the pings cannot reach is closed and leaves the chat
+	bob.expectClosed(t)
+	<-bobDone
+	alice.expectUsers(t, "alice")
+}
@@ -1,8 +1,56 @@
 package wsconfig
 
-import "github.com/gorilla/websocket"
+import (
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// WriteWait : the time a write, ping or close frame has to reach the client
+	WriteWait = 10 * time.Second
+	// PongWait : the silence after which a client is considered gone, a pong answers every ping
+	PongWait = 60 * time.Second
+	// PingPeriod : how often the server pings a client, shorter than PongWait
+	PingPeriod = PongWait * 9 / 10
+	// MaxMessageSize : the largest message in bytes read from a client, larger ones close the connection
+	MaxMessageSize = 8192
+)
 
 // SocketConnection : WebSocket connection setup on the server side with Gorilla
 type SocketConnection struct {
 	*websocket.Conn
 }
+
+/*
+NewSocketConnection : this sets up a websocket connection with the read limit and a read
+deadline renewed by every pong, so a client gone silent ends the reads
+*/
+func NewSocketConnection(conn *websocket.Conn) *SocketConnection {
+	conn.SetReadLimit(MaxMessageSize)
+	_ = conn.SetReadDeadline(time.Now().Add(PongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(PongWait))
+	})
+	return &SocketConnection{Conn: conn}
+}
+
+// WriteJSON : this writes a message, giving up once WriteWait is over
+func (s *SocketConnection) WriteJSON(v interface{}) error {
+	if err := s.Conn.SetWriteDeadline(time.Now().Add(WriteWait)); err != nil {
+		return err
+	}
+	return s.Conn.WriteJSON(v)
+}
+
+// Ping : this sends a ping, the pong of the client renews the read deadline
+func (s *SocketConnection) Ping() error {
+	return s.Conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(WriteWait))
+}
+
+// CloseWith : this sends a close frame with the code and reason before closing the connection
+func (s *SocketConnection) CloseWith(code int, reason string) error {
+	// the client may be gone already, the connection is closed anyway
+	_ = s.Conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(WriteWait))
+	return s.Conn.Close()
+}
@@ -0,0 +1,74 @@
+package wsconfig
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// serve : this starts a websocket server handing every connection to handle
+func serve(t *testing.T, handle func(*SocketConnection)) *websocket.Conn {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		handle(NewSocketConnection(conn))
+	}))
+	t.Cleanup(srv.Close)
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func TestSocketConnection_ReadLimit(t *testing.T) {
+	read := make(chan error, 2)
+	client := serve(t, func(conn *SocketConnection) {
+		for {
+			var v map[string]string
+			err := conn.ReadJSON(&v)
+			read <- err
+			if err != nil {
+				return
+			}
+		}
+	})
+
+	if err := client.WriteJSON(map[string]string{"message": "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-read; err != nil {
+		t.Fatalf("small message: %v", err)
+	}
+	if err := client.WriteJSON(map[string]string{"message": strings.Repeat("a", MaxMessageSize)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-read; !errors.Is(err, websocket.ErrReadLimit) {
+		t.Errorf("large message error = %v, want %v", err, websocket.ErrReadLimit)
+	}
+}
+
+func TestSocketConnection_CloseWith(t *testing.T) {
+	client := serve(t, func(conn *SocketConnection) {
+		if err := conn.Ping(); err != nil {
+			t.Errorf("Ping() = %v", err)
+		}
+		_ = conn.CloseWith(websocket.CloseGoingAway, "server shutting down")
+	})
+
+	// the ping is answered by the default ping handler of the client while reading
+	_, _, err := client.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("read error = %v, want a going away close frame", err)
+	}
+}
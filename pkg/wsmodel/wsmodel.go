@@ -1,6 +1,6 @@
 package wsmodel
 
-import "github.com/yusuf/track-space/pkg/wsconfig"
+import "github.com/yusuf/track-space/pkg/model"
 
 /*Working with Web Socket*/
 
@@ -10,10 +10,30 @@ Client-side of the application during RTC(real time communication) using
 Web socket ( Reconnecting web socket ).
 */
 type SocketPayLoad struct {
-	Condition  string                    `json:"condition"`
-	Message    string                    `json:"message"`
-	UserName   string                    `json:"username"`
-	SocketConn wsconfig.SocketConnection `json:"-"`
+	Condition string `json:"condition"`
+	Message   string `json:"message"`
+	// UserName : ignored, messages are signed with the name of the logged-in user
+	UserName string `json:"username"`
+	// Room : the room joined, left or sent to, the general room when empty
+	Room string `json:"room"`
+	// To : the user id direct messages are opened with by a "join"
+	To string `json:"to"`
+	// Status : "online" or "away" as seen by the browser, sent with every "heartbeat"
+	Status string `json:"status"`
+	// MessageID : the last message read of a "read" payload, or the message deleted by an admin
+	MessageID string `json:"message_id"`
+	// Duration : how long an admin mutes, kicks or bans the user To, like "10m"
+	Duration string `json:"duration"`
+	// Sanction : the id of the mute, kick or ban an admin lifts
+	Sanction string `json:"sanction"`
+}
+
+// ChatUser : a user connected to the chat, with the id direct messages are sent to
+type ChatUser struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Status : "online", or "away" when idle
+	Status string `json:"status,omitempty"`
 }
 
 /*
@@ -25,4 +45,47 @@ type SocketResponse struct {
 	Message       string   `json:"message"`
 	MessageType   string   `json:"message_type"`
 	ConnectedUser []string `json:"connected_user"`
+	// Users : the users connected of a "username" response
+	Users []ChatUser `json:"users,omitempty"`
+	// Room : the room a "history", "left", "error", typing or "read" response is about
+	Room string `json:"room,omitempty"`
+	// User : the user typing of a "typingStarted" or "typingStopped" response
+	User *ChatUser `json:"user,omitempty"`
+	// MessageID : the message removed by an admin of a "deleted" response
+	MessageID string `json:"message_id,omitempty"`
+	// Sanction : the mute, kick or ban of a "muted", "kicked", "banned" or "lifted" response
+	Sanction *model.ChatSanction `json:"sanction,omitempty"`
+	// Receipt : the message read by a user of a "read" response
+	Receipt *model.ReadReceipt `json:"receipt,omitempty"`
+	// Chat : the message of a "message" response
+	Chat *model.ChatMessage `json:"chat,omitempty"`
+	// History : the last messages of the room of a "history" response, oldest first,
+	// sent once connected for the general room and on every join
+	History []model.ChatMessage `json:"history,omitempty"`
+	// Receipts : the last message read by the users of the room of a "history" response
+	Receipts []model.ReadReceipt `json:"receipts,omitempty"`
+	// Change : the project or todo of a "changed" response, sent to the live pages of its owner
+	Change *Change `json:"change,omitempty"`
+}
+
+// kinds and actions of a Change
+const (
+	ChangeProject = "project"
+	ChangeTodo    = "todo"
+	ChangeCreated = "created"
+	ChangeUpdated = "updated"
+	ChangeDeleted = "deleted"
+)
+
+/*
+Change : a project or todo of a user created, updated or deleted from any tab or
+device, so the dashboard and tables opened elsewhere refresh in place
+*/
+type Change struct {
+	// Kind : "project" or "todo"
+	Kind string `json:"kind"`
+	// Action : "created", "updated" or "deleted"
+	Action string `json:"action"`
+	// ID : the project or todo changed, empty when several of them did
+	ID string `json:"id,omitempty"`
 }
@@ -0,0 +1,314 @@
+// collab.js keeps a textarea in sync with the other editors of a project. Every edit
+// is sent to the server as an operation, the ot.js json form also used by the Go
+// side in pkg/collab: a positive number retains characters, a negative number
+// deletes characters and a string inserts it. Positions are UTF-16 code units,
+// the unit of textarea selections.
+(function (window) {
+  "use strict";
+
+  function retain(ops, n) {
+    if (n <= 0) return;
+    const last = ops.length - 1;
+    if (typeof ops[last] === "number" && ops[last] > 0) ops[last] += n;
+    else ops.push(n);
+  }
+
+  function remove(ops, n) {
+    if (n <= 0) return;
+    const last = ops.length - 1;
+    if (typeof ops[last] === "number" && ops[last] < 0) ops[last] -= n;
+    else ops.push(-n);
+  }
+
+  // insert keeps inserts before the delete next to them, like Operation.Insert
+  function insert(ops, text) {
+    if (text === "") return;
+    const last = ops.length - 1;
+    if (typeof ops[last] === "string") {
+      ops[last] += text;
+    } else if (typeof ops[last] === "number" && ops[last] < 0) {
+      if (typeof ops[last - 1] === "string") ops[last - 1] += text;
+      else ops.splice(last, 0, text);
+    } else {
+      ops.push(text);
+    }
+  }
+
+  function apply(op, text) {
+    let pos = 0;
+    let out = "";
+    for (const c of op) {
+      if (typeof c === "string") {
+        out += c;
+      } else if (c > 0) {
+        out += text.slice(pos, pos + c);
+        pos += c;
+      } else {
+        pos -= c;
+      }
+    }
+    return out;
+  }
+
+  function shrink(c, n) {
+    return c > 0 ? c - n : c + n;
+  }
+
+  // transform returns [a', b'] for the concurrent operations a and b, the text of a goes first
+  function transform(a, b) {
+    const a1 = [];
+    const b1 = [];
+    let i1 = 0;
+    let i2 = 0;
+    let op1 = a[i1++];
+    let op2 = b[i2++];
+    while (op1 !== undefined || op2 !== undefined) {
+      if (typeof op1 === "string") {
+        insert(a1, op1);
+        retain(b1, op1.length);
+        op1 = a[i1++];
+        continue;
+      }
+      if (typeof op2 === "string") {
+        retain(a1, op2.length);
+        insert(b1, op2);
+        op2 = b[i2++];
+        continue;
+      }
+      if (op1 === undefined || op2 === undefined) {
+        throw new Error("operations of different lengths");
+      }
+      const n = Math.min(Math.abs(op1), Math.abs(op2));
+      if (op1 > 0 && op2 > 0) {
+        retain(a1, n);
+        retain(b1, n);
+      } else if (op1 < 0 && op2 > 0) {
+        remove(a1, n);
+      } else if (op1 > 0 && op2 < 0) {
+        remove(b1, n);
+      }
+      op1 = shrink(op1, n) === 0 ? a[i1++] : shrink(op1, n);
+      op2 = shrink(op2, n) === 0 ? b[i2++] : shrink(op2, n);
+    }
+    return [a1, b1];
+  }
+
+  function transformIndex(op, index) {
+    let newIndex = index;
+    let oldIndex = 0;
+    for (const c of op) {
+      if (typeof c === "string") {
+        newIndex += c.length;
+      } else if (c > 0) {
+        oldIndex += c;
+      } else {
+        newIndex -= Math.min(index - oldIndex, -c);
+        oldIndex -= c;
+      }
+      if (oldIndex > index) break;
+    }
+    return newIndex;
+  }
+
+  function isHighSurrogate(code) {
+    return code >= 0xd800 && code <= 0xdbff;
+  }
+
+  function isLowSurrogate(code) {
+    return code >= 0xdc00 && code <= 0xdfff;
+  }
+
+  // diff turns the change of a textarea into an operation, never splitting a surrogate pair
+  function diff(before, after) {
+    let prefix = 0;
+    const max = Math.min(before.length, after.length);
+    while (prefix < max && before.charCodeAt(prefix) === after.charCodeAt(prefix)) prefix++;
+    if (prefix > 0 && isHighSurrogate(before.charCodeAt(prefix - 1))) prefix--;
+    let suffix = 0;
+    while (
+      suffix < max - prefix &&
+      before.charCodeAt(before.length - 1 - suffix) === after.charCodeAt(after.length - 1 - suffix)
+    ) {
+      suffix++;
+    }
+    if (suffix > 0 && isLowSurrogate(before.charCodeAt(before.length - suffix))) suffix--;
+
+    const op = [];
+    retain(op, prefix);
+    insert(op, after.slice(prefix, after.length - suffix));
+    remove(op, before.length - prefix - suffix);
+    retain(op, suffix);
+    return op;
+  }
+
+  function isNoop(op) {
+    return op.length === 0 || (op.length === 1 && typeof op[0] === "number" && op[0] > 0);
+  }
+
+  // CollabEditor connects a textarea to the editing room of url and lists the other editors in peerList
+  function CollabEditor(textarea, url, peerList) {
+    this.textarea = textarea;
+    this.url = url;
+    this.peerList = peerList;
+    this.revision = 0;
+    this.text = textarea.value;
+    // pending holds the operations not acknowledged yet, the first one is on its way
+    this.pending = [];
+    this.peers = {};
+    this.connect();
+
+    const editor = this;
+    textarea.addEventListener("input", function () {
+      editor.onInput();
+    });
+    ["select", "keyup", "mouseup"].forEach(function (type) {
+      textarea.addEventListener(type, function () {
+        editor.sendCursor();
+      });
+    });
+  }
+
+  CollabEditor.prototype.connect = function () {
+    const editor = this;
+    this.textarea.readOnly = true;
+    this.socket = new WebSocket(this.url);
+    this.socket.onmessage = function (event) {
+      editor.onMessage(JSON.parse(event.data));
+    };
+    this.socket.onclose = function () {
+      editor.textarea.readOnly = true;
+      editor.peers = {};
+      editor.renderPeers();
+      setTimeout(function () {
+        editor.connect();
+      }, 2000);
+    };
+  };
+
+  CollabEditor.prototype.send = function (msg) {
+    if (this.socket.readyState === WebSocket.OPEN) {
+      this.socket.send(JSON.stringify(msg));
+    }
+  };
+
+  CollabEditor.prototype.onInput = function () {
+    const op = diff(this.text, this.textarea.value);
+    this.text = this.textarea.value;
+    if (isNoop(op)) return;
+    this.pending.push(op);
+    if (this.pending.length === 1) {
+      this.send({ type: "op", rev: this.revision, op: op });
+    }
+  };
+
+  CollabEditor.prototype.sendCursor = function () {
+    // a cursor is only meaningful to the server once the local edits are applied there
+    if (this.pending.length > 0) return;
+    this.send({
+      type: "cursor",
+      rev: this.revision,
+      cursor: { anchor: this.textarea.selectionStart, head: this.textarea.selectionEnd },
+    });
+  };
+
+  CollabEditor.prototype.onMessage = function (msg) {
+    switch (msg.type) {
+      case "init":
+        this.revision = msg.rev;
+        this.pending = [];
+        this.text = msg.content || "";
+        this.textarea.value = this.text;
+        this.textarea.readOnly = false;
+        this.peers = {};
+        (msg.peers || []).forEach(function (peer) {
+          this.peers[peer.id] = peer;
+        }, this);
+        break;
+      case "ack":
+        this.revision = msg.rev;
+        this.pending.shift();
+        if (this.pending.length > 0) {
+          this.send({ type: "op", rev: this.revision, op: this.pending[0] });
+        } else {
+          this.sendCursor();
+        }
+        break;
+      case "op":
+        this.revision = msg.rev;
+        this.applyRemote(msg.op);
+        break;
+      case "cursor":
+        if (this.peers[msg.client]) {
+          this.peers[msg.client].cursor = this.localCursor(msg.cursor);
+        }
+        break;
+      case "join":
+        this.peers[msg.peer.id] = msg.peer;
+        break;
+      case "leave":
+        delete this.peers[msg.client];
+        break;
+      case "error":
+        // the server lost track of this editor, start again from its copy
+        this.socket.close();
+        return;
+    }
+    this.renderPeers();
+  };
+
+  CollabEditor.prototype.applyRemote = function (op) {
+    for (let i = 0; i < this.pending.length; i++) {
+      const pair = transform(this.pending[i], op);
+      this.pending[i] = pair[0];
+      op = pair[1];
+    }
+    const start = transformIndex(op, this.textarea.selectionStart);
+    const end = transformIndex(op, this.textarea.selectionEnd);
+    this.text = apply(op, this.text);
+    this.textarea.value = this.text;
+    if (document.activeElement === this.textarea) {
+      this.textarea.setSelectionRange(start, end);
+    }
+    Object.keys(this.peers).forEach(function (id) {
+      const cursor = this.peers[id].cursor;
+      if (cursor) {
+        this.peers[id].cursor = { anchor: transformIndex(op, cursor.anchor), head: transformIndex(op, cursor.head) };
+      }
+    }, this);
+  };
+
+  // localCursor moves a cursor of the server document over the local edits it has not seen yet
+  CollabEditor.prototype.localCursor = function (cursor) {
+    let anchor = cursor.anchor;
+    let head = cursor.head;
+    this.pending.forEach(function (op) {
+      anchor = transformIndex(op, anchor);
+      head = transformIndex(op, head);
+    });
+    return { anchor: anchor, head: head };
+  };
+
+  CollabEditor.prototype.renderPeers = function () {
+    if (!this.peerList) return;
+    this.peerList.textContent = "";
+    Object.keys(this.peers).forEach(function (id) {
+      const peer = this.peers[id];
+      const item = document.createElement("li");
+      item.className = "list-group-item collab-peer";
+      const dot = document.createElement("span");
+      dot.className = "collab-dot";
+      dot.style.backgroundColor = peer.color;
+      item.appendChild(dot);
+      let label = peer.name;
+      if (peer.cursor) {
+        const before = this.text.slice(0, peer.cursor.head).split("\n");
+        label += " - line " + before.length + ", column " + (before[before.length - 1].length + 1);
+        if (peer.cursor.anchor !== peer.cursor.head) label += " (selecting)";
+      }
+      item.appendChild(document.createTextNode(label));
+      this.peerList.appendChild(item);
+    }, this);
+  };
+
+  window.CollabEditor = CollabEditor;
+})(window);
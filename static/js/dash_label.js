@@ -1,30 +1,40 @@
 let datum;
 
-fetch('/static/json/data.json')
-    .then(res => res.json())
-    .then(jsondata => datum = jsondata)
-    .then(() => {
+// showLabels : shows the totals of the last day, again every time the dashboard stats change
+function showLabels(jsondata) {
+    datum = jsondata;
+    const lastDataItem = datum[datum.length - 1];
+
+    //total articles
+    setLabel("article", lastDataItem.article);
 
-            const lastDataItem = datum[datum.length - 1];
+    //total text
+    setLabel("text", lastDataItem.text);
 
-            //total articles
-            const article = lastDataItem.article;
-            document.getElementById("article").textContent += ` ${article}`;
+    //total code
+    setLabel("code", lastDataItem.code);
 
-            //total text
-            const text = lastDataItem.text
-            document.getElementById("text").textContent += ` ${text}`;
+    //todo
+    setLabel("todo", lastDataItem.todo);
 
-            //total code
-            const code = lastDataItem.code
-            document.getElementById("code").textContent += ` ${code}`;
-            
-            //todo
-            const todo = lastDataItem.todo
-            document.getElementById("todo").textContent += ` ${todo}`;
+    //total
+    setLabel("total", lastDataItem.total);
+}
 
-            //total
-            const total = lastDataItem.total
-            document.getElementById("total").textContent += total;
+// setLabel : writes the count after the "Total:" of a label, replacing the previous one
+function setLabel(id, value) {
+    const label = document.getElementById(id);
+    let count = label.querySelector(".count");
+    if (!count) {
+        count = document.createElement("span");
+        count.className = "count";
+        label.append(count);
+    }
+    count.textContent = label.children.length > 1 ? ` ${value}` : `${value}`;
+}
+
+fetch('/static/json/data.json')
+    .then(res => res.json())
+    .then(showLabels)//to fetch data from json file
 
-    })
\ No newline at end of file
+document.addEventListener("dashboard-stats", (event) => showLabels(event.detail));
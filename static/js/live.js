@@ -0,0 +1,67 @@
+// keeps the dashboard, project table and todo table up to date with the changes of the
+// projects and todos of the user made from any tab or device. The page includes it as
+// <script src="/static/js/live.js" data-token="{{.LiveToken}}" data-live="project todo"></script>
+(function () {
+    const script = document.currentScript;
+    const kinds = (script.dataset.live || "").split(" ");
+    const scheme = window.location.protocol === "https:" ? "wss://" : "ws://";
+    // the token of the page is checked by the server before the connection is upgraded
+    const liveURL = scheme + window.location.host + "/auth/user/live?token=" + encodeURIComponent(script.dataset.token);
+    let connected = false;
+    let refreshTimer = null;
+
+    // changes made together, like an import, are refreshed once
+    function scheduleRefresh() {
+        clearTimeout(refreshTimer);
+        refreshTimer = setTimeout(refresh, 300);
+    }
+
+    function refresh() {
+        if (document.querySelector("[data-live-stats]")) {
+            fetch("/auth/user/dashboard/stats", { credentials: "same-origin" })
+                .then(res => res.json())
+                .then(stats => document.dispatchEvent(new CustomEvent("dashboard-stats", { detail: stats })))
+                .catch(error => console.log("cannot refresh the dashboard : ", error));
+        }
+        if (document.querySelector("[data-live-table], [data-live-part]")) {
+            // the page is asked again with its filters, its tables take the rows of the new one
+            fetch(window.location.href, { credentials: "same-origin" })
+                .then(res => res.text())
+                .then(html => replaceParts(new DOMParser().parseFromString(html, "text/html")))
+                .catch(error => console.log("cannot refresh the page : ", error));
+        }
+    }
+
+    function replaceParts(fresh) {
+        document.querySelectorAll("[data-live-table]").forEach(function (table) {
+            let rows = fresh.querySelectorAll("#" + table.id + " tbody tr");
+            // the search, order and page of the table are kept
+            $(table).DataTable().clear().rows.add($(rows)).draw(false);
+        });
+        document.querySelectorAll("[data-live-part]").forEach(function (part) {
+            let freshPart = fresh.getElementById(part.id);
+            if (freshPart) {
+                part.innerHTML = freshPart.innerHTML;
+            }
+        });
+    }
+
+    document.addEventListener("DOMContentLoaded", function () {
+        let socket = new ReconnectingWebSocket(liveURL, null, {
+            reconnectInterval: 5000,
+        });
+        socket.onopen = () => {
+            // the changes made while disconnected were missed
+            if (connected) {
+                scheduleRefresh();
+            }
+            connected = true;
+        };
+        socket.onmessage = (msg) => {
+            let respData = JSON.parse(msg.data);
+            if (respData.condition === "changed" && respData.change && kinds.includes(respData.change.kind)) {
+                scheduleRefresh();
+            }
+        };
+    });
+})();